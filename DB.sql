@@ -61,8 +61,200 @@ CREATE TABLE tbl_mining_cost_current (
 );
     
     
-INSERT INTO tbl_mining_cost_current (location_id, cost_code, currency_code, price, last_updated) VALUES ('QLD1', 'CAPEX', 'AUD',  1, now());
-INSERT INTO tbl_mining_cost_current (location_id, cost_code, currency_code, price, last_updated) VALUES ('QLD1', 'EMPLOYEE', 'AUD',  1, now());
-INSERT INTO tbl_mining_cost_current (location_id, cost_code, currency_code, price, last_updated) VALUES ('QLD1', 'OFFICE', 'AUD',  1, now());
+CREATE TABLE tbl_fx_rate (
+    id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+	base_currency VARCHAR(10) NOT NULL,
+	quote_currency VARCHAR(10) NOT NULL,
+	rate DECIMAL(18, 8) NOT NULL,
+    timestamp DATETIME NOT NULL,
+	UNIQUE KEY (base_currency, quote_currency, timestamp)
+);
+
+CREATE TABLE tbl_mining_cost_item (
+    id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    location_id VARCHAR(10) NOT NULL,
+	cost_code VARCHAR(10) NOT NULL,
+	cost_type VARCHAR(10) NOT NULL,
+	currency_code VARCHAR(10) NOT NULL,
+	amount DECIMAL(18, 5) NOT NULL,
+	purchase_date DATE,
+	lifetime_months INT,
+	salvage_value DECIMAL(18, 5),
+	effective_from DATE NOT NULL,
+	effective_to DATE,
+    last_updated DATETIME NOT NULL,
+	INDEX (location_id)
+);
+
+-- cost_type: CAPEX (amount = purchase cost), FIXED (amount per month), VARIABLE (amount per MWh), HOSTING (amount per TH/s per month)
+INSERT INTO tbl_mining_cost_item (location_id, cost_code, cost_type, currency_code, amount, purchase_date, lifetime_months, salvage_value, effective_from, effective_to, last_updated) VALUES ('QLD1', 'CAPEX', 'CAPEX', 'AUD', 1200000, '2023-01-15', 36, 60000, '2023-01-15', NULL, now());
+INSERT INTO tbl_mining_cost_item (location_id, cost_code, cost_type, currency_code, amount, purchase_date, lifetime_months, salvage_value, effective_from, effective_to, last_updated) VALUES ('QLD1', 'EMPLOYEE', 'FIXED', 'AUD', 25000, NULL, NULL, NULL, '2023-01-01', NULL, now());
+INSERT INTO tbl_mining_cost_item (location_id, cost_code, cost_type, currency_code, amount, purchase_date, lifetime_months, salvage_value, effective_from, effective_to, last_updated) VALUES ('QLD1', 'OFFICE', 'FIXED', 'AUD', 4000, NULL, NULL, NULL, '2023-01-01', NULL, now());
+INSERT INTO tbl_mining_cost_item (location_id, cost_code, cost_type, currency_code, amount, purchase_date, lifetime_months, salvage_value, effective_from, effective_to, last_updated) VALUES ('QLD1', 'NETWORK', 'VARIABLE', 'AUD', 12.5, NULL, NULL, NULL, '2023-01-01', NULL, now());
+INSERT INTO tbl_mining_cost_item (location_id, cost_code, cost_type, currency_code, amount, purchase_date, lifetime_months, salvage_value, effective_from, effective_to, last_updated) VALUES ('VIC1', 'HOSTING', 'HOSTING', 'AUD', 1.8, NULL, NULL, NULL, '2023-07-01', NULL, now());
+
+CREATE TABLE tbl_mining_decision (
+    id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    location_id VARCHAR(10) NOT NULL,
+    action VARCHAR(10) NOT NULL,
+    run_fraction DECIMAL(5, 4) NOT NULL,
+    margin DECIMAL(18, 6) NOT NULL,
+    reason VARCHAR(40) NOT NULL,
+    changed TINYINT(1) NOT NULL,
+    timestamp DATETIME NOT NULL,
+    INDEX (location_id, timestamp)
+);
+
+CREATE TABLE tbl_mining_decision_group (
+    decision_id INT NOT NULL,
+    model VARCHAR(40) NOT NULL,
+    units INT NOT NULL,
+    run_units INT NOT NULL,
+    break_even_price DECIMAL(18, 6) NOT NULL,
+    PRIMARY KEY (decision_id, model)
+);
+
+CREATE TABLE tbl_miner_telemetry_device (
+    device_id VARCHAR(40) NOT NULL,
+    bucket_time DATETIME NOT NULL,
+    location_id VARCHAR(10) NOT NULL,
+    model VARCHAR(40) NOT NULL,
+    samples INT NOT NULL,
+    online_samples INT NOT NULL,
+    hashrate_th DECIMAL(18, 6) NOT NULL,
+    power_w DECIMAL(18, 3) NOT NULL,
+    chip_temp_max DECIMAL(6, 2) NOT NULL,
+    accepted BIGINT NOT NULL,
+    rejected BIGINT NOT NULL,
+    hardware_errors BIGINT NOT NULL,
+    PRIMARY KEY (device_id, bucket_time),
+    INDEX (location_id, bucket_time)
+);
+
+CREATE TABLE tbl_miner_telemetry_site (
+    location_id VARCHAR(10) NOT NULL,
+    bucket_time DATETIME NOT NULL,
+    samples INT NOT NULL,
+    devices INT NOT NULL,
+    online DECIMAL(10, 3) NOT NULL,
+    hashing DECIMAL(10, 3) NOT NULL,
+    hashrate_th DECIMAL(18, 6) NOT NULL,
+    power_w DECIMAL(18, 3) NOT NULL,
+    PRIMARY KEY (location_id, bucket_time)
+);
+
+CREATE TABLE tbl_inventory_device (
+    device_id VARCHAR(40) NOT NULL PRIMARY KEY,
+    model VARCHAR(40) NOT NULL,
+    serial VARCHAR(60),
+    ip VARCHAR(60),
+    location_id VARCHAR(10) NOT NULL,
+    rack VARCHAR(20),
+    status VARCHAR(12) NOT NULL,
+    hashrate_th DECIMAL(10, 3),
+    power_w DECIMAL(10, 1),
+    purchase_cost DECIMAL(18, 2),
+    purchase_currency VARCHAR(3),
+    purchase_date DATE,
+    firmware VARCHAR(60),
+    last_updated DATETIME NOT NULL,
+    INDEX (location_id)
+);
+
+CREATE TABLE tbl_mining_decision_audit (
+    id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    location_id VARCHAR(10) NOT NULL,
+    timestamp DATETIME NOT NULL,
+    action VARCHAR(10) NOT NULL,
+    run_fraction DECIMAL(5, 4) NOT NULL,
+    margin DECIMAL(18, 6) NOT NULL,
+    reason VARCHAR(40) NOT NULL,
+    changed TINYINT(1) NOT NULL,
+    currency_code VARCHAR(3) NOT NULL,
+    crypto_price DECIMAL(18, 6) NOT NULL,
+    energy_price DECIMAL(18, 6) NOT NULL,
+    difficulty DECIMAL(30, 2) NOT NULL,
+    reward DECIMAL(18, 8) NOT NULL,
+    hashprice DECIMAL(18, 8) NOT NULL,
+    mining_cost DECIMAL(18, 6) NOT NULL,
+    mining_incentive DECIMAL(18, 6) NOT NULL,
+    config_version VARCHAR(16) NOT NULL,
+    policy TEXT NOT NULL,
+    inputs TEXT NOT NULL,
+    sources TEXT NOT NULL,
+    groups_json TEXT NOT NULL,
+    INDEX (location_id, timestamp),
+    INDEX (timestamp)
+);
+
+CREATE TABLE tbl_service_state (
+    service VARCHAR(40) NOT NULL,
+    state_key VARCHAR(40) NOT NULL,
+    state TEXT NOT NULL,
+    last_updated DATETIME NOT NULL,
+    PRIMARY KEY (service, state_key)
+);
+
+CREATE TABLE tbl_price_forecast (
+    series VARCHAR(20) NOT NULL,
+    series_key VARCHAR(10) NOT NULL,
+    model VARCHAR(20) NOT NULL,
+    issued DATETIME NOT NULL,
+    target_time DATETIME NOT NULL,
+    currency_code VARCHAR(10) NOT NULL,
+    value DECIMAL(18, 5) NOT NULL,
+    lower_bound DECIMAL(18, 5) NOT NULL,
+    upper_bound DECIMAL(18, 5) NOT NULL,
+    level DECIMAL(5, 4) NOT NULL,
+    PRIMARY KEY (series, series_key, model, issued, target_time),
+    INDEX (series, series_key, target_time)
+);
+
+CREATE TABLE tbl_forecast_score (
+    series VARCHAR(20) NOT NULL,
+    series_key VARCHAR(10) NOT NULL,
+    model VARCHAR(20) NOT NULL,
+    horizon_minutes INT NOT NULL,
+    samples INT NOT NULL,
+    mae DECIMAL(18, 5) NOT NULL,
+    mape DECIMAL(12, 6) NOT NULL,
+    rmse DECIMAL(18, 5) NOT NULL,
+    pinball DECIMAL(18, 5) NOT NULL,
+    last_updated DATETIME NOT NULL,
+    PRIMARY KEY (series, series_key, model, horizon_minutes)
+);
+
+CREATE TABLE tbl_run_schedule (
+    location_id VARCHAR(10) NOT NULL,
+    issued DATETIME NOT NULL,
+    start_time DATETIME NOT NULL,
+    currency_code VARCHAR(10) NOT NULL,
+    energy_price DECIMAL(18, 5) NOT NULL,
+    run_fraction DECIMAL(5, 4) NOT NULL,
+    power_mw DECIMAL(12, 6) NOT NULL,
+    hashrate_th DECIMAL(18, 3) NOT NULL,
+    revenue DECIMAL(18, 5) NOT NULL,
+    energy_cost DECIMAL(18, 5) NOT NULL,
+    profit DECIMAL(18, 5) NOT NULL,
+    PRIMARY KEY (location_id, issued, start_time),
+    INDEX (location_id, start_time)
+);
+
+CREATE TABLE tbl_correlation (
+    location_id VARCHAR(10) NOT NULL,
+    symbol VARCHAR(10) NOT NULL,
+    window_hours INT NOT NULL,
+    window_end DATETIME NOT NULL,
+    step_seconds INT NOT NULL,
+    transform VARCHAR(10) NOT NULL,
+    samples INT NOT NULL,
+    pearson DECIMAL(8, 6) NOT NULL,
+    spearman DECIMAL(8, 6) NOT NULL,
+    kendall DECIMAL(8, 6) NOT NULL,
+    lead_lag INT NOT NULL,
+    lead_correlation DECIMAL(8, 6) NOT NULL,
+    cross_correlation TEXT NOT NULL,
+    PRIMARY KEY (location_id, symbol, window_hours, window_end)
+);
 
 SET GLOBAL time_zone = '+10:00';
\ No newline at end of file
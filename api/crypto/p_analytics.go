@@ -0,0 +1,212 @@
+package main
+
+/*
+CREATE TABLE tbl_correlation (
+    location_id VARCHAR(10) NOT NULL,
+    symbol VARCHAR(10) NOT NULL,
+    window_hours INT NOT NULL,
+    window_end DATETIME NOT NULL,
+    step_seconds INT NOT NULL,
+    transform VARCHAR(10) NOT NULL,
+    samples INT NOT NULL,
+    pearson DECIMAL(8, 6) NOT NULL,
+    spearman DECIMAL(8, 6) NOT NULL,
+    kendall DECIMAL(8, 6) NOT NULL,
+    lead_lag INT NOT NULL,
+    lead_correlation DECIMAL(8, 6) NOT NULL,
+    cross_correlation TEXT NOT NULL,
+    PRIMARY KEY (location_id, symbol, window_hours, window_end)
+);
+*/
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"time"
+
+	analytics "profitmax/util/analytics"
+	fleet "profitmax/util/fleet"
+	forecast "profitmax/util/forecast"
+	logger "profitmax/util/logger"
+
+	"github.com/Shopify/sarama"
+	_ "github.com/go-sql-driver/mysql"
+)
+
+type DBConfig struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	User     string `json:"user"`
+	Password string `json:"password"`
+	Database string `json:"database"`
+}
+
+// Config is the service config with the sections only this service reads.
+type Config struct {
+	fleet.Config
+	Analytics analytics.Config `json:"analytics"`
+}
+
+var logs *log.Logger
+var config Config
+var db *sql.DB
+var producer sarama.SyncProducer
+
+func main() {
+	args := os.Args
+
+	if len(args) < 2 {
+		fmt.Println("Usage: p_analytics [Config File]", len(args))
+		fmt.Println("Example: p_analytics p_analytics.json")
+		return
+	}
+
+	// Read the JSON file
+	filePath := args[1]
+	fileData, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		log.Println("Error reading file:", err)
+		return
+	}
+
+	// Parse the JSON data into a struct
+	config = Config{}
+	err = json.Unmarshal(fileData, &config)
+	if err != nil {
+		log.Println("Error parsing JSON:", err)
+		return
+	}
+	// Create logs directory path
+	logsDir, err := logger.CreateLogsDirectory(config.LogPath)
+	if err != nil {
+		log.Fatal("Failed to create logs directory:", err)
+	}
+
+	// Create log file path based on the current date
+	logFilePath, err := logger.CreateLogFile(logsDir, config.LogFile)
+	if err != nil {
+		log.Fatal("Failed to create log file path:", err)
+	}
+
+	// Open the log file
+	logFile, err := os.OpenFile(logFilePath.Name(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Fatal("Failed to open log file:", err)
+	}
+	defer logFile.Close()
+
+	logs = log.New(logFile, "", log.LstdFlags)
+
+	if config.Analytics.Transform == "" {
+		config.Analytics.Transform = analytics.Change
+	}
+	err = config.Analytics.Validate()
+	if err != nil {
+		logs.Fatal(err)
+	}
+
+	// Create a Kafka producer
+	producer, err = sarama.NewSyncProducer([]string{config.KafkaBroker}, nil)
+	if err != nil {
+		logs.Fatalln("Error creating Kafka producer:", config.KafkaBroker, err)
+		return
+	}
+	defer producer.Close()
+
+	// Open a connection to the MySQL database
+	// Read the JSON file
+	dbFilePath := "dbconfig.json"
+	dbFileData, err := ioutil.ReadFile(dbFilePath)
+	if err != nil {
+		logs.Println("Error reading file:", err)
+		return
+	}
+	// Parse the JSON data into a struct
+	var dbConfig DBConfig
+	err = json.Unmarshal(dbFileData, &dbConfig)
+	if err != nil {
+		logs.Println("Error parsing JSON:", err)
+		return
+	}
+
+	// Create the MySQL connection string
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s", dbConfig.User, dbConfig.Password, dbConfig.Host, dbConfig.Port, dbConfig.Database)
+
+	db, err = sql.Open("mysql", dsn)
+	if err != nil {
+		logs.Fatal("Error connecting to the database:", err)
+	}
+	defer db.Close()
+
+	// Create a ticker that ticks every x seconds
+	timeInterval := config.TimeInterval
+	ticker := time.NewTicker(time.Duration(timeInterval) * time.Second)
+
+	// Run the loop indefinitely
+	for range ticker.C {
+		now := time.Now()
+		crypto, err := forecast.LoadTicks(db, forecast.CryptoPrice, config.Symbol, since(now))
+		if err != nil {
+			logs.Println("Error loading crypto prices:", config.Symbol, err)
+			continue
+		}
+		for _, site := range config.SiteList() {
+			analyseSite(site.LocationID, crypto, now)
+		}
+	}
+}
+
+// since is how far back ticks are loaded: the longest window, and a day
+// before it for the first samples to be taken as of.
+func since(now time.Time) time.Time {
+	return now.Truncate(config.Analytics.Step()).Add(-config.Analytics.Longest() - 24*time.Hour)
+}
+
+// analyseSite correlates a location's energy prices with crypto prices over
+// every window, stores each result and publishes it.
+func analyseSite(locationID string, crypto []forecast.Tick, now time.Time) {
+	energy, err := forecast.LoadTicks(db, forecast.EnergyPrice, locationID, since(now))
+	if err != nil {
+		logs.Println("Error loading energy prices:", locationID, err)
+		return
+	}
+	for _, hours := range config.Analytics.WindowHours {
+		result, err := analytics.Analyse(locationID, config.Symbol, energy, crypto, config.Analytics, hours, now)
+		if err != nil {
+			logs.Println("Error analysing:", err)
+			continue
+		}
+		err = analytics.Save(db, result)
+		if err != nil {
+			logs.Println("Error saving correlation:", locationID, hours, err)
+		}
+		publishResult(result)
+	}
+}
+
+func publishResult(result analytics.Result) {
+	// Convert Result struct to JSON
+	OutputJSON, err := json.Marshal(result)
+	if err != nil {
+		logs.Println("Error marshaling correlation data:", err)
+		return
+	}
+
+	// Print the response
+	logs.Println("[OUT]: " + string(OutputJSON))
+
+	// Send the response to Kafka topic, keyed by location
+	message := &sarama.ProducerMessage{
+		Topic: config.Ptopic,
+		Key:   sarama.StringEncoder(result.LocationID),
+		Value: sarama.StringEncoder(OutputJSON),
+	}
+	_, _, err = producer.SendMessage(message)
+	if err != nil {
+		logs.Println("Error sending message to Kafka:", err)
+	}
+}
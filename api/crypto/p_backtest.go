@@ -0,0 +1,236 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	backtest "profitmax/util/backtest"
+	costmodel "profitmax/util/costmodel"
+	decision "profitmax/util/decision"
+	fleet "profitmax/util/fleet"
+	fx "profitmax/util/fx"
+	logger "profitmax/util/logger"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+type DBConfig struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	User     string `json:"user"`
+	Password string `json:"password"`
+	Database string `json:"database"`
+}
+
+// Config is the service config with the sections only this service reads.
+type Config struct {
+	fleet.Config
+	Policy decision.Policy `json:"policy"`
+}
+
+var logs *log.Logger
+var config Config
+var db *sql.DB
+
+func usage() {
+	fmt.Println("Usage: p_backtest [Config File] -from YYYY-MM-DD -to YYYY-MM-DD [-interval MINUTES -threshold F -band F -min-on MINUTES -min-off MINUTES -ramp F -out FILE.csv]")
+	fmt.Println("Example: p_backtest p_backtest.json -from 2023-01-01 -to 2024-01-01 -threshold 0.1 -band 0.02 -out backtest.csv")
+}
+
+func main() {
+	args := os.Args
+
+	if len(args) < 2 {
+		usage()
+		return
+	}
+
+	// Read the JSON file
+	filePath := args[1]
+	fileData, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		log.Println("Error reading file:", err)
+		return
+	}
+
+	// Parse the JSON data into a struct
+	config = Config{}
+	err = json.Unmarshal(fileData, &config)
+	if err != nil {
+		log.Println("Error parsing JSON:", err)
+		return
+	}
+	// Create logs directory path
+	logsDir, err := logger.CreateLogsDirectory(config.LogPath)
+	if err != nil {
+		log.Fatal("Failed to create logs directory:", err)
+	}
+
+	// Create log file path based on the current date
+	logFilePath, err := logger.CreateLogFile(logsDir, config.LogFile)
+	if err != nil {
+		log.Fatal("Failed to create log file path:", err)
+	}
+
+	// Open the log file
+	logFile, err := os.OpenFile(logFilePath.Name(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Fatal("Failed to open log file:", err)
+	}
+	defer logFile.Close()
+
+	logs = log.New(logFile, "", log.LstdFlags)
+
+	// Open a connection to the MySQL database
+	// Read the JSON file
+	dbFilePath := "dbconfig.json"
+	dbFileData, err := ioutil.ReadFile(dbFilePath)
+	if err != nil {
+		logs.Println("Error reading file:", err)
+		return
+	}
+	// Parse the JSON data into a struct
+	var dbConfig DBConfig
+	err = json.Unmarshal(dbFileData, &dbConfig)
+	if err != nil {
+		logs.Println("Error parsing JSON:", err)
+		return
+	}
+
+	// Create the MySQL connection string
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s", dbConfig.User, dbConfig.Password, dbConfig.Host, dbConfig.Port, dbConfig.Database)
+
+	db, err = sql.Open("mysql", dsn)
+	if err != nil {
+		logs.Fatal("Error connecting to the database:", err)
+	}
+	defer db.Close()
+
+	err = run(args[2:])
+	if err != nil {
+		logs.Println("Error:", err)
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+}
+
+// run backtests the configured policy, with any parameter overridden by a
+// flag, over the history between -from and -to.
+func run(args []string) error {
+	var from, to, out string
+	var intervalMinutes int
+	policy := config.Policy
+
+	flags := flag.NewFlagSet("backtest", flag.ContinueOnError)
+	flags.StringVar(&from, "from", "", "first day to replay")
+	flags.StringVar(&to, "to", time.Now().Format("2006-01-02"), "day to stop replaying at (exclusive)")
+	flags.IntVar(&intervalMinutes, "interval", 5, "minutes between decisions")
+	flags.Float64Var(&policy.MarginThreshold, "threshold", policy.MarginThreshold, "margin threshold")
+	flags.Float64Var(&policy.HysteresisBand, "band", policy.HysteresisBand, "hysteresis band")
+	flags.IntVar(&policy.MinOnMinutes, "min-on", policy.MinOnMinutes, "minimum on-time in minutes")
+	flags.IntVar(&policy.MinOffMinutes, "min-off", policy.MinOffMinutes, "minimum off-time in minutes")
+	flags.Float64Var(&policy.RampPerMinute, "ramp", policy.RampPerMinute, "largest change in run fraction per minute")
+	flags.StringVar(&out, "out", "", "CSV file to write every interval's decision to")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if from == "" {
+		usage()
+		return fmt.Errorf("from is required")
+	}
+	start, err := time.ParseInLocation("2006-01-02", from, time.Local)
+	if err != nil {
+		return err
+	}
+	end, err := time.ParseInLocation("2006-01-02", to, time.Local)
+	if err != nil {
+		return err
+	}
+
+	sites := config.SiteList()
+	var locations []string
+	items := make(map[string][]costmodel.Item)
+	for _, site := range sites {
+		locations = append(locations, site.LocationID)
+		items[site.LocationID], err = costmodel.LoadItems(db, site.LocationID)
+		if err != nil {
+			fmt.Println("Warning:", err)
+		}
+	}
+
+	// Rates are the latest known, not the historical ones
+	converter := fx.NewConverter()
+	err = fx.LoadRates(db, converter)
+	if err != nil {
+		return err
+	}
+
+	events, err := backtest.LoadEvents(db, config.Symbol, locations, start, end)
+	if err != nil {
+		return err
+	}
+	logs.Println("Replaying", len(events), "events from", from, "to", to)
+
+	result, err := backtest.Run(sites, events, items, converter, policy, time.Duration(intervalMinutes)*time.Minute, start, end)
+	if err != nil {
+		return err
+	}
+
+	if out != "" {
+		err = writeSteps(out, result.Steps)
+		if err != nil {
+			return err
+		}
+	}
+	for _, summary := range result.Summaries {
+		summaryJSON, err := json.Marshal(summary)
+		if err != nil {
+			return err
+		}
+		logs.Println("[OUT]: " + string(summaryJSON))
+		fmt.Println(string(summaryJSON))
+	}
+	return nil
+}
+
+// writeSteps writes every interval's decision and P&L to a CSV file.
+func writeSteps(path string, steps []backtest.Step) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"start", "location_id", "action", "run_fraction", "reason", "margin", "energy_price", "crypto_price", "energy_mwh", "coins", "revenue", "energy_cost", "other_cost", "profit", "always_on_profit"})
+	for _, step := range steps {
+		writer.Write([]string{
+			step.Start.Format("2006-01-02 15:04:05"),
+			step.LocationID,
+			string(step.Action),
+			strconv.FormatFloat(step.RunFraction, 'f', 4, 64),
+			string(step.Reason),
+			strconv.FormatFloat(step.Margin, 'f', 6, 64),
+			strconv.FormatFloat(float64(step.EnergyPrice), 'f', 5, 64),
+			strconv.FormatFloat(float64(step.CryptoPrice), 'f', 2, 64),
+			strconv.FormatFloat(step.EnergyMWh, 'f', 6, 64),
+			strconv.FormatFloat(float64(step.Coins), 'f', 8, 64),
+			strconv.FormatFloat(float64(step.Revenue), 'f', 5, 64),
+			strconv.FormatFloat(float64(step.EnergyCost), 'f', 5, 64),
+			strconv.FormatFloat(float64(step.OtherCost), 'f', 5, 64),
+			strconv.FormatFloat(float64(step.Profit), 'f', 5, 64),
+			strconv.FormatFloat(float64(step.AlwaysOnProfit), 'f', 5, 64),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
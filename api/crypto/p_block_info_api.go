@@ -18,9 +18,10 @@ import (
 )
 
 type OutputData struct {
-	MessageType string  `json:"message_type"`
-	Symbol      string  `json:"symbol"`
-	Value       float64 `json:"value"`
+	MessageType string    `json:"message_type"`
+	Symbol      string    `json:"symbol"`
+	Value       float64   `json:"value"`
+	EventTime   time.Time `json:"event_time"`
 }
 
 var logs *log.Logger
@@ -188,8 +189,9 @@ func getValue(symbol string, url string, topic string) {
 
 	// Create the OutputData struct
 	OutputData := OutputData{
-		Symbol: symbol,
-		Value:  value,
+		Symbol:    symbol,
+		Value:     value,
+		EventTime: time.Now(),
 	}
 
 	if value <= 0 {
@@ -28,9 +28,10 @@ type CryptoPriceRespData struct {
 }
 
 type OutputData struct {
-	Symbol   string  `json:"symbol"`
-	Currency string  `json:"currency"`
-	Price    float64 `json:"price"`
+	Symbol    string    `json:"symbol"`
+	Currency  string    `json:"currency"`
+	Price     float64   `json:"price"`
+	EventTime time.Time `json:"event_time"`
 }
 
 func main() {
@@ -126,9 +127,10 @@ func main() {
 		}
 		// Create the CryptoPrice struct
 		cryptoPrice := OutputData{
-			Symbol:   config.Symbol,
-			Currency: currency,
-			Price:    price,
+			Symbol:    config.Symbol,
+			Currency:  currency,
+			Price:     price,
+			EventTime: time.Now(),
 		}
 
 		// Convert CryptoPriceRespData struct to JSON
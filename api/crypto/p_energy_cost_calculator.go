@@ -7,11 +7,17 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
-	"math"
 	"os"
 	"os/signal"
-	common "profitmax/util/common"
+	calculator "profitmax/util/calculator"
+	economics "profitmax/util/economics"
+	fleet "profitmax/util/fleet"
+	fx "profitmax/util/fx"
+	inventory "profitmax/util/inventory"
 	logger "profitmax/util/logger"
+	quality "profitmax/util/quality"
+	state "profitmax/util/state"
+	telemetry "profitmax/util/telemetry"
 	"sync"
 	"time"
 
@@ -33,29 +39,53 @@ type BlockchainData struct {
 }
 
 type InputData struct {
-	MessageType string  `json:"message_type"`
-	Symbol      string  `json:"symbol"`
-	Value       float64 `json:"value"`
+	MessageType string    `json:"message_type"`
+	Symbol      string    `json:"symbol"`
+	Value       float64   `json:"value"`
+	EventTime   time.Time `json:"event_time"`
 }
 
 type CurrentEnergyCost struct {
-	Symbol      string  `json:"symbol"`
-	Difficulty  int64   `json:"difficulty"`
-	EnergyPrice float64 `json:"energy_price"`
-	EnergyCost  float64 `json:"energy_cost"`
+	LocationID  string                `json:"location_id"`
+	Currency    string                `json:"currency"`
+	Symbol      string                `json:"symbol"`
+	Difficulty  int64                 `json:"difficulty"`
+	EnergyPrice economics.PricePerMWh `json:"energy_price"`
+	EnergyCost  economics.Amount      `json:"energy_cost"`
+	Horizon     economics.Horizon     `json:"horizon"`
+	Status      string                `json:"status"`
+	Missing     []string              `json:"missing,omitempty"`
+	EventTimes  quality.Times         `json:"event_times"`
+	Quality     string                `json:"quality"`
+	Stale       []string              `json:"stale,omitempty"`
 }
 
+// serviceName keys this service's snapshots in tbl_service_state.
+const serviceName = "p_energy_cost_calculator"
+
 type InputEnergyPriceData struct {
-	LocaionID string  `json:"location_id"`
-	Currency  string  `json:"currency"`
-	Price     float64 `json:"price"`
+	LocaionID string    `json:"location_id"`
+	Currency  string    `json:"currency"`
+	Price     float64   `json:"price"`
+	EventTime time.Time `json:"event_time"`
+}
+
+// Config is the service config with the sections only this service reads.
+type Config struct {
+	fleet.Config
+	TelemetryMaxAge int            `json:"telemetry_max_age"`
+	UseInventory    bool           `json:"use_inventory"`
+	TTLs            map[string]int `json:"ttl"`
 }
 
 var logs *log.Logger
-var config common.Config
+var config Config
 var db *sql.DB
 var consumer sarama.Consumer
-var currentEnergyCost CurrentEnergyCost
+var currentEnergyCosts map[string]*CurrentEnergyCost
+var converter *fx.Converter
+var siteTelemetry = make(map[string]telemetry.SiteSummary)
+var sites *inventory.Fleet
 var producer sarama.SyncProducer
 
 func main() {
@@ -76,7 +106,7 @@ func main() {
 	}
 
 	// Parse the JSON data into a struct
-	config = common.Config{}
+	config = Config{}
 	err = json.Unmarshal(fileData, &config)
 	if err != nil {
 		log.Println("Error parsing JSON:", err)
@@ -150,14 +180,68 @@ func main() {
 	}
 	defer db.Close()
 
+	// Take the fleet from the inventory
+	sites = inventory.NewFleet(config.SiteList())
+	if config.UseInventory {
+		err = sites.Reload(db)
+		if err != nil {
+			logs.Fatal("Error loading inventory:", err)
+		}
+	}
+
+	// Load the latest fx rates to restate energy prices in site currency
+	converter = fx.NewConverter()
+	err = fx.LoadRates(db, converter)
+	if err != nil {
+		logs.Println("Error loading fx rates:", err)
+	}
+
 	difficulty := getDifficulty(config.Symbol)
-	energyPrice := getEnergyPrice(config.LocationID)
-	energyCost := calculateEnergyCost(difficulty, energyPrice)
-	currentEnergyCost = CurrentEnergyCost{
-		config.Symbol,
-		difficulty,
-		energyPrice,
-		energyCost,
+	currentEnergyCosts = make(map[string]*CurrentEnergyCost)
+	for _, site := range sites.List() {
+		if site.Currency == "" {
+			logs.Fatal("No currency configured for site:", site.LocationID)
+		}
+		energyPrice, priced := getEnergyPrice(site)
+		siteDifficulty := difficulty
+
+		// Fall back to the last snapshot for anything never stored
+		var snapshot CurrentEnergyCost
+		found, err := state.Load(db, serviceName, site.LocationID, &snapshot)
+		if err != nil {
+			logs.Println("Error restoring state:", err)
+		}
+		if found && !priced && snapshot.Currency == site.Currency && !state.Lacks(snapshot.Missing, "energy_price") {
+			energyPrice = snapshot.EnergyPrice
+			priced = true
+		}
+		if found && siteDifficulty == 0 {
+			siteDifficulty = snapshot.Difficulty
+		}
+
+		var missing []string
+		if !priced {
+			missing = append(missing, "energy_price")
+		}
+		if siteDifficulty == 0 && len(site.Fleet) == 0 {
+			missing = append(missing, "difficulty")
+		}
+
+		currentEnergyCosts[site.LocationID] = &CurrentEnergyCost{
+			LocationID:  site.LocationID,
+			Currency:    site.Currency,
+			Symbol:      config.Symbol,
+			Difficulty:  siteDifficulty,
+			EnergyPrice: energyPrice,
+			EnergyCost:  calculateEnergyCost(site, siteDifficulty, energyPrice),
+			Horizon:     economics.PerBlock,
+			Missing:     missing,
+			EventTimes:  make(quality.Times),
+		}
+		currentEnergyCosts[site.LocationID].EventTimes.Merge(snapshot.EventTimes)
+
+		// Let the cost calculator start from the restored cost
+		publishEnergyCost(currentEnergyCosts[site.LocationID])
 	}
 
 	// Specify the topics you want to consume from
@@ -237,38 +321,30 @@ func (h *ConsumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession,
 				continue
 			}
 
-			// Create the OutputData struct
-			if config.LocationID != input.LocaionID {
+			// Only sites we manage are of interest
+			currentEnergyCost, ok := currentEnergyCosts[input.LocaionID]
+			if !ok {
 				continue
 			}
 
-			if currentEnergyCost.EnergyPrice == input.Price {
+			// Restate the price in the site's currency
+			site, _ := sites.Site(input.LocaionID)
+			energyPrice, err := fx.Convert(converter, economics.PricePerMWh(input.Price), input.Currency, site.Currency)
+			if err != nil {
+				logs.Println("Error converting energy price:", err)
 				continue
 			}
 
-			currentEnergyCost.EnergyPrice = input.Price
-			currentEnergyCost.EnergyCost = calculateEnergyCost(currentEnergyCost.Difficulty, currentEnergyCost.EnergyPrice)
-
-			// Convert OutputData struct to JSON
-			OutputJSON, err := json.Marshal(currentEnergyCost)
-			if err != nil {
-				logs.Println("Error marshaling energy cost data:", err)
+			moved := currentEnergyCost.EventTimes.Observe("energy_price", quality.EventTime(input.EventTime, message.Timestamp))
+			if currentEnergyCost.EnergyPrice == energyPrice && currentEnergyCost.Status == state.Ready && !moved {
 				continue
 			}
+			currentEnergyCost.Missing = state.Known(currentEnergyCost.Missing, "energy_price")
 
-			// Print the response
-			logs.Println("[OUT]: " + string(OutputJSON))
+			currentEnergyCost.EnergyPrice = energyPrice
+			currentEnergyCost.EnergyCost = calculateEnergyCost(site, currentEnergyCost.Difficulty, currentEnergyCost.EnergyPrice)
 
-			// Send the response to Kafka topic
-			message := &sarama.ProducerMessage{
-				Topic: config.Ptopic,
-				Value: sarama.StringEncoder(OutputJSON),
-			}
-			_, _, err = producer.SendMessage(message)
-			if err != nil {
-				logs.Println("Error sending message to Kafka:", err)
-				continue
-			}
+			publishEnergyCost(currentEnergyCost)
 		case "public.block.difficulty":
 			//
 			// JSON data
@@ -282,33 +358,99 @@ func (h *ConsumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession,
 				continue
 			}
 
-			if currentEnergyCost.Difficulty == int64(input.Value) {
+			// Difficulty is shared by every site
+			for _, site := range sites.List() {
+				currentEnergyCost := currentEnergyCosts[site.LocationID]
+				moved := currentEnergyCost.EventTimes.Observe("difficulty", quality.EventTime(input.EventTime, message.Timestamp))
+				if currentEnergyCost.Difficulty == int64(input.Value) && currentEnergyCost.Status == state.Ready && !moved {
+					continue
+				}
+				currentEnergyCost.Missing = state.Known(currentEnergyCost.Missing, "difficulty")
+				currentEnergyCost.Difficulty = int64(input.Value)
+				currentEnergyCost.EnergyCost = calculateEnergyCost(site, currentEnergyCost.Difficulty, currentEnergyCost.EnergyPrice)
+
+				publishEnergyCost(currentEnergyCost)
+			}
+		case "public.miner.telemetry.site":
+			//
+			// JSON data
+			jsonData := message.Value
+
+			// Parse the JSON data into a SiteSummary struct
+			var input telemetry.SiteSummary
+			err := json.Unmarshal(jsonData, &input)
+			if err != nil {
+				logs.Println("Error parsing JSON:", err)
 				continue
 			}
-			// Create the OutputData struct
-			currentEnergyCost.Difficulty = int64(input.Value)
-			currentEnergyCost.EnergyCost = calculateEnergyCost(currentEnergyCost.Difficulty, currentEnergyCost.EnergyPrice)
 
-			// Convert OutputData struct to JSON
-			OutputJSON, err := json.Marshal(currentEnergyCost)
+			// Only sites we manage are of interest
+			site, ok := sites.Site(input.LocationID)
+			if !ok {
+				continue
+			}
+			siteTelemetry[site.LocationID] = input
+
+			// Republish when the measured fleet changes the cost
+			currentEnergyCost := currentEnergyCosts[site.LocationID]
+			energyCost := calculateEnergyCost(site, currentEnergyCost.Difficulty, currentEnergyCost.EnergyPrice)
+			if energyCost == currentEnergyCost.EnergyCost {
+				continue
+			}
+			currentEnergyCost.EnergyCost = energyCost
+			publishEnergyCost(currentEnergyCost)
+		case "public.fxrate":
+			//
+			// JSON data
+			jsonData := message.Value
+
+			// Parse the JSON data into a Rate struct
+			var input fx.Rate
+			err := json.Unmarshal(jsonData, &input)
 			if err != nil {
-				logs.Println("Error marshaling energy cost data:", err)
+				logs.Println("Error parsing JSON:", err)
 				continue
 			}
 
-			// Print the response
-			logs.Println("[OUT]: " + string(OutputJSON))
+			converter.Set(input)
+		case "private.inventory":
+			//
+			// JSON data
+			jsonData := message.Value
+
+			// Parse the JSON data into an Event struct
+			var input inventory.Event
+			err := json.Unmarshal(jsonData, &input)
+			if err != nil {
+				logs.Println("Error parsing JSON:", err)
+				continue
+			}
+			if !config.UseInventory {
+				continue
+			}
 
-			// Send the response to Kafka topic
-			message := &sarama.ProducerMessage{
-				Topic: config.Ptopic,
-				Value: sarama.StringEncoder(OutputJSON),
+			// Only sites we manage are of interest
+			affected := sites.Affected(input)
+			if len(affected) == 0 {
+				continue
 			}
-			_, _, err = producer.SendMessage(message)
+			err = sites.Reload(db)
 			if err != nil {
-				logs.Println("Error sending message to Kafka:", err)
+				logs.Println("Error reloading inventory:", err)
 				continue
 			}
+
+			// Republish the sites whose changed fleet changes their cost
+			for _, locationID := range affected {
+				site, _ := sites.Site(locationID)
+				currentEnergyCost := currentEnergyCosts[site.LocationID]
+				energyCost := calculateEnergyCost(site, currentEnergyCost.Difficulty, currentEnergyCost.EnergyPrice)
+				if energyCost == currentEnergyCost.EnergyCost {
+					continue
+				}
+				currentEnergyCost.EnergyCost = energyCost
+				publishEnergyCost(currentEnergyCost)
+			}
 		default:
 		}
 
@@ -319,6 +461,42 @@ func (h *ConsumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession,
 	return nil
 }
 
+// publishEnergyCost publishes a site's energy cost, flagged as warming up
+// while an input is missing and as stale while an input is past its TTL, and
+// snapshots it.
+func publishEnergyCost(currentEnergyCost *CurrentEnergyCost) {
+	currentEnergyCost.Status = state.Of(currentEnergyCost.Missing)
+	report := quality.Check(currentEnergyCost.EventTimes, config.TTLs, time.Now())
+	currentEnergyCost.Quality = report.Status
+	currentEnergyCost.Stale = report.Stale
+
+	err := state.Save(db, serviceName, currentEnergyCost.LocationID, currentEnergyCost)
+	if err != nil {
+		logs.Println("Error saving state:", err)
+	}
+
+	// Convert OutputData struct to JSON
+	OutputJSON, err := json.Marshal(currentEnergyCost)
+	if err != nil {
+		logs.Println("Error marshaling energy cost data:", err)
+		return
+	}
+
+	// Print the response
+	logs.Println("[OUT]: " + string(OutputJSON))
+
+	// Send the response to Kafka topic, keyed by site
+	message := &sarama.ProducerMessage{
+		Topic: config.Ptopic,
+		Key:   sarama.StringEncoder(currentEnergyCost.LocationID),
+		Value: sarama.StringEncoder(OutputJSON),
+	}
+	_, _, err = producer.SendMessage(message)
+	if err != nil {
+		logs.Println("Error sending message to Kafka:", err)
+	}
+}
+
 func getDifficulty(blockchain string) int64 {
 	// Prepare the SELECT statement with placeholders for the key values
 	stmt, err := db.Prepare("SELECT difficulty, last_updated FROM tbl_blockchain_info WHERE blockchain=?")
@@ -352,7 +530,7 @@ func getDifficulty(blockchain string) int64 {
 		return 0
 	}
 
-	logs.Printf("Blockchain: %s, Difficulty: %.2f, Last Updated: %s\n", blockchain, difficulty, lastUpdated)
+	logs.Printf("Blockchain: %s, Difficulty: %d, Last Updated: %s\n", blockchain, difficulty, lastUpdated)
 
 	// Check for any errors during iteration
 	err = rows.Err()
@@ -363,83 +541,87 @@ func getDifficulty(blockchain string) int64 {
 	return difficulty
 }
 
-func getEnergyPrice(LocationID string) float64 {
+// getEnergyPrice returns the last stored energy price of a site in its
+// currency. It is false when there is none.
+func getEnergyPrice(site fleet.Site) (economics.PricePerMWh, bool) {
 	// Prepare the SELECT statement with placeholders for the key values
-	stmt, err := db.Prepare("SELECT price, last_updated FROM tbl_energy_price_current WHERE location_id=?")
+	stmt, err := db.Prepare("SELECT price, currency_code, last_updated FROM tbl_energy_price_current WHERE location_id=?")
 	if err != nil {
 		logs.Println(err)
-		return 0
+		return 0, false
 	}
 	defer stmt.Close()
 
 	// Execute the SELECT statement with the key values
-	rows, err := stmt.Query(LocationID)
+	rows, err := stmt.Query(site.LocationID)
 	if err != nil {
 		logs.Println(err)
-		return 0
+		return 0, false
 	}
 	defer rows.Close()
 
 	// Check if there is any data available for the specified key values
 	if !rows.Next() {
-		logs.Printf("No data found for location ID: %s\n", LocationID)
-		return 0
+		logs.Printf("No data found for location ID: %s\n", site.LocationID)
+		return 0, false
 	}
 
 	// Retrieve the result
 	var energyPrice float64
+	var currencyCode string
 	var lastUpdated string
 
-	err = rows.Scan(&energyPrice, &lastUpdated)
+	err = rows.Scan(&energyPrice, &currencyCode, &lastUpdated)
 	if err != nil {
 		logs.Println(err)
-		return 0
+		return 0, false
 	}
 
-	logs.Printf("Location ID: %s, Energy Price: %.2f, Last Updated: %s\n", LocationID, energyPrice, lastUpdated)
+	logs.Printf("Location ID: %s, Energy Price: %.2f %s, Last Updated: %s\n", site.LocationID, energyPrice, currencyCode, lastUpdated)
 
 	// Check for any errors during iteration
 	err = rows.Err()
 	if err != nil {
 		logs.Println(err)
-		return 0
+		return 0, false
 	}
-	return energyPrice
-}
-
-func calculateEnergyCost(difficulty int64, energyPrice float64) float64 {
-	// Convert Bitcoin mining time (10 minutes) to seconds
-	miningTime := 10 * 60
 
-	// Calculate hash rate based on the difficulty
-	hashRate := float64(difficulty) / float64(miningTime) * math.Pow(2, 32)
-
-	// Power consumption per hash (example value, modify according to actual values)
-	powerConsumptionPerHash := 0.0295454545454545 / math.Pow(10, 12) // kWh
-
-	// Energy price per kilowatt-hour (kWh)
-	energyPricePerKWh := energyPrice / 1000
-
-	// Calculate energy cost per hash
-	energyCostPerHash := powerConsumptionPerHash * energyPricePerKWh
-
-	// Calculate hashes mined per second
-	hashRatePerSecond := hashRate / float64(miningTime)
+	// Restate the price in the site's currency
+	price, err := fx.Convert(converter, economics.PricePerMWh(energyPrice), currencyCode, site.Currency)
+	if err != nil {
+		logs.Println(err)
+		return 0, false
+	}
+	return price, true
+}
 
-	// Calculate energy cost per second
-	energyCostPerSecond := energyCostPerHash * hashRatePerSecond
+func calculateEnergyCost(site fleet.Site, difficulty int64, energyPrice economics.PricePerMWh) economics.Amount {
+	// Energy cost of one block interval (10 minutes) of the site's fleet,
+	// or of the whole network at the reference efficiency (J/TH) when no
+	// fleet is configured
+	site = measuredSite(site)
+	energyCost := calculator.EnergyCost(site, float64(difficulty), calculator.Efficiency(config.Efficiency), energyPrice)
 
 	// Insert Energy Cost info to DB
-	insertTable(config.LocationID, "ENERGY", config.Currency, energyCostPerSecond*float64(miningTime))
-	// Convert energy cost per second to a 10-minute interval and return
-	return energyCostPerSecond * float64(miningTime)
+	insertTable(site.LocationID, "ENERGY", site.Currency, energyCost)
+	return energyCost
 }
 
-func insertTable(location_id string, cost_code string, currency_code string, energyCost float64) {
+func insertTable(location_id string, cost_code string, currency_code string, energyCost economics.Amount) {
 	// Insert the current data into the table
 	insertCurrentData := "INSERT INTO tbl_mining_cost_current (location_id, cost_code, currency_code, price, last_updated) VALUES (?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE price = ?, last_updated = ?"
-	_, err := db.Exec(insertCurrentData, location_id, cost_code, currency_code, energyCost, time.Now(), energyCost, time.Now())
+	_, err := db.Exec(insertCurrentData, location_id, cost_code, currency_code, float64(energyCost), time.Now(), float64(energyCost), time.Now())
 	if err != nil {
 		logs.Println("Error inserting data into table:", err)
 	}
 }
+
+// measuredSite returns site with the hashrate and power its miners last
+// reported, when telemetry is fresh enough, in place of nameplate figures.
+func measuredSite(site fleet.Site) fleet.Site {
+	summary, ok := siteTelemetry[site.LocationID]
+	if !ok {
+		return site
+	}
+	return telemetry.Apply(site, summary, time.Duration(config.TelemetryMaxAge)*time.Second, time.Now())
+}
@@ -29,11 +29,15 @@ type EnergyPriceData struct {
 }
 
 type OutputData struct {
-	LocaionID string  `json:"location_id"`
-	Currency  string  `json:"currency"`
-	Price     float64 `json:"price"`
+	LocaionID string    `json:"location_id"`
+	Currency  string    `json:"currency"`
+	Price     float64   `json:"price"`
+	EventTime time.Time `json:"event_time"`
 }
 
+// nemTime is the market time of the NEM, which keeps no daylight saving.
+var nemTime = time.FixedZone("AEST", 10*60*60)
+
 func main() {
 	args := os.Args
 
@@ -97,14 +101,16 @@ func main() {
 		// Send an HTTP GET request
 		response, err := http.Get(config.URL)
 		if err != nil {
-			log.Fatal(err)
+			logs.Println("Error making request:", err)
+			continue
 		}
-		defer response.Body.Close()
 
 		// Read the response body
 		body, err := ioutil.ReadAll(response.Body)
+		response.Body.Close()
 		if err != nil {
-			log.Fatal(err)
+			logs.Println("Error reading response:", err)
+			continue
 		}
 
 		// Parse the JSON data
@@ -112,7 +118,7 @@ func main() {
 		err = json.Unmarshal([]byte(body), &parsedData)
 		if err != nil {
 			logs.Println("Error parsing JSON:", err)
-			return
+			continue
 		}
 
 		// Iterate over the ElecNemSummary slice using a for loop
@@ -123,11 +129,19 @@ func main() {
 			//logs.Println("REGIONID:", locationID)
 			//logs.Println("PRICE:", price)
 
+			// The price is as of its settlement interval, not of this poll
+			eventTime, err := time.ParseInLocation("2006-01-02T15:04:05", summary.SettlementDate, nemTime)
+			if err != nil {
+				logs.Println("Error parsing settlement date:", summary.SettlementDate, err)
+				eventTime = time.Now()
+			}
+
 			// Create the EnergyPrice struct
 			energyPrice := OutputData{
 				LocaionID: locationID,
 				Currency:  config.Currency,
 				Price:     price,
+				EventTime: eventTime,
 			}
 
 			// Convert EnergyPriceRespData struct to JSON
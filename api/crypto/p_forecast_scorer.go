@@ -0,0 +1,264 @@
+package main
+
+/*
+CREATE TABLE tbl_forecast_score (
+    series VARCHAR(20) NOT NULL,
+    series_key VARCHAR(10) NOT NULL,
+    model VARCHAR(20) NOT NULL,
+    horizon_minutes INT NOT NULL,
+    samples INT NOT NULL,
+    mae DECIMAL(18, 5) NOT NULL,
+    mape DECIMAL(12, 6) NOT NULL,
+    rmse DECIMAL(18, 5) NOT NULL,
+    pinball DECIMAL(18, 5) NOT NULL,
+    last_updated DATETIME NOT NULL,
+    PRIMARY KEY (series, series_key, model, horizon_minutes)
+);
+*/
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	fleet "profitmax/util/fleet"
+	forecast "profitmax/util/forecast"
+	logger "profitmax/util/logger"
+
+	"github.com/Shopify/sarama"
+	_ "github.com/go-sql-driver/mysql"
+)
+
+type DBConfig struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	User     string `json:"user"`
+	Password string `json:"password"`
+	Database string `json:"database"`
+}
+
+// Config is the service config with the sections only this service reads.
+type Config struct {
+	fleet.Config
+	Forecast forecast.Config `json:"forecast"`
+	Listen   string          `json:"listen"`
+}
+
+var logs *log.Logger
+var config Config
+var db *sql.DB
+var producer sarama.SyncProducer
+
+func main() {
+	args := os.Args
+
+	if len(args) < 2 {
+		fmt.Println("Usage: p_forecast_scorer [Config File]", len(args))
+		fmt.Println("Example: p_forecast_scorer p_forecast_scorer.json")
+		return
+	}
+
+	// Read the JSON file
+	filePath := args[1]
+	fileData, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		log.Println("Error reading file:", err)
+		return
+	}
+
+	// Parse the JSON data into a struct
+	config = Config{}
+	err = json.Unmarshal(fileData, &config)
+	if err != nil {
+		log.Println("Error parsing JSON:", err)
+		return
+	}
+	// Create logs directory path
+	logsDir, err := logger.CreateLogsDirectory(config.LogPath)
+	if err != nil {
+		log.Fatal("Failed to create logs directory:", err)
+	}
+
+	// Create log file path based on the current date
+	logFilePath, err := logger.CreateLogFile(logsDir, config.LogFile)
+	if err != nil {
+		log.Fatal("Failed to create log file path:", err)
+	}
+
+	// Open the log file
+	logFile, err := os.OpenFile(logFilePath.Name(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Fatal("Failed to open log file:", err)
+	}
+	defer logFile.Close()
+
+	logs = log.New(logFile, "", log.LstdFlags)
+
+	if config.Forecast.CandleSeconds <= 0 || config.Forecast.ScoreWindowHours <= 0 {
+		logs.Fatal("candle_seconds and score_window_hours must be positive")
+	}
+	if config.Forecast.Metric == "" {
+		config.Forecast.Metric = forecast.MAE
+	}
+	if !forecast.ValidMetric(config.Forecast.Metric) {
+		logs.Fatal("Unknown metric:", config.Forecast.Metric)
+	}
+
+	// Create a Kafka producer
+	producer, err = sarama.NewSyncProducer([]string{config.KafkaBroker}, nil)
+	if err != nil {
+		logs.Fatalln("Error creating Kafka producer:", config.KafkaBroker, err)
+		return
+	}
+	defer producer.Close()
+
+	// Open a connection to the MySQL database
+	// Read the JSON file
+	dbFilePath := "dbconfig.json"
+	dbFileData, err := ioutil.ReadFile(dbFilePath)
+	if err != nil {
+		logs.Println("Error reading file:", err)
+		return
+	}
+	// Parse the JSON data into a struct
+	var dbConfig DBConfig
+	err = json.Unmarshal(dbFileData, &dbConfig)
+	if err != nil {
+		logs.Println("Error parsing JSON:", err)
+		return
+	}
+
+	// Create the MySQL connection string
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s", dbConfig.User, dbConfig.Password, dbConfig.Host, dbConfig.Port, dbConfig.Database)
+
+	db, err = sql.Open("mysql", dsn)
+	if err != nil {
+		logs.Fatal("Error connecting to the database:", err)
+	}
+	defer db.Close()
+
+	if config.Listen != "" {
+		go func() {
+			err := serve()
+			if err != nil {
+				logs.Println("Error serving leaderboard API:", err)
+			}
+		}()
+	}
+
+	// Create a ticker that ticks every x seconds
+	timeInterval := config.TimeInterval
+	ticker := time.NewTicker(time.Duration(timeInterval) * time.Second)
+
+	// Run the loop indefinitely
+	for range ticker.C {
+		for _, site := range config.SiteList() {
+			scoreSeries(forecast.EnergyPrice, site.LocationID)
+		}
+		scoreSeries(forecast.CryptoPrice, config.Symbol)
+	}
+}
+
+// scoreSeries scores the forecasts of a series that came due within the
+// score window, stores the scores and publishes the leaderboard.
+func scoreSeries(series string, key string) {
+	now := time.Now()
+	outcomes, err := forecast.LoadOutcomes(db, series, key, now.Add(-config.Forecast.ScoreWindow()), now, config.Forecast.Step())
+	if err != nil {
+		logs.Println("Error loading outcomes:", series, key, err)
+		return
+	}
+	if len(outcomes) == 0 {
+		logs.Println("No outcomes to score:", series, key)
+		return
+	}
+
+	scores := forecast.ScoreOutcomes(series, key, outcomes, now)
+	err = forecast.SaveScores(db, scores)
+	if err != nil {
+		logs.Println("Error saving scores:", series, key, err)
+	}
+	publishLeaderboard(forecast.NewLeaderboard(series, key, config.Forecast.Metric, scores, now))
+}
+
+func publishLeaderboard(leaderboard forecast.Leaderboard) {
+	// Convert Leaderboard struct to JSON
+	OutputJSON, err := json.Marshal(leaderboard)
+	if err != nil {
+		logs.Println("Error marshaling leaderboard data:", err)
+		return
+	}
+
+	// Print the response
+	logs.Println("[OUT]: " + string(OutputJSON))
+
+	// Send the response to Kafka topic, keyed by series
+	message := &sarama.ProducerMessage{
+		Topic: config.Ptopic,
+		Key:   sarama.StringEncoder(leaderboard.Series + "/" + leaderboard.Key),
+		Value: sarama.StringEncoder(OutputJSON),
+	}
+	_, _, err = producer.SendMessage(message)
+	if err != nil {
+		logs.Println("Error sending message to Kafka:", err)
+	}
+}
+
+// serve answers the leaderboard API:
+//
+//	GET /leaderboard?series=S&key=K[&metric=M]   rank the stored scores of a series
+func serve() error {
+	http.HandleFunc("/leaderboard", handleLeaderboard)
+
+	logs.Println("Serving leaderboard API on", config.Listen)
+	fmt.Println("Serving leaderboard API on", config.Listen)
+	return http.ListenAndServe(config.Listen, nil)
+}
+
+func handleLeaderboard(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	query := r.URL.Query()
+	series := query.Get("series")
+	key := query.Get("key")
+	if series == "" || key == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("series and key are required"))
+		return
+	}
+	metric := query.Get("metric")
+	if metric == "" {
+		metric = config.Forecast.Metric
+	}
+	if !forecast.ValidMetric(metric) {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("unknown metric %s", metric))
+		return
+	}
+
+	scores, err := forecast.LoadScores(db, series, key)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if scores == nil {
+		scores = []forecast.Score{}
+	}
+	writeJSON(w, http.StatusOK, forecast.NewLeaderboard(series, key, metric, scores, time.Now()))
+}
+
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	logs.Println("Error:", err)
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
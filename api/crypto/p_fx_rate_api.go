@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	common "profitmax/util/common"
+	fx "profitmax/util/fx"
+	logger "profitmax/util/logger"
+
+	"github.com/Shopify/sarama"
+)
+
+type FxRateRespData struct {
+	Result             string             `json:"result"`
+	BaseCode           string             `json:"base_code"`
+	TimeLastUpdateUnix int64              `json:"time_last_update_unix"`
+	Rates              map[string]float64 `json:"rates"`
+}
+
+// Config is the service config with the sections only this service reads.
+type Config struct {
+	common.Config
+	Currencies []string `json:"currencies"`
+}
+
+func main() {
+	args := os.Args
+
+	if len(args) < 2 {
+		fmt.Println("Usage: p_fx_rate_api [Config File]", len(args))
+		fmt.Println("Example: p_fx_rate_api p_fx_rate_api.json")
+		return
+	}
+
+	// Read the JSON file
+	filePath := args[1]
+	fileData, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		log.Println("Error reading file:", err)
+		return
+	}
+
+	// Parse the JSON data into a struct
+	config := Config{}
+	err = json.Unmarshal(fileData, &config)
+	if err != nil {
+		log.Println("Error parsing JSON:", err)
+		return
+	}
+
+	// Create logs directory path
+	logsDir, err := logger.CreateLogsDirectory(config.LogPath)
+	if err != nil {
+		log.Fatal("Failed to create logs directory:", err)
+	}
+
+	// Create log file path based on the current date
+	logFilePath, err := logger.CreateLogFile(logsDir, config.LogFile)
+	if err != nil {
+		log.Fatal("Failed to create log file path:", err)
+	}
+
+	// Open the log file
+	logFile, err := os.OpenFile(logFilePath.Name(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Fatal("Failed to open log file:", err)
+	}
+	defer logFile.Close()
+
+	logs := log.New(logFile, "", log.LstdFlags)
+
+	// Create a Kafka producer
+	producer, err := sarama.NewSyncProducer([]string{config.KafkaBroker}, nil)
+	if err != nil {
+		logs.Fatalln("Error creating Kafka producer:", config.KafkaBroker, err)
+		return
+	}
+	defer producer.Close()
+
+	// Create a ticker that ticks every x seconds
+	timeInterval := config.TimeInterval
+	ticker := time.NewTicker(time.Duration(timeInterval) * time.Second)
+
+	// Run the loop indefinitely
+	for range ticker.C {
+		// Make the HTTP GET request
+		resp, err := http.Get(config.URL)
+		if err != nil {
+			logs.Println("Error making request:", err)
+			continue
+		}
+
+		// Read the response body
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			logs.Println("Error reading response:", err)
+			continue
+		}
+		logs.Println("[IN]: " + string(body))
+
+		// Parse the fx rate data from the response body
+		var respData FxRateRespData
+		err = json.Unmarshal(body, &respData)
+		if err != nil {
+			logs.Println("Error parsing fx rate data:", err)
+			continue
+		}
+		if respData.Result != "success" || respData.BaseCode != config.Currency {
+			logs.Println("Unexpected fx rate response:", respData.Result, respData.BaseCode)
+			continue
+		}
+
+		timestamp := time.Unix(respData.TimeLastUpdateUnix, 0)
+		for _, quote := range config.Currencies {
+			price, ok := respData.Rates[quote]
+			if !ok {
+				logs.Println("No fx rate in response for:", quote)
+				continue
+			}
+
+			// Create the Rate struct
+			rate := fx.Rate{
+				Base:      respData.BaseCode,
+				Quote:     quote,
+				Rate:      price,
+				Timestamp: timestamp,
+			}
+
+			// Convert Rate struct to JSON
+			rateJSON, err := json.Marshal(rate)
+			if err != nil {
+				logs.Println("Error marshaling fx rate data:", err)
+				continue
+			}
+
+			// Print the response
+			logs.Println("[OUT]: " + string(rateJSON))
+
+			// Send the response to Kafka topic, keyed by currency pair
+			message := &sarama.ProducerMessage{
+				Topic: config.Ptopic,
+				Key:   sarama.StringEncoder(rate.Base + "/" + rate.Quote),
+				Value: sarama.StringEncoder(rateJSON),
+			}
+			_, _, err = producer.SendMessage(message)
+			if err != nil {
+				logs.Println("Error sending message to Kafka:", err)
+				continue
+			}
+		}
+	}
+}
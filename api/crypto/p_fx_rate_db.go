@@ -0,0 +1,190 @@
+package main
+
+/*
+CREATE TABLE tbl_fx_rate (
+    id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+	base_currency VARCHAR(10) NOT NULL,
+	quote_currency VARCHAR(10) NOT NULL,
+	rate DECIMAL(18, 8) NOT NULL,
+    timestamp DATETIME NOT NULL,
+	UNIQUE KEY (base_currency, quote_currency, timestamp)
+);
+*/
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	common "profitmax/util/common"
+	fx "profitmax/util/fx"
+	logger "profitmax/util/logger"
+	"sync"
+
+	"github.com/Shopify/sarama"
+	_ "github.com/go-sql-driver/mysql"
+)
+
+type DBConfig struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	User     string `json:"user"`
+	Password string `json:"password"`
+	Database string `json:"database"`
+}
+
+var logs *log.Logger
+
+func main() {
+	args := os.Args
+
+	if len(args) < 2 {
+		fmt.Println("Usage: p_fx_rate_db [Config File]", len(args))
+		fmt.Println("Example: p_fx_rate_db p_fx_rate_db.json")
+		return
+	}
+
+	// Read the JSON file
+	filePath := args[1]
+	fileData, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		log.Println("Error reading file:", err)
+		return
+	}
+
+	// Parse the JSON data into a struct
+	config := common.Config{}
+	err = json.Unmarshal(fileData, &config)
+	if err != nil {
+		log.Println("Error parsing JSON:", err)
+		return
+	}
+	// Create logs directory path
+	logsDir, err := logger.CreateLogsDirectory(config.LogPath)
+	if err != nil {
+		log.Fatal("Failed to create logs directory:", err)
+	}
+
+	// Create log file path based on the current date
+	logFilePath, err := logger.CreateLogFile(logsDir, config.LogFile)
+	if err != nil {
+		log.Fatal("Failed to create log file path:", err)
+	}
+
+	// Open the log file
+	logFile, err := os.OpenFile(logFilePath.Name(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Fatal("Failed to open log file:", err)
+	}
+	defer logFile.Close()
+
+	logs = log.New(logFile, "", log.LstdFlags)
+
+	// Configure the Kafka consumer
+	conf := sarama.NewConfig()
+	conf.Consumer.Return.Errors = true
+
+	// Create a new consumer
+	consumer, err := sarama.NewConsumer([]string{config.KafkaBroker}, conf)
+	if err != nil {
+		logs.Fatal("Failed to create Kafka consumer:", err)
+	}
+	defer consumer.Close()
+
+	// Open a connection to the MySQL database
+	// Read the JSON file
+	dbFilePath := "dbconfig.json"
+	dbFileData, err := ioutil.ReadFile(dbFilePath)
+	if err != nil {
+		logs.Println("Error reading file:", err)
+		return
+	}
+	// Parse the JSON data into a struct
+	var dbConfig DBConfig
+	err = json.Unmarshal(dbFileData, &dbConfig)
+	if err != nil {
+		logs.Println("Error parsing JSON:", err)
+		return
+	}
+
+	// Create the MySQL connection string
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s", dbConfig.User, dbConfig.Password, dbConfig.Host, dbConfig.Port, dbConfig.Database)
+
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		logs.Fatal("Error connecting to the database:", err)
+	}
+	defer db.Close()
+
+	// Specify the topic and partition you want to consume from
+	topic := config.Topic
+	partition := int32(0)
+
+	// Start consuming from the specified topic and partition
+	partitionConsumer, err := consumer.ConsumePartition(topic, partition, sarama.OffsetNewest)
+	if err != nil {
+		logs.Fatal("Failed to start consumer:", err)
+	}
+
+	// Create a signal channel to handle termination
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+
+	// Create a wait group to wait for the consumer to finish
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+
+	// Start consuming messages in a separate goroutine
+	go func() {
+		defer wg.Done()
+
+		for {
+			select {
+			case msg := <-partitionConsumer.Messages():
+				logs.Printf("Received message: Topic=%s, Partition=%d, Offset=%d, Key=%s, Value=%s\n",
+					msg.Topic, msg.Partition, msg.Offset, string(msg.Key), string(msg.Value))
+
+				insertTable(db, msg)
+
+			case err := <-partitionConsumer.Errors():
+				logs.Println("Error:", err.Err)
+
+			case <-signals:
+				return
+			}
+		}
+	}()
+
+	// Wait for a termination signal
+	<-signals
+
+	// Close the partition consumer and wait for it to finish
+	partitionConsumer.Close()
+	wg.Wait()
+}
+
+func insertTable(db *sql.DB, msg *sarama.ConsumerMessage) {
+	// JSON data
+	jsonData := msg.Value
+
+	// Parse the JSON data into a Rate struct
+	var input fx.Rate
+	err := json.Unmarshal(jsonData, &input)
+	if err != nil {
+		logs.Println("Error parsing JSON:", err)
+		return
+	}
+
+	// Insert the data into the table, once per published rate
+	insertData := "INSERT IGNORE INTO tbl_fx_rate (base_currency, quote_currency, rate, timestamp) VALUES (?, ?, ?, ?)"
+	_, err = db.Exec(insertData, input.Base, input.Quote, input.Rate, input.Timestamp)
+	if err != nil {
+		logs.Println("Error inserting data into table:", err)
+		return
+	}
+
+	logs.Println("Data inserted successfully!")
+}
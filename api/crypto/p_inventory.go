@@ -0,0 +1,450 @@
+package main
+
+/*
+CREATE TABLE tbl_inventory_device (
+    device_id VARCHAR(40) NOT NULL PRIMARY KEY,
+    model VARCHAR(40) NOT NULL,
+    serial VARCHAR(60),
+    ip VARCHAR(60),
+    location_id VARCHAR(10) NOT NULL,
+    rack VARCHAR(20),
+    status VARCHAR(12) NOT NULL,
+    hashrate_th DECIMAL(10, 3),
+    power_w DECIMAL(10, 1),
+    purchase_cost DECIMAL(18, 2),
+    purchase_currency VARCHAR(3),
+    purchase_date DATE,
+    firmware VARCHAR(60),
+    last_updated DATETIME NOT NULL,
+    INDEX (location_id)
+);
+*/
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	common "profitmax/util/common"
+	inventory "profitmax/util/inventory"
+	logger "profitmax/util/logger"
+
+	"github.com/Shopify/sarama"
+	_ "github.com/go-sql-driver/mysql"
+)
+
+type DBConfig struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	User     string `json:"user"`
+	Password string `json:"password"`
+	Database string `json:"database"`
+}
+
+// Config is the service config with the sections only this service reads.
+type Config struct {
+	common.Config
+	Listen string `json:"listen"`
+}
+
+var logs *log.Logger
+var config Config
+var db *sql.DB
+var producer sarama.SyncProducer
+
+func usage() {
+	fmt.Println("Usage: p_inventory [Config File] list [Location ID]")
+	fmt.Println("       p_inventory [Config File] get [Device ID]")
+	fmt.Println("       p_inventory [Config File] set -id ID -model MODEL -location ID [-serial S -ip IP -rack R -status ACTIVE|SPARE|MAINTENANCE|RETIRED -hashrate TH -power W -cost N -currency CCY -purchase YYYY-MM-DD -firmware F]")
+	fmt.Println("       p_inventory [Config File] delete [Device ID]")
+	fmt.Println("       p_inventory [Config File] import [CSV File]")
+	fmt.Println("       p_inventory [Config File] export [Location ID]")
+	fmt.Println("       p_inventory [Config File] serve")
+	fmt.Println("Example: p_inventory p_inventory.json set -id QLD1-S19-001 -model \"Antminer S19\" -location QLD1 -ip 10.1.0.11 -hashrate 95 -power 3250")
+}
+
+func main() {
+	args := os.Args
+
+	if len(args) < 3 {
+		usage()
+		return
+	}
+
+	// Read the JSON file
+	filePath := args[1]
+	fileData, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		log.Println("Error reading file:", err)
+		return
+	}
+
+	// Parse the JSON data into a struct
+	config = Config{}
+	err = json.Unmarshal(fileData, &config)
+	if err != nil {
+		log.Println("Error parsing JSON:", err)
+		return
+	}
+	// Create logs directory path
+	logsDir, err := logger.CreateLogsDirectory(config.LogPath)
+	if err != nil {
+		log.Fatal("Failed to create logs directory:", err)
+	}
+
+	// Create log file path based on the current date
+	logFilePath, err := logger.CreateLogFile(logsDir, config.LogFile)
+	if err != nil {
+		log.Fatal("Failed to create log file path:", err)
+	}
+
+	// Open the log file
+	logFile, err := os.OpenFile(logFilePath.Name(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Fatal("Failed to open log file:", err)
+	}
+	defer logFile.Close()
+
+	logs = log.New(logFile, "", log.LstdFlags)
+
+	// Open a connection to the MySQL database
+	// Read the JSON file
+	dbFilePath := "dbconfig.json"
+	dbFileData, err := ioutil.ReadFile(dbFilePath)
+	if err != nil {
+		logs.Println("Error reading file:", err)
+		return
+	}
+	// Parse the JSON data into a struct
+	var dbConfig DBConfig
+	err = json.Unmarshal(dbFileData, &dbConfig)
+	if err != nil {
+		logs.Println("Error parsing JSON:", err)
+		return
+	}
+
+	// Create the MySQL connection string
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s", dbConfig.User, dbConfig.Password, dbConfig.Host, dbConfig.Port, dbConfig.Database)
+
+	db, err = sql.Open("mysql", dsn)
+	if err != nil {
+		logs.Fatal("Error connecting to the database:", err)
+	}
+	defer db.Close()
+
+	// Changes are announced on the inventory topic
+	switch args[2] {
+	case "set", "delete", "import", "serve":
+		producer, err = sarama.NewSyncProducer([]string{config.KafkaBroker}, nil)
+		if err != nil {
+			logs.Fatalln("Error creating Kafka producer:", config.KafkaBroker, err)
+			return
+		}
+		defer producer.Close()
+	}
+
+	argument := ""
+	if len(args) > 3 {
+		argument = args[3]
+	}
+	switch args[2] {
+	case "list":
+		err = listDevices(argument)
+	case "get":
+		err = getDevice(argument)
+	case "set":
+		err = setDevice(args[3:])
+	case "delete":
+		err = deleteDevice(argument)
+	case "import":
+		err = importDevices(argument)
+	case "export":
+		err = exportDevices(argument)
+	case "serve":
+		err = serve()
+	default:
+		usage()
+		return
+	}
+	if err != nil {
+		logs.Println("Error:", err)
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+}
+
+func listDevices(locationID string) error {
+	devices, err := inventory.Load(db, locationID)
+	if err != nil {
+		return err
+	}
+	for _, device := range devices {
+		deviceJSON, err := json.Marshal(device)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(deviceJSON))
+	}
+	return nil
+}
+
+func getDevice(id string) error {
+	device, err := inventory.Get(db, id)
+	if err != nil {
+		return err
+	}
+	deviceJSON, err := json.MarshalIndent(device, "", "    ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(deviceJSON))
+	return nil
+}
+
+func setDevice(args []string) error {
+	var device inventory.Device
+	var status, purchase string
+
+	flags := flag.NewFlagSet("set", flag.ContinueOnError)
+	flags.StringVar(&device.ID, "id", "", "device ID")
+	flags.StringVar(&device.Model, "model", "", "miner model")
+	flags.StringVar(&device.LocationID, "location", "", "site location ID")
+	flags.StringVar(&device.Serial, "serial", "", "serial number")
+	flags.StringVar(&device.IP, "ip", "", "IP address of the miner API")
+	flags.StringVar(&device.Rack, "rack", "", "rack position")
+	flags.StringVar(&status, "status", string(inventory.Active), "ACTIVE, SPARE, MAINTENANCE or RETIRED")
+	flags.Float64Var(&device.HashrateTH, "hashrate", 0, "nameplate hashrate in TH/s")
+	flags.Float64Var(&device.PowerW, "power", 0, "nameplate power in W")
+	flags.Float64Var(&device.PurchaseCost, "cost", 0, "purchase cost")
+	flags.StringVar(&device.PurchaseCurrency, "currency", "", "purchase currency code")
+	flags.StringVar(&purchase, "purchase", "", "purchase date")
+	flags.StringVar(&device.Firmware, "firmware", "", "firmware version")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	device.Status = inventory.Status(strings.ToUpper(status))
+	if purchase != "" {
+		device.PurchaseDate, err = time.ParseInLocation("2006-01-02", purchase, time.Local)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = saveDevice(&device)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Saved device:", device.ID)
+	return nil
+}
+
+func deleteDevice(id string) error {
+	device, err := inventory.Delete(db, id)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Deleted device:", device.ID)
+	return publishEvent(inventory.ActionDelete, device, "")
+}
+
+func importDevices(filePath string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	count, err := importCSV(file)
+	fmt.Println("Imported devices:", count)
+	return err
+}
+
+func exportDevices(locationID string) error {
+	devices, err := inventory.Load(db, locationID)
+	if err != nil {
+		return err
+	}
+	return inventory.WriteCSV(os.Stdout, devices)
+}
+
+// importCSV saves every device in a CSV file. Nothing is saved when any row
+// is invalid.
+func importCSV(r io.Reader) (int, error) {
+	devices, err := inventory.ReadCSV(r)
+	if err != nil {
+		return 0, err
+	}
+	for i := range devices {
+		err = saveDevice(&devices[i])
+		if err != nil {
+			return i, err
+		}
+	}
+	return len(devices), nil
+}
+
+// saveDevice stores a device and announces it, with the site it moved from
+// if it changed sites.
+func saveDevice(device *inventory.Device) error {
+	var previousLocationID string
+	previous, err := inventory.Get(db, device.ID)
+	if err == nil {
+		previousLocationID = previous.LocationID
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	err = inventory.Save(db, device)
+	if err != nil {
+		return err
+	}
+	return publishEvent(inventory.ActionUpsert, *device, previousLocationID)
+}
+
+// serve exposes the inventory over HTTP:
+//
+//	GET    /devices?location_id=ID  list devices
+//	POST   /devices                 add or update a device
+//	POST   /devices/import          import a CSV body
+//	GET    /devices/{id}            get a device
+//	PUT    /devices/{id}            update a device
+//	DELETE /devices/{id}            delete a device
+func serve() error {
+	http.HandleFunc("/devices", handleDevices)
+	http.HandleFunc("/devices/", handleDevice)
+
+	logs.Println("Serving inventory API on", config.Listen)
+	fmt.Println("Serving inventory API on", config.Listen)
+	return http.ListenAndServe(config.Listen, nil)
+}
+
+func handleDevices(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		devices, err := inventory.Load(db, r.URL.Query().Get("location_id"))
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if devices == nil {
+			devices = []inventory.Device{}
+		}
+		writeJSON(w, http.StatusOK, devices)
+	case http.MethodPost:
+		var device inventory.Device
+		err := json.NewDecoder(r.Body).Decode(&device)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		err = saveDevice(&device)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, device)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func handleDevice(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/devices/")
+	if id == "import" && r.Method == http.MethodPost {
+		count, err := importCSV(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]int{"imported": count})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		device, err := inventory.Get(db, id)
+		if err != nil {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, device)
+	case http.MethodPut:
+		var device inventory.Device
+		err := json.NewDecoder(r.Body).Decode(&device)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		device.ID = id
+		err = saveDevice(&device)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, device)
+	case http.MethodDelete:
+		device, err := inventory.Delete(db, id)
+		if err != nil {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		err = publishEvent(inventory.ActionDelete, device, "")
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, device)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	logs.Println("Error:", err)
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// publishEvent tells the other services a device was added, changed or
+// removed.
+func publishEvent(action string, device inventory.Device, previousLocationID string) error {
+	event := inventory.Event{
+		Action:             action,
+		Device:             device,
+		PreviousLocationID: previousLocationID,
+		Timestamp:          time.Now(),
+	}
+
+	// Convert Event struct to JSON
+	OutputJSON, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	// Print the response
+	logs.Println("[OUT]: " + string(OutputJSON))
+
+	// Send the response to Kafka topic, keyed by site
+	message := &sarama.ProducerMessage{
+		Topic: config.Ptopic,
+		Key:   sarama.StringEncoder(device.LocationID),
+		Value: sarama.StringEncoder(OutputJSON),
+	}
+	_, _, err = producer.SendMessage(message)
+	return err
+}
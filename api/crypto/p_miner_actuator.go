@@ -0,0 +1,329 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	actuator "profitmax/util/actuator"
+	decision "profitmax/util/decision"
+	fleet "profitmax/util/fleet"
+	inventory "profitmax/util/inventory"
+	logger "profitmax/util/logger"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	_ "github.com/go-sql-driver/mysql"
+)
+
+type DBConfig struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	User     string `json:"user"`
+	Password string `json:"password"`
+	Database string `json:"database"`
+}
+
+// Config is the service config with the sections only this service reads.
+type Config struct {
+	fleet.Config
+	DryRun          bool `json:"dry_run"`
+	CommandInterval int  `json:"min_command_interval"`
+	CommandTimeout  int  `json:"command_timeout"`
+	UseInventory    bool `json:"use_inventory"`
+}
+
+var logs *log.Logger
+var config Config
+var producer sarama.SyncProducer
+var miners *actuator.Actuator
+var db *sql.DB
+var sites *inventory.Fleet
+
+func main() {
+	args := os.Args
+
+	if len(args) < 2 {
+		fmt.Println("Usage: p_miner_actuator [Config File]", len(args))
+		fmt.Println("Example: p_miner_actuator p_miner_actuator.json")
+		return
+	}
+
+	// Read the JSON file
+	filePath := args[1]
+	fileData, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		log.Println("Error reading file:", err)
+		return
+	}
+
+	// Parse the JSON data into a struct
+	config = Config{}
+	err = json.Unmarshal(fileData, &config)
+	if err != nil {
+		log.Println("Error parsing JSON:", err)
+		return
+	}
+	// Create logs directory path
+	logsDir, err := logger.CreateLogsDirectory(config.LogPath)
+	if err != nil {
+		log.Fatal("Failed to create logs directory:", err)
+	}
+
+	// Create log file path based on the current date
+	logFilePath, err := logger.CreateLogFile(logsDir, config.LogFile)
+	if err != nil {
+		log.Fatal("Failed to create log file path:", err)
+	}
+
+	// Open the log file
+	logFile, err := os.OpenFile(logFilePath.Name(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Fatal("Failed to open log file:", err)
+	}
+	defer logFile.Close()
+
+	logs = log.New(logFile, "", log.LstdFlags)
+
+	// Take the fleet from the inventory
+	sites = inventory.NewFleet(config.SiteList())
+	if config.UseInventory {
+		// Open a connection to the MySQL database
+		// Read the JSON file
+		dbFilePath := "dbconfig.json"
+		dbFileData, err := ioutil.ReadFile(dbFilePath)
+		if err != nil {
+			logs.Println("Error reading file:", err)
+			return
+		}
+		// Parse the JSON data into a struct
+		var dbConfig DBConfig
+		err = json.Unmarshal(dbFileData, &dbConfig)
+		if err != nil {
+			logs.Println("Error parsing JSON:", err)
+			return
+		}
+
+		// Create the MySQL connection string
+		dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s", dbConfig.User, dbConfig.Password, dbConfig.Host, dbConfig.Port, dbConfig.Database)
+
+		db, err = sql.Open("mysql", dsn)
+		if err != nil {
+			logs.Fatal("Error connecting to the database:", err)
+		}
+		defer db.Close()
+		err = sites.Reload(db)
+		if err != nil {
+			logs.Fatal("Error loading inventory:", err)
+		}
+	}
+
+	// Configure the Kafka consumer
+	conf := sarama.NewConfig()
+	conf.Consumer.Return.Errors = true
+
+	// Kafka consumer group
+	group := "miner_actuator"
+
+	// Create a new consumer
+	consumer, err := sarama.NewConsumerGroup([]string{config.KafkaBroker}, group, nil)
+	if err != nil {
+		logs.Fatal("Failed to create Kafka consumer:", err)
+	}
+	defer consumer.Close()
+
+	// Create a Kafka producer
+	producer, err = sarama.NewSyncProducer([]string{config.KafkaBroker}, nil)
+	if err != nil {
+		logs.Fatalln("Error creating Kafka producer:", config.KafkaBroker, err)
+		return
+	}
+	defer producer.Close()
+
+	if config.DryRun {
+		logs.Println("Dry run: commands will be acknowledged but not sent to miners")
+	}
+	timeout := time.Duration(config.CommandTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	miners = actuator.New(time.Duration(config.CommandInterval)*time.Second, config.DryRun, actuator.CGMiner(timeout))
+
+	// Send the commands the rate limit held back once it allows
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		for range ticker.C {
+			for _, ack := range miners.Retry(time.Now()) {
+				publishAck(ack)
+			}
+		}
+	}()
+
+	// Specify the topics you want to consume from
+	topics := config.Topics
+	// Create a context for the consumer group
+	ctx := context.Background()
+
+	// Create a signal channel to handle termination
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+
+	// Create a wait group to wait for the consumer group to finish
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+
+	// Start consuming messages in a separate goroutine
+	go func() {
+		defer wg.Done()
+
+		for {
+			select {
+			case <-signals:
+				// Interrupt signal received, stop consuming
+				consumer.Close()
+				return
+
+			default:
+				// Consume messages
+				err := consumer.Consume(ctx, topics, &ConsumerGroupHandler{})
+				if err != nil {
+					logs.Println("Error consuming messages:", err)
+				}
+			}
+		}
+	}()
+
+	// Wait for a termination signal
+	<-signals
+
+	// Wait for the consumer group to finish
+	wg.Wait()
+
+}
+
+// ConsumerGroupHandler implements the sarama.ConsumerGroupHandler interface
+type ConsumerGroupHandler struct{}
+
+// Setup is called when the consumer group session is being set up
+func (h *ConsumerGroupHandler) Setup(session sarama.ConsumerGroupSession) error {
+	logs.Println("Consumer group session is being set up")
+	return nil
+}
+
+// Cleanup is called when the consumer group session is ending
+func (h *ConsumerGroupHandler) Cleanup(session sarama.ConsumerGroupSession) error {
+	logs.Println("Consumer group session is ending")
+	return nil
+}
+
+// ConsumeClaim is called when a new set of messages is claimed by the consumer group
+func (h *ConsumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for message := range claim.Messages() {
+		logs.Printf("Message received: Topic=%s, Partition=%d, Offset=%d, Key=%s, Value=%s\n",
+			message.Topic, message.Partition, message.Offset, string(message.Key), string(message.Value))
+
+		switch message.Topic {
+		case "private.mining.decision":
+			actuate(message)
+		case "private.inventory":
+			reloadInventory(message)
+		default:
+		}
+
+		// Mark the message as processed
+		session.MarkMessage(message, "")
+	}
+
+	return nil
+}
+
+// actuate brings a site's miners in line with a decision. Miners already
+// doing what the decision asks are left alone, and a miner is sent at most
+// one command per min_command_interval seconds; commands held back are sent
+// once the interval has passed.
+func actuate(msg *sarama.ConsumerMessage) {
+	// JSON data
+	jsonData := msg.Value
+
+	// Parse the JSON data into a Decision struct
+	var input decision.Decision
+	err := json.Unmarshal(jsonData, &input)
+	if err != nil {
+		logs.Println("Error parsing JSON:", err)
+		return
+	}
+
+	// Only sites we manage are of interest
+	site, ok := sites.Site(input.LocationID)
+	if !ok {
+		return
+	}
+
+	for _, ack := range miners.Apply(site, input, time.Now()) {
+		if ack.Status == actuator.StatusFailed {
+			logs.Println("Error sending command to miner:", ack.DeviceID, ack.Error)
+		}
+		publishAck(ack)
+	}
+}
+
+// reloadInventory takes the sites' devices from the inventory again after one
+// of them changed, so the next decision reaches miners added since startup
+// and leaves alone those that were removed.
+func reloadInventory(msg *sarama.ConsumerMessage) {
+	// JSON data
+	jsonData := msg.Value
+
+	// Parse the JSON data into an Event struct
+	var input inventory.Event
+	err := json.Unmarshal(jsonData, &input)
+	if err != nil {
+		logs.Println("Error parsing JSON:", err)
+		return
+	}
+	if !config.UseInventory {
+		return
+	}
+
+	// Only sites we manage are of interest
+	affected := sites.Affected(input)
+	if len(affected) == 0 {
+		return
+	}
+	err = sites.Reload(db)
+	if err != nil {
+		logs.Println("Error reloading inventory:", err)
+		return
+	}
+	logs.Println("Inventory event:", input.Action, input.Device.ID, affected)
+}
+
+func publishAck(ack actuator.Ack) {
+	ack.Timestamp = time.Now()
+
+	// Convert Ack struct to JSON
+	OutputJSON, err := json.Marshal(ack)
+	if err != nil {
+		logs.Println("Error marshaling miner ack data:", err)
+		return
+	}
+
+	// Print the response
+	logs.Println("[OUT]: " + string(OutputJSON))
+
+	// Send the response to Kafka topic, keyed by site
+	message := &sarama.ProducerMessage{
+		Topic: config.Ptopic,
+		Key:   sarama.StringEncoder(ack.LocationID),
+		Value: sarama.StringEncoder(OutputJSON),
+	}
+	_, _, err = producer.SendMessage(message)
+	if err != nil {
+		logs.Println("Error sending message to Kafka:", err)
+	}
+}
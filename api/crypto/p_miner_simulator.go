@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+
+	cgminer "profitmax/util/cgminer"
+	fleet "profitmax/util/fleet"
+	logger "profitmax/util/logger"
+)
+
+func main() {
+	args := os.Args
+
+	if len(args) < 2 {
+		fmt.Println("Usage: p_miner_simulator [Config File]", len(args))
+		fmt.Println("Example: p_miner_simulator p_miner_simulator.json")
+		return
+	}
+
+	// Read the JSON file
+	filePath := args[1]
+	fileData, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		log.Println("Error reading file:", err)
+		return
+	}
+
+	// Parse the JSON data into a struct
+	config := fleet.Config{}
+	err = json.Unmarshal(fileData, &config)
+	if err != nil {
+		log.Println("Error parsing JSON:", err)
+		return
+	}
+
+	// Create logs directory path
+	logsDir, err := logger.CreateLogsDirectory(config.LogPath)
+	if err != nil {
+		log.Fatal("Failed to create logs directory:", err)
+	}
+
+	// Create log file path based on the current date
+	logFilePath, err := logger.CreateLogFile(logsDir, config.LogFile)
+	if err != nil {
+		log.Fatal("Failed to create log file path:", err)
+	}
+
+	// Open the log file
+	logFile, err := os.OpenFile(logFilePath.Name(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Fatal("Failed to open log file:", err)
+	}
+	defer logFile.Close()
+
+	logs := log.New(logFile, "", log.LstdFlags)
+
+	// Start a simulated miner for every configured device
+	for _, site := range config.SiteList() {
+		for _, device := range site.Devices {
+			group, ok := site.Group(device.Model)
+			if !ok {
+				logs.Println("No fleet entry for device model:", device.ID, device.Model)
+				continue
+			}
+
+			listener, err := net.Listen("tcp", device.Address)
+			if err != nil {
+				logs.Fatal("Failed to listen for device:", device.ID, err)
+			}
+			defer listener.Close()
+
+			simulator := cgminer.NewSimulator(device.Model, group.HashrateTH, group.PowerW)
+			go simulator.Serve(listener)
+			logs.Println("Simulating miner:", site.LocationID, device.ID, device.Model, device.Address)
+		}
+	}
+
+	// Run until interrupted
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	<-signals
+}
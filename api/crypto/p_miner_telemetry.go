@@ -0,0 +1,222 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	cgminer "profitmax/util/cgminer"
+	fleet "profitmax/util/fleet"
+	inventory "profitmax/util/inventory"
+	logger "profitmax/util/logger"
+	telemetry "profitmax/util/telemetry"
+
+	"github.com/Shopify/sarama"
+	_ "github.com/go-sql-driver/mysql"
+)
+
+type DBConfig struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	User     string `json:"user"`
+	Password string `json:"password"`
+	Database string `json:"database"`
+}
+
+// Config is the service config with the sections only this service reads.
+type Config struct {
+	fleet.Config
+	SiteTopic      string `json:"site_topic"`
+	CommandTimeout int    `json:"command_timeout"`
+	UseInventory   bool   `json:"use_inventory"`
+}
+
+var logs *log.Logger
+var config Config
+var producer sarama.SyncProducer
+var db *sql.DB
+var sites *inventory.Fleet
+
+func main() {
+	args := os.Args
+
+	if len(args) < 2 {
+		fmt.Println("Usage: p_miner_telemetry [Config File]", len(args))
+		fmt.Println("Example: p_miner_telemetry p_miner_telemetry.json")
+		return
+	}
+
+	// Read the JSON file
+	filePath := args[1]
+	fileData, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		log.Println("Error reading file:", err)
+		return
+	}
+
+	// Parse the JSON data into a struct
+	config = Config{}
+	err = json.Unmarshal(fileData, &config)
+	if err != nil {
+		log.Println("Error parsing JSON:", err)
+		return
+	}
+
+	// Create logs directory path
+	logsDir, err := logger.CreateLogsDirectory(config.LogPath)
+	if err != nil {
+		log.Fatal("Failed to create logs directory:", err)
+	}
+
+	// Create log file path based on the current date
+	logFilePath, err := logger.CreateLogFile(logsDir, config.LogFile)
+	if err != nil {
+		log.Fatal("Failed to create log file path:", err)
+	}
+
+	// Open the log file
+	logFile, err := os.OpenFile(logFilePath.Name(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Fatal("Failed to open log file:", err)
+	}
+	defer logFile.Close()
+
+	logs = log.New(logFile, "", log.LstdFlags)
+
+	// Take the fleet from the inventory
+	sites = inventory.NewFleet(config.SiteList())
+	if config.UseInventory {
+		// Open a connection to the MySQL database
+		// Read the JSON file
+		dbFilePath := "dbconfig.json"
+		dbFileData, err := ioutil.ReadFile(dbFilePath)
+		if err != nil {
+			logs.Println("Error reading file:", err)
+			return
+		}
+		// Parse the JSON data into a struct
+		var dbConfig DBConfig
+		err = json.Unmarshal(dbFileData, &dbConfig)
+		if err != nil {
+			logs.Println("Error parsing JSON:", err)
+			return
+		}
+
+		// Create the MySQL connection string
+		dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s", dbConfig.User, dbConfig.Password, dbConfig.Host, dbConfig.Port, dbConfig.Database)
+
+		db, err = sql.Open("mysql", dsn)
+		if err != nil {
+			logs.Fatal("Error connecting to the database:", err)
+		}
+		defer db.Close()
+		err = sites.Reload(db)
+		if err != nil {
+			logs.Fatal("Error loading inventory:", err)
+		}
+	}
+
+	// Create a Kafka producer
+	producer, err = sarama.NewSyncProducer([]string{config.KafkaBroker}, nil)
+	if err != nil {
+		logs.Fatalln("Error creating Kafka producer:", config.KafkaBroker, err)
+		return
+	}
+	defer producer.Close()
+
+	// Create a ticker that ticks every x seconds
+	timeInterval := config.TimeInterval
+	ticker := time.NewTicker(time.Duration(timeInterval) * time.Second)
+
+	// Run the loop indefinitely
+	for range ticker.C {
+		// Pick up miners added to or removed from the inventory since the
+		// last poll
+		if config.UseInventory {
+			err = sites.Reload(db)
+			if err != nil {
+				logs.Println("Error reloading inventory:", err)
+			}
+		}
+		for _, site := range sites.List() {
+			pollSite(site)
+		}
+	}
+}
+
+// pollSite collects a sample from every miner of a site at once, then
+// publishes the samples and the site's totals.
+func pollSite(site fleet.Site) {
+	now := time.Now()
+	samples := make([]telemetry.Sample, len(site.Devices))
+
+	wg := sync.WaitGroup{}
+	for i, device := range site.Devices {
+		wg.Add(1)
+		go func(i int, device fleet.Device) {
+			defer wg.Done()
+			samples[i] = pollDevice(site, device, now)
+		}(i, device)
+	}
+	wg.Wait()
+
+	for _, sample := range samples {
+		publish(config.Ptopic, site.LocationID, sample)
+	}
+	publish(config.SiteTopic, site.LocationID, telemetry.Summarise(site.LocationID, samples, now))
+}
+
+func pollDevice(site fleet.Site, device fleet.Device, now time.Time) telemetry.Sample {
+	timeout := time.Duration(config.CommandTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	client := cgminer.NewClient(device.Address, timeout)
+
+	offline := telemetry.Sample{
+		LocationID: site.LocationID,
+		DeviceID:   device.ID,
+		Model:      device.Model,
+		Timestamp:  now,
+	}
+	summary, err := client.Summary()
+	if err != nil {
+		logs.Println("Error polling miner summary:", device.ID, err)
+		offline.Error = err.Error()
+		return offline
+	}
+	stats, err := client.Stats()
+	if err != nil {
+		// Summary alone is still worth keeping
+		logs.Println("Error polling miner stats:", device.ID, err)
+	}
+	return telemetry.NewSample(site, device, summary, stats, now)
+}
+
+func publish(topic string, locationID string, data interface{}) {
+	// Convert struct to JSON
+	OutputJSON, err := json.Marshal(data)
+	if err != nil {
+		logs.Println("Error marshaling telemetry data:", err)
+		return
+	}
+
+	// Print the response
+	logs.Println("[OUT]: " + string(OutputJSON))
+
+	// Send the response to Kafka topic, keyed by site
+	message := &sarama.ProducerMessage{
+		Topic: topic,
+		Key:   sarama.StringEncoder(locationID),
+		Value: sarama.StringEncoder(OutputJSON),
+	}
+	_, _, err = producer.SendMessage(message)
+	if err != nil {
+		logs.Println("Error sending message to Kafka:", err)
+	}
+}
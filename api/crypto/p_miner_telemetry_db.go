@@ -0,0 +1,307 @@
+package main
+
+/*
+CREATE TABLE tbl_miner_telemetry_device (
+    device_id VARCHAR(40) NOT NULL,
+    bucket_time DATETIME NOT NULL,
+    location_id VARCHAR(10) NOT NULL,
+    model VARCHAR(40) NOT NULL,
+    samples INT NOT NULL,
+    online_samples INT NOT NULL,
+    hashrate_th DECIMAL(18, 6) NOT NULL,
+    power_w DECIMAL(18, 3) NOT NULL,
+    chip_temp_max DECIMAL(6, 2) NOT NULL,
+    accepted BIGINT NOT NULL,
+    rejected BIGINT NOT NULL,
+    hardware_errors BIGINT NOT NULL,
+    PRIMARY KEY (device_id, bucket_time),
+    INDEX (location_id, bucket_time)
+);
+
+CREATE TABLE tbl_miner_telemetry_site (
+    location_id VARCHAR(10) NOT NULL,
+    bucket_time DATETIME NOT NULL,
+    samples INT NOT NULL,
+    devices INT NOT NULL,
+    online DECIMAL(10, 3) NOT NULL,
+    hashing DECIMAL(10, 3) NOT NULL,
+    hashrate_th DECIMAL(18, 6) NOT NULL,
+    power_w DECIMAL(18, 3) NOT NULL,
+    PRIMARY KEY (location_id, bucket_time)
+);
+*/
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	common "profitmax/util/common"
+	logger "profitmax/util/logger"
+	telemetry "profitmax/util/telemetry"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	_ "github.com/go-sql-driver/mysql"
+)
+
+type DBConfig struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	User     string `json:"user"`
+	Password string `json:"password"`
+	Database string `json:"database"`
+}
+
+var logs *log.Logger
+var config common.Config
+var db *sql.DB
+var consumer sarama.Consumer
+
+func main() {
+	args := os.Args
+
+	if len(args) < 2 {
+		fmt.Println("Usage: p_miner_telemetry_db [Config File]", len(args))
+		fmt.Println("Example: p_miner_telemetry_db p_miner_telemetry_db.json")
+		return
+	}
+
+	// Read the JSON file
+	filePath := args[1]
+	fileData, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		fmt.Println("Error reading file:", err)
+		return
+	}
+
+	// Parse the JSON data into a struct
+	config = common.Config{}
+	err = json.Unmarshal(fileData, &config)
+	if err != nil {
+		fmt.Println("Error parsing JSON:", err)
+		return
+	}
+	// Create logs directory path
+	logsDir, err := logger.CreateLogsDirectory(config.LogPath)
+	if err != nil {
+		log.Fatal("Failed to create logs directory:", err)
+	}
+
+	// Create log file path based on the current date
+	logFilePath, err := logger.CreateLogFile(logsDir, config.LogFile)
+	if err != nil {
+		log.Fatal("Failed to create log file path:", err)
+	}
+
+	// Open the log file
+	logFile, err := os.OpenFile(logFilePath.Name(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Fatal("Failed to open log file:", err)
+	}
+	defer logFile.Close()
+
+	logs = log.New(logFile, "", log.LstdFlags)
+
+	// Configure the Kafka consumer
+	conf := sarama.NewConfig()
+	conf.Consumer.Return.Errors = true
+
+	// Kafka consumer group
+	group := "miner_telemetry_db"
+
+	// Create a new consumer
+	consumer, err := sarama.NewConsumerGroup([]string{config.KafkaBroker}, group, nil)
+	if err != nil {
+		logs.Fatal("Failed to create Kafka consumer:", err)
+	}
+	defer consumer.Close()
+
+	// Open a connection to the MySQL database
+	// Read the JSON file
+	dbFilePath := "dbconfig.json"
+	dbFileData, err := ioutil.ReadFile(dbFilePath)
+	if err != nil {
+		logs.Println("Error reading file:", err)
+		return
+	}
+	// Parse the JSON data into a struct
+	var dbConfig DBConfig
+	err = json.Unmarshal(dbFileData, &dbConfig)
+	if err != nil {
+		logs.Println("Error parsing JSON:", err)
+		return
+	}
+
+	// Create the MySQL connection string
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s", dbConfig.User, dbConfig.Password, dbConfig.Host, dbConfig.Port, dbConfig.Database)
+
+	db, err = sql.Open("mysql", dsn)
+	if err != nil {
+		logs.Fatal("Error connecting to the database:", err)
+	}
+	defer db.Close()
+
+	// Specify the topics you want to consume from
+	topics := config.Topics
+	// Create a context for the consumer group
+	ctx := context.Background()
+
+	// Create a signal channel to handle termination
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+
+	// Create a wait group to wait for the consumer group to finish
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+
+	// Start consuming messages in a separate goroutine
+	go func() {
+		defer wg.Done()
+
+		for {
+			select {
+			case <-signals:
+				// Interrupt signal received, stop consuming
+				consumer.Close()
+				return
+
+			default:
+				// Consume messages
+				err := consumer.Consume(ctx, topics, &ConsumerGroupHandler{})
+				if err != nil {
+					logs.Println("Error consuming messages:", err)
+				}
+			}
+		}
+	}()
+
+	// Wait for a termination signal
+	<-signals
+
+	// Wait for the consumer group to finish
+	wg.Wait()
+
+}
+
+// ConsumerGroupHandler implements the sarama.ConsumerGroupHandler interface
+type ConsumerGroupHandler struct{}
+
+// Setup is called when the consumer group session is being set up
+func (h *ConsumerGroupHandler) Setup(session sarama.ConsumerGroupSession) error {
+	logs.Println("Consumer group session is being set up")
+	return nil
+}
+
+// Cleanup is called when the consumer group session is ending
+func (h *ConsumerGroupHandler) Cleanup(session sarama.ConsumerGroupSession) error {
+	logs.Println("Consumer group session is ending")
+	return nil
+}
+
+// ConsumeClaim is called when a new set of messages is claimed by the consumer group
+func (h *ConsumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for message := range claim.Messages() {
+		logs.Printf("Message received: Topic=%s, Partition=%d, Offset=%d, Key=%s, Value=%s\n",
+			message.Topic, message.Partition, message.Offset, string(message.Key), string(message.Value))
+
+		switch message.Topic {
+		case "public.miner.telemetry":
+			insertDeviceTable(message)
+		case "public.miner.telemetry.site":
+			insertSiteTable(message)
+		default:
+		}
+
+		// Mark the message as processed
+		session.MarkMessage(message, "")
+	}
+
+	return nil
+}
+
+// bucket returns the start of the roll-up period t falls in.
+func bucket(t time.Time) time.Time {
+	return t.Truncate(time.Duration(config.TimeInterval) * time.Second)
+}
+
+// insertDeviceTable folds a miner sample into its device's roll-up. Readings
+// are averaged over the samples of the period, chip temperature keeps its
+// maximum and share counters keep their latest value.
+func insertDeviceTable(msg *sarama.ConsumerMessage) {
+	// JSON data
+	jsonData := msg.Value
+
+	// Parse the JSON data into a Sample struct
+	var input telemetry.Sample
+	err := json.Unmarshal(jsonData, &input)
+	if err != nil {
+		logs.Println("Error parsing JSON:", err)
+		return
+	}
+
+	online := 0
+	if input.Online {
+		online = 1
+	}
+
+	// Insert the data into the table
+	insertData := `INSERT INTO tbl_miner_telemetry_device (device_id, bucket_time, location_id, model, samples, online_samples, hashrate_th, power_w, chip_temp_max, accepted, rejected, hardware_errors)
+		VALUES (?, ?, ?, ?, 1, ?, ?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+		hashrate_th = (hashrate_th * samples + VALUES(hashrate_th)) / (samples + 1),
+		power_w = (power_w * samples + VALUES(power_w)) / (samples + 1),
+		chip_temp_max = GREATEST(chip_temp_max, VALUES(chip_temp_max)),
+		accepted = GREATEST(accepted, VALUES(accepted)),
+		rejected = GREATEST(rejected, VALUES(rejected)),
+		hardware_errors = GREATEST(hardware_errors, VALUES(hardware_errors)),
+		online_samples = online_samples + VALUES(online_samples),
+		samples = samples + 1`
+	_, err = db.Exec(insertData, input.DeviceID, bucket(input.Timestamp), input.LocationID, input.Model, online,
+		input.HashrateTH, input.PowerW, input.ChipTempMax, input.Accepted, input.Rejected, input.HardwareErrors)
+	if err != nil {
+		logs.Println("Error inserting data into table:", err)
+		return
+	}
+
+	logs.Printf("tbl_miner_telemetry_device: Data inserted successfully!")
+}
+
+// insertSiteTable folds a site's totals into its roll-up, averaging them
+// over the polls of the period.
+func insertSiteTable(msg *sarama.ConsumerMessage) {
+	// JSON data
+	jsonData := msg.Value
+
+	// Parse the JSON data into a SiteSummary struct
+	var input telemetry.SiteSummary
+	err := json.Unmarshal(jsonData, &input)
+	if err != nil {
+		logs.Println("Error parsing JSON:", err)
+		return
+	}
+
+	// Insert the data into the table
+	insertData := `INSERT INTO tbl_miner_telemetry_site (location_id, bucket_time, samples, devices, online, hashing, hashrate_th, power_w)
+		VALUES (?, ?, 1, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+		devices = GREATEST(devices, VALUES(devices)),
+		online = (online * samples + VALUES(online)) / (samples + 1),
+		hashing = (hashing * samples + VALUES(hashing)) / (samples + 1),
+		hashrate_th = (hashrate_th * samples + VALUES(hashrate_th)) / (samples + 1),
+		power_w = (power_w * samples + VALUES(power_w)) / (samples + 1),
+		samples = samples + 1`
+	_, err = db.Exec(insertData, input.LocationID, bucket(input.Timestamp), input.Devices, input.Online,
+		input.Hashing, input.HashrateTH, input.PowerW)
+	if err != nil {
+		logs.Println("Error inserting data into table:", err)
+		return
+	}
+
+	logs.Printf("tbl_miner_telemetry_site: Data inserted successfully!")
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	common "profitmax/util/common"
+	costmodel "profitmax/util/costmodel"
+	logger "profitmax/util/logger"
+
+	"github.com/Shopify/sarama"
+	_ "github.com/go-sql-driver/mysql"
+)
+
+type DBConfig struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	User     string `json:"user"`
+	Password string `json:"password"`
+	Database string `json:"database"`
+}
+
+var logs *log.Logger
+var config common.Config
+var db *sql.DB
+
+func usage() {
+	fmt.Println("Usage: p_mining_cost_admin [Config File] list [Location ID]")
+	fmt.Println("       p_mining_cost_admin [Config File] set [-id ID] -location ID -code CODE -type CAPEX|FIXED|VARIABLE|HOSTING -currency CCY -amount N -from YYYY-MM-DD [-to YYYY-MM-DD] [-purchase YYYY-MM-DD -lifetime MONTHS -salvage N]")
+	fmt.Println("       p_mining_cost_admin [Config File] delete [Cost Item ID]")
+	fmt.Println("Example: p_mining_cost_admin p_mining_cost_admin.json set -location VIC1 -code HOSTING -type HOSTING -currency AUD -amount 1.8 -from 2023-07-01")
+}
+
+func main() {
+	args := os.Args
+
+	if len(args) < 3 {
+		usage()
+		return
+	}
+
+	// Read the JSON file
+	filePath := args[1]
+	fileData, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		log.Println("Error reading file:", err)
+		return
+	}
+
+	// Parse the JSON data into a struct
+	config = common.Config{}
+	err = json.Unmarshal(fileData, &config)
+	if err != nil {
+		log.Println("Error parsing JSON:", err)
+		return
+	}
+	// Create logs directory path
+	logsDir, err := logger.CreateLogsDirectory(config.LogPath)
+	if err != nil {
+		log.Fatal("Failed to create logs directory:", err)
+	}
+
+	// Create log file path based on the current date
+	logFilePath, err := logger.CreateLogFile(logsDir, config.LogFile)
+	if err != nil {
+		log.Fatal("Failed to create log file path:", err)
+	}
+
+	// Open the log file
+	logFile, err := os.OpenFile(logFilePath.Name(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Fatal("Failed to open log file:", err)
+	}
+	defer logFile.Close()
+
+	logs = log.New(logFile, "", log.LstdFlags)
+
+	// Open a connection to the MySQL database
+	// Read the JSON file
+	dbFilePath := "dbconfig.json"
+	dbFileData, err := ioutil.ReadFile(dbFilePath)
+	if err != nil {
+		logs.Println("Error reading file:", err)
+		return
+	}
+	// Parse the JSON data into a struct
+	var dbConfig DBConfig
+	err = json.Unmarshal(dbFileData, &dbConfig)
+	if err != nil {
+		logs.Println("Error parsing JSON:", err)
+		return
+	}
+
+	// Create the MySQL connection string
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s", dbConfig.User, dbConfig.Password, dbConfig.Host, dbConfig.Port, dbConfig.Database)
+
+	db, err = sql.Open("mysql", dsn)
+	if err != nil {
+		logs.Fatal("Error connecting to the database:", err)
+	}
+	defer db.Close()
+
+	switch args[2] {
+	case "list":
+		if len(args) < 4 {
+			usage()
+			return
+		}
+		err = listItems(args[3])
+	case "set":
+		err = setItem(args[3:])
+	case "delete":
+		if len(args) < 4 {
+			usage()
+			return
+		}
+		err = deleteItem(args[3])
+	default:
+		usage()
+		return
+	}
+	if err != nil {
+		logs.Println("Error:", err)
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+}
+
+func listItems(locationID string) error {
+	items, err := costmodel.LoadItems(db, locationID)
+	if err != nil {
+		fmt.Println("Warning:", err)
+	}
+	for _, item := range items {
+		itemJSON, err := json.Marshal(item)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(itemJSON))
+	}
+	return nil
+}
+
+func setItem(args []string) error {
+	var item costmodel.Item
+	var itemType, from, to, purchase string
+
+	flags := flag.NewFlagSet("set", flag.ContinueOnError)
+	flags.Int64Var(&item.ID, "id", 0, "cost item to update (0 adds a new item)")
+	flags.StringVar(&item.LocationID, "location", "", "site location ID")
+	flags.StringVar(&item.CostCode, "code", "", "cost code")
+	flags.StringVar(&itemType, "type", "", "CAPEX, FIXED, VARIABLE or HOSTING")
+	flags.StringVar(&item.Currency, "currency", "", "currency code")
+	flags.Float64Var(&item.Amount, "amount", 0, "purchase cost, monthly charge, charge per MWh or monthly charge per TH/s")
+	flags.StringVar(&from, "from", time.Now().Format("2006-01-02"), "effective from date")
+	flags.StringVar(&to, "to", "", "effective to date (exclusive)")
+	flags.StringVar(&purchase, "purchase", "", "capex purchase date")
+	flags.IntVar(&item.LifetimeMonths, "lifetime", 0, "capex lifetime in months")
+	flags.Float64Var(&item.Salvage, "salvage", 0, "capex salvage value")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if item.LocationID == "" || item.CostCode == "" {
+		return fmt.Errorf("location and code are required")
+	}
+	item.Type = costmodel.ItemType(itemType)
+
+	dates := []struct {
+		value  string
+		target *time.Time
+	}{{from, &item.EffectiveFrom}, {to, &item.EffectiveTo}, {purchase, &item.PurchaseDate}}
+	for _, date := range dates {
+		if date.value == "" {
+			continue
+		}
+		*date.target, err = time.ParseInLocation("2006-01-02", date.value, time.Local)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = costmodel.SaveItem(db, &item)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Saved cost item:", item.ID)
+	return publishEvent(costmodel.ActionUpsert, item)
+}
+
+func deleteItem(value string) error {
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return err
+	}
+	item, err := costmodel.DeleteItem(db, id)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Deleted cost item:", item.ID)
+	return publishEvent(costmodel.ActionDelete, item)
+}
+
+// publishEvent tells the cost calculators to recompute the item's site now.
+func publishEvent(action string, item costmodel.Item) error {
+	// Create a Kafka producer
+	producer, err := sarama.NewSyncProducer([]string{config.KafkaBroker}, nil)
+	if err != nil {
+		return err
+	}
+	defer producer.Close()
+
+	event := costmodel.ItemEvent{
+		Action:    action,
+		Item:      item,
+		Timestamp: time.Now(),
+	}
+
+	// Convert ItemEvent struct to JSON
+	OutputJSON, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	// Print the response
+	logs.Println("[OUT]: " + string(OutputJSON))
+
+	// Send the response to Kafka topic, keyed by site
+	message := &sarama.ProducerMessage{
+		Topic: config.Ptopic,
+		Key:   sarama.StringEncoder(item.LocationID),
+		Value: sarama.StringEncoder(OutputJSON),
+	}
+	_, _, err = producer.SendMessage(message)
+	return err
+}
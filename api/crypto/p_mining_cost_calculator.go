@@ -9,9 +9,18 @@ import (
 	"log"
 	"os"
 	"os/signal"
-	common "profitmax/util/common"
+	calculator "profitmax/util/calculator"
+	costmodel "profitmax/util/costmodel"
+	economics "profitmax/util/economics"
+	fleet "profitmax/util/fleet"
+	fx "profitmax/util/fx"
+	inventory "profitmax/util/inventory"
 	logger "profitmax/util/logger"
+	quality "profitmax/util/quality"
+	state "profitmax/util/state"
+	telemetry "profitmax/util/telemetry"
 	"sync"
+	"time"
 
 	"github.com/Shopify/sarama"
 	_ "github.com/go-sql-driver/mysql"
@@ -32,24 +41,53 @@ type InputData struct {
 }
 
 type CurrentEnergyCost struct {
-	Symbol      string  `json:"symbol"`
-	Difficulty  int64   `json:"difficulty"`
-	EnergyPrice float64 `json:"energy_price"`
-	EnergyCost  float64 `json:"energy_cost"`
+	LocationID  string                `json:"location_id"`
+	Currency    string                `json:"currency"`
+	Symbol      string                `json:"symbol"`
+	Difficulty  int64                 `json:"difficulty"`
+	EnergyPrice economics.PricePerMWh `json:"energy_price"`
+	EnergyCost  economics.Amount      `json:"energy_cost"`
+	Status      string                `json:"status"`
+	EventTimes  quality.Times         `json:"event_times"`
 }
 
 type CurrentCost struct {
-	LocaionID  string  `json:"location_id"`
-	EnergyCost float64 `json:"energy_cost"`
-	OtherCost  float64 `json:"other_cost"`
-	TotalCost  float64 `json:"total_cost"`
+	LocaionID   string                `json:"location_id"`
+	Currency    string                `json:"currency"`
+	EnergyPrice economics.PricePerMWh `json:"energy_price"`
+	EnergyCost  economics.Amount      `json:"energy_cost"`
+	OtherCost   economics.Amount      `json:"other_cost"`
+	TotalCost   economics.Amount      `json:"total_cost"`
+	Horizon     economics.Horizon     `json:"horizon"`
+	Status      string                `json:"status"`
+	Missing     []string              `json:"missing,omitempty"`
+	EventTimes  quality.Times         `json:"event_times"`
+	Quality     string                `json:"quality"`
+	Stale       []string              `json:"stale,omitempty"`
+}
+
+// serviceName keys this service's snapshots in tbl_service_state.
+const serviceName = "p_mining_cost_calculator"
+
+// Config is the service config with the sections only this service reads.
+type Config struct {
+	fleet.Config
+	PollInterval    int            `json:"poll_interval"`
+	TelemetryMaxAge int            `json:"telemetry_max_age"`
+	UseInventory    bool           `json:"use_inventory"`
+	TTLs            map[string]int `json:"ttl"`
 }
 
 var logs *log.Logger
-var config common.Config
+var config Config
 var db *sql.DB
 var consumer sarama.Consumer
-var currentCost CurrentCost
+var currentCosts map[string]*CurrentCost
+var currentCostsMutex sync.Mutex
+var costItemVersions = make(map[string]string)
+var converter *fx.Converter
+var siteTelemetry = make(map[string]telemetry.SiteSummary)
+var sites *inventory.Fleet
 var producer sarama.SyncProducer
 
 func main() {
@@ -70,7 +108,7 @@ func main() {
 	}
 
 	// Parse the JSON data into a struct
-	config = common.Config{}
+	config = Config{}
 	err = json.Unmarshal(fileData, &config)
 	if err != nil {
 		log.Println("Error parsing JSON:", err)
@@ -144,14 +182,112 @@ func main() {
 	}
 	defer db.Close()
 
-	otherCost := getOtherCost(config.LocationID)
-	energyCost := getEnergyCost(config.LocationID)
+	// Take the fleet from the inventory
+	sites = inventory.NewFleet(config.SiteList())
+	if config.UseInventory {
+		err = sites.Reload(db)
+		if err != nil {
+			logs.Fatal("Error loading inventory:", err)
+		}
+	}
+
+	// Load the latest fx rates to restate costs in site currency
+	converter = fx.NewConverter()
+	err = fx.LoadRates(db, converter)
+	if err != nil {
+		logs.Println("Error loading fx rates:", err)
+	}
+
+	currentCosts = make(map[string]*CurrentCost)
+	for _, site := range sites.List() {
+		if site.Currency == "" {
+			logs.Fatal("No currency configured for site:", site.LocationID)
+		}
+		costItemVersions[site.LocationID], _ = costmodel.Version(db, site.LocationID)
+		otherCost, otherErr := calculateOtherCost(site)
+		if otherErr != nil {
+			logs.Println("Error calculating other cost:", site.LocationID, otherErr)
+		}
+		energyCost, costed := getEnergyCost(site)
+
+		// The energy price is only ever held in memory, so take it from the
+		// last snapshot, along with the energy cost if it was never stored
+		var energyPrice economics.PricePerMWh
+		var snapshot CurrentCost
+		found, err := state.Load(db, serviceName, site.LocationID, &snapshot)
+		if err != nil {
+			logs.Println("Error restoring state:", err)
+		}
+		if found && snapshot.Currency == site.Currency {
+			energyPrice = snapshot.EnergyPrice
+			if !costed && !state.Lacks(snapshot.Missing, "energy_cost") {
+				energyCost = snapshot.EnergyCost
+				costed = true
+			}
+		}
+
+		var missing []string
+		if !costed {
+			missing = append(missing, "energy_cost")
+		}
+		if otherErr != nil {
+			missing = append(missing, "other_cost")
+		}
+
+		currentCosts[site.LocationID] = &CurrentCost{
+			site.LocationID,
+			site.Currency,
+			energyPrice,
+			energyCost,
+			otherCost,
+			economics.Sum(energyCost, otherCost),
+			economics.PerBlock,
+			"",
+			missing,
+			make(quality.Times),
+			"",
+			nil,
+		}
+		currentCosts[site.LocationID].EventTimes.Merge(snapshot.EventTimes)
+
+		// Let the decision maker start from the restored cost
+		publishCost(currentCosts[site.LocationID])
+	}
+
+	// Recompute the other costs on a schedule so amortisation, effective
+	// dates and edited cost items are picked up
+	if config.TimeInterval > 0 {
+		go func() {
+			ticker := time.NewTicker(time.Duration(config.TimeInterval) * time.Second)
+			for range ticker.C {
+				for _, site := range sites.List() {
+					updateOtherCost(site)
+				}
+			}
+		}()
+	}
 
-	currentCost = CurrentCost{
-		config.LocationID,
-		energyCost,
-		otherCost,
-		energyCost + otherCost,
+	// Watch the cost items for edits made without a cost item event
+	if config.PollInterval > 0 {
+		go func() {
+			ticker := time.NewTicker(time.Duration(config.PollInterval) * time.Second)
+			for range ticker.C {
+				for _, site := range sites.List() {
+					version, err := costmodel.Version(db, site.LocationID)
+					if err != nil {
+						logs.Println("Error polling cost items:", site.LocationID, err)
+						continue
+					}
+					currentCostsMutex.Lock()
+					known := costItemVersions[site.LocationID]
+					currentCostsMutex.Unlock()
+					if version != known {
+						logs.Println("Cost items changed:", site.LocationID, version)
+						updateOtherCost(site)
+					}
+				}
+			}
+		}()
 	}
 
 	// Specify the topics you want to consume from
@@ -196,90 +332,137 @@ func main() {
 
 }
 
-func getOtherCost(location_id string) float64 {
-	// Prepare the SELECT statement with placeholders for the key values
-	stmt, err := db.Prepare("SELECT IFNULL(SUM(price), 0) FROM tbl_mining_cost_current WHERE cost_code <> 'ENERGY' and location_id=?")
+// calculateOtherCost returns the non-energy cost of one block interval at a
+// site from its cost items, and records each cost code's share in
+// tbl_mining_cost_current. It fails, recording nothing, when a cost item
+// cannot be loaded or costed, such as when its fx rate is unknown.
+func calculateOtherCost(site fleet.Site) (economics.Amount, error) {
+	items, err := costmodel.LoadItems(db, site.LocationID)
 	if err != nil {
-		logs.Println(err)
-		return 0
+		return 0, err
 	}
-	defer stmt.Close()
 
-	// Execute the SELECT statement with the key values
-	rows, err := stmt.Query(location_id)
+	// What the fleet consumes and deploys over one block interval
+	site = measuredSite(site)
+	usage := calculator.Usage(site, economics.BlockInterval)
+
+	costs, err := costmodel.PeriodCosts(items, time.Now(), economics.BlockInterval, usage, site.Currency, converter)
 	if err != nil {
-		logs.Println(err)
-		return 0
+		return 0, err
 	}
-	defer rows.Close()
 
-	// Check if there is any data available for the specified key values
-	if !rows.Next() {
-		logs.Printf("No data found for lcoation_id: %s\n", location_id)
-		return 0
+	var other_cost economics.Amount
+	for cost_code, cost := range costs {
+		insertTable(site.LocationID, cost_code, site.Currency, cost)
+		other_cost += cost
 	}
+	deleteRemovedCosts(site.LocationID, costs)
 
-	// Retrieve the result
-	var other_cost float64
+	logs.Printf("Location ID: %s, Other Cost: %.5f %s\n", site.LocationID, other_cost, site.Currency)
+	return other_cost, nil
+}
 
-	err = rows.Scan(&other_cost)
+// updateOtherCost recomputes the other cost of a site and republishes its
+// cost when it changed. A cost that cannot be calculated keeps the last good
+// value, or leaves the cost warming up when there never was one.
+func updateOtherCost(site fleet.Site) {
+	currentCostsMutex.Lock()
+	defer currentCostsMutex.Unlock()
+
+	costItemVersions[site.LocationID], _ = costmodel.Version(db, site.LocationID)
+	otherCost, err := calculateOtherCost(site)
 	if err != nil {
-		logs.Println(err)
-		return 0
+		logs.Println("Error calculating other cost:", site.LocationID, err)
+		return
 	}
 
-	logs.Printf("Location ID: %s, Other Cost: %.2f\n", location_id, other_cost)
+	currentCost := currentCosts[site.LocationID]
+	missing := len(currentCost.Missing)
+	currentCost.Missing = state.Known(currentCost.Missing, "other_cost")
+	if currentCost.OtherCost == otherCost && len(currentCost.Missing) == missing {
+		return
+	}
+	currentCost.OtherCost = otherCost
+	currentCost.TotalCost = economics.Sum(currentCost.EnergyCost, otherCost)
 
-	// Check for any errors during iteration
-	err = rows.Err()
+	publishCost(currentCost)
+}
+
+// deleteRemovedCosts drops the cost codes of a site that no longer have any
+// active cost item.
+func deleteRemovedCosts(location_id string, costs map[string]economics.Amount) {
+	deleteData := "DELETE FROM tbl_mining_cost_current WHERE location_id = ? AND cost_code <> 'ENERGY'"
+	args := []interface{}{location_id}
+	for cost_code := range costs {
+		deleteData += " AND cost_code <> ?"
+		args = append(args, cost_code)
+	}
+	_, err := db.Exec(deleteData, args...)
 	if err != nil {
-		logs.Println(err)
-		return 0
+		logs.Println("Error deleting data from table:", err)
+	}
+}
+
+func insertTable(location_id string, cost_code string, currency_code string, cost economics.Amount) {
+	// Insert the current data into the table
+	insertCurrentData := "INSERT INTO tbl_mining_cost_current (location_id, cost_code, currency_code, price, last_updated) VALUES (?, ?, ?, ?, now()) ON DUPLICATE KEY UPDATE price = ?, last_updated = now()"
+	_, err := db.Exec(insertCurrentData, location_id, cost_code, currency_code, float64(cost), float64(cost))
+	if err != nil {
+		logs.Println("Error inserting data into table:", err)
 	}
-	return other_cost
 }
 
-func getEnergyCost(location_id string) float64 {
+// getEnergyCost returns the last stored energy cost of a site in its
+// currency. It is false when there is none.
+func getEnergyCost(site fleet.Site) (economics.Amount, bool) {
 	// Prepare the SELECT statement with placeholders for the key values
-	stmt, err := db.Prepare("SELECT price FROM tbl_mining_cost_current WHERE cost_code = 'ENERGY' and location_id=?")
+	stmt, err := db.Prepare("SELECT currency_code, price FROM tbl_mining_cost_current WHERE cost_code = 'ENERGY' and location_id=?")
 	if err != nil {
 		logs.Println(err)
-		return 0
+		return 0, false
 	}
 	defer stmt.Close()
 
 	// Execute the SELECT statement with the key values
-	rows, err := stmt.Query(location_id)
+	rows, err := stmt.Query(site.LocationID)
 	if err != nil {
 		logs.Println(err)
-		return 0
+		return 0, false
 	}
 	defer rows.Close()
 
 	// Check if there is any data available for the specified key values
 	if !rows.Next() {
-		logs.Printf("No data found for lcoation_id: %s\n", location_id)
-		return 0
+		logs.Printf("No data found for lcoation_id: %s\n", site.LocationID)
+		return 0, false
 	}
 
 	// Retrieve the result
+	var currency_code string
 	var energy_cost float64
 
-	err = rows.Scan(&energy_cost)
+	err = rows.Scan(&currency_code, &energy_cost)
 	if err != nil {
 		logs.Println(err)
-		return 0
+		return 0, false
 	}
 
-	logs.Printf("Location ID: %s, Energy Cost: %.2f\n", location_id, energy_cost)
+	logs.Printf("Location ID: %s, Energy Cost: %.2f %s\n", site.LocationID, energy_cost, currency_code)
 
 	// Check for any errors during iteration
 	err = rows.Err()
 	if err != nil {
 		logs.Println(err)
-		return 0
+		return 0, false
+	}
+
+	// Restate the cost in the site's currency
+	cost, err := fx.Convert(converter, economics.Amount(energy_cost), currency_code, site.Currency)
+	if err != nil {
+		logs.Println(err)
+		return 0, false
 	}
-	return energy_cost
+	return cost, true
 }
 
 // ConsumerGroupHandler implements the sarama.ConsumerGroupHandler interface
@@ -317,30 +500,121 @@ func (h *ConsumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession,
 				continue
 			}
 
+			// Only sites we manage are of interest
+			currentCost, ok := currentCosts[input.LocationID]
+			if !ok {
+				continue
+			}
+
+			// Restate the cost in the site's currency
+			energyCost, err := fx.Convert(converter, input.EnergyCost, input.Currency, currentCost.Currency)
+			if err != nil {
+				logs.Println("Error converting energy cost:", err)
+				continue
+			}
+			energyPrice, err := fx.Convert(converter, input.EnergyPrice, input.Currency, currentCost.Currency)
+			if err != nil {
+				logs.Println("Error converting energy price:", err)
+				continue
+			}
+
 			// Create the OutputData struct
-			currentCost.EnergyCost = input.EnergyCost
-			currentCost.TotalCost = currentCost.OtherCost + input.EnergyCost
+			currentCostsMutex.Lock()
+			currentCost.EnergyPrice = energyPrice
+			currentCost.EnergyCost = energyCost
+			if input.Status != state.WarmingUp {
+				currentCost.Missing = state.Known(currentCost.Missing, "energy_cost")
+			}
+			currentCost.EventTimes.Merge(input.EventTimes)
+			currentCost.TotalCost = economics.Sum(currentCost.OtherCost, energyCost)
+
+			publishCost(currentCost)
+			currentCostsMutex.Unlock()
+		case "private.mining.costitems":
+			//
+			// JSON data
+			jsonData := message.Value
+
+			// Parse the JSON data into an ItemEvent struct
+			var input costmodel.ItemEvent
+			err := json.Unmarshal(jsonData, &input)
+			if err != nil {
+				logs.Println("Error parsing JSON:", err)
+				continue
+			}
+
+			// Only sites we manage are of interest
+			site, ok := sites.Site(input.Item.LocationID)
+			if !ok {
+				continue
+			}
+
+			logs.Println("Cost item event:", input.Action, input.Item.ID, site.LocationID)
+			updateOtherCost(site)
+		case "public.miner.telemetry.site":
+			//
+			// JSON data
+			jsonData := message.Value
+
+			// Parse the JSON data into a SiteSummary struct
+			var input telemetry.SiteSummary
+			err := json.Unmarshal(jsonData, &input)
+			if err != nil {
+				logs.Println("Error parsing JSON:", err)
+				continue
+			}
+
+			// Picked up by the next scheduled recompute
+			currentCostsMutex.Lock()
+			siteTelemetry[input.LocationID] = input
+			currentCostsMutex.Unlock()
+		case "public.fxrate":
+			//
+			// JSON data
+			jsonData := message.Value
 
-			// Convert OutputData struct to JSON
-			OutputJSON, err := json.Marshal(currentCost)
+			// Parse the JSON data into a Rate struct
+			var input fx.Rate
+			err := json.Unmarshal(jsonData, &input)
 			if err != nil {
-				logs.Println("Error marshaling mining cost data:", err)
+				logs.Println("Error parsing JSON:", err)
 				continue
 			}
 
-			// Print the response
-			logs.Println("[OUT]: " + string(OutputJSON))
+			converter.Set(input)
+		case "private.inventory":
+			//
+			// JSON data
+			jsonData := message.Value
+
+			// Parse the JSON data into an Event struct
+			var input inventory.Event
+			err := json.Unmarshal(jsonData, &input)
+			if err != nil {
+				logs.Println("Error parsing JSON:", err)
+				continue
+			}
+			if !config.UseInventory {
+				continue
+			}
 
-			// Send the response to Kafka topic
-			message := &sarama.ProducerMessage{
-				Topic: config.Ptopic,
-				Value: sarama.StringEncoder(OutputJSON),
+			// Only sites we manage are of interest
+			affected := sites.Affected(input)
+			if len(affected) == 0 {
+				continue
 			}
-			_, _, err = producer.SendMessage(message)
+			err = sites.Reload(db)
 			if err != nil {
-				logs.Println("Error sending message to Kafka:", err)
+				logs.Println("Error reloading inventory:", err)
 				continue
 			}
+
+			// The sites' capacity may have changed their other cost
+			logs.Println("Inventory event:", input.Action, input.Device.ID, affected)
+			for _, locationID := range affected {
+				site, _ := sites.Site(locationID)
+				updateOtherCost(site)
+			}
 		default:
 		}
 
@@ -350,3 +624,49 @@ func (h *ConsumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession,
 
 	return nil
 }
+
+// publishCost publishes a site's cost, flagged as warming up while an input
+// is missing and as stale while an input is past its TTL, and snapshots it.
+func publishCost(currentCost *CurrentCost) {
+	currentCost.Status = state.Of(currentCost.Missing)
+	report := quality.Check(currentCost.EventTimes, config.TTLs, time.Now())
+	currentCost.Quality = report.Status
+	currentCost.Stale = report.Stale
+
+	err := state.Save(db, serviceName, currentCost.LocaionID, currentCost)
+	if err != nil {
+		logs.Println("Error saving state:", err)
+	}
+
+	// Convert OutputData struct to JSON
+	OutputJSON, err := json.Marshal(currentCost)
+	if err != nil {
+		logs.Println("Error marshaling mining cost data:", err)
+		return
+	}
+
+	// Print the response
+	logs.Println("[OUT]: " + string(OutputJSON))
+
+	// Send the response to Kafka topic, keyed by site
+	message := &sarama.ProducerMessage{
+		Topic: config.Ptopic,
+		Key:   sarama.StringEncoder(currentCost.LocaionID),
+		Value: sarama.StringEncoder(OutputJSON),
+	}
+	_, _, err = producer.SendMessage(message)
+	if err != nil {
+		logs.Println("Error sending message to Kafka:", err)
+	}
+}
+
+// measuredSite returns site with the hashrate and power its miners last
+// reported, when telemetry is fresh enough, in place of nameplate figures.
+// Callers hold currentCostsMutex once the consumer is running.
+func measuredSite(site fleet.Site) fleet.Site {
+	summary, ok := siteTelemetry[site.LocationID]
+	if !ok {
+		return site
+	}
+	return telemetry.Apply(site, summary, time.Duration(config.TelemetryMaxAge)*time.Second, time.Now())
+}
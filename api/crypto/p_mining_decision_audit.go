@@ -0,0 +1,162 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"time"
+
+	audit "profitmax/util/audit"
+	common "profitmax/util/common"
+	logger "profitmax/util/logger"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+type DBConfig struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	User     string `json:"user"`
+	Password string `json:"password"`
+	Database string `json:"database"`
+}
+
+var logs *log.Logger
+var config common.Config
+var db *sql.DB
+
+func usage() {
+	fmt.Println("Usage: p_mining_decision_audit [Config File] [Location ID|all] [From] [To]")
+	fmt.Println("Times are YYYY-MM-DD or \"YYYY-MM-DD HH:MM\" local time; To defaults to one hour after From")
+	fmt.Println("Example: p_mining_decision_audit p_mining_decision_audit.json QLD1 \"2023-08-01 14:00\" \"2023-08-01 14:10\"")
+}
+
+func main() {
+	args := os.Args
+
+	if len(args) < 4 {
+		usage()
+		return
+	}
+
+	// Read the JSON file
+	filePath := args[1]
+	fileData, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		log.Println("Error reading file:", err)
+		return
+	}
+
+	// Parse the JSON data into a struct
+	config = common.Config{}
+	err = json.Unmarshal(fileData, &config)
+	if err != nil {
+		log.Println("Error parsing JSON:", err)
+		return
+	}
+	// Create logs directory path
+	logsDir, err := logger.CreateLogsDirectory(config.LogPath)
+	if err != nil {
+		log.Fatal("Failed to create logs directory:", err)
+	}
+
+	// Create log file path based on the current date
+	logFilePath, err := logger.CreateLogFile(logsDir, config.LogFile)
+	if err != nil {
+		log.Fatal("Failed to create log file path:", err)
+	}
+
+	// Open the log file
+	logFile, err := os.OpenFile(logFilePath.Name(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Fatal("Failed to open log file:", err)
+	}
+	defer logFile.Close()
+
+	logs = log.New(logFile, "", log.LstdFlags)
+
+	// Open a connection to the MySQL database
+	// Read the JSON file
+	dbFilePath := "dbconfig.json"
+	dbFileData, err := ioutil.ReadFile(dbFilePath)
+	if err != nil {
+		logs.Println("Error reading file:", err)
+		return
+	}
+	// Parse the JSON data into a struct
+	var dbConfig DBConfig
+	err = json.Unmarshal(dbFileData, &dbConfig)
+	if err != nil {
+		logs.Println("Error parsing JSON:", err)
+		return
+	}
+
+	// Create the MySQL connection string
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s", dbConfig.User, dbConfig.Password, dbConfig.Host, dbConfig.Port, dbConfig.Database)
+
+	db, err = sql.Open("mysql", dsn)
+	if err != nil {
+		logs.Fatal("Error connecting to the database:", err)
+	}
+	defer db.Close()
+
+	locationID := args[2]
+	if locationID == "all" {
+		locationID = ""
+	}
+	from, err := parseTime(args[3])
+	if err != nil {
+		usage()
+		return
+	}
+	to := from.Add(time.Hour)
+	if len(args) > 4 {
+		to, err = parseTime(args[4])
+		if err != nil {
+			usage()
+			return
+		}
+	}
+
+	err = printRecords(locationID, from, to)
+	if err != nil {
+		logs.Println("Error:", err)
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+}
+
+func parseTime(value string) (time.Time, error) {
+	t, err := time.ParseInLocation("2006-01-02 15:04", value, time.Local)
+	if err != nil {
+		t, err = time.ParseInLocation("2006-01-02", value, time.Local)
+	}
+	return t, err
+}
+
+// printRecords prints a line explaining every decision in the range,
+// followed by its full audit record.
+func printRecords(locationID string, from time.Time, to time.Time) error {
+	records, err := audit.Query(db, locationID, from, to)
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		d := record.Decision
+		in := record.Inputs
+		fmt.Printf("%s %s %s run=%.2f margin=%.4f reason=%s cost=%.2f incentive=%.2f %s energy=%.2f/MWh config=%s\n",
+			d.Timestamp.Format("2006-01-02 15:04:05"), d.LocationID, d.Action, d.RunFraction, d.Margin, d.Reason,
+			float64(in.Cost), float64(in.Incentive), in.Currency, float64(in.EnergyPrice), record.ConfigVersion)
+
+		recordJSON, err := json.MarshalIndent(record, "", "    ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(recordJSON))
+	}
+	fmt.Println("Decisions:", len(records))
+	return nil
+}
@@ -0,0 +1,304 @@
+package main
+
+/*
+CREATE TABLE tbl_mining_decision (
+    id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    location_id VARCHAR(10) NOT NULL,
+    action VARCHAR(10) NOT NULL,
+    run_fraction DECIMAL(5, 4) NOT NULL,
+    margin DECIMAL(18, 6) NOT NULL,
+    reason VARCHAR(40) NOT NULL,
+    changed TINYINT(1) NOT NULL,
+    timestamp DATETIME NOT NULL,
+    INDEX (location_id, timestamp)
+);
+
+CREATE TABLE tbl_mining_decision_group (
+    decision_id INT NOT NULL,
+    model VARCHAR(40) NOT NULL,
+    units INT NOT NULL,
+    run_units INT NOT NULL,
+    break_even_price DECIMAL(18, 6) NOT NULL,
+    PRIMARY KEY (decision_id, model)
+);
+
+CREATE TABLE tbl_mining_decision_audit (
+    id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    location_id VARCHAR(10) NOT NULL,
+    timestamp DATETIME NOT NULL,
+    action VARCHAR(10) NOT NULL,
+    run_fraction DECIMAL(5, 4) NOT NULL,
+    margin DECIMAL(18, 6) NOT NULL,
+    reason VARCHAR(40) NOT NULL,
+    changed TINYINT(1) NOT NULL,
+    currency_code VARCHAR(3) NOT NULL,
+    crypto_price DECIMAL(18, 6) NOT NULL,
+    energy_price DECIMAL(18, 6) NOT NULL,
+    difficulty DECIMAL(30, 2) NOT NULL,
+    reward DECIMAL(18, 8) NOT NULL,
+    hashprice DECIMAL(18, 8) NOT NULL,
+    mining_cost DECIMAL(18, 6) NOT NULL,
+    mining_incentive DECIMAL(18, 6) NOT NULL,
+    config_version VARCHAR(16) NOT NULL,
+    policy TEXT NOT NULL,
+    inputs TEXT NOT NULL,
+    sources TEXT NOT NULL,
+    groups_json TEXT NOT NULL,
+    INDEX (location_id, timestamp),
+    INDEX (timestamp)
+);
+*/
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	audit "profitmax/util/audit"
+	common "profitmax/util/common"
+	decision "profitmax/util/decision"
+	logger "profitmax/util/logger"
+	"sync"
+
+	"github.com/Shopify/sarama"
+	_ "github.com/go-sql-driver/mysql"
+)
+
+type DBConfig struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	User     string `json:"user"`
+	Password string `json:"password"`
+	Database string `json:"database"`
+}
+
+var logs *log.Logger
+var config common.Config
+var db *sql.DB
+var consumer sarama.Consumer
+
+func main() {
+	args := os.Args
+
+	if len(args) < 2 {
+		fmt.Println("Usage: p_mining_decision_db [Config File]", len(args))
+		fmt.Println("Example: p_mining_decision_db p_mining_decision_db.json")
+		return
+	}
+
+	// Read the JSON file
+	filePath := args[1]
+	fileData, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		fmt.Println("Error reading file:", err)
+		return
+	}
+
+	// Parse the JSON data into a struct
+	config = common.Config{}
+	err = json.Unmarshal(fileData, &config)
+	if err != nil {
+		fmt.Println("Error parsing JSON:", err)
+		return
+	}
+	// Create logs directory path
+	logsDir, err := logger.CreateLogsDirectory(config.LogPath)
+	if err != nil {
+		log.Fatal("Failed to create logs directory:", err)
+	}
+
+	// Create log file path based on the current date
+	logFilePath, err := logger.CreateLogFile(logsDir, config.LogFile)
+	if err != nil {
+		log.Fatal("Failed to create log file path:", err)
+	}
+
+	// Open the log file
+	logFile, err := os.OpenFile(logFilePath.Name(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Fatal("Failed to open log file:", err)
+	}
+	defer logFile.Close()
+
+	logs = log.New(logFile, "", log.LstdFlags)
+
+	// Configure the Kafka consumer
+	conf := sarama.NewConfig()
+	conf.Consumer.Return.Errors = true
+
+	// Kafka consumer group
+	group := "mining_decision_db"
+
+	// Create a new consumer
+	consumer, err := sarama.NewConsumerGroup([]string{config.KafkaBroker}, group, nil)
+	if err != nil {
+		logs.Fatal("Failed to create Kafka consumer:", err)
+	}
+	defer consumer.Close()
+
+	// Open a connection to the MySQL database
+	// Read the JSON file
+	dbFilePath := "dbconfig.json"
+	dbFileData, err := ioutil.ReadFile(dbFilePath)
+	if err != nil {
+		logs.Println("Error reading file:", err)
+		return
+	}
+	// Parse the JSON data into a struct
+	var dbConfig DBConfig
+	err = json.Unmarshal(dbFileData, &dbConfig)
+	if err != nil {
+		logs.Println("Error parsing JSON:", err)
+		return
+	}
+
+	// Create the MySQL connection string
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s", dbConfig.User, dbConfig.Password, dbConfig.Host, dbConfig.Port, dbConfig.Database)
+
+	db, err = sql.Open("mysql", dsn)
+	if err != nil {
+		logs.Fatal("Error connecting to the database:", err)
+	}
+	defer db.Close()
+
+	// Specify the topics you want to consume from
+	topics := config.Topics
+	// Create a context for the consumer group
+	ctx := context.Background()
+
+	// Create a signal channel to handle termination
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+
+	// Create a wait group to wait for the consumer group to finish
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+
+	// Start consuming messages in a separate goroutine
+	go func() {
+		defer wg.Done()
+
+		for {
+			select {
+			case <-signals:
+				// Interrupt signal received, stop consuming
+				consumer.Close()
+				return
+
+			default:
+				// Consume messages
+				err := consumer.Consume(ctx, topics, &ConsumerGroupHandler{})
+				if err != nil {
+					logs.Println("Error consuming messages:", err)
+				}
+			}
+		}
+	}()
+
+	// Wait for a termination signal
+	<-signals
+
+	// Wait for the consumer group to finish
+	wg.Wait()
+
+}
+
+// ConsumerGroupHandler implements the sarama.ConsumerGroupHandler interface
+type ConsumerGroupHandler struct{}
+
+// Setup is called when the consumer group session is being set up
+func (h *ConsumerGroupHandler) Setup(session sarama.ConsumerGroupSession) error {
+	logs.Println("Consumer group session is being set up")
+	return nil
+}
+
+// Cleanup is called when the consumer group session is ending
+func (h *ConsumerGroupHandler) Cleanup(session sarama.ConsumerGroupSession) error {
+	logs.Println("Consumer group session is ending")
+	return nil
+}
+
+// ConsumeClaim is called when a new set of messages is claimed by the consumer group
+func (h *ConsumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for message := range claim.Messages() {
+		logs.Printf("Message received: Topic=%s, Partition=%d, Offset=%d, Key=%s, Value=%s\n",
+			message.Topic, message.Partition, message.Offset, string(message.Key), string(message.Value))
+
+		switch message.Topic {
+		case "private.mining.decision":
+			insertDecisionTable(message)
+		case "private.mining.decision.audit":
+			insertAuditTable(message)
+		default:
+		}
+
+		// Mark the message as processed
+		session.MarkMessage(message, "")
+	}
+
+	return nil
+}
+
+func insertDecisionTable(msg *sarama.ConsumerMessage) {
+	// JSON data
+	jsonData := msg.Value
+
+	// Parse the JSON data into a Decision struct
+	var input decision.Decision
+	err := json.Unmarshal(jsonData, &input)
+	if err != nil {
+		logs.Println("Error parsing JSON:", err)
+		return
+	}
+
+	// Insert the data into the table
+	insertData := "INSERT INTO tbl_mining_decision (location_id, action, run_fraction, margin, reason, changed, timestamp) VALUES (?, ?, ?, ?, ?, ?, ?)"
+	result, err := db.Exec(insertData, input.LocationID, input.Action, input.RunFraction, input.Margin, input.Reason, input.Changed, input.Timestamp)
+	if err != nil {
+		logs.Println("Error inserting data into table:", err)
+		return
+	}
+
+	// Keep the per-model targets alongside the decision
+	decisionID, err := result.LastInsertId()
+	if err != nil {
+		logs.Println("Error reading decision id:", err)
+		return
+	}
+	for _, group := range input.Groups {
+		insertGroup := "INSERT INTO tbl_mining_decision_group (decision_id, model, units, run_units, break_even_price) VALUES (?, ?, ?, ?, ?)"
+		_, err = db.Exec(insertGroup, decisionID, group.Model, group.Units, group.RunUnits, float64(group.BreakEvenPrice))
+		if err != nil {
+			logs.Println("Error inserting data into table:", err)
+			return
+		}
+	}
+
+	logs.Printf("tbl_mining_decision: Data inserted successfully!")
+}
+
+func insertAuditTable(msg *sarama.ConsumerMessage) {
+	// JSON data
+	jsonData := msg.Value
+
+	// Parse the JSON data into a Record struct
+	var input audit.Record
+	err := json.Unmarshal(jsonData, &input)
+	if err != nil {
+		logs.Println("Error parsing JSON:", err)
+		return
+	}
+
+	// Insert the data into the table
+	err = audit.Save(db, input)
+	if err != nil {
+		logs.Println("Error inserting data into table:", err)
+		return
+	}
+
+	logs.Printf("tbl_mining_decision_audit: Data inserted successfully!")
+}
@@ -2,48 +2,194 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
 	"os/signal"
-	common "profitmax/util/common"
+	audit "profitmax/util/audit"
+	calculator "profitmax/util/calculator"
+	decision "profitmax/util/decision"
+	economics "profitmax/util/economics"
+	fleet "profitmax/util/fleet"
+	forecast "profitmax/util/forecast"
+	fx "profitmax/util/fx"
+	inventory "profitmax/util/inventory"
 	logger "profitmax/util/logger"
+	quality "profitmax/util/quality"
+	state "profitmax/util/state"
+	telemetry "profitmax/util/telemetry"
 	"sync"
+	"time"
 
 	"github.com/Shopify/sarama"
+	_ "github.com/go-sql-driver/mysql"
 )
 
+type DBConfig struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	User     string `json:"user"`
+	Password string `json:"password"`
+	Database string `json:"database"`
+}
+
+// Config is the service config with the sections only this service reads.
+type Config struct {
+	fleet.Config
+	Horizons        []economics.Horizon `json:"reporting_horizons"`
+	Policy          decision.Policy     `json:"policy"`
+	ReportingCcy    string              `json:"reporting_currency"`
+	DecisionTopic   string              `json:"decision_topic"`
+	TelemetryMaxAge int                 `json:"telemetry_max_age"`
+	UseInventory    bool                `json:"use_inventory"`
+	AuditTopic      string              `json:"audit_topic"`
+	BreakEvenTopic  string              `json:"breakeven_topic"`
+	TTLs            map[string]int      `json:"ttl"`
+	Fallback        string              `json:"fallback"`
+	QualityTopic    string              `json:"quality_topic"`
+	ForecastModel   string              `json:"forecast_model"`
+}
+
 type CurrentCost struct {
-	LocaionID  string  `json:"location_id"`
-	EnergyCost float64 `json:"energy_cost"`
-	OtherCost  float64 `json:"other_cost"`
-	TotalCost  float64 `json:"total_cost"`
+	LocaionID   string                `json:"location_id"`
+	Currency    string                `json:"currency"`
+	EnergyPrice economics.PricePerMWh `json:"energy_price"`
+	EnergyCost  economics.Amount      `json:"energy_cost"`
+	OtherCost   economics.Amount      `json:"other_cost"`
+	TotalCost   economics.Amount      `json:"total_cost"`
+	Status      string                `json:"status"`
+	EventTimes  quality.Times         `json:"event_times"`
 }
 
 type CurrentReward struct {
-	Symbol string  `json:"symbol"`
-	Reward float64 `json:"reward"`
+	Symbol     string          `json:"symbol"`
+	Reward     economics.Coins `json:"reward"`
+	Status     string          `json:"status"`
+	EventTimes quality.Times   `json:"event_times"`
 }
 type CurrentCrypto struct {
-	Symbol   string  `json:"symbol"`
-	Currency string  `json:"currency"`
-	Price    float64 `json:"price"`
+	Symbol    string           `json:"symbol"`
+	Currency  string           `json:"currency"`
+	Price     economics.Amount `json:"price"`
+	EventTime time.Time        `json:"event_time"`
+}
+
+type InputData struct {
+	MessageType string    `json:"message_type"`
+	Symbol      string    `json:"symbol"`
+	Value       float64   `json:"value"`
+	EventTime   time.Time `json:"event_time"`
 }
 
+// CurrentStatus is a site's economics. Cost, OtherCost, Incentive and
+// Profits accrue over one block interval, as Horizon says; Horizons restates
+// them on each reporting horizon.
 type CurrentStatus struct {
-	Symbol      string  `json:"symbol"`
-	Cost        float64 `json:"mining_cost"`
-	Incentive   float64 `json:"mining_incentive"`
-	Profits     float64 `json:"profits"`
-	CryptoPrice float64 `json:"crypto_price"`
+	LocationID        string                `json:"location_id"`
+	Symbol            string                `json:"symbol"`
+	Currency          string                `json:"currency"`
+	Cost              economics.Amount      `json:"mining_cost"`
+	OtherCost         economics.Amount      `json:"other_cost"`
+	Incentive         economics.Amount      `json:"mining_incentive"`
+	Profits           economics.Amount      `json:"profits"`
+	Horizon           economics.Horizon     `json:"horizon"`
+	Horizons          []HorizonStatus       `json:"horizons,omitempty"`
+	CryptoPrice       economics.Amount      `json:"crypto_price"`
+	EnergyPrice       economics.PricePerMWh `json:"energy_price"`
+	Hashprice         economics.Amount      `json:"hashprice"`
+	ReportingCurrency string                `json:"reporting_currency,omitempty"`
+	Reported          *ReportedStatus       `json:"reported,omitempty"`
+	Status            string                `json:"status"`
+	Missing           []string              `json:"missing,omitempty"`
+	Quality           string                `json:"quality"`
+	Stale             []string              `json:"stale,omitempty"`
+}
+
+// Snapshot is what the decision maker restores on a restart: the inputs
+// shared by every site and each site's status and decision state.
+type Snapshot struct {
+	Reward         economics.Coins           `json:"reward"`
+	CryptoPrice    economics.Amount          `json:"crypto_price"`
+	CryptoCurrency string                    `json:"crypto_currency"`
+	Difficulty     float64                   `json:"difficulty"`
+	Statuses       map[string]CurrentStatus  `json:"statuses"`
+	DecisionStates map[string]decision.State `json:"decision_states"`
+	CostsKnown     map[string]bool           `json:"costs_known"`
+	SharedTimes    quality.Times             `json:"shared_times"`
+	SiteTimes      map[string]quality.Times  `json:"site_times"`
+}
+
+// serviceName keys this service's snapshot in tbl_service_state.
+const serviceName = "p_mining_decision_maker"
+
+// BreakEvenStatus states a site's economics in the energy market's units:
+// the energy price it can pay before mining stops paying for its energy,
+// overall and per machine model. AllInBreakEvenPrice also covers the site's
+// other costs.
+type BreakEvenStatus struct {
+	LocationID          string                `json:"location_id"`
+	Symbol              string                `json:"symbol"`
+	Currency            string                `json:"currency"`
+	Hashprice           economics.Amount      `json:"hashprice"`
+	EnergyPrice         economics.PricePerMWh `json:"energy_price"`
+	BreakEvenPrice      economics.PricePerMWh `json:"break_even_price"`
+	MarginToBreakEven   economics.PricePerMWh `json:"margin_to_break_even"`
+	AllInBreakEvenPrice economics.PricePerMWh `json:"all_in_break_even_price,omitempty"`
+	Models              []ModelBreakEven      `json:"models,omitempty"`
+	Timestamp           time.Time             `json:"timestamp"`
+}
+
+// ModelBreakEven is the break-even energy price of one machine model.
+type ModelBreakEven struct {
+	Model             string                `json:"model"`
+	Efficiency        economics.Efficiency  `json:"efficiency_j_per_th"`
+	BreakEvenPrice    economics.PricePerMWh `json:"break_even_price"`
+	MarginToBreakEven economics.PricePerMWh `json:"margin_to_break_even"`
+}
+
+// ReportedStatus restates a site's figures in the reporting currency.
+type ReportedStatus struct {
+	Cost      economics.Amount `json:"mining_cost"`
+	Incentive economics.Amount `json:"mining_incentive"`
+	Profits   economics.Amount `json:"profits"`
+	Horizons  []HorizonStatus  `json:"horizons,omitempty"`
+}
+
+// HorizonStatus is a site's revenue and costs on one reporting horizon.
+type HorizonStatus struct {
+	Horizon    economics.Horizon `json:"horizon"`
+	Revenue    economics.Amount  `json:"revenue"`
+	EnergyCost economics.Amount  `json:"energy_cost"`
+	OtherCost  economics.Amount  `json:"other_cost"`
+	Cost       economics.Amount  `json:"cost"`
+	Profits    economics.Amount  `json:"profits"`
 }
 
 var logs *log.Logger
-var config common.Config
+var config Config
 var producer sarama.SyncProducer
-var currentStatus CurrentStatus
+var db *sql.DB
+var currentStatuses map[string]*CurrentStatus
+var currentReward economics.Coins
+var currentCryptoPrice economics.Amount
+var currentCryptoCurrency string
+var currentDifficulty float64
+var converter *fx.Converter
+var siteTelemetry = make(map[string]telemetry.SiteSummary)
+var decisionStates = make(map[string]decision.State)
+var sources = make(audit.Sources)
+var configVersion string
+var sharedTimes = make(quality.Times)
+var siteTimes = make(map[string]quality.Times)
+var costsKnown = make(map[string]bool)
+var sites *inventory.Fleet
+var forecasts = make(map[string]forecast.Forecast)
+var leaders = make(map[string]string)
+var statusMutex sync.Mutex
 
 func main() {
 	args := os.Args
@@ -63,12 +209,13 @@ func main() {
 	}
 
 	// Parse the JSON data into a struct
-	config = common.Config{}
+	config = Config{}
 	err = json.Unmarshal(fileData, &config)
 	if err != nil {
 		log.Println("Error parsing JSON:", err)
 		return
 	}
+	configVersion = audit.ConfigVersion(fileData)
 	// Create logs directory path
 	logsDir, err := logger.CreateLogsDirectory(config.LogPath)
 	if err != nil {
@@ -112,6 +259,122 @@ func main() {
 	}
 	defer producer.Close()
 
+	// Open a connection to the MySQL database
+	// Read the JSON file
+	dbFilePath := "dbconfig.json"
+	dbFileData, err := ioutil.ReadFile(dbFilePath)
+	if err != nil {
+		logs.Println("Error reading file:", err)
+		return
+	}
+	// Parse the JSON data into a struct
+	var dbConfig DBConfig
+	err = json.Unmarshal(dbFileData, &dbConfig)
+	if err != nil {
+		logs.Println("Error parsing JSON:", err)
+		return
+	}
+
+	// Create the MySQL connection string
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s", dbConfig.User, dbConfig.Password, dbConfig.Host, dbConfig.Port, dbConfig.Database)
+
+	db, err = sql.Open("mysql", dsn)
+	if err != nil {
+		logs.Fatal("Error connecting to the database:", err)
+	}
+	defer db.Close()
+
+	// Take the fleet from the inventory
+	sites = inventory.NewFleet(config.SiteList())
+	if config.UseInventory {
+		err = sites.Reload(db)
+		if err != nil {
+			logs.Fatal("Error loading inventory:", err)
+		}
+	}
+
+	// Pick up where the last run left off
+	var snapshot Snapshot
+	_, err = state.Load(db, serviceName, "all", &snapshot)
+	if err != nil {
+		logs.Println("Error restoring state:", err)
+	}
+	currentReward = snapshot.Reward
+	currentCryptoPrice = snapshot.CryptoPrice
+	currentCryptoCurrency = snapshot.CryptoCurrency
+	currentDifficulty = snapshot.Difficulty
+	sharedTimes.Merge(snapshot.SharedTimes)
+
+	for _, horizon := range config.Horizons {
+		if !horizon.Valid() {
+			logs.Fatal("Unknown reporting horizon:", horizon)
+		}
+	}
+	if lookAhead := config.Policy.LookAhead; lookAhead != nil && lookAhead.Intervals > 0 && lookAhead.IntervalMinutes <= 0 {
+		logs.Fatal("look_ahead interval_minutes must be positive")
+	}
+
+	// Load the latest fx rates so statuses can be restated before the next
+	// rate arrives, and age them like any other input
+	converter = fx.NewConverter()
+	err = fx.LoadRates(db, converter)
+	if err != nil {
+		logs.Println("Error loading fx rates:", err)
+	}
+	sharedTimes.Observe("fx_rate", converter.Latest())
+
+	currentStatuses = make(map[string]*CurrentStatus)
+	for _, site := range sites.List() {
+		if site.Currency == "" {
+			logs.Fatal("No currency configured for site:", site.LocationID)
+		}
+		currentStatus := &CurrentStatus{
+			LocationID:        site.LocationID,
+			Symbol:            config.Symbol,
+			Currency:          site.Currency,
+			Horizon:           economics.PerBlock,
+			ReportingCurrency: config.ReportingCcy,
+		}
+		if restored, ok := snapshot.Statuses[site.LocationID]; ok && restored.Currency == site.Currency {
+			currentStatus.Cost = restored.Cost
+			currentStatus.OtherCost = restored.OtherCost
+			currentStatus.EnergyPrice = restored.EnergyPrice
+			costsKnown[site.LocationID] = snapshot.CostsKnown[site.LocationID]
+			if restoredState, ok := snapshot.DecisionStates[site.LocationID]; ok {
+				decisionStates[site.LocationID] = restoredState
+			}
+		}
+		currentStatuses[site.LocationID] = currentStatus
+		siteTimes[site.LocationID] = make(quality.Times)
+		siteTimes[site.LocationID].Merge(snapshot.SiteTimes[site.LocationID])
+	}
+
+	// Restate the restored statuses. Decisions wait for fresh inputs.
+	for _, site := range sites.List() {
+		currentStatus := currentStatuses[site.LocationID]
+		err := updateStatus(site, currentStatus)
+		if err != nil {
+			logs.Println("Error updating status:", site.LocationID, err)
+			continue
+		}
+		publishStatus(currentStatus)
+	}
+
+	// Re-check every site on a schedule so inputs that stop arriving are
+	// caught going stale
+	if config.TimeInterval > 0 {
+		ticker := time.NewTicker(time.Duration(config.TimeInterval) * time.Second)
+		defer ticker.Stop()
+		go func() {
+			for range ticker.C {
+				statusMutex.Lock()
+				publishAllStatuses()
+				saveState()
+				statusMutex.Unlock()
+			}
+		}()
+	}
+
 	// Specify the topics you want to consume from
 	topics := config.Topics
 	// Create a context for the consumer group
@@ -175,6 +438,16 @@ func (h *ConsumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession,
 		logs.Printf("Message received: Topic=%s, Partition=%d, Offset=%d, Key=%s, Value=%s\n",
 			message.Topic, message.Partition, message.Offset, string(message.Key), string(message.Value))
 
+		// Remember where every input came from for the audit trail
+		sources.Record(audit.Source{
+			Topic:     message.Topic,
+			Key:       string(message.Key),
+			Partition: message.Partition,
+			Offset:    message.Offset,
+			EventTime: message.Timestamp,
+		})
+
+		statusMutex.Lock()
 		switch message.Topic {
 		case "public.cryptoprice":
 			//
@@ -186,120 +459,771 @@ func (h *ConsumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession,
 			err := json.Unmarshal(jsonData, &input)
 			if err != nil {
 				logs.Println("Error parsing JSON:", err)
-				continue
+				break
 			}
 
-			// Create the OutputData struct
-			currentStatus.Symbol = input.Symbol
-			currentStatus.CryptoPrice = input.Price
-			if currentStatus.Incentive > 0 && currentStatus.Cost > 0 {
-				currentStatus.Profits = currentStatus.Incentive - currentStatus.Cost
-			}
-			// Convert OutputData struct to JSON
-			OutputJSON, err := json.Marshal(currentStatus)
+			// Crypto price is shared by every site
+			sharedTimes.Observe("crypto_price", quality.EventTime(input.EventTime, message.Timestamp))
+			currentCryptoPrice = input.Price
+			currentCryptoCurrency = input.Currency
+			publishAllStatuses()
+		case "private.mining.cost":
+			//
+			// JSON data
+			jsonData := message.Value
+
+			// Parse the JSON data into an InputData struct
+			var input CurrentCost
+			err := json.Unmarshal(jsonData, &input)
 			if err != nil {
-				logs.Println("Error marshaling current mining cost status data:", err)
-				continue
+				logs.Println("Error parsing JSON:", err)
+				break
 			}
 
-			// Print the response
-			logs.Println("[OUT]: " + string(OutputJSON))
+			// Only sites we manage are of interest
+			site, ok := sites.Site(input.LocaionID)
+			if !ok {
+				break
+			}
 
-			// Send the response to Kafka topic
-			message := &sarama.ProducerMessage{
-				Topic: config.Ptopic,
-				Value: sarama.StringEncoder(OutputJSON),
+			// A cost still missing an input is not a cost to act on
+			if input.Status == state.WarmingUp {
+				break
 			}
-			_, _, err = producer.SendMessage(message)
+
+			// Restate the cost in the site's currency
+			totalCost, err := fx.Convert(converter, input.TotalCost, input.Currency, site.Currency)
 			if err != nil {
-				logs.Println("Error sending message to Kafka:", err)
-				continue
+				logs.Println("Error converting mining cost:", err)
+				break
 			}
-		case "private.mining.cost":
+			energyPrice, err := fx.Convert(converter, input.EnergyPrice, input.Currency, site.Currency)
+			if err != nil {
+				logs.Println("Error converting energy price:", err)
+				break
+			}
+			otherCost, err := fx.Convert(converter, input.OtherCost, input.Currency, site.Currency)
+			if err != nil {
+				logs.Println("Error converting other cost:", err)
+				break
+			}
+
+			currentStatus := currentStatuses[site.LocationID]
+			currentStatus.Cost = totalCost
+			currentStatus.OtherCost = otherCost
+			currentStatus.EnergyPrice = energyPrice
+			costsKnown[site.LocationID] = true
+			siteTimes[site.LocationID].Merge(input.EventTimes)
+			publishSite(site, currentStatus)
+		case "private.mining.incentive":
 			//
 			// JSON data
 			jsonData := message.Value
 
 			// Parse the JSON data into an InputData struct
-			var input CurrentCost
+			var input CurrentReward
+			err := json.Unmarshal(jsonData, &input)
+			if err != nil {
+				logs.Fatal("Error parsing JSON:", err)
+			}
+			if input.Status == state.WarmingUp {
+				break
+			}
+
+			// Block reward is shared by every site
+			sharedTimes.Merge(input.EventTimes)
+			currentReward = input.Reward
+			publishAllStatuses()
+		case "public.block.difficulty":
+			//
+			// JSON data
+			jsonData := message.Value
+
+			// Parse the JSON data into an InputData struct
+			var input InputData
 			err := json.Unmarshal(jsonData, &input)
 			if err != nil {
 				logs.Println("Error parsing JSON:", err)
-				continue
+				break
 			}
 
-			// Create the OutputData struct
-			currentStatus.Symbol = config.Symbol
-			currentStatus.Cost = input.TotalCost
-			if currentStatus.Incentive > 0 {
-				currentStatus.Profits = currentStatus.Incentive - input.TotalCost
+			sharedTimes.Observe("difficulty", quality.EventTime(input.EventTime, message.Timestamp))
+			if currentDifficulty == input.Value {
+				break
 			}
-			// Convert OutputData struct to JSON
-			OutputJSON, err := json.Marshal(currentStatus)
+
+			// Difficulty is shared by every site
+			currentDifficulty = input.Value
+			publishAllStatuses()
+		case "public.miner.telemetry.site":
+			//
+			// JSON data
+			jsonData := message.Value
+
+			// Parse the JSON data into a SiteSummary struct
+			var input telemetry.SiteSummary
+			err := json.Unmarshal(jsonData, &input)
 			if err != nil {
-				logs.Println("Error marshaling current mining cost status data:", err)
-				continue
+				logs.Println("Error parsing JSON:", err)
+				break
 			}
 
-			// Print the response
-			logs.Println("[OUT]: " + string(OutputJSON))
+			// Picked up by the next status update
+			siteTelemetry[input.LocationID] = input
+		case "public.forecast":
+			//
+			// JSON data
+			jsonData := message.Value
 
-			// Send the response to Kafka topic
-			message := &sarama.ProducerMessage{
-				Topic: config.Ptopic,
-				Value: sarama.StringEncoder(OutputJSON),
-			}
-			_, _, err = producer.SendMessage(message)
+			// Parse the JSON data into a Forecast struct
+			var input forecast.Forecast
+			err := json.Unmarshal(jsonData, &input)
 			if err != nil {
-				logs.Println("Error sending message to Kafka:", err)
-				continue
+				logs.Println("Error parsing JSON:", err)
+				break
 			}
-		case "private.mining.incentive":
+
+			// Kept for the forecast fallback, per model and as the latest
+			// of any model
+			forecasts[input.Series+"/"+input.Key+"/"+input.Model] = input
+			forecasts[input.Series+"/"+input.Key] = input
+		case "public.forecast.leaderboard":
 			//
 			// JSON data
 			jsonData := message.Value
 
-			// Parse the JSON data into an InputData struct
-			var input CurrentReward
+			// Parse the JSON data into a Leaderboard struct
+			var input forecast.Leaderboard
 			err := json.Unmarshal(jsonData, &input)
 			if err != nil {
-				logs.Fatal("Error parsing JSON:", err)
+				logs.Println("Error parsing JSON:", err)
+				break
 			}
 
-			// Create the OutputData struct
-			currentStatus.Symbol = input.Symbol
-			if currentStatus.CryptoPrice > 0 {
-				currentStatus.Incentive = input.Reward * currentStatus.CryptoPrice
-				if currentStatus.Cost > 0 {
-					currentStatus.Profits = currentStatus.Incentive - currentStatus.Cost
-				}
+			// Trusted by the forecast fallback when forecast_model is BEST
+			if input.Leader() != "" {
+				leaders[input.Series+"/"+input.Key] = input.Leader()
 			}
-			// Convert OutputData struct to JSON
-			OutputJSON, err := json.Marshal(currentStatus)
+		case "public.fxrate":
+			//
+			// JSON data
+			jsonData := message.Value
+
+			// Parse the JSON data into a Rate struct
+			var input fx.Rate
+			err := json.Unmarshal(jsonData, &input)
 			if err != nil {
-				logs.Println("Error marshaling current mining incentive status data:", err)
-				continue
+				logs.Println("Error parsing JSON:", err)
+				break
 			}
 
-			// Print the response
-			logs.Println("[OUT]: " + string(OutputJSON))
+			converter.Set(input)
+			sharedTimes.Observe("fx_rate", quality.EventTime(input.Timestamp, message.Timestamp))
+		case "private.inventory":
+			//
+			// JSON data
+			jsonData := message.Value
+
+			// Parse the JSON data into an Event struct
+			var input inventory.Event
+			err := json.Unmarshal(jsonData, &input)
+			if err != nil {
+				logs.Println("Error parsing JSON:", err)
+				break
+			}
+			if !config.UseInventory {
+				break
+			}
 
-			// Send the response to Kafka topic
-			message := &sarama.ProducerMessage{
-				Topic: config.Ptopic,
-				Value: sarama.StringEncoder(OutputJSON),
+			// Only sites we manage are of interest
+			affected := sites.Affected(input)
+			if len(affected) == 0 {
+				break
 			}
-			_, _, err = producer.SendMessage(message)
+			err = sites.Reload(db)
 			if err != nil {
-				logs.Println("Error sending message to Kafka:", err)
-				continue
+				logs.Println("Error reloading inventory:", err)
+				break
+			}
+
+			// Restate and decide the sites on their changed fleet
+			for _, locationID := range affected {
+				site, _ := sites.Site(locationID)
+				publishSite(site, currentStatuses[site.LocationID])
 			}
 		default:
 		}
 
+		saveState()
+		statusMutex.Unlock()
+
 		// Mark the message as processed
 		session.MarkMessage(message, "")
 	}
 
 	return nil
 }
+
+// updateStatus recomputes the incentive and profits of a site from the
+// latest inputs, in the site's currency. A site without a fleet is measured
+// against the whole network's block reward. The status stays warming up, and
+// its profits unreported, until every input is known, including the fx rates
+// it needs; it never mixes currencies.
+func updateStatus(site fleet.Site, currentStatus *CurrentStatus) error {
+	site = measuredSite(site)
+	currentStatus.Missing = missingInputs(site, currentStatus)
+	currentStatus.Status = state.Of(currentStatus.Missing)
+	report := checkQuality(site)
+	currentStatus.Quality = report.Status
+	currentStatus.Stale = report.Stale
+	if currentCryptoPrice > 0 {
+		cryptoPrice, err := fx.Convert(converter, currentCryptoPrice, currentCryptoCurrency, site.Currency)
+		if err != nil && !errors.Is(err, fx.ErrNoRate) {
+			return err
+		}
+		if err == nil {
+			currentStatus.CryptoPrice = cryptoPrice
+		}
+	}
+	if currentReward > 0 && currentStatus.CryptoPrice > 0 && currentDifficulty > 0 {
+		currentStatus.Hashprice = economics.Hashprice(currentDifficulty, currentReward, currentStatus.CryptoPrice)
+	}
+	if currentReward > 0 && currentStatus.CryptoPrice > 0 {
+		currentStatus.Incentive = calculator.Incentive(site, currentDifficulty, currentReward, currentStatus.CryptoPrice)
+	}
+	currentStatus.Profits = 0
+	currentStatus.Horizons = nil
+	if currentStatus.Status == state.Ready {
+		currentStatus.Profits = economics.Profit(currentStatus.Incentive, currentStatus.Cost)
+		currentStatus.Horizons = horizonStatuses(site, currentStatus)
+	}
+
+	// Restate the figures in the reporting currency
+	currentStatus.Reported = nil
+	if config.ReportingCcy == "" {
+		return nil
+	}
+	rate, err := converter.Rate(site.Currency, config.ReportingCcy)
+	if errors.Is(err, fx.ErrNoRate) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	currentStatus.Reported = &ReportedStatus{
+		Cost:      currentStatus.Cost * economics.Amount(rate),
+		Incentive: currentStatus.Incentive * economics.Amount(rate),
+		Profits:   currentStatus.Profits * economics.Amount(rate),
+	}
+	for _, h := range currentStatus.Horizons {
+		currentStatus.Reported.Horizons = append(currentStatus.Reported.Horizons, HorizonStatus{
+			Horizon:    h.Horizon,
+			Revenue:    h.Revenue * economics.Amount(rate),
+			EnergyCost: h.EnergyCost * economics.Amount(rate),
+			OtherCost:  h.OtherCost * economics.Amount(rate),
+			Cost:       h.Cost * economics.Amount(rate),
+			Profits:    h.Profits * economics.Amount(rate),
+		})
+	}
+	return nil
+}
+
+// horizonStatuses restates a site's revenue and costs, which accrue over one
+// block interval, on each reporting horizon.
+func horizonStatuses(site fleet.Site, currentStatus *CurrentStatus) []HorizonStatus {
+	basis := siteBasis(site)
+	horizons := config.Horizons
+	if len(horizons) == 0 {
+		horizons = []economics.Horizon{economics.PerDay}
+	}
+
+	var statuses []HorizonStatus
+	for _, horizon := range horizons {
+		scale, err := horizon.Scale(basis)
+		if err != nil {
+			logs.Println("Error normalising status:", site.LocationID, err)
+			continue
+		}
+		s := economics.Amount(scale)
+		statuses = append(statuses, HorizonStatus{
+			Horizon:    horizon,
+			Revenue:    currentStatus.Incentive * s,
+			EnergyCost: (currentStatus.Cost - currentStatus.OtherCost) * s,
+			OtherCost:  currentStatus.OtherCost * s,
+			Cost:       currentStatus.Cost * s,
+			Profits:    currentStatus.Profits * s,
+		})
+	}
+	return statuses
+}
+
+// siteBasis is what a site uses over one block interval. A site without a
+// fleet is the whole network at the reference efficiency, as its incentive
+// and energy cost are.
+func siteBasis(site fleet.Site) economics.Basis {
+	return calculator.Basis(site, currentDifficulty, referenceEfficiency())
+}
+
+// referenceEfficiency is the efficiency assumed for a site without a fleet.
+func referenceEfficiency() economics.Efficiency {
+	return calculator.Efficiency(config.Efficiency)
+}
+
+// missingInputs names the inputs a site's status still lacks.
+func missingInputs(site fleet.Site, currentStatus *CurrentStatus) []string {
+	var missing []string
+	if currentCryptoPrice <= 0 {
+		missing = append(missing, "crypto_price")
+	}
+	if currentReward <= 0 {
+		missing = append(missing, "reward")
+	}
+	if currentDifficulty <= 0 && len(site.Fleet) > 0 {
+		missing = append(missing, "difficulty")
+	}
+	if !costsKnown[site.LocationID] {
+		missing = append(missing, "mining_cost")
+	}
+	if !ratesKnown(site) {
+		missing = append(missing, "fx_rate")
+	}
+	return missing
+}
+
+// ratesKnown reports whether the fx rates a site's status needs are known:
+// from the crypto price's currency to the site's, and from the site's to the
+// reporting currency.
+func ratesKnown(site fleet.Site) bool {
+	if currentCryptoPrice > 0 {
+		if _, err := converter.Rate(currentCryptoCurrency, site.Currency); err != nil {
+			return false
+		}
+	}
+	if config.ReportingCcy != "" {
+		if _, err := converter.Rate(site.Currency, config.ReportingCcy); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// saveState snapshots the shared inputs and every site's status and
+// decision state.
+func saveState() {
+	snapshot := Snapshot{
+		Reward:         currentReward,
+		CryptoPrice:    currentCryptoPrice,
+		CryptoCurrency: currentCryptoCurrency,
+		Difficulty:     currentDifficulty,
+		Statuses:       make(map[string]CurrentStatus),
+		DecisionStates: decisionStates,
+		SharedTimes:    sharedTimes,
+		SiteTimes:      siteTimes,
+		CostsKnown:     costsKnown,
+	}
+	for locationID, currentStatus := range currentStatuses {
+		snapshot.Statuses[locationID] = *currentStatus
+	}
+	err := state.Save(db, serviceName, "all", snapshot)
+	if err != nil {
+		logs.Println("Error saving state:", err)
+	}
+}
+
+func publishAllStatuses() {
+	for _, site := range sites.List() {
+		publishSite(site, currentStatuses[site.LocationID])
+	}
+}
+
+// publishSite updates a site's status and publishes it with its data
+// quality and break-even prices, then decides for the site.
+func publishSite(site fleet.Site, currentStatus *CurrentStatus) {
+	err := updateStatus(site, currentStatus)
+	if err != nil {
+		logs.Println("Error updating status:", site.LocationID, err)
+		return
+	}
+	publishStatus(currentStatus)
+	publishQuality(checkQuality(measuredSite(site)))
+	publishBreakEven(site, currentStatus)
+	makeDecision(currentStatus)
+}
+
+// checkQuality reports the freshness of the inputs behind a site's status
+// and the fallback applied while any is stale.
+func checkQuality(site fleet.Site) quality.Report {
+	times := make(quality.Times)
+	times.Merge(sharedTimes)
+	times.Merge(siteTimes[site.LocationID])
+	report := quality.Check(times, config.TTLs, time.Now())
+	report.LocationID = site.LocationID
+	if report.Status == quality.Stale {
+		report.Fallback = fallbackPolicy()
+	}
+	return report
+}
+
+// fallbackPolicy is the configured fallback for stale inputs. Anything
+// unrecognised fails safe.
+func fallbackPolicy() string {
+	switch config.Fallback {
+	case quality.Hold, quality.Forecast:
+		return config.Fallback
+	default:
+		return quality.Curtail
+	}
+}
+
+// publishQuality publishes the data-quality status of a site.
+func publishQuality(report quality.Report) {
+	if config.QualityTopic == "" {
+		return
+	}
+
+	// Convert Report struct to JSON
+	OutputJSON, err := json.Marshal(report)
+	if err != nil {
+		logs.Println("Error marshaling data quality data:", err)
+		return
+	}
+
+	// Print the response
+	logs.Println("[OUT]: " + string(OutputJSON))
+
+	// Send the response to Kafka topic, keyed by site
+	message := &sarama.ProducerMessage{
+		Topic: config.QualityTopic,
+		Key:   sarama.StringEncoder(report.LocationID),
+		Value: sarama.StringEncoder(OutputJSON),
+	}
+	_, _, err = producer.SendMessage(message)
+	if err != nil {
+		logs.Println("Error sending message to Kafka:", err)
+	}
+}
+
+// publishBreakEven publishes the break-even energy prices of a site once its
+// hashprice is known. The hashprice covers the block reward published by the
+// incentive calculator.
+func publishBreakEven(site fleet.Site, currentStatus *CurrentStatus) {
+	if config.BreakEvenTopic == "" {
+		return
+	}
+	if currentStatus.Hashprice <= 0 {
+		return
+	}
+	site = measuredSite(site)
+
+	// Without a fleet the site is taken to run at the reference efficiency
+	efficiency := referenceEfficiency()
+	if site.Hashrate() > 0 {
+		efficiency = economics.Efficiency(float64(site.Power()) / site.Hashrate().TH())
+	}
+
+	breakEven := economics.BreakEvenEnergyPrice(currentStatus.Hashprice, efficiency)
+	status := BreakEvenStatus{
+		LocationID:        site.LocationID,
+		Symbol:            currentStatus.Symbol,
+		Currency:          currentStatus.Currency,
+		Hashprice:         currentStatus.Hashprice,
+		EnergyPrice:       currentStatus.EnergyPrice,
+		BreakEvenPrice:    breakEven,
+		MarginToBreakEven: breakEven - currentStatus.EnergyPrice,
+		Timestamp:         time.Now(),
+	}
+
+	// The energy price at which the incentive just covers every cost
+	energy := economics.EnergyUsed(site.Power(), economics.BlockInterval)
+	if energy > 0 && currentStatus.Incentive > 0 {
+		status.AllInBreakEvenPrice = economics.PricePerMWh(float64(currentStatus.Incentive-currentStatus.OtherCost) / energy.MWh())
+	}
+
+	for _, group := range site.Fleet {
+		modelBreakEven := economics.BreakEvenEnergyPrice(currentStatus.Hashprice, group.Efficiency())
+		status.Models = append(status.Models, ModelBreakEven{
+			Model:             group.Model,
+			Efficiency:        group.Efficiency(),
+			BreakEvenPrice:    modelBreakEven,
+			MarginToBreakEven: modelBreakEven - currentStatus.EnergyPrice,
+		})
+	}
+
+	// Convert BreakEvenStatus struct to JSON
+	OutputJSON, err := json.Marshal(status)
+	if err != nil {
+		logs.Println("Error marshaling break-even data:", err)
+		return
+	}
+
+	// Print the response
+	logs.Println("[OUT]: " + string(OutputJSON))
+
+	// Send the response to Kafka topic, keyed by site
+	message := &sarama.ProducerMessage{
+		Topic: config.BreakEvenTopic,
+		Key:   sarama.StringEncoder(status.LocationID),
+		Value: sarama.StringEncoder(OutputJSON),
+	}
+	_, _, err = producer.SendMessage(message)
+	if err != nil {
+		logs.Println("Error sending message to Kafka:", err)
+	}
+}
+
+// makeDecision decides whether a site runs or curtails once its status is
+// ready, and publishes the decision. Once the hashprice is known too, the
+// site's run fraction is spread across its fleet in merit order. While an
+// input is stale the configured fallback decides instead.
+func makeDecision(currentStatus *CurrentStatus) {
+	if currentStatus.Status != state.Ready || currentStatus.Incentive <= 0 {
+		return
+	}
+
+	site, _ := sites.Site(currentStatus.LocationID)
+	site = measuredSite(site)
+	now := time.Now()
+
+	// Decide on a copy so forecast figures never leak into the status
+	status := *currentStatus
+	var fallback string
+	if len(status.Stale) > 0 {
+		fallback = fallbackPolicy()
+		if fallback == quality.Forecast && !forecastStatus(site, &status, now) {
+			fallback = quality.Curtail
+		}
+	}
+
+	margin := decision.Margin(status.Incentive, status.Cost)
+	previous := decisionStates[status.LocationID]
+	var result decision.Decision
+	var next decision.State
+	switch fallback {
+	case quality.Hold:
+		result, next = decision.Hold(previous, margin, now)
+	case quality.Curtail:
+		result, next = decision.FailSafe(previous, margin, now)
+	default:
+		intervals := lookAheadIntervals(site, status, now)
+		if len(intervals) > 1 {
+			result, next = decision.Plan(config.Policy, previous, intervals, now)
+		} else {
+			result, next = decision.Decide(config.Policy, previous, margin, now)
+		}
+	}
+	decisionStates[status.LocationID] = next
+	result.LocationID = status.LocationID
+	result.Stale = status.Stale
+	result.Fallback = fallback
+
+	if len(site.Fleet) > 0 && status.Hashprice > 0 {
+		result.Groups = decision.MeritOrder(site.Classes(), status.Hashprice, status.EnergyPrice, result.RunFraction)
+	}
+
+	// Convert Decision struct to JSON
+	OutputJSON, err := json.Marshal(result)
+	if err != nil {
+		logs.Println("Error marshaling mining decision data:", err)
+		return
+	}
+
+	// Print the response
+	logs.Println("[OUT]: " + string(OutputJSON))
+
+	// Send the response to Kafka topic, keyed by site
+	message := &sarama.ProducerMessage{
+		Topic: config.DecisionTopic,
+		Key:   sarama.StringEncoder(result.LocationID),
+		Value: sarama.StringEncoder(OutputJSON),
+	}
+	_, _, err = producer.SendMessage(message)
+	if err != nil {
+		logs.Println("Error sending message to Kafka:", err)
+	}
+
+	publishAudit(audit.Record{
+		Decision: result,
+		Inputs: audit.Inputs{
+			Currency:      status.Currency,
+			CryptoPrice:   status.CryptoPrice,
+			EnergyPrice:   status.EnergyPrice,
+			Difficulty:    currentDifficulty,
+			Reward:        currentReward,
+			Hashprice:     status.Hashprice,
+			HashrateTH:    site.Hashrate().TH(),
+			PowerW:        float64(site.Power()),
+			Cost:          status.Cost,
+			Incentive:     status.Incentive,
+			PreviousState: previous,
+			Stale:         status.Stale,
+			Fallback:      fallback,
+		},
+		Policy:        config.Policy,
+		Sources:       sources.For(status.LocationID),
+		ConfigVersion: configVersion,
+	})
+}
+
+// forecastStatus stands in forecasts for a site's stale prices. Energy cost
+// is the site's power costed at the forecast price; incentive and hashprice
+// are scaled with the crypto price they are linear in. It is false when a
+// stale input has no forecast covering now.
+func forecastStatus(site fleet.Site, status *CurrentStatus, now time.Time) bool {
+	for _, input := range status.Stale {
+		switch input {
+		case "energy_price":
+			price, ok := forecastAt(forecast.EnergyPrice, site.LocationID, site.Currency, now)
+			if !ok {
+				return false
+			}
+			power := calculator.Power(site, currentDifficulty, referenceEfficiency())
+			status.EnergyPrice = economics.PricePerMWh(price)
+			status.Cost = status.OtherCost + economics.EnergyCost(power, economics.BlockInterval, status.EnergyPrice)
+		case "crypto_price":
+			price, ok := forecastAt(forecast.CryptoPrice, config.Symbol, site.Currency, now)
+			if !ok || status.CryptoPrice <= 0 {
+				return false
+			}
+			scale := economics.Amount(price / float64(status.CryptoPrice))
+			status.Incentive *= scale
+			status.Hashprice *= scale
+			status.CryptoPrice = economics.Amount(price)
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// lookAheadIntervals prices a site's look-ahead intervals, starting now, on
+// the forward energy price curve. The first interval takes the current cost;
+// later ones cost the site's power at the curve's price and hold the other
+// figures, over as many intervals as the curve covers. It is empty when
+// look-ahead is off.
+func lookAheadIntervals(site fleet.Site, status CurrentStatus, now time.Time) []decision.Interval {
+	lookAhead := config.Policy.LookAhead
+	if lookAhead == nil || lookAhead.Intervals <= 0 {
+		return nil
+	}
+	model := lookAhead.Curve
+	if model == "" {
+		model = trustedModel(forecast.EnergyPrice, site.LocationID)
+	}
+
+	step := lookAhead.Interval()
+	blocks := economics.Amount(economics.BlocksIn(step))
+	power := calculator.Power(site, currentDifficulty, referenceEfficiency())
+	var intervals []decision.Interval
+	for i := 0; i < lookAhead.Intervals; i++ {
+		cost := status.Cost * blocks
+		if i > 0 {
+			price, ok := modelAt(forecast.EnergyPrice, site.LocationID, model, site.Currency, now.Add(time.Duration(i)*step))
+			if !ok {
+				break
+			}
+			cost = status.OtherCost*blocks + economics.EnergyCost(power, step, economics.PricePerMWh(price))
+		}
+		intervals = append(intervals, decision.Interval{
+			Incentive: status.Incentive * blocks,
+			Cost:      cost,
+		})
+	}
+	return intervals
+}
+
+// forecastAt returns the latest forecast of a series at t, in currency, from
+// the trusted model.
+func forecastAt(series string, key string, currency string, t time.Time) (float64, bool) {
+	return modelAt(series, key, trustedModel(series, key), currency, t)
+}
+
+// modelAt returns the latest forecast of a series by model at t, in
+// currency. An empty model takes the latest forecast of any model.
+func modelAt(series string, key string, model string, currency string, t time.Time) (float64, bool) {
+	name := series + "/" + key
+	if model != "" {
+		name += "/" + model
+	}
+	f, ok := forecasts[name]
+	if !ok {
+		return 0, false
+	}
+	value, ok := f.At(t)
+	if !ok {
+		return 0, false
+	}
+	value, err := fx.Convert(converter, value, f.Currency, currency)
+	if err != nil {
+		logs.Println("Error converting forecast:", err)
+		return 0, false
+	}
+	return value, true
+}
+
+// trustedModel is the model whose forecasts of a series stand in for stale
+// prices: the configured one, the leaderboard's leader for BEST, or "" for
+// whichever forecast came last. BEST trusts the latest forecast until a
+// leaderboard arrives.
+func trustedModel(series string, key string) string {
+	if config.ForecastModel != forecast.Best {
+		return config.ForecastModel
+	}
+	return leaders[series+"/"+key]
+}
+
+// publishAudit publishes the full record behind a decision.
+func publishAudit(record audit.Record) {
+	// Convert Record struct to JSON
+	OutputJSON, err := json.Marshal(record)
+	if err != nil {
+		logs.Println("Error marshaling decision audit data:", err)
+		return
+	}
+
+	// Print the response
+	logs.Println("[OUT]: " + string(OutputJSON))
+
+	// Send the response to Kafka topic, keyed by site
+	message := &sarama.ProducerMessage{
+		Topic: config.AuditTopic,
+		Key:   sarama.StringEncoder(record.Decision.LocationID),
+		Value: sarama.StringEncoder(OutputJSON),
+	}
+	_, _, err = producer.SendMessage(message)
+	if err != nil {
+		logs.Println("Error sending message to Kafka:", err)
+	}
+}
+
+func publishStatus(currentStatus *CurrentStatus) {
+	// Convert OutputData struct to JSON
+	OutputJSON, err := json.Marshal(currentStatus)
+	if err != nil {
+		logs.Println("Error marshaling current mining status data:", err)
+		return
+	}
+
+	// Print the response
+	logs.Println("[OUT]: " + string(OutputJSON))
+
+	// Send the response to Kafka topic, keyed by site
+	message := &sarama.ProducerMessage{
+		Topic: config.Ptopic,
+		Key:   sarama.StringEncoder(currentStatus.LocationID),
+		Value: sarama.StringEncoder(OutputJSON),
+	}
+	_, _, err = producer.SendMessage(message)
+	if err != nil {
+		logs.Println("Error sending message to Kafka:", err)
+	}
+}
+
+// measuredSite returns site with the hashrate and power its miners last
+// reported, when telemetry is fresh enough, in place of nameplate figures.
+func measuredSite(site fleet.Site) fleet.Site {
+	summary, ok := siteTelemetry[site.LocationID]
+	if !ok {
+		return site
+	}
+	return telemetry.Apply(site, summary, time.Duration(config.TelemetryMaxAge)*time.Second, time.Now())
+}
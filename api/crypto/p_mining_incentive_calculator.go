@@ -10,8 +10,12 @@ import (
 	"os"
 	"os/signal"
 	common "profitmax/util/common"
+	economics "profitmax/util/economics"
 	logger "profitmax/util/logger"
+	quality "profitmax/util/quality"
+	state "profitmax/util/state"
 	"sync"
+	"time"
 
 	"github.com/Shopify/sarama"
 	_ "github.com/go-sql-driver/mysql"
@@ -31,16 +35,22 @@ type BlockchainData struct {
 }
 
 type InputData struct {
-	MessageType string  `json:"message_type"`
-	Symbol      string  `json:"symbol"`
-	Value       float64 `json:"value"`
+	MessageType string    `json:"message_type"`
+	Symbol      string    `json:"symbol"`
+	Value       float64   `json:"value"`
+	EventTime   time.Time `json:"event_time"`
 }
 
 type CurrentReward struct {
-	Symbol string  `json:"symbol"`
-	Reward float64 `json:"reward"`
+	Symbol     string          `json:"symbol"`
+	Reward     economics.Coins `json:"reward"`
+	Status     string          `json:"status"`
+	EventTimes quality.Times   `json:"event_times"`
 }
 
+// serviceName keys this service's snapshot in tbl_service_state.
+const serviceName = "p_mining_incentive_calculator"
+
 var logs *log.Logger
 var config common.Config
 var db *sql.DB
@@ -142,11 +152,31 @@ func main() {
 
 	subsidy := getBlockSubsidy(config.Symbol)
 
+	// Fall back to the last snapshot when the subsidy was never stored, and
+	// keep the time it was last seen either way
+	var snapshot CurrentReward
+	_, err = state.Load(db, serviceName, config.Symbol, &snapshot)
+	if err != nil {
+		logs.Println("Error restoring state:", err)
+	}
+	if subsidy <= 0 {
+		subsidy = snapshot.Reward
+	}
+
 	currentReward = CurrentReward{
 		config.Symbol,
 		subsidy,
+		state.Ready,
+		make(quality.Times),
+	}
+	currentReward.EventTimes.Merge(snapshot.EventTimes)
+	if subsidy <= 0 {
+		currentReward.Status = state.WarmingUp
 	}
 
+	// Let the decision maker start from the restored reward
+	publishReward()
+
 	// Specify the topics you want to consume from
 	topics := config.Topics
 	// Create a context for the consumer group
@@ -189,7 +219,7 @@ func main() {
 
 }
 
-func getBlockSubsidy(blockchain string) float64 {
+func getBlockSubsidy(blockchain string) economics.Coins {
 	// Prepare the SELECT statement with placeholders for the key values
 	stmt, err := db.Prepare("SELECT subsidy, last_updated FROM tbl_blockchain_info WHERE blockchain=?")
 	if err != nil {
@@ -226,7 +256,7 @@ func getBlockSubsidy(blockchain string) float64 {
 	if err != nil {
 		logs.Fatal(err)
 	}
-	return subsidy
+	return economics.Coins(subsidy)
 }
 
 // ConsumerGroupHandler implements the sarama.ConsumerGroupHandler interface
@@ -267,27 +297,14 @@ func (h *ConsumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession,
 			}
 
 			// Create the OutputData struct
-			currentReward.Reward = input.Value
+			currentReward.Reward = economics.Coins(input.Value)
+			currentReward.Status = state.Ready
+			currentReward.EventTimes.Observe("subsidy", quality.EventTime(input.EventTime, message.Timestamp))
 
-			// Convert OutputData struct to JSON
-			OutputJSON, err := json.Marshal(currentReward)
+			publishReward()
+			err = state.Save(db, serviceName, config.Symbol, currentReward)
 			if err != nil {
-				logs.Println("Error marshaling blockchain subsidy data:", err)
-				continue
-			}
-
-			// Print the response
-			logs.Println("[OUT]: " + string(OutputJSON))
-
-			// Send the response to Kafka topic
-			message := &sarama.ProducerMessage{
-				Topic: config.Ptopic,
-				Value: sarama.StringEncoder(OutputJSON),
-			}
-			_, _, err = producer.SendMessage(message)
-			if err != nil {
-				logs.Println("Error sending message to Kafka:", err)
-				continue
+				logs.Println("Error saving state:", err)
 			}
 		default:
 		}
@@ -298,3 +315,25 @@ func (h *ConsumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession,
 
 	return nil
 }
+
+func publishReward() {
+	// Convert OutputData struct to JSON
+	OutputJSON, err := json.Marshal(currentReward)
+	if err != nil {
+		logs.Println("Error marshaling blockchain subsidy data:", err)
+		return
+	}
+
+	// Print the response
+	logs.Println("[OUT]: " + string(OutputJSON))
+
+	// Send the response to Kafka topic
+	message := &sarama.ProducerMessage{
+		Topic: config.Ptopic,
+		Value: sarama.StringEncoder(OutputJSON),
+	}
+	_, _, err = producer.SendMessage(message)
+	if err != nil {
+		logs.Println("Error sending message to Kafka:", err)
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"time"
+
+	fleet "profitmax/util/fleet"
+	forecast "profitmax/util/forecast"
+	logger "profitmax/util/logger"
+
+	"github.com/Shopify/sarama"
+	_ "github.com/go-sql-driver/mysql"
+)
+
+type DBConfig struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	User     string `json:"user"`
+	Password string `json:"password"`
+	Database string `json:"database"`
+}
+
+// Config is the service config with the sections only this service reads.
+type Config struct {
+	fleet.Config
+	Forecast forecast.Config `json:"forecast"`
+}
+
+var logs *log.Logger
+var config Config
+var db *sql.DB
+var producer sarama.SyncProducer
+
+func main() {
+	args := os.Args
+
+	if len(args) < 2 {
+		fmt.Println("Usage: p_price_forecast [Config File]", len(args))
+		fmt.Println("Example: p_price_forecast p_price_forecast.json")
+		return
+	}
+
+	// Read the JSON file
+	filePath := args[1]
+	fileData, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		log.Println("Error reading file:", err)
+		return
+	}
+
+	// Parse the JSON data into a struct
+	config = Config{}
+	err = json.Unmarshal(fileData, &config)
+	if err != nil {
+		log.Println("Error parsing JSON:", err)
+		return
+	}
+	// Create logs directory path
+	logsDir, err := logger.CreateLogsDirectory(config.LogPath)
+	if err != nil {
+		log.Fatal("Failed to create logs directory:", err)
+	}
+
+	// Create log file path based on the current date
+	logFilePath, err := logger.CreateLogFile(logsDir, config.LogFile)
+	if err != nil {
+		log.Fatal("Failed to create log file path:", err)
+	}
+
+	// Open the log file
+	logFile, err := os.OpenFile(logFilePath.Name(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Fatal("Failed to open log file:", err)
+	}
+	defer logFile.Close()
+
+	logs = log.New(logFile, "", log.LstdFlags)
+
+	// Check every model before forecasting anything
+	for _, modelConfig := range config.Forecast.Models {
+		_, err := forecast.New(modelConfig)
+		if err != nil {
+			logs.Fatal("Invalid model:", err)
+		}
+	}
+	if config.Forecast.CandleSeconds <= 0 || config.Forecast.LookbackHours <= 0 || config.Forecast.HorizonSteps <= 0 {
+		logs.Fatal("candle_seconds, lookback_hours and horizon_steps must be positive")
+	}
+	if !forecast.ValidLevel(config.Forecast.Level) {
+		logs.Fatal("level must be between 0 and 1:", config.Forecast.Level)
+	}
+
+	// Create a Kafka producer
+	producer, err = sarama.NewSyncProducer([]string{config.KafkaBroker}, nil)
+	if err != nil {
+		logs.Fatalln("Error creating Kafka producer:", config.KafkaBroker, err)
+		return
+	}
+	defer producer.Close()
+
+	// Open a connection to the MySQL database
+	// Read the JSON file
+	dbFilePath := "dbconfig.json"
+	dbFileData, err := ioutil.ReadFile(dbFilePath)
+	if err != nil {
+		logs.Println("Error reading file:", err)
+		return
+	}
+	// Parse the JSON data into a struct
+	var dbConfig DBConfig
+	err = json.Unmarshal(dbFileData, &dbConfig)
+	if err != nil {
+		logs.Println("Error parsing JSON:", err)
+		return
+	}
+
+	// Create the MySQL connection string
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s", dbConfig.User, dbConfig.Password, dbConfig.Host, dbConfig.Port, dbConfig.Database)
+
+	db, err = sql.Open("mysql", dsn)
+	if err != nil {
+		logs.Fatal("Error connecting to the database:", err)
+	}
+	defer db.Close()
+
+	// Create a ticker that ticks every x seconds
+	timeInterval := config.TimeInterval
+	ticker := time.NewTicker(time.Duration(timeInterval) * time.Second)
+
+	// Run the loop indefinitely
+	for range ticker.C {
+		for _, site := range config.SiteList() {
+			forecastSeries(forecast.EnergyPrice, site.LocationID)
+		}
+		forecastSeries(forecast.CryptoPrice, config.Symbol)
+	}
+}
+
+// forecastSeries trains every model on the recent candles of a series and
+// publishes their forecasts.
+func forecastSeries(series string, key string) {
+	now := time.Now()
+	ticks, err := forecast.LoadTicks(db, series, key, now.Add(-config.Forecast.Lookback()))
+	if err != nil {
+		logs.Println("Error loading ticks:", series, key, err)
+		return
+	}
+	if len(ticks) == 0 {
+		logs.Println("No ticks to forecast:", series, key)
+		return
+	}
+
+	candles, err := forecast.Candles(ticks, config.Forecast.Step())
+	if err != nil {
+		logs.Println("Error building candles:", series, key, err)
+		return
+	}
+	closes := forecast.Closes(candles)
+	last := candles[len(candles)-1].Start
+
+	for _, modelConfig := range config.Forecast.Models {
+		model, _ := forecast.New(modelConfig)
+		f, err := forecast.Run(model, closes, last, config.Forecast.Step(), config.Forecast.HorizonSteps, config.Forecast.Level)
+		if err != nil {
+			logs.Println("Error forecasting:", series, key, err)
+			continue
+		}
+		f.Series = series
+		f.Key = key
+		f.Currency = ticks[len(ticks)-1].Currency
+		f.Issued = now
+		publishForecast(f)
+	}
+}
+
+func publishForecast(f forecast.Forecast) {
+	// Convert Forecast struct to JSON
+	OutputJSON, err := json.Marshal(f)
+	if err != nil {
+		logs.Println("Error marshaling forecast data:", err)
+		return
+	}
+
+	// Print the response
+	logs.Println("[OUT]: " + string(OutputJSON))
+
+	// Send the response to Kafka topic, keyed by series
+	message := &sarama.ProducerMessage{
+		Topic: config.Ptopic,
+		Key:   sarama.StringEncoder(f.Series + "/" + f.Key),
+		Value: sarama.StringEncoder(OutputJSON),
+	}
+	_, _, err = producer.SendMessage(message)
+	if err != nil {
+		logs.Println("Error sending message to Kafka:", err)
+	}
+}
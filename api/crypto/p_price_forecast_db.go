@@ -0,0 +1,228 @@
+package main
+
+/*
+CREATE TABLE tbl_price_forecast (
+    series VARCHAR(20) NOT NULL,
+    series_key VARCHAR(10) NOT NULL,
+    model VARCHAR(20) NOT NULL,
+    issued DATETIME NOT NULL,
+    target_time DATETIME NOT NULL,
+    currency_code VARCHAR(10) NOT NULL,
+    value DECIMAL(18, 5) NOT NULL,
+    lower_bound DECIMAL(18, 5) NOT NULL,
+    upper_bound DECIMAL(18, 5) NOT NULL,
+    level DECIMAL(5, 4) NOT NULL,
+    PRIMARY KEY (series, series_key, model, issued, target_time),
+    INDEX (series, series_key, target_time)
+);
+*/
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	common "profitmax/util/common"
+	forecast "profitmax/util/forecast"
+	logger "profitmax/util/logger"
+	"sync"
+
+	"github.com/Shopify/sarama"
+	_ "github.com/go-sql-driver/mysql"
+)
+
+type DBConfig struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	User     string `json:"user"`
+	Password string `json:"password"`
+	Database string `json:"database"`
+}
+
+var logs *log.Logger
+var config common.Config
+var db *sql.DB
+var consumer sarama.Consumer
+
+func main() {
+	args := os.Args
+
+	if len(args) < 2 {
+		fmt.Println("Usage: p_price_forecast_db [Config File]", len(args))
+		fmt.Println("Example: p_price_forecast_db p_price_forecast_db.json")
+		return
+	}
+
+	// Read the JSON file
+	filePath := args[1]
+	fileData, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		fmt.Println("Error reading file:", err)
+		return
+	}
+
+	// Parse the JSON data into a struct
+	config = common.Config{}
+	err = json.Unmarshal(fileData, &config)
+	if err != nil {
+		fmt.Println("Error parsing JSON:", err)
+		return
+	}
+	// Create logs directory path
+	logsDir, err := logger.CreateLogsDirectory(config.LogPath)
+	if err != nil {
+		log.Fatal("Failed to create logs directory:", err)
+	}
+
+	// Create log file path based on the current date
+	logFilePath, err := logger.CreateLogFile(logsDir, config.LogFile)
+	if err != nil {
+		log.Fatal("Failed to create log file path:", err)
+	}
+
+	// Open the log file
+	logFile, err := os.OpenFile(logFilePath.Name(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Fatal("Failed to open log file:", err)
+	}
+	defer logFile.Close()
+
+	logs = log.New(logFile, "", log.LstdFlags)
+
+	// Configure the Kafka consumer
+	conf := sarama.NewConfig()
+	conf.Consumer.Return.Errors = true
+
+	// Kafka consumer group
+	group := "price_forecast_db"
+
+	// Create a new consumer
+	consumer, err := sarama.NewConsumerGroup([]string{config.KafkaBroker}, group, nil)
+	if err != nil {
+		logs.Fatal("Failed to create Kafka consumer:", err)
+	}
+	defer consumer.Close()
+
+	// Open a connection to the MySQL database
+	// Read the JSON file
+	dbFilePath := "dbconfig.json"
+	dbFileData, err := ioutil.ReadFile(dbFilePath)
+	if err != nil {
+		logs.Println("Error reading file:", err)
+		return
+	}
+	// Parse the JSON data into a struct
+	var dbConfig DBConfig
+	err = json.Unmarshal(dbFileData, &dbConfig)
+	if err != nil {
+		logs.Println("Error parsing JSON:", err)
+		return
+	}
+
+	// Create the MySQL connection string
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s", dbConfig.User, dbConfig.Password, dbConfig.Host, dbConfig.Port, dbConfig.Database)
+
+	db, err = sql.Open("mysql", dsn)
+	if err != nil {
+		logs.Fatal("Error connecting to the database:", err)
+	}
+	defer db.Close()
+
+	// Specify the topics you want to consume from
+	topics := config.Topics
+	// Create a context for the consumer group
+	ctx := context.Background()
+
+	// Create a signal channel to handle termination
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+
+	// Create a wait group to wait for the consumer group to finish
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+
+	// Start consuming messages in a separate goroutine
+	go func() {
+		defer wg.Done()
+
+		for {
+			select {
+			case <-signals:
+				// Interrupt signal received, stop consuming
+				consumer.Close()
+				return
+
+			default:
+				// Consume messages
+				err := consumer.Consume(ctx, topics, &ConsumerGroupHandler{})
+				if err != nil {
+					logs.Println("Error consuming messages:", err)
+				}
+			}
+		}
+	}()
+
+	// Wait for a termination signal
+	<-signals
+
+	// Wait for the consumer group to finish
+	wg.Wait()
+
+}
+
+// ConsumerGroupHandler implements the sarama.ConsumerGroupHandler interface
+type ConsumerGroupHandler struct{}
+
+// Setup is called when the consumer group session is being set up
+func (h *ConsumerGroupHandler) Setup(session sarama.ConsumerGroupSession) error {
+	logs.Println("Consumer group session is being set up")
+	return nil
+}
+
+// Cleanup is called when the consumer group session is ending
+func (h *ConsumerGroupHandler) Cleanup(session sarama.ConsumerGroupSession) error {
+	logs.Println("Consumer group session is ending")
+	return nil
+}
+
+// ConsumeClaim is called when a new set of messages is claimed by the consumer group
+func (h *ConsumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for message := range claim.Messages() {
+		logs.Printf("Message received: Topic=%s, Partition=%d, Offset=%d, Key=%s, Value=%s\n",
+			message.Topic, message.Partition, message.Offset, string(message.Key), string(message.Value))
+
+		switch message.Topic {
+		case "public.forecast":
+			insertTable(message)
+		default:
+		}
+
+		// Mark the message as processed
+		session.MarkMessage(message, "")
+	}
+
+	return nil
+}
+
+// insertTable stores every point of a forecast.
+func insertTable(msg *sarama.ConsumerMessage) {
+	// JSON data
+	jsonData := msg.Value
+
+	// Parse the JSON data into a Forecast struct
+	var input forecast.Forecast
+	err := json.Unmarshal(jsonData, &input)
+	if err != nil {
+		logs.Println("Error parsing JSON:", err)
+		return
+	}
+
+	err = forecast.Save(db, input)
+	if err != nil {
+		logs.Println("Error inserting data into table:", err)
+	}
+}
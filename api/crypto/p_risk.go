@@ -0,0 +1,255 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	common "profitmax/util/common"
+	forecast "profitmax/util/forecast"
+	logger "profitmax/util/logger"
+	risk "profitmax/util/risk"
+	scenario "profitmax/util/scenario"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+type DBConfig struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	User     string `json:"user"`
+	Password string `json:"password"`
+	Database string `json:"database"`
+}
+
+// RiskConfig is the risk engine's section of the config file.
+type RiskConfig struct {
+	Risk risk.Config `json:"risk"`
+}
+
+var logs *log.Logger
+var config common.Config
+var riskConfig RiskConfig
+
+func usage() {
+	fmt.Println("Usage: p_risk [Config File] [-paths N -seed N -calibrate LOCATION_ID -lookback DAYS -out FILE.csv] [Scenario File]")
+	fmt.Println("Example: p_risk p_risk.json -paths 2000 -calibrate QLD1 -out risk.csv scenario_base.json")
+}
+
+func main() {
+	args := os.Args
+
+	if len(args) < 3 {
+		usage()
+		return
+	}
+
+	// Read the JSON file
+	filePath := args[1]
+	fileData, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		log.Println("Error reading file:", err)
+		return
+	}
+
+	// Parse the JSON data into a struct
+	config = common.Config{}
+	err = json.Unmarshal(fileData, &config)
+	if err != nil {
+		log.Println("Error parsing JSON:", err)
+		return
+	}
+	err = json.Unmarshal(fileData, &riskConfig)
+	if err != nil {
+		log.Println("Error parsing JSON:", err)
+		return
+	}
+	// Create logs directory path
+	logsDir, err := logger.CreateLogsDirectory(config.LogPath)
+	if err != nil {
+		log.Fatal("Failed to create logs directory:", err)
+	}
+
+	// Create log file path based on the current date
+	logFilePath, err := logger.CreateLogFile(logsDir, config.LogFile)
+	if err != nil {
+		log.Fatal("Failed to create log file path:", err)
+	}
+
+	// Open the log file
+	logFile, err := os.OpenFile(logFilePath.Name(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Fatal("Failed to open log file:", err)
+	}
+	defer logFile.Close()
+
+	logs = log.New(logFile, "", log.LstdFlags)
+
+	err = run(args[2:])
+	if err != nil {
+		logs.Println("Error:", err)
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+}
+
+// run simulates a scenario file and prints its risk report.
+func run(args []string) error {
+	var location, out string
+	var lookbackDays int
+	settings := riskConfig.Risk
+
+	flags := flag.NewFlagSet("risk", flag.ContinueOnError)
+	flags.IntVar(&settings.Paths, "paths", settings.Paths, "number of paths to simulate")
+	flags.Int64Var(&settings.Seed, "seed", settings.Seed, "random seed")
+	flags.StringVar(&location, "calibrate", "", "location to calibrate the energy price model to")
+	flags.IntVar(&lookbackDays, "lookback", 90, "days of price history to calibrate to")
+	flags.StringVar(&out, "out", "", "CSV file to write each month's profit distribution to")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		usage()
+		return fmt.Errorf("one scenario file is required")
+	}
+
+	fileData, err := ioutil.ReadFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	var s scenario.Scenario
+	err = json.Unmarshal(fileData, &s)
+	if err != nil {
+		return fmt.Errorf("%s: %v", flags.Arg(0), err)
+	}
+
+	if location != "" {
+		err = calibrate(&settings, location, time.Now().AddDate(0, 0, -lookbackDays))
+		if err != nil {
+			return err
+		}
+	}
+	settingsJSON, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	logs.Println("Simulating", s.Name, "with", string(settingsJSON))
+
+	report, err := risk.Simulate(s, settings)
+	if err != nil {
+		return err
+	}
+	reportJSON, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	logs.Println("[OUT]: " + string(reportJSON))
+	printReport(report)
+
+	if out != "" {
+		return writeMonths(out, report)
+	}
+	return nil
+}
+
+// calibrate fits the energy price model to a location's hourly prices, and
+// the crypto price's drift and volatility to the configured symbol's, since
+// from. Prices are taken as they were recorded, in their own currency.
+func calibrate(settings *risk.Config, location string, from time.Time) error {
+	// Open a connection to the MySQL database
+	// Read the JSON file
+	dbFilePath := "dbconfig.json"
+	dbFileData, err := ioutil.ReadFile(dbFilePath)
+	if err != nil {
+		return err
+	}
+	// Parse the JSON data into a struct
+	var dbConfig DBConfig
+	err = json.Unmarshal(dbFileData, &dbConfig)
+	if err != nil {
+		return err
+	}
+
+	// Create the MySQL connection string
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s", dbConfig.User, dbConfig.Password, dbConfig.Host, dbConfig.Port, dbConfig.Database)
+
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ticks, err := forecast.LoadTicks(db, forecast.EnergyPrice, location, from)
+	if err != nil {
+		return err
+	}
+	candles, err := forecast.Candles(ticks, time.Hour)
+	if err != nil {
+		return err
+	}
+	prices := forecast.Closes(candles)
+	if len(prices) < 3 {
+		return fmt.Errorf("not enough energy prices at %s to calibrate to", location)
+	}
+	settings.Energy = risk.CalibrateEnergy(prices, time.Hour)
+
+	ticks, err = forecast.LoadTicks(db, forecast.CryptoPrice, config.Symbol, from)
+	if err != nil {
+		return err
+	}
+	candles, err = forecast.Candles(ticks, time.Hour)
+	if err != nil {
+		return err
+	}
+	crypto := risk.CalibrateCrypto(forecast.Closes(candles), time.Hour)
+	if crypto.Volatility > 0 {
+		settings.Crypto.Drift = crypto.Drift
+		settings.Crypto.Volatility = crypto.Volatility
+	}
+	return nil
+}
+
+func printReport(r risk.Report) {
+	fmt.Printf("Scenario %s (%s), %d paths\n", r.Scenario, r.Currency, r.Paths)
+	fmt.Printf("%-8s %12s %12s %12s %12s %8s\n", "Month", "Mean", "P5", "P50", "P95", "P(loss)")
+	for _, m := range r.Months {
+		fmt.Printf("%-8s %12.2f %12.2f %12.2f %12.2f %7.1f%%\n", m.Month, m.Mean, m.P5, m.P50, m.P95, m.LossProbability*100)
+	}
+	fmt.Printf("Profit mean %.2f, P5 %.2f, P50 %.2f, P95 %.2f\n", r.Profit.Mean, r.Profit.P5, r.Profit.P50, r.Profit.P95)
+	fmt.Printf("VaR(%.0f%%) %.2f, CVaR(%.0f%%) %.2f\n", r.Level*100, r.VaR, r.Level*100, r.CVaR)
+	fmt.Printf("Probability of a loss over the scenario %.1f%%, in any one month %.1f%%\n", r.Profit.LossProbability*100, r.MonthLossProbability*100)
+}
+
+// writeMonths writes each month's profit distribution to a CSV file.
+func writeMonths(path string, r risk.Report) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"scenario", "currency", "month", "mean", "p5", "p50", "p95", "loss_probability"})
+	for _, m := range r.Months {
+		writer.Write([]string{
+			r.Scenario,
+			r.Currency,
+			m.Month,
+			strconv.FormatFloat(float64(m.Mean), 'f', 2, 64),
+			strconv.FormatFloat(float64(m.P5), 'f', 2, 64),
+			strconv.FormatFloat(float64(m.P50), 'f', 2, 64),
+			strconv.FormatFloat(float64(m.P95), 'f', 2, 64),
+			strconv.FormatFloat(m.LossProbability, 'f', 4, 64),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+
+	decision "profitmax/util/decision"
+	economics "profitmax/util/economics"
+	fleet "profitmax/util/fleet"
+	forecast "profitmax/util/forecast"
+	logger "profitmax/util/logger"
+	schedule "profitmax/util/schedule"
+	state "profitmax/util/state"
+
+	"github.com/Shopify/sarama"
+	_ "github.com/go-sql-driver/mysql"
+)
+
+type DBConfig struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	User     string `json:"user"`
+	Password string `json:"password"`
+	Database string `json:"database"`
+}
+
+// BreakEvenInput is the part of a site's break-even message the scheduler
+// uses.
+type BreakEvenInput struct {
+	LocationID string           `json:"location_id"`
+	Currency   string           `json:"currency"`
+	Hashprice  economics.Amount `json:"hashprice"`
+}
+
+// Config is the service config with the sections only this service reads.
+type Config struct {
+	fleet.Config
+	Schedule schedule.Config `json:"schedule"`
+}
+
+// serviceName keys this service's snapshots in tbl_service_state.
+const serviceName = "p_run_scheduler"
+
+var logs *log.Logger
+var config Config
+var db *sql.DB
+var producer sarama.SyncProducer
+var curves = make(map[string]forecast.Forecast)
+var hashprices = make(map[string]BreakEvenInput)
+var currents = make(map[string]*schedule.Current)
+var inputMutex sync.Mutex
+
+func main() {
+	args := os.Args
+
+	if len(args) < 2 {
+		fmt.Println("Usage: p_run_scheduler [Config File]", len(args))
+		fmt.Println("Example: p_run_scheduler p_run_scheduler.json")
+		return
+	}
+
+	// Read the JSON file
+	filePath := args[1]
+	fileData, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		log.Println("Error reading file:", err)
+		return
+	}
+
+	// Parse the JSON data into a struct
+	config = Config{}
+	err = json.Unmarshal(fileData, &config)
+	if err != nil {
+		log.Println("Error parsing JSON:", err)
+		return
+	}
+	// Create logs directory path
+	logsDir, err := logger.CreateLogsDirectory(config.LogPath)
+	if err != nil {
+		log.Fatal("Failed to create logs directory:", err)
+	}
+
+	// Create log file path based on the current date
+	logFilePath, err := logger.CreateLogFile(logsDir, config.LogFile)
+	if err != nil {
+		log.Fatal("Failed to create log file path:", err)
+	}
+
+	// Open the log file
+	logFile, err := os.OpenFile(logFilePath.Name(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Fatal("Failed to open log file:", err)
+	}
+	defer logFile.Close()
+
+	logs = log.New(logFile, "", log.LstdFlags)
+
+	if config.Schedule.IntervalMinutes <= 0 || config.Schedule.Intervals <= 0 || config.Schedule.Levels <= 0 {
+		logs.Fatal("interval_minutes, intervals and levels must be positive")
+	}
+
+	// Open a connection to the MySQL database
+	// Read the JSON file
+	dbFilePath := "dbconfig.json"
+	dbFileData, err := ioutil.ReadFile(dbFilePath)
+	if err != nil {
+		logs.Println("Error reading file:", err)
+		return
+	}
+	// Parse the JSON data into a struct
+	var dbConfig DBConfig
+	err = json.Unmarshal(dbFileData, &dbConfig)
+	if err != nil {
+		logs.Println("Error parsing JSON:", err)
+		return
+	}
+
+	// Create the MySQL connection string
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s", dbConfig.User, dbConfig.Password, dbConfig.Host, dbConfig.Port, dbConfig.Database)
+
+	db, err = sql.Open("mysql", dsn)
+	if err != nil {
+		logs.Fatal("Error connecting to the database:", err)
+	}
+	defer db.Close()
+
+	// Start every site from the run state it was last in
+	for _, site := range config.SiteList() {
+		var current schedule.Current
+		found, err := state.Load(db, serviceName, site.LocationID, &current)
+		if err != nil {
+			logs.Println("Error restoring state:", err)
+			continue
+		}
+		if found {
+			currents[site.LocationID] = &current
+		}
+	}
+
+	// Configure the Kafka consumer
+	conf := sarama.NewConfig()
+	conf.Consumer.Return.Errors = true
+
+	// Kafka consumer group
+	group := "run_scheduler"
+
+	// Create a new consumer
+	consumer, err := sarama.NewConsumerGroup([]string{config.KafkaBroker}, group, nil)
+	if err != nil {
+		logs.Fatal("Failed to create Kafka consumer:", err)
+	}
+	defer consumer.Close()
+
+	// Create a Kafka producer
+	producer, err = sarama.NewSyncProducer([]string{config.KafkaBroker}, nil)
+	if err != nil {
+		logs.Fatalln("Error creating Kafka producer:", config.KafkaBroker, err)
+		return
+	}
+	defer producer.Close()
+
+	// Re-solve every site's schedule on a schedule
+	timeInterval := config.TimeInterval
+	ticker := time.NewTicker(time.Duration(timeInterval) * time.Second)
+	defer ticker.Stop()
+	go func() {
+		for range ticker.C {
+			inputMutex.Lock()
+			for _, site := range config.SiteList() {
+				scheduleSite(site)
+			}
+			inputMutex.Unlock()
+		}
+	}()
+
+	// Specify the topics you want to consume from
+	topics := config.Topics
+	// Create a context for the consumer group
+	ctx := context.Background()
+
+	// Create a signal channel to handle termination
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+
+	// Create a wait group to wait for the consumer group to finish
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+
+	// Start consuming messages in a separate goroutine
+	go func() {
+		defer wg.Done()
+
+		for {
+			select {
+			case <-signals:
+				// Interrupt signal received, stop consuming
+				consumer.Close()
+				return
+
+			default:
+				// Consume messages
+				err := consumer.Consume(ctx, topics, &ConsumerGroupHandler{})
+				if err != nil {
+					logs.Println("Error consuming messages:", err)
+				}
+			}
+		}
+	}()
+
+	// Wait for a termination signal
+	<-signals
+
+	// Wait for the consumer group to finish
+	wg.Wait()
+
+}
+
+// ConsumerGroupHandler implements the sarama.ConsumerGroupHandler interface
+type ConsumerGroupHandler struct{}
+
+// Setup is called when the consumer group session is being set up
+func (h *ConsumerGroupHandler) Setup(session sarama.ConsumerGroupSession) error {
+	logs.Println("Consumer group session is being set up")
+	return nil
+}
+
+// Cleanup is called when the consumer group session is ending
+func (h *ConsumerGroupHandler) Cleanup(session sarama.ConsumerGroupSession) error {
+	logs.Println("Consumer group session is ending")
+	return nil
+}
+
+// ConsumeClaim is called when a new set of messages is claimed by the consumer group
+func (h *ConsumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for message := range claim.Messages() {
+		logs.Printf("Message received: Topic=%s, Partition=%d, Offset=%d, Key=%s, Value=%s\n",
+			message.Topic, message.Partition, message.Offset, string(message.Key), string(message.Value))
+
+		inputMutex.Lock()
+		switch message.Topic {
+		case "public.forecast":
+			//
+			// JSON data
+			jsonData := message.Value
+
+			// Parse the JSON data into a Forecast struct
+			var input forecast.Forecast
+			err := json.Unmarshal(jsonData, &input)
+			if err != nil {
+				logs.Println("Error parsing JSON:", err)
+				break
+			}
+
+			// Only energy price curves from the configured model
+			if input.Series != forecast.EnergyPrice {
+				break
+			}
+			if config.Schedule.Curve != "" && input.Model != config.Schedule.Curve {
+				break
+			}
+			curves[input.Key] = input
+		case "public.mining.breakeven":
+			//
+			// JSON data
+			jsonData := message.Value
+
+			// Parse the JSON data into a BreakEvenInput struct
+			var input BreakEvenInput
+			err := json.Unmarshal(jsonData, &input)
+			if err != nil {
+				logs.Println("Error parsing JSON:", err)
+				break
+			}
+			hashprices[input.LocationID] = input
+		case "private.mining.decision":
+			//
+			// JSON data
+			jsonData := message.Value
+
+			// Parse the JSON data into a Decision struct
+			var input decision.Decision
+			err := json.Unmarshal(jsonData, &input)
+			if err != nil {
+				logs.Println("Error parsing JSON:", err)
+				break
+			}
+			trackDecision(input)
+		default:
+		}
+		inputMutex.Unlock()
+
+		// Mark the message as processed
+		session.MarkMessage(message, "")
+	}
+
+	return nil
+}
+
+// trackDecision keeps the run state a site's schedule starts from up to date
+// with the decisions issued for it. Starts are kept for two days, enough to
+// count those of the day a schedule starts on.
+func trackDecision(input decision.Decision) {
+	// With no history, assume the site only just entered its state, so its
+	// minimum up or down time and a start count against the first schedule
+	up := input.RunFraction > 0
+	current, ok := currents[input.LocationID]
+	if !ok {
+		current = &schedule.Current{Since: input.Timestamp}
+		if up {
+			current.Starts = []time.Time{input.Timestamp}
+		}
+		currents[input.LocationID] = current
+	} else if up != (current.RunFraction > 0) {
+		current.Since = input.Timestamp
+		if up {
+			current.Starts = append(current.Starts, input.Timestamp)
+		}
+	}
+	current.RunFraction = input.RunFraction
+
+	starts := current.Starts[:0]
+	for _, at := range current.Starts {
+		if input.Timestamp.Sub(at) < 48*time.Hour {
+			starts = append(starts, at)
+		}
+	}
+	current.Starts = starts
+
+	err := state.Save(db, serviceName, input.LocationID, current)
+	if err != nil {
+		logs.Println("Error saving state:", err)
+	}
+}
+
+// scheduleSite solves a site's run schedule from the next interval on, over
+// as much of the schedule as its price curve covers, and publishes it. It
+// waits for the site's curve and hashprice, and fails rather than mix
+// currencies.
+func scheduleSite(site fleet.Site) {
+	if len(site.Fleet) == 0 {
+		return
+	}
+	curve, ok := curves[site.LocationID]
+	if !ok {
+		logs.Println("No price curve yet:", site.LocationID)
+		return
+	}
+	breakEven, ok := hashprices[site.LocationID]
+	if !ok || breakEven.Hashprice <= 0 {
+		logs.Println("No hashprice yet:", site.LocationID)
+		return
+	}
+	if curve.Currency != breakEven.Currency {
+		logs.Println("Price curve and hashprice currencies differ:", site.LocationID, curve.Currency, breakEven.Currency)
+		return
+	}
+
+	now := time.Now()
+	interval := config.Schedule.Interval()
+	start := now.Truncate(interval).Add(interval)
+	var prices []economics.PricePerMWh
+	for i := 0; i < config.Schedule.Intervals; i++ {
+		price, ok := curve.At(start.Add(time.Duration(i) * interval))
+		if !ok {
+			break
+		}
+		prices = append(prices, economics.PricePerMWh(price))
+	}
+
+	result, err := schedule.Solve(site.Classes(), breakEven.Hashprice, prices, start, interval, config.Schedule.Levels, config.Schedule.Constraints, currents[site.LocationID])
+	if err != nil {
+		logs.Println("Error solving schedule:", site.LocationID, err)
+		return
+	}
+	result.LocationID = site.LocationID
+	result.Currency = breakEven.Currency
+	result.Issued = now
+
+	// Convert Schedule struct to JSON
+	OutputJSON, err := json.Marshal(result)
+	if err != nil {
+		logs.Println("Error marshaling schedule data:", err)
+		return
+	}
+
+	// Print the response
+	logs.Println("[OUT]: " + string(OutputJSON))
+
+	// Send the response to Kafka topic, keyed by site
+	message := &sarama.ProducerMessage{
+		Topic: config.Ptopic,
+		Key:   sarama.StringEncoder(result.LocationID),
+		Value: sarama.StringEncoder(OutputJSON),
+	}
+	_, _, err = producer.SendMessage(message)
+	if err != nil {
+		logs.Println("Error sending message to Kafka:", err)
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strconv"
+
+	common "profitmax/util/common"
+	logger "profitmax/util/logger"
+	scenario "profitmax/util/scenario"
+)
+
+var logs *log.Logger
+var config common.Config
+
+func usage() {
+	fmt.Println("Usage: p_scenario [Config File] [-out FILE.csv] [Scenario File]...")
+	fmt.Println("Example: p_scenario p_scenario.json -out projection.csv scenario_base.json")
+}
+
+func main() {
+	args := os.Args
+
+	if len(args) < 3 {
+		usage()
+		return
+	}
+
+	// Read the JSON file
+	filePath := args[1]
+	fileData, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		log.Println("Error reading file:", err)
+		return
+	}
+
+	// Parse the JSON data into a struct
+	config = common.Config{}
+	err = json.Unmarshal(fileData, &config)
+	if err != nil {
+		log.Println("Error parsing JSON:", err)
+		return
+	}
+	// Create logs directory path
+	logsDir, err := logger.CreateLogsDirectory(config.LogPath)
+	if err != nil {
+		log.Fatal("Failed to create logs directory:", err)
+	}
+
+	// Create log file path based on the current date
+	logFilePath, err := logger.CreateLogFile(logsDir, config.LogFile)
+	if err != nil {
+		log.Fatal("Failed to create log file path:", err)
+	}
+
+	// Open the log file
+	logFile, err := os.OpenFile(logFilePath.Name(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Fatal("Failed to open log file:", err)
+	}
+	defer logFile.Close()
+
+	logs = log.New(logFile, "", log.LstdFlags)
+
+	err = run(args[2:])
+	if err != nil {
+		logs.Println("Error:", err)
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+}
+
+// run projects every scenario file and prints each projection month by
+// month.
+func run(args []string) error {
+	var out string
+	flags := flag.NewFlagSet("scenario", flag.ContinueOnError)
+	flags.StringVar(&out, "out", "", "CSV file to write every scenario's months to")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		usage()
+		return fmt.Errorf("no scenario files")
+	}
+
+	var projections []scenario.Projection
+	for _, path := range flags.Args() {
+		fileData, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var s scenario.Scenario
+		err = json.Unmarshal(fileData, &s)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		projection, err := scenario.Project(s)
+		if err != nil {
+			return err
+		}
+		projectionJSON, err := json.Marshal(projection)
+		if err != nil {
+			return err
+		}
+		logs.Println("[OUT]: " + string(projectionJSON))
+		printProjection(projection)
+		projections = append(projections, projection)
+	}
+
+	if out != "" {
+		return writeProjections(out, projections)
+	}
+	return nil
+}
+
+func printProjection(p scenario.Projection) {
+	fmt.Printf("Scenario %s (%s)\n", p.Scenario, p.Currency)
+	fmt.Printf("%-8s %12s %10s %12s %12s %12s %12s %14s\n", "Month", "Crypto", "Run", "Revenue", "Energy", "Other", "Profit", "Cumulative")
+	for _, m := range p.Months {
+		fmt.Printf("%-8s %12.2f %9.1f%% %12.2f %12.2f %12.2f %12.2f %14.2f\n",
+			m.Month, m.CryptoPrice, m.RunShare*100, m.Revenue, m.EnergyCost, m.OtherCost, m.Profit, m.Cumulative)
+	}
+	fmt.Printf("Revenue %.2f, cost %.2f, profit %.2f, investment %.2f\n", p.Revenue, p.Cost, p.Profit, p.Investment)
+	if p.PaybackMonth != "" {
+		fmt.Println("Payback in", p.PaybackMonth)
+	} else if p.Investment > 0 {
+		fmt.Println("No payback within the scenario")
+	}
+	fmt.Println()
+}
+
+// writeProjections writes every scenario's months to a CSV file.
+func writeProjections(path string, projections []scenario.Projection) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"scenario", "currency", "month", "crypto_price", "difficulty", "reward", "energy_price", "run_share", "energy_mwh", "coins", "revenue", "energy_cost", "other_cost", "profit", "cash_flow", "cumulative"})
+	for _, p := range projections {
+		for _, m := range p.Months {
+			writer.Write([]string{
+				p.Scenario,
+				p.Currency,
+				m.Month,
+				strconv.FormatFloat(float64(m.CryptoPrice), 'f', 2, 64),
+				strconv.FormatFloat(m.Difficulty, 'f', 0, 64),
+				strconv.FormatFloat(float64(m.Reward), 'f', 8, 64),
+				strconv.FormatFloat(float64(m.EnergyPrice), 'f', 5, 64),
+				strconv.FormatFloat(m.RunShare, 'f', 4, 64),
+				strconv.FormatFloat(m.EnergyMWh, 'f', 3, 64),
+				strconv.FormatFloat(float64(m.Coins), 'f', 8, 64),
+				strconv.FormatFloat(float64(m.Revenue), 'f', 2, 64),
+				strconv.FormatFloat(float64(m.EnergyCost), 'f', 2, 64),
+				strconv.FormatFloat(float64(m.OtherCost), 'f', 2, 64),
+				strconv.FormatFloat(float64(m.Profit), 'f', 2, 64),
+				strconv.FormatFloat(float64(m.CashFlow), 'f', 2, 64),
+				strconv.FormatFloat(float64(m.Cumulative), 'f', 2, 64),
+			})
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
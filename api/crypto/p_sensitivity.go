@@ -0,0 +1,319 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	costmodel "profitmax/util/costmodel"
+	decision "profitmax/util/decision"
+	fleet "profitmax/util/fleet"
+	fx "profitmax/util/fx"
+	logger "profitmax/util/logger"
+	sensitivity "profitmax/util/sensitivity"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+type DBConfig struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	User     string `json:"user"`
+	Password string `json:"password"`
+	Database string `json:"database"`
+}
+
+// Config is the service config with the sections only this service reads.
+type Config struct {
+	fleet.Config
+	Policy decision.Policy `json:"policy"`
+	Listen string          `json:"listen"`
+}
+
+var logs *log.Logger
+var config Config
+var db *sql.DB
+
+func usage() {
+	fmt.Println("Usage: p_sensitivity [Config File] [-location LOCATION_ID -range F -hours N -curtail -out FILE.csv | -serve]")
+	fmt.Println("Example: p_sensitivity p_sensitivity.json -location QLD1 -range 0.2 -out tornado.csv")
+}
+
+func main() {
+	args := os.Args
+
+	if len(args) < 2 {
+		usage()
+		return
+	}
+
+	// Read the JSON file
+	filePath := args[1]
+	fileData, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		log.Println("Error reading file:", err)
+		return
+	}
+
+	// Parse the JSON data into a struct
+	config = Config{}
+	err = json.Unmarshal(fileData, &config)
+	if err != nil {
+		log.Println("Error parsing JSON:", err)
+		return
+	}
+	// Create logs directory path
+	logsDir, err := logger.CreateLogsDirectory(config.LogPath)
+	if err != nil {
+		log.Fatal("Failed to create logs directory:", err)
+	}
+
+	// Create log file path based on the current date
+	logFilePath, err := logger.CreateLogFile(logsDir, config.LogFile)
+	if err != nil {
+		log.Fatal("Failed to create log file path:", err)
+	}
+
+	// Open the log file
+	logFile, err := os.OpenFile(logFilePath.Name(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Fatal("Failed to open log file:", err)
+	}
+	defer logFile.Close()
+
+	logs = log.New(logFile, "", log.LstdFlags)
+
+	// Open a connection to the MySQL database
+	// Read the JSON file
+	dbFilePath := "dbconfig.json"
+	dbFileData, err := ioutil.ReadFile(dbFilePath)
+	if err != nil {
+		logs.Println("Error reading file:", err)
+		return
+	}
+	// Parse the JSON data into a struct
+	var dbConfig DBConfig
+	err = json.Unmarshal(dbFileData, &dbConfig)
+	if err != nil {
+		logs.Println("Error parsing JSON:", err)
+		return
+	}
+
+	// Create the MySQL connection string
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s", dbConfig.User, dbConfig.Password, dbConfig.Host, dbConfig.Port, dbConfig.Database)
+
+	db, err = sql.Open("mysql", dsn)
+	if err != nil {
+		logs.Fatal("Error connecting to the database:", err)
+	}
+	defer db.Close()
+
+	err = run(args[2:])
+	if err != nil {
+		logs.Println("Error:", err)
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+}
+
+// run reports the sensitivity of every site, or only -location, at the
+// current operating point, or with -serve answers the same over HTTP.
+func run(args []string) error {
+	var location, out string
+	var rangeFraction float64
+	var hours int
+	var curtail, listen bool
+
+	flags := flag.NewFlagSet("sensitivity", flag.ContinueOnError)
+	flags.StringVar(&location, "location", "", "only report this location")
+	flags.Float64Var(&rangeFraction, "range", 0.2, "fraction each driver moves either way")
+	flags.IntVar(&hours, "hours", 24, "hours of profit to report")
+	flags.BoolVar(&curtail, "curtail", false, "curtail below the policy's margin threshold")
+	flags.StringVar(&out, "out", "", "CSV file to write the tornado chart data to")
+	flags.BoolVar(&listen, "serve", false, "serve the sensitivity API instead")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if listen {
+		return serve()
+	}
+
+	reports, err := analyse(location, rangeFraction, time.Duration(hours)*time.Hour, curtail, time.Now())
+	if err != nil {
+		return err
+	}
+	for _, report := range reports {
+		reportJSON, err := json.Marshal(report)
+		if err != nil {
+			return err
+		}
+		logs.Println("[OUT]: " + string(reportJSON))
+		printReport(report)
+	}
+	if out != "" {
+		return writeTornado(out, reports)
+	}
+	return nil
+}
+
+// analyse reports the sensitivity of every site with a fleet, or only the
+// one at location, at its current operating point.
+func analyse(location string, rangeFraction float64, period time.Duration, curtail bool, now time.Time) ([]sensitivity.Report, error) {
+	converter := fx.NewConverter()
+	err := fx.LoadRates(db, converter)
+	if err != nil {
+		return nil, err
+	}
+
+	var reports []sensitivity.Report
+	for _, site := range config.SiteList() {
+		if location != "" && site.LocationID != location {
+			continue
+		}
+		if len(site.Fleet) == 0 {
+			continue
+		}
+		items, err := costmodel.LoadItems(db, site.LocationID)
+		if err != nil {
+			return nil, err
+		}
+		point, err := sensitivity.LoadPoint(db, config.Symbol, site, converter, now)
+		if err != nil {
+			return nil, err
+		}
+		model := sensitivity.Model{
+			Site:            site,
+			Items:           items,
+			Converter:       converter,
+			Period:          period,
+			Curtail:         curtail,
+			MarginThreshold: config.Policy.MarginThreshold,
+		}
+		report, err := sensitivity.Analyse(model, point, rangeFraction)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+	if location != "" && len(reports) == 0 {
+		return nil, fmt.Errorf("no site with a fleet at %s", location)
+	}
+	return reports, nil
+}
+
+func printReport(r sensitivity.Report) {
+	p := r.Point
+	fmt.Printf("Site %s (%s) over %s\n", p.LocationID, p.Currency, r.Period)
+	fmt.Printf("Crypto price %.2f, difficulty %.0f, subsidy %.4f, fees %.4f, energy price %.2f/MWh, efficiency %.2f J/TH\n",
+		p.CryptoPrice, p.Difficulty, p.Subsidy, p.Fees, p.EnergyPrice, p.Efficiency)
+	fmt.Printf("Revenue %.2f, energy %.2f, other %.2f, profit %.2f, margin %.4f\n",
+		r.Base.Revenue, r.Base.EnergyCost, r.Base.OtherCost, r.Base.Profit, r.Base.Margin)
+	fmt.Printf("%-14s %12s %12s %12s %12s %12s\n", "Driver", "Per 1%", "Elasticity", fmt.Sprintf("-%.0f%%", r.Range*100), fmt.Sprintf("+%.0f%%", r.Range*100), "Swing")
+	for _, s := range r.Sensitivities {
+		fmt.Printf("%-14s %12.2f %12.2f %12.2f %12.2f %12.2f\n", s.Driver, s.Delta, s.Elasticity, s.Low, s.High, s.Swing)
+	}
+	fmt.Println()
+}
+
+// writeTornado writes each site's profit at either end of every driver's
+// range to a CSV file, largest swing first.
+func writeTornado(path string, reports []sensitivity.Report) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"location_id", "currency", "driver", "range", "base", "low", "high", "swing", "delta", "elasticity"})
+	for _, r := range reports {
+		for _, s := range r.Sensitivities {
+			writer.Write([]string{
+				r.Point.LocationID,
+				r.Point.Currency,
+				s.Driver,
+				strconv.FormatFloat(r.Range, 'f', 4, 64),
+				strconv.FormatFloat(float64(r.Base.Profit), 'f', 2, 64),
+				strconv.FormatFloat(float64(s.Low), 'f', 2, 64),
+				strconv.FormatFloat(float64(s.High), 'f', 2, 64),
+				strconv.FormatFloat(float64(s.Swing), 'f', 2, 64),
+				strconv.FormatFloat(float64(s.Delta), 'f', 2, 64),
+				strconv.FormatFloat(s.Elasticity, 'f', 4, 64),
+			})
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// serve answers the sensitivity API:
+//
+//	GET /sensitivity[?location_id=L&range=F&hours=N&curtail=true]   sensitivity at the current operating point
+func serve() error {
+	http.HandleFunc("/sensitivity", handleSensitivity)
+
+	logs.Println("Serving sensitivity API on", config.Listen)
+	fmt.Println("Serving sensitivity API on", config.Listen)
+	return http.ListenAndServe(config.Listen, nil)
+}
+
+func handleSensitivity(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	query := r.URL.Query()
+	rangeFraction, hours, curtail := 0.2, 24, false
+	var err error
+	if value := query.Get("range"); value != "" {
+		rangeFraction, err = strconv.ParseFloat(value, 64)
+		if err != nil || rangeFraction <= 0 || rangeFraction >= 1 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("range must be between 0 and 1"))
+			return
+		}
+	}
+	if value := query.Get("hours"); value != "" {
+		hours, err = strconv.Atoi(value)
+		if err != nil || hours <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("hours must be a positive number"))
+			return
+		}
+	}
+	if value := query.Get("curtail"); value != "" {
+		curtail, err = strconv.ParseBool(value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	reports, err := analyse(query.Get("location_id"), rangeFraction, time.Duration(hours)*time.Hour, curtail, time.Now())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if reports == nil {
+		reports = []sensitivity.Report{}
+	}
+	writeJSON(w, http.StatusOK, reports)
+}
+
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	logs.Println("Error:", err)
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,3 @@
+cd C:\ProfitMax\api\crypto
+
+p_analytics.exe p_analytics.json
\ No newline at end of file
@@ -0,0 +1,3 @@
+cd C:\ProfitMax\api\crypto
+
+p_forecast_scorer.exe p_forecast_scorer.json
\ No newline at end of file
@@ -0,0 +1,3 @@
+cd C:\ProfitMax\api\crypto
+
+p_fx_rate_api.exe p_fx_rate_api.json
\ No newline at end of file
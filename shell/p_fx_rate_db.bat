@@ -0,0 +1,3 @@
+cd C:\ProfitMax\api\crypto
+
+p_fx_rate_db.exe p_fx_rate_db.json
\ No newline at end of file
@@ -0,0 +1,3 @@
+cd C:\ProfitMax\api\crypto
+
+p_inventory.exe p_inventory.json serve
\ No newline at end of file
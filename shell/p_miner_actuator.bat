@@ -0,0 +1,3 @@
+cd C:\ProfitMax\api\crypto
+
+p_miner_actuator.exe p_miner_actuator.json
\ No newline at end of file
@@ -0,0 +1,3 @@
+cd C:\ProfitMax\api\crypto
+
+p_miner_simulator.exe p_miner_simulator.json
\ No newline at end of file
@@ -0,0 +1,3 @@
+cd C:\ProfitMax\api\crypto
+
+p_miner_telemetry.exe p_miner_telemetry.json
\ No newline at end of file
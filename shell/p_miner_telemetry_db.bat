@@ -0,0 +1,3 @@
+cd C:\ProfitMax\api\crypto
+
+p_miner_telemetry_db.exe p_miner_telemetry_db.json
\ No newline at end of file
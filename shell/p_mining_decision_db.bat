@@ -0,0 +1,3 @@
+cd C:\ProfitMax\api\crypto
+
+p_mining_decision_db.exe p_mining_decision_db.json
\ No newline at end of file
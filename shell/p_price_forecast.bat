@@ -0,0 +1,3 @@
+cd C:\ProfitMax\api\crypto
+
+p_price_forecast.exe p_price_forecast.json
\ No newline at end of file
@@ -0,0 +1,3 @@
+cd C:\ProfitMax\api\crypto
+
+p_price_forecast_db.exe p_price_forecast_db.json
\ No newline at end of file
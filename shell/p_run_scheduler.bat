@@ -0,0 +1,3 @@
+cd C:\ProfitMax\api\crypto
+
+p_run_scheduler.exe p_run_scheduler.json
\ No newline at end of file
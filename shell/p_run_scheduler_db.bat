@@ -0,0 +1,3 @@
+cd C:\ProfitMax\api\crypto
+
+p_run_scheduler_db.exe p_run_scheduler_db.json
\ No newline at end of file
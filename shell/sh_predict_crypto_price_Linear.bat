@@ -1,3 +0,0 @@
-cd C:\ProfitMax\api\crypto
-
-py sh_predict_energy_price.py --type Crypto --symbol BTC --location QLD1 --model Linear --interval 10
@@ -1,3 +0,0 @@
-cd C:\ProfitMax\api\crypto
-
-py sh_predict_energy_price.py --type Energy --symbol BTC --location QLD1 --model LSTM --interval 10
\ No newline at end of file
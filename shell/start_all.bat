@@ -22,15 +22,29 @@ start C:\ProfitMax\shell\p_energy_cost_calculator.bat
 timeout 1
 start C:\ProfitMax\shell\p_mining_cost_calculator.bat
 timeout 1
-
-start C:\ProfitMax\shell\sh_predict_crypto_price_Linear.bat
+start C:\ProfitMax\shell\p_fx_rate_api.bat
 timeout 1
-start C:\ProfitMax\shell\sh_predict_crypto_price_LSTM.bat
+start C:\ProfitMax\shell\p_fx_rate_db.bat
 timeout 1
-start C:\ProfitMax\shell\sh_predict_crypto_price_RandomForest.bat
+start C:\ProfitMax\shell\p_mining_decision_db.bat
 timeout 1
-start C:\ProfitMax\shell\sh_predict_energy_price_Linear.bat
+start C:\ProfitMax\shell\p_miner_actuator.bat
 timeout 1
-start C:\ProfitMax\shell\sh_predict_energy_price_LSTM.bat
+start C:\ProfitMax\shell\p_miner_telemetry.bat
 timeout 1
-start C:\ProfitMax\shell\sh_predict_energy_price_RandomForest.bat
\ No newline at end of file
+start C:\ProfitMax\shell\p_miner_telemetry_db.bat
+timeout 1
+start C:\ProfitMax\shell\p_inventory.bat
+timeout 1
+start C:\ProfitMax\shell\p_price_forecast.bat
+timeout 1
+start C:\ProfitMax\shell\p_forecast_scorer.bat
+timeout 1
+start C:\ProfitMax\shell\p_run_scheduler.bat
+timeout 1
+start C:\ProfitMax\shell\p_run_scheduler_db.bat
+timeout 1
+start C:\ProfitMax\shell\p_analytics.bat
+timeout 1
+start C:\ProfitMax\shell\p_price_forecast_db.bat
+timeout 1
\ No newline at end of file
@@ -0,0 +1,219 @@
+package actuator
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	cgminer "profitmax/util/cgminer"
+	decision "profitmax/util/decision"
+	fleet "profitmax/util/fleet"
+)
+
+// Command is what the actuator asks a miner to do.
+type Command string
+
+const (
+	Resume Command = "RESUME"
+	Pause  Command = "PAUSE"
+)
+
+// Ack statuses.
+const (
+	StatusOK          = "OK"
+	StatusFailed      = "FAILED"
+	StatusDryRun      = "DRY_RUN"
+	StatusRateLimited = "RATE_LIMITED"
+)
+
+// Ack reports what became of a command sent to a miner.
+type Ack struct {
+	LocationID        string    `json:"location_id"`
+	DeviceID          string    `json:"device_id"`
+	Model             string    `json:"model"`
+	Address           string    `json:"address"`
+	Command           Command   `json:"command"`
+	Status            string    `json:"status"`
+	Error             string    `json:"error,omitempty"`
+	DecisionTimestamp time.Time `json:"decision_timestamp"`
+	Timestamp         time.Time `json:"timestamp"`
+}
+
+// Plan returns the command for every device of a site under d. When d has
+// per-model targets the first RunUnits devices of each model run; devices of
+// other models, or every device when d has no targets, run in config order
+// up to d's run fraction.
+func Plan(devices []fleet.Device, d decision.Decision) map[string]Command {
+	targets := make(map[string]int)
+	for _, group := range d.Groups {
+		targets[group.Model] = group.RunUnits
+	}
+
+	var untargeted int
+	for _, device := range devices {
+		if _, ok := targets[device.Model]; !ok {
+			untargeted++
+		}
+	}
+	remaining := int(math.Round(d.RunFraction * float64(untargeted)))
+
+	plan := make(map[string]Command, len(devices))
+	for _, device := range devices {
+		command := Pause
+		if units, ok := targets[device.Model]; ok {
+			if units > 0 {
+				command = Resume
+				targets[device.Model] = units - 1
+			}
+		} else if remaining > 0 {
+			command = Resume
+			remaining--
+		}
+		plan[device.ID] = command
+	}
+	return plan
+}
+
+// Sender sends command to device.
+type Sender func(device fleet.Device, command Command) error
+
+// CGMiner returns a Sender that pauses and resumes miners over the CGMiner
+// API, giving up on a miner after timeout.
+func CGMiner(timeout time.Duration) Sender {
+	return func(device fleet.Device, command Command) error {
+		client := cgminer.NewClient(device.Address, timeout)
+		switch command {
+		case Pause:
+			return client.Pause()
+		case Resume:
+			return client.Resume()
+		default:
+			return fmt.Errorf("unknown command %q", command)
+		}
+	}
+}
+
+// queued is a command held back by the rate limit.
+type queued struct {
+	locationID        string
+	device            fleet.Device
+	command           Command
+	decisionTimestamp time.Time
+}
+
+// Actuator brings miners in line with decisions. Miners already doing what
+// a decision asks are left alone, and a miner is sent at most one command
+// per Interval. Commands held back by that limit are queued and sent by
+// Retry once it allows, unless a later decision changes them first. In a
+// dry run commands are acknowledged but not sent.
+type Actuator struct {
+	Interval time.Duration
+	DryRun   bool
+	Send     Sender
+
+	mutex    sync.Mutex
+	last     map[string]Command
+	sent     map[string]time.Time
+	deferred map[string]queued
+}
+
+// New returns an actuator that sends commands with send.
+func New(interval time.Duration, dryRun bool, send Sender) *Actuator {
+	return &Actuator{
+		Interval: interval,
+		DryRun:   dryRun,
+		Send:     send,
+		last:     make(map[string]Command),
+		sent:     make(map[string]time.Time),
+		deferred: make(map[string]queued),
+	}
+}
+
+// Apply commands the devices of site as d asks at now, and returns an ack
+// for every command sent or held back.
+func (a *Actuator) Apply(site fleet.Site, d decision.Decision, now time.Time) []Ack {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	plan := Plan(site.Devices, d)
+	var acks []Ack
+	for _, device := range site.Devices {
+		delete(a.deferred, device.ID)
+		command := plan[device.ID]
+		if a.last[device.ID] == command {
+			continue
+		}
+		acks = append(acks, a.command(queued{site.LocationID, device, command, d.Timestamp}, now))
+	}
+	return acks
+}
+
+// Retry sends the queued commands the rate limit now allows, and returns
+// their acks.
+func (a *Actuator) Retry(now time.Time) []Ack {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	ids := make([]string, 0, len(a.deferred))
+	for id := range a.deferred {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var acks []Ack
+	for _, id := range ids {
+		if a.limited(id, now) {
+			continue
+		}
+		q := a.deferred[id]
+		delete(a.deferred, id)
+		acks = append(acks, a.command(q, now))
+	}
+	return acks
+}
+
+// Pending returns how many commands are queued.
+func (a *Actuator) Pending() int {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return len(a.deferred)
+}
+
+// limited reports whether device id was sent a command too recently.
+func (a *Actuator) limited(id string, now time.Time) bool {
+	last, ok := a.sent[id]
+	return ok && now.Sub(last) < a.Interval
+}
+
+// command sends q, or queues it while the rate limit holds it back.
+func (a *Actuator) command(q queued, now time.Time) Ack {
+	ack := Ack{
+		LocationID:        q.locationID,
+		DeviceID:          q.device.ID,
+		Model:             q.device.Model,
+		Address:           q.device.Address,
+		Command:           q.command,
+		DecisionTimestamp: q.decisionTimestamp,
+	}
+	if a.limited(q.device.ID, now) {
+		a.deferred[q.device.ID] = q
+		ack.Status = StatusRateLimited
+		return ack
+	}
+
+	a.sent[q.device.ID] = now
+	if a.DryRun {
+		ack.Status = StatusDryRun
+	} else if err := a.Send(q.device, q.command); err != nil {
+		ack.Status = StatusFailed
+		ack.Error = err.Error()
+	} else {
+		ack.Status = StatusOK
+	}
+	if ack.Status != StatusFailed {
+		a.last[q.device.ID] = q.command
+	}
+	return ack
+}
@@ -0,0 +1,228 @@
+package actuator
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	cgminer "profitmax/util/cgminer"
+	decision "profitmax/util/decision"
+	fleet "profitmax/util/fleet"
+)
+
+func TestPlan(t *testing.T) {
+	devices := []fleet.Device{
+		{ID: "a1", Model: "A"},
+		{ID: "a2", Model: "A"},
+		{ID: "b1", Model: "B"},
+		{ID: "b2", Model: "B"},
+	}
+	tests := []struct {
+		name     string
+		decision decision.Decision
+		want     map[string]Command
+	}{
+		{
+			name:     "run in full",
+			decision: decision.Decision{RunFraction: 1},
+			want:     map[string]Command{"a1": Resume, "a2": Resume, "b1": Resume, "b2": Resume},
+		},
+		{
+			name:     "curtail",
+			decision: decision.Decision{RunFraction: 0},
+			want:     map[string]Command{"a1": Pause, "a2": Pause, "b1": Pause, "b2": Pause},
+		},
+		{
+			name:     "half in config order",
+			decision: decision.Decision{RunFraction: 0.5},
+			want:     map[string]Command{"a1": Resume, "a2": Resume, "b1": Pause, "b2": Pause},
+		},
+		{
+			name: "per model targets",
+			decision: decision.Decision{RunFraction: 0.5, Groups: []decision.GroupTarget{
+				{Model: "B", RunUnits: 2},
+			}},
+			// Model A is untargeted and runs at the run fraction
+			want: map[string]Command{"a1": Resume, "a2": Pause, "b1": Resume, "b2": Resume},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Plan(devices, tt.decision)
+			for id, want := range tt.want {
+				if got[id] != want {
+					t.Errorf("%s = %s, want %s", id, got[id], want)
+				}
+			}
+		})
+	}
+}
+
+// miners starts a simulator for each of ids and returns the site they make
+// up, with the simulators by device.
+func miners(t *testing.T, ids ...string) (fleet.Site, map[string]*cgminer.Simulator) {
+	t.Helper()
+	site := fleet.Site{LocationID: "QLD1"}
+	simulators := make(map[string]*cgminer.Simulator)
+	for _, id := range ids {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { listener.Close() })
+		simulator := cgminer.NewSimulator("Antminer S19", 95, 3250)
+		go simulator.Serve(listener)
+		simulators[id] = simulator
+		site.Devices = append(site.Devices, fleet.Device{ID: id, Model: "Antminer S19", Address: listener.Addr().String()})
+	}
+	return site, simulators
+}
+
+// hashing reports whether the miner at device is hashing.
+func hashing(t *testing.T, device fleet.Device) bool {
+	t.Helper()
+	summary, err := cgminer.NewClient(device.Address, 2*time.Second).Summary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return summary.MHSAv > 0
+}
+
+func statuses(acks []Ack) map[string]string {
+	result := make(map[string]string)
+	for _, ack := range acks {
+		result[ack.DeviceID] = ack.Status
+	}
+	return result
+}
+
+func TestApplyPausesAndResumes(t *testing.T) {
+	site, _ := miners(t, "m1", "m2")
+	a := New(0, false, CGMiner(2*time.Second))
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	acks := a.Apply(site, decision.Decision{RunFraction: 0.5}, now)
+	if len(acks) != 2 {
+		t.Fatalf("%d acks, want 2", len(acks))
+	}
+	for _, ack := range acks {
+		if ack.Status != StatusOK {
+			t.Errorf("%s ack = %s, want OK", ack.DeviceID, ack.Status)
+		}
+	}
+	if !hashing(t, site.Devices[0]) || hashing(t, site.Devices[1]) {
+		t.Error("want m1 hashing and m2 paused")
+	}
+
+	// Miners already doing what is asked are left alone
+	if acks := a.Apply(site, decision.Decision{RunFraction: 0.5}, now.Add(time.Minute)); len(acks) != 0 {
+		t.Errorf("repeated decision sent %d commands, want none", len(acks))
+	}
+
+	acks = a.Apply(site, decision.Decision{RunFraction: 1}, now.Add(2*time.Minute))
+	if got := statuses(acks); len(got) != 1 || got["m2"] != StatusOK {
+		t.Errorf("acks = %v, want only m2 resumed", got)
+	}
+	if !hashing(t, site.Devices[1]) {
+		t.Error("want m2 hashing")
+	}
+}
+
+func TestApplyDryRun(t *testing.T) {
+	site, _ := miners(t, "m1")
+	a := New(0, true, CGMiner(2*time.Second))
+	acks := a.Apply(site, decision.Decision{RunFraction: 0}, time.Now())
+	if got := statuses(acks); got["m1"] != StatusDryRun {
+		t.Errorf("acks = %v, want a dry run", got)
+	}
+	if !hashing(t, site.Devices[0]) {
+		t.Error("dry run paused the miner")
+	}
+}
+
+func TestApplyFailure(t *testing.T) {
+	site, _ := miners(t, "m1")
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unreachable := listener.Addr().String()
+	listener.Close()
+	site.Devices = append(site.Devices, fleet.Device{ID: "gone", Model: "Antminer S19", Address: unreachable})
+
+	a := New(0, false, CGMiner(time.Second))
+	now := time.Now()
+	acks := a.Apply(site, decision.Decision{RunFraction: 0}, now)
+	if got := statuses(acks); got["m1"] != StatusOK || got["gone"] != StatusFailed {
+		t.Errorf("acks = %v, want m1 OK and gone FAILED", got)
+	}
+	for _, ack := range acks {
+		if ack.DeviceID == "gone" && ack.Error == "" {
+			t.Error("failed ack has no error")
+		}
+	}
+
+	// A failed command is tried again on the next decision
+	acks = a.Apply(site, decision.Decision{RunFraction: 0}, now.Add(time.Minute))
+	if got := statuses(acks); len(got) != 1 || got["gone"] != StatusFailed {
+		t.Errorf("acks = %v, want gone tried again", got)
+	}
+}
+
+func TestApplyRateLimit(t *testing.T) {
+	site, _ := miners(t, "m1", "m2")
+	a := New(5*time.Minute, false, CGMiner(2*time.Second))
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	a.Apply(site, decision.Decision{RunFraction: 0}, now)
+	acks := a.Apply(site, decision.Decision{RunFraction: 1}, now.Add(time.Minute))
+	if got := statuses(acks); got["m1"] != StatusRateLimited || got["m2"] != StatusRateLimited {
+		t.Errorf("acks = %v, want both rate limited", got)
+	}
+	if hashing(t, site.Devices[0]) || hashing(t, site.Devices[1]) {
+		t.Error("rate limited miners were resumed")
+	}
+	if a.Pending() != 2 {
+		t.Errorf("%d commands queued, want 2", a.Pending())
+	}
+
+	// Nothing is sent before the interval has passed
+	if acks := a.Retry(now.Add(4 * time.Minute)); len(acks) != 0 {
+		t.Errorf("early retry sent %d commands, want none", len(acks))
+	}
+
+	// The queued commands go out once it has, without another decision
+	acks = a.Retry(now.Add(5 * time.Minute))
+	if got := statuses(acks); got["m1"] != StatusOK || got["m2"] != StatusOK {
+		t.Errorf("acks = %v, want both resumed", got)
+	}
+	if !hashing(t, site.Devices[0]) || !hashing(t, site.Devices[1]) {
+		t.Error("queued resumes were not sent")
+	}
+	if a.Pending() != 0 {
+		t.Errorf("%d commands still queued, want none", a.Pending())
+	}
+}
+
+func TestApplyReplacesQueuedCommands(t *testing.T) {
+	site, _ := miners(t, "m1")
+	a := New(5*time.Minute, false, CGMiner(2*time.Second))
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	a.Apply(site, decision.Decision{RunFraction: 0}, now)
+	a.Apply(site, decision.Decision{RunFraction: 1}, now.Add(time.Minute))
+
+	// Going back to curtailing before the resume went out cancels it
+	if acks := a.Apply(site, decision.Decision{RunFraction: 0}, now.Add(2*time.Minute)); len(acks) != 0 {
+		t.Errorf("acks = %v, want none", statuses(acks))
+	}
+	if a.Pending() != 0 {
+		t.Errorf("%d commands queued, want none", a.Pending())
+	}
+	if acks := a.Retry(now.Add(10 * time.Minute)); len(acks) != 0 {
+		t.Errorf("retry sent %v, want nothing", statuses(acks))
+	}
+	if hashing(t, site.Devices[0]) {
+		t.Error("cancelled resume was sent")
+	}
+}
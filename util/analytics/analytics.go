@@ -0,0 +1,166 @@
+package analytics
+
+import (
+	"fmt"
+	"time"
+
+	forecast "profitmax/util/forecast"
+	timeseries "profitmax/util/timeseries"
+)
+
+// Transforms of the joined series before they are correlated.
+const (
+	Level  = "LEVEL"
+	Change = "CHANGE"
+)
+
+// Config configures the analytics service. Energy and crypto prices are
+// joined as of every StepSeconds and correlated over the last WindowHours,
+// once for each window, after Transform: prices as they are, or their change
+// from step to step, which keeps two trending series from looking
+// correlated. Cross-correlation reaches MaxLagSteps steps either way. A
+// price more than MaxStaleSeconds old is not joined; zero joins prices of
+// any age.
+type Config struct {
+	StepSeconds     int    `json:"step_seconds"`
+	WindowHours     []int  `json:"window_hours"`
+	MaxLagSteps     int    `json:"max_lag_steps"`
+	Transform       string `json:"transform"`
+	MaxStaleSeconds int    `json:"max_stale_seconds"`
+}
+
+// Step is the length of one step of the joined series.
+func (c Config) Step() time.Duration {
+	return time.Duration(c.StepSeconds) * time.Second
+}
+
+// MaxStale is the oldest a price can be and still be joined.
+func (c Config) MaxStale() time.Duration {
+	return time.Duration(c.MaxStaleSeconds) * time.Second
+}
+
+// Longest is the longest window.
+func (c Config) Longest() time.Duration {
+	var longest int
+	for _, hours := range c.WindowHours {
+		if hours > longest {
+			longest = hours
+		}
+	}
+	return time.Duration(longest) * time.Hour
+}
+
+// Validate reports a configuration the service cannot run.
+func (c Config) Validate() error {
+	if c.StepSeconds <= 0 || len(c.WindowHours) == 0 || c.MaxLagSteps < 0 {
+		return fmt.Errorf("step_seconds and window_hours must be positive and max_lag_steps not negative")
+	}
+	for _, hours := range c.WindowHours {
+		if hours <= 0 {
+			return fmt.Errorf("window of %d hours is not positive", hours)
+		}
+	}
+	switch c.Transform {
+	case Level, Change:
+	default:
+		return fmt.Errorf("unknown transform %s", c.Transform)
+	}
+	return nil
+}
+
+// Result is how a location's energy price moved with the crypto price over
+// one window ending at To. Lead is the strongest lag: a positive lag means
+// energy prices led crypto prices by that many steps.
+type Result struct {
+	LocationID  string    `json:"location_id"`
+	Symbol      string    `json:"symbol"`
+	WindowHours int       `json:"window_hours"`
+	StepSeconds int       `json:"step_seconds"`
+	Transform   string    `json:"transform"`
+	From        time.Time `json:"from"`
+	To          time.Time `json:"to"`
+	Samples     int       `json:"samples"`
+	Pearson     float64   `json:"pearson"`
+	Spearman    float64   `json:"spearman"`
+	Kendall     float64   `json:"kendall"`
+	Lead        Lag       `json:"lead"`
+	Lags        []Lag     `json:"lags"`
+}
+
+// Analyse correlates a location's energy ticks with crypto ticks, both
+// oldest first, over the window of windowHours ending at the last whole
+// step before now. It is an error when fewer than three steps have both
+// prices.
+func Analyse(locationID string, symbol string, energy []forecast.Tick, crypto []forecast.Tick, config Config, windowHours int, now time.Time) (Result, error) {
+	step := config.Step()
+	to := now.Truncate(step)
+	from := to.Add(-time.Duration(windowHours) * time.Hour)
+	x, y := Join(energy, crypto, from, to, step, config.MaxStale())
+	if config.Transform == Change {
+		x, y = changes(x), changes(y)
+	}
+	lags := CrossCorrelation(x, y, config.MaxLagSteps)
+	x, y = valid(x, y)
+	if len(x) < 3 {
+		return Result{}, fmt.Errorf("%s and %s have %d steps in common over %d hours", locationID, symbol, len(x), windowHours)
+	}
+
+	return Result{
+		LocationID:  locationID,
+		Symbol:      symbol,
+		WindowHours: windowHours,
+		StepSeconds: config.StepSeconds,
+		Transform:   config.Transform,
+		From:        from,
+		To:          to,
+		Samples:     len(x),
+		Pearson:     Pearson(x, y),
+		Spearman:    Spearman(x, y),
+		Kendall:     Kendall(x, y),
+		Lead:        Strongest(lags),
+		Lags:        lags,
+	}, nil
+}
+
+// Join samples both series as of every step after from up to to. It keeps
+// every step, so that positions stay a step apart: a price missing, or more
+// than maxStale old, is timeseries.Missing.
+func Join(left []forecast.Tick, right []forecast.Tick, from time.Time, to time.Time, step time.Duration, maxStale time.Duration) ([]float64, []float64) {
+	grid := timeseries.Grid(from, to, step)
+	l := timeseries.AsOf(forecast.Series(left), grid, maxStale)
+	r := timeseries.AsOf(forecast.Series(right), grid, maxStale)
+	return l.Values(), r.Values()
+}
+
+// valid returns the values of x and y at the steps where both have one.
+func valid(x []float64, y []float64) ([]float64, []float64) {
+	n := len(x)
+	if len(y) < n {
+		n = len(y)
+	}
+	var validX, validY []float64
+	for i := 0; i < n; i++ {
+		if timeseries.IsMissing(x[i]) || timeseries.IsMissing(y[i]) {
+			continue
+		}
+		validX = append(validX, x[i])
+		validY = append(validY, y[i])
+	}
+	return validX, validY
+}
+
+// changes returns the change of values from each step to the next, missing
+// where either step is.
+func changes(values []float64) []float64 {
+	if len(values) < 2 {
+		return nil
+	}
+	result := make([]float64, len(values)-1)
+	for i := range result {
+		result[i] = timeseries.Missing
+		if !timeseries.IsMissing(values[i]) && !timeseries.IsMissing(values[i+1]) {
+			result[i] = values[i+1] - values[i]
+		}
+	}
+	return result
+}
@@ -0,0 +1,114 @@
+package analytics
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	forecast "profitmax/util/forecast"
+	timeseries "profitmax/util/timeseries"
+)
+
+var start = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// price is a made-up price at step k.
+func price(k int) float64 {
+	return 100 + 10*math.Sin(float64(k)*0.7) + float64(k%5)
+}
+
+// ticks returns a tick a minute at value(k) for k from 0 to n, except at the
+// steps in gaps.
+func ticks(n int, value func(int) float64, gaps ...int) []forecast.Tick {
+	skip := make(map[int]bool)
+	for _, k := range gaps {
+		skip[k] = true
+	}
+	var result []forecast.Tick
+	for k := 0; k <= n; k++ {
+		if !skip[k] {
+			result = append(result, forecast.Tick{Time: start.Add(time.Duration(k) * time.Minute), Price: value(k)})
+		}
+	}
+	return result
+}
+
+func TestJoinKeepsGaps(t *testing.T) {
+	left := ticks(6, price, 3)
+	right := ticks(6, price, 5)
+	x, y := Join(left, right, start, start.Add(6*time.Minute), time.Minute, 30*time.Second)
+	if len(x) != 6 || len(y) != 6 {
+		t.Fatalf("joined %d and %d steps, want 6", len(x), len(y))
+	}
+	for i := range x {
+		wantX, wantY := i+1 != 3, i+1 != 5
+		if timeseries.IsMissing(x[i]) == wantX || timeseries.IsMissing(y[i]) == wantY {
+			t.Errorf("step %d = %v, %v", i+1, x[i], y[i])
+		}
+	}
+}
+
+func TestChanges(t *testing.T) {
+	missing := timeseries.Missing
+	tests := []struct {
+		name   string
+		values []float64
+		want   []float64
+	}{
+		{"none", nil, nil},
+		{"one", []float64{1}, nil},
+		{"steps", []float64{1, 2, 4}, []float64{1, 2}},
+		{"across a gap", []float64{1, 2, missing, 4, 7}, []float64{1, missing, missing, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := changes(tt.values)
+			if len(got) != len(tt.want) {
+				t.Fatalf("changes() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if timeseries.IsMissing(got[i]) != timeseries.IsMissing(tt.want[i]) || (!timeseries.IsMissing(got[i]) && got[i] != tt.want[i]) {
+					t.Errorf("changes() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCrossCorrelationSkipsMissing(t *testing.T) {
+	missing := timeseries.Missing
+	x := []float64{1, 3, 2, missing, 5, 4, 6, 8, 7}
+	y := []float64{0, 0, 1, 3, 2, missing, 5, 4, 6}
+	lead := Strongest(CrossCorrelation(x, y, 3))
+	if lead.Lag != 2 || math.Abs(lead.Correlation-1) > 1e-9 {
+		t.Errorf("lead = %+v, want lag 2 at correlation 1", lead)
+	}
+}
+
+func TestAnalyseLeadWithGaps(t *testing.T) {
+	// Crypto prices follow energy prices two steps later, and both have
+	// gaps that would shift them against each other if dropped
+	energy := ticks(60, price, 10, 11, 12, 30)
+	crypto := ticks(60, func(k int) float64 { return price(k - 2) }, 20, 45, 46)
+	config := Config{StepSeconds: 60, WindowHours: []int{1}, MaxLagSteps: 5, MaxStaleSeconds: 30}
+
+	for _, transform := range []string{Level, Change} {
+		t.Run(transform, func(t *testing.T) {
+			config.Transform = transform
+			result, err := Analyse("QLD1", "BTC", energy, crypto, config, 1, start.Add(time.Hour))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Lead.Lag != 2 || result.Lead.Correlation < 0.999 {
+				t.Errorf("lead = %+v, want lag 2 at correlation 1", result.Lead)
+			}
+		})
+	}
+}
+
+func TestAnalyseTooFewSteps(t *testing.T) {
+	energy := ticks(60, price)
+	config := Config{StepSeconds: 60, WindowHours: []int{1}, Transform: Level, MaxStaleSeconds: 30}
+	if _, err := Analyse("QLD1", "BTC", energy, ticks(1, price), config, 1, start.Add(time.Hour)); err == nil {
+		t.Error("Analyse() of one crypto step succeeded")
+	}
+}
@@ -0,0 +1,152 @@
+package analytics
+
+import (
+	"math"
+	"sort"
+)
+
+// Pearson returns the linear correlation of x and y, or 0 when either is
+// constant or they are shorter than two values.
+func Pearson(x []float64, y []float64) float64 {
+	n := len(x)
+	if len(y) < n {
+		n = len(y)
+	}
+	if n < 2 {
+		return 0
+	}
+	var mx, my float64
+	for i := 0; i < n; i++ {
+		mx += x[i]
+		my += y[i]
+	}
+	mx /= float64(n)
+	my /= float64(n)
+	var sxy, sxx, syy float64
+	for i := 0; i < n; i++ {
+		dx, dy := x[i]-mx, y[i]-my
+		sxy += dx * dy
+		sxx += dx * dx
+		syy += dy * dy
+	}
+	if sxx == 0 || syy == 0 {
+		return 0
+	}
+	return sxy / math.Sqrt(sxx*syy)
+}
+
+// Spearman returns the rank correlation of x and y: the Pearson correlation
+// of their ranks, tied values sharing their mean rank.
+func Spearman(x []float64, y []float64) float64 {
+	return Pearson(ranks(x), ranks(y))
+}
+
+// ranks returns the rank of each value, from 1, tied values sharing their
+// mean rank.
+func ranks(values []float64) []float64 {
+	order := make([]int, len(values))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return values[order[i]] < values[order[j]]
+	})
+	result := make([]float64, len(values))
+	for i := 0; i < len(order); {
+		j := i
+		for j+1 < len(order) && values[order[j+1]] == values[order[i]] {
+			j++
+		}
+		rank := float64(i+j)/2 + 1
+		for k := i; k <= j; k++ {
+			result[order[k]] = rank
+		}
+		i = j + 1
+	}
+	return result
+}
+
+// Kendall returns Kendall's tau-b of x and y: concordant less discordant
+// pairs, corrected for ties. It compares every pair, so it is quadratic in
+// the number of values.
+func Kendall(x []float64, y []float64) float64 {
+	n := len(x)
+	if len(y) < n {
+		n = len(y)
+	}
+	var concordant, discordant, tiesX, tiesY float64
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			dx := x[i] - x[j]
+			dy := y[i] - y[j]
+			switch {
+			case dx == 0 && dy == 0:
+			case dx == 0:
+				tiesX++
+			case dy == 0:
+				tiesY++
+			case dx*dy > 0:
+				concordant++
+			default:
+				discordant++
+			}
+		}
+	}
+	denominator := math.Sqrt((concordant + discordant + tiesX) * (concordant + discordant + tiesY))
+	if denominator == 0 {
+		return 0
+	}
+	return (concordant - discordant) / denominator
+}
+
+// Lag is the correlation of x with y shifted by Lag steps. A positive lag
+// pairs x with later values of y, so a high correlation there means x
+// leads y.
+type Lag struct {
+	Lag         int     `json:"lag"`
+	Correlation float64 `json:"correlation"`
+}
+
+// CrossCorrelation returns the Pearson correlation of x and y at every lag
+// from -maxLag to maxLag, each over the steps the shifted series overlap on
+// where both have a value. Lags count steps, missing values included.
+func CrossCorrelation(x []float64, y []float64, maxLag int) []Lag {
+	n := len(x)
+	if len(y) < n {
+		n = len(y)
+	}
+	var lags []Lag
+	for lag := -maxLag; lag <= maxLag; lag++ {
+		if lag >= n || -lag >= n {
+			continue
+		}
+		var correlation float64
+		if lag >= 0 {
+			correlation = Pearson(valid(x[:n-lag], y[lag:n]))
+		} else {
+			correlation = Pearson(valid(x[-lag:n], y[:n+lag]))
+		}
+		lags = append(lags, Lag{Lag: lag, Correlation: correlation})
+	}
+	return lags
+}
+
+// Strongest returns the lag with the largest absolute correlation, the
+// shortest such lag on a tie.
+func Strongest(lags []Lag) Lag {
+	var best Lag
+	for i, lag := range lags {
+		a, b := math.Abs(lag.Correlation), math.Abs(best.Correlation)
+		if i == 0 || a > b || (a == b && abs(lag.Lag) < abs(best.Lag)) {
+			best = lag
+		}
+	}
+	return best
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,20 @@
+package analytics
+
+import (
+	"database/sql"
+	"encoding/json"
+)
+
+// Save stores r in tbl_correlation, its cross-correlation at every lag as
+// JSON.
+func Save(db *sql.DB, r Result) error {
+	lags, err := json.Marshal(r.Lags)
+	if err != nil {
+		return err
+	}
+	insertData := `INSERT INTO tbl_correlation (location_id, symbol, window_hours, window_end, step_seconds, transform, samples, pearson, spearman, kendall, lead_lag, lead_correlation, cross_correlation)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE step_seconds = VALUES(step_seconds), transform = VALUES(transform), samples = VALUES(samples), pearson = VALUES(pearson), spearman = VALUES(spearman), kendall = VALUES(kendall), lead_lag = VALUES(lead_lag), lead_correlation = VALUES(lead_correlation), cross_correlation = VALUES(cross_correlation)`
+	_, err = db.Exec(insertData, r.LocationID, r.Symbol, r.WindowHours, r.To, r.StepSeconds, r.Transform, r.Samples, r.Pearson, r.Spearman, r.Kendall, r.Lead.Lag, r.Lead.Correlation, string(lags))
+	return err
+}
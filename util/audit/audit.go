@@ -0,0 +1,162 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"time"
+
+	decision "profitmax/util/decision"
+	economics "profitmax/util/economics"
+)
+
+// Source is the Kafka message an input was last taken from.
+type Source struct {
+	Topic     string    `json:"topic"`
+	Key       string    `json:"key,omitempty"`
+	Partition int32     `json:"partition"`
+	Offset    int64     `json:"offset"`
+	EventTime time.Time `json:"event_time"`
+}
+
+// Inputs are the values a decision was made from, in the site's currency.
+// Stale names the inputs past their TTL and Fallback how they were covered.
+type Inputs struct {
+	Currency      string                `json:"currency"`
+	CryptoPrice   economics.Amount      `json:"crypto_price"`
+	EnergyPrice   economics.PricePerMWh `json:"energy_price"`
+	Difficulty    float64               `json:"difficulty"`
+	Reward        economics.Coins       `json:"reward"`
+	Hashprice     economics.Amount      `json:"hashprice"`
+	HashrateTH    float64               `json:"hashrate_th"`
+	PowerW        float64               `json:"power_w"`
+	Cost          economics.Amount      `json:"mining_cost"`
+	Incentive     economics.Amount      `json:"mining_incentive"`
+	PreviousState decision.State        `json:"previous_state"`
+	Stale         []string              `json:"stale,omitempty"`
+	Fallback      string                `json:"fallback,omitempty"`
+}
+
+// Record is everything needed to explain a decision after the fact.
+type Record struct {
+	Decision      decision.Decision `json:"decision"`
+	Inputs        Inputs            `json:"inputs"`
+	Policy        decision.Policy   `json:"policy"`
+	Sources       []Source          `json:"sources"`
+	ConfigVersion string            `json:"config_version"`
+}
+
+// ConfigVersion identifies a config file by the hash of its contents.
+func ConfigVersion(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Sources remembers the latest message seen on every topic and key.
+type Sources map[string]map[string]Source
+
+// Record notes source as the latest message of its topic and key.
+func (s Sources) Record(source Source) {
+	if s[source.Topic] == nil {
+		s[source.Topic] = make(map[string]Source)
+	}
+	s[source.Topic][source.Key] = source
+}
+
+// For returns the sources behind a site's inputs: the site's own message on
+// topics keyed by site, and every latest message on the other topics.
+func (s Sources) For(locationID string) []Source {
+	var sources []Source
+	for _, byKey := range s {
+		if source, ok := byKey[locationID]; ok {
+			sources = append(sources, source)
+			continue
+		}
+		for _, source := range byKey {
+			sources = append(sources, source)
+		}
+	}
+	sort.Slice(sources, func(i, j int) bool {
+		if sources[i].Topic != sources[j].Topic {
+			return sources[i].Topic < sources[j].Topic
+		}
+		return sources[i].Key < sources[j].Key
+	})
+	return sources
+}
+
+// Save stores record in tbl_mining_decision_audit.
+func Save(db *sql.DB, record Record) error {
+	policy, err := json.Marshal(record.Policy)
+	if err != nil {
+		return err
+	}
+	sources, err := json.Marshal(record.Sources)
+	if err != nil {
+		return err
+	}
+	inputs, err := json.Marshal(record.Inputs)
+	if err != nil {
+		return err
+	}
+	groups, err := json.Marshal(record.Decision.Groups)
+	if err != nil {
+		return err
+	}
+
+	d := record.Decision
+	in := record.Inputs
+	insertData := `INSERT INTO tbl_mining_decision_audit (location_id, timestamp, action, run_fraction, margin, reason, changed, currency_code,
+		crypto_price, energy_price, difficulty, reward, hashprice, mining_cost, mining_incentive, config_version, policy, inputs, sources, groups_json)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err = db.Exec(insertData, d.LocationID, d.Timestamp, d.Action, d.RunFraction, d.Margin, d.Reason, d.Changed, in.Currency,
+		float64(in.CryptoPrice), float64(in.EnergyPrice), in.Difficulty, float64(in.Reward), float64(in.Hashprice),
+		float64(in.Cost), float64(in.Incentive), record.ConfigVersion, string(policy), string(inputs), string(sources), string(groups))
+	return err
+}
+
+// Query returns the audit records of a site, or of every site when
+// locationID is empty, made in [from, to), oldest first.
+func Query(db *sql.DB, locationID string, from time.Time, to time.Time) ([]Record, error) {
+	query := "SELECT location_id, timestamp, action, run_fraction, margin, reason, changed, config_version, policy, inputs, sources, groups_json FROM tbl_mining_decision_audit WHERE timestamp >= ? AND timestamp < ?"
+	args := []interface{}{from, to}
+	if locationID != "" {
+		query += " AND location_id = ?"
+		args = append(args, locationID)
+	}
+	query += " ORDER BY timestamp, id"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []Record
+	for rows.Next() {
+		var record Record
+		var timestamp string
+		var policy, inputs, sources, groups string
+		d := &record.Decision
+		err = rows.Scan(&d.LocationID, &timestamp, &d.Action, &d.RunFraction, &d.Margin, &d.Reason, &d.Changed,
+			&record.ConfigVersion, &policy, &inputs, &sources, &groups)
+		if err != nil {
+			return nil, err
+		}
+		d.Timestamp, _ = time.ParseInLocation("2006-01-02 15:04:05", timestamp, time.Local)
+
+		for _, field := range []struct {
+			data   string
+			target interface{}
+		}{{policy, &record.Policy}, {inputs, &record.Inputs}, {sources, &record.Sources}, {groups, &d.Groups}} {
+			err = json.Unmarshal([]byte(field.data), field.target)
+			if err != nil {
+				return nil, err
+			}
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
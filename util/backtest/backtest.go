@@ -0,0 +1,247 @@
+package backtest
+
+import (
+	"fmt"
+	"time"
+
+	calculator "profitmax/util/calculator"
+	costmodel "profitmax/util/costmodel"
+	decision "profitmax/util/decision"
+	economics "profitmax/util/economics"
+	fleet "profitmax/util/fleet"
+	fx "profitmax/util/fx"
+)
+
+// Step is what a site decided at the start of one interval and what that
+// made by its end, beside what running in full would have made.
+type Step struct {
+	Start          time.Time             `json:"start"`
+	LocationID     string                `json:"location_id"`
+	Action         decision.Action       `json:"action"`
+	RunFraction    float64               `json:"run_fraction"`
+	Reason         decision.Reason       `json:"reason"`
+	Margin         float64               `json:"margin"`
+	EnergyPrice    economics.PricePerMWh `json:"energy_price"`
+	CryptoPrice    economics.Amount      `json:"crypto_price"`
+	EnergyMWh      float64               `json:"energy_mwh"`
+	Coins          economics.Coins       `json:"coins"`
+	Revenue        economics.Amount      `json:"revenue"`
+	EnergyCost     economics.Amount      `json:"energy_cost"`
+	OtherCost      economics.Amount      `json:"other_cost"`
+	Profit         economics.Amount      `json:"profit"`
+	AlwaysOnProfit economics.Amount      `json:"always_on_profit"`
+}
+
+// Summary totals a site's steps. Uplift is what the policy made over always
+// running in full.
+type Summary struct {
+	LocationID        string           `json:"location_id"`
+	Currency          string           `json:"currency"`
+	Intervals         int              `json:"intervals"`
+	Switches          int              `json:"switches"`
+	EnergyMWh         float64          `json:"energy_mwh"`
+	Coins             economics.Coins  `json:"coins"`
+	Revenue           economics.Amount `json:"revenue"`
+	Cost              economics.Amount `json:"cost"`
+	Profit            economics.Amount `json:"profit"`
+	AlwaysOnEnergyMWh float64          `json:"always_on_energy_mwh"`
+	AlwaysOnCoins     economics.Coins  `json:"always_on_coins"`
+	AlwaysOnProfit    economics.Amount `json:"always_on_profit"`
+	Uplift            economics.Amount `json:"uplift"`
+}
+
+// Result is a backtest's steps, oldest first, and each site's summary.
+type Result struct {
+	Steps     []Step    `json:"steps"`
+	Summaries []Summary `json:"summaries"`
+}
+
+// market is the inputs known at a point of the replay.
+type market struct {
+	cryptoPrice    float64
+	cryptoCurrency string
+	reward         economics.Coins
+	difficulty     float64
+	energyPrices   map[string]Event
+}
+
+func (m *market) apply(event Event) {
+	switch event.Kind {
+	case CryptoPrice:
+		m.cryptoPrice = event.Price
+		m.cryptoCurrency = event.Currency
+	case EnergyPrice:
+		m.energyPrices[event.Key] = event
+	case Block:
+		m.reward = event.Reward
+		m.difficulty = event.Difficulty
+	}
+}
+
+// prices returns the energy and crypto prices at a site in its currency. It
+// is false until both prices, the reward and the difficulty are known.
+func (m *market) prices(site fleet.Site, converter *fx.Converter) (economics.PricePerMWh, economics.Amount, bool, error) {
+	energy, ok := m.energyPrices[site.LocationID]
+	if !ok || m.cryptoPrice <= 0 || m.reward <= 0 || m.difficulty <= 0 {
+		return 0, 0, false, nil
+	}
+	energyPrice, err := fx.Convert(converter, economics.PricePerMWh(energy.Price), energy.Currency, site.Currency)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	cryptoPrice, err := fx.Convert(converter, economics.Amount(m.cryptoPrice), m.cryptoCurrency, site.Currency)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	return energyPrice, cryptoPrice, true, nil
+}
+
+// Run replays events, in event-time order, through the calculators and the
+// decision policy every interval from from to to. Each site is decided at
+// the start of an interval on the inputs known then, the way the decision
+// maker would, and settled at the prices in force at its end. Sites are
+// skipped until every input is known, and sites without a fleet entirely.
+// items are each site's cost items.
+func Run(sites []fleet.Site, events []Event, items map[string][]costmodel.Item, converter *fx.Converter, policy decision.Policy, interval time.Duration, from time.Time, to time.Time) (Result, error) {
+	if interval <= 0 {
+		return Result{}, fmt.Errorf("interval must be positive")
+	}
+	known := market{energyPrices: make(map[string]Event)}
+	states := make(map[string]decision.State)
+	summaries := make(map[string]*Summary)
+	var result Result
+
+	next := 0
+	for start := from; start.Before(to); start = start.Add(interval) {
+		end := start.Add(interval)
+		for next < len(events) && !events[next].Time.After(start) {
+			known.apply(events[next])
+			next++
+		}
+
+		// Decide every site on what is known at the start
+		type pending struct {
+			site     fleet.Site
+			decision decision.Decision
+		}
+		var decided []pending
+		for _, site := range sites {
+			if len(site.Fleet) == 0 {
+				continue
+			}
+			energyPrice, cryptoPrice, ok, err := known.prices(site, converter)
+			if err != nil {
+				return Result{}, err
+			}
+			if !ok {
+				continue
+			}
+			other, err := otherCost(site, items[site.LocationID], start, economics.BlockInterval, 1, converter)
+			if err != nil {
+				return Result{}, err
+			}
+			cost := economics.Sum(calculator.EnergyCost(site, known.difficulty, economics.DefaultEfficiency, energyPrice), other)
+			incentive := calculator.Incentive(site, known.difficulty, known.reward, cryptoPrice)
+			margin := decision.Margin(incentive, cost)
+
+			d, state := decision.Decide(policy, states[site.LocationID], margin, start)
+			states[site.LocationID] = state
+			d.LocationID = site.LocationID
+			decided = append(decided, pending{site, d})
+		}
+
+		// Settle each decision at the prices in force at the end
+		for next < len(events) && events[next].Time.Before(end) {
+			known.apply(events[next])
+			next++
+		}
+		for _, p := range decided {
+			energyPrice, cryptoPrice, _, err := known.prices(p.site, converter)
+			if err != nil {
+				return Result{}, err
+			}
+			step, err := settle(p.site, p.decision.RunFraction, energyPrice, cryptoPrice, &known, items[p.site.LocationID], start, interval, converter)
+			if err != nil {
+				return Result{}, err
+			}
+			alwaysOn, err := settle(p.site, 1, energyPrice, cryptoPrice, &known, items[p.site.LocationID], start, interval, converter)
+			if err != nil {
+				return Result{}, err
+			}
+			step.Action = p.decision.Action
+			step.Reason = p.decision.Reason
+			step.Margin = p.decision.Margin
+			step.AlwaysOnProfit = alwaysOn.Profit
+			result.Steps = append(result.Steps, step)
+
+			summary, ok := summaries[p.site.LocationID]
+			if !ok {
+				summary = &Summary{LocationID: p.site.LocationID, Currency: p.site.Currency}
+				summaries[p.site.LocationID] = summary
+			}
+			summary.Intervals++
+			if p.decision.Changed {
+				summary.Switches++
+			}
+			summary.EnergyMWh += step.EnergyMWh
+			summary.Coins += step.Coins
+			summary.Revenue += step.Revenue
+			summary.Cost += step.EnergyCost + step.OtherCost
+			summary.Profit += step.Profit
+			summary.AlwaysOnEnergyMWh += alwaysOn.EnergyMWh
+			summary.AlwaysOnCoins += alwaysOn.Coins
+			summary.AlwaysOnProfit += alwaysOn.Profit
+		}
+	}
+
+	for _, site := range sites {
+		if summary, ok := summaries[site.LocationID]; ok {
+			summary.Uplift = summary.Profit - summary.AlwaysOnProfit
+			result.Summaries = append(result.Summaries, *summary)
+		}
+	}
+	return result, nil
+}
+
+// settle works out what a site running runFraction of its fleet made over
+// one interval.
+func settle(site fleet.Site, runFraction float64, energyPrice economics.PricePerMWh, cryptoPrice economics.Amount, known *market, items []costmodel.Item, start time.Time, interval time.Duration, converter *fx.Converter) (Step, error) {
+	hashrate := site.Hashrate() * economics.Hashrate(runFraction)
+	power := site.Power() * economics.Power(runFraction)
+	coins := economics.ExpectedCoins(hashrate, known.difficulty, known.reward, interval)
+	other, err := otherCost(site, items, start, interval, runFraction, converter)
+	if err != nil {
+		return Step{}, err
+	}
+	step := Step{
+		Start:       start,
+		LocationID:  site.LocationID,
+		RunFraction: runFraction,
+		EnergyPrice: energyPrice,
+		CryptoPrice: cryptoPrice,
+		EnergyMWh:   economics.EnergyUsed(power, interval).MWh(),
+		Coins:       coins,
+		Revenue:     economics.CoinValue(coins, cryptoPrice),
+		EnergyCost:  economics.EnergyCost(power, interval, energyPrice),
+		OtherCost:   other,
+	}
+	step.Profit = economics.Profit(step.Revenue, economics.Sum(step.EnergyCost, step.OtherCost))
+	return step, nil
+}
+
+// otherCost is a site's non-energy cost over period with runFraction of its
+// fleet running, which scales its variable and hosting charges.
+func otherCost(site fleet.Site, items []costmodel.Item, t time.Time, period time.Duration, runFraction float64, converter *fx.Converter) (economics.Amount, error) {
+	usage := calculator.Usage(site, period)
+	usage.Energy *= economics.Energy(runFraction)
+	usage.Hashrate *= economics.Hashrate(runFraction)
+	costs, err := costmodel.PeriodCosts(items, t, period, usage, site.Currency, converter)
+	if err != nil {
+		return 0, err
+	}
+	var total economics.Amount
+	for _, cost := range costs {
+		total += cost
+	}
+	return total, nil
+}
@@ -0,0 +1,99 @@
+package backtest
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+
+	economics "profitmax/util/economics"
+)
+
+// Kinds of replayed event.
+const (
+	CryptoPrice = "crypto_price"
+	EnergyPrice = "energy_price"
+	Block       = "block"
+)
+
+// Event is one historical input. Key is the site of an energy price. A block
+// carries its reward and difficulty; the prices carry Price and Currency.
+type Event struct {
+	Time       time.Time
+	Kind       string
+	Key        string
+	Price      float64
+	Currency   string
+	Reward     economics.Coins
+	Difficulty float64
+}
+
+// LoadEvents reads the crypto prices of symbol, the energy prices of each
+// location and the blocks of symbol's chain between from and to, merged in
+// event-time order. Events at the same time keep the order blocks, crypto
+// prices, energy prices.
+func LoadEvents(db *sql.DB, symbol string, locations []string, from time.Time, to time.Time) ([]Event, error) {
+	var events []Event
+	blocks, err := loadRows(db, Block, symbol,
+		"SELECT timestamp, reward, difficulty FROM tbl_block_info WHERE blockchain=? AND timestamp >= ? AND timestamp < ? ORDER BY timestamp, block_height",
+		from, to)
+	if err != nil {
+		return nil, err
+	}
+	events = append(events, blocks...)
+
+	prices, err := loadRows(db, CryptoPrice, symbol,
+		"SELECT timestamp, price, currency_code FROM tbl_crypto_price_tick WHERE symbol=? AND timestamp >= ? AND timestamp < ? ORDER BY timestamp, id",
+		from, to)
+	if err != nil {
+		return nil, err
+	}
+	events = append(events, prices...)
+
+	for _, location := range locations {
+		prices, err := loadRows(db, EnergyPrice, location,
+			"SELECT timestamp, price, currency_code FROM tbl_energy_price_tick WHERE location_id=? AND timestamp >= ? AND timestamp < ? ORDER BY timestamp, id",
+			from, to)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, prices...)
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Time.Before(events[j].Time)
+	})
+	return events, nil
+}
+
+// loadRows reads events of one kind. Blocks scan a reward and difficulty,
+// prices a price and currency.
+func loadRows(db *sql.DB, kind string, key string, query string, from time.Time, to time.Time) ([]Event, error) {
+	rows, err := db.Query(query, key, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []Event
+	for rows.Next() {
+		event := Event{Kind: kind}
+		if kind != Block {
+			event.Key = key
+		}
+		var timestamp string
+		if kind == Block {
+			err = rows.Scan(&timestamp, &event.Reward, &event.Difficulty)
+		} else {
+			err = rows.Scan(&timestamp, &event.Price, &event.Currency)
+		}
+		if err != nil {
+			return nil, err
+		}
+		event.Time, err = time.ParseInLocation("2006-01-02 15:04:05", timestamp, time.Local)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,69 @@
+package calculator
+
+import (
+	"time"
+
+	costmodel "profitmax/util/costmodel"
+	economics "profitmax/util/economics"
+	fleet "profitmax/util/fleet"
+)
+
+// Efficiency returns the configured reference efficiency, or
+// DefaultEfficiency when none is configured.
+func Efficiency(configured float64) economics.Efficiency {
+	efficiency := economics.Efficiency(configured)
+	if efficiency <= 0 {
+		efficiency = economics.DefaultEfficiency
+	}
+	return efficiency
+}
+
+// capacity returns the hashrate and power a site is costed at. A site without a
+// fleet stands for the whole network at efficiency.
+func capacity(site fleet.Site, difficulty float64, efficiency economics.Efficiency) (economics.Hashrate, economics.Power) {
+	if len(site.Fleet) == 0 {
+		hashrate := economics.NetworkHashrate(difficulty)
+		return hashrate, economics.PowerFor(hashrate, efficiency)
+	}
+	return site.Hashrate(), site.Power()
+}
+
+// Basis returns what a site consumes and deploys over one block interval.
+func Basis(site fleet.Site, difficulty float64, efficiency economics.Efficiency) economics.Basis {
+	hashrate, power := capacity(site, difficulty, efficiency)
+	return economics.Basis{
+		Period:   economics.BlockInterval,
+		Energy:   economics.EnergyUsed(power, economics.BlockInterval),
+		Hashrate: hashrate,
+	}
+}
+
+// Power returns the power a site is costed at.
+func Power(site fleet.Site, difficulty float64, efficiency economics.Efficiency) economics.Power {
+	_, power := capacity(site, difficulty, efficiency)
+	return power
+}
+
+// EnergyCost returns the energy cost of one block interval at a site.
+func EnergyCost(site fleet.Site, difficulty float64, efficiency economics.Efficiency, price economics.PricePerMWh) economics.Amount {
+	_, power := capacity(site, difficulty, efficiency)
+	return economics.EnergyCost(power, economics.BlockInterval, price)
+}
+
+// Incentive returns what a site earns over one block interval: its share of
+// the block reward, or the whole reward for a site without a fleet.
+func Incentive(site fleet.Site, difficulty float64, reward economics.Coins, price economics.Amount) economics.Amount {
+	if len(site.Fleet) == 0 {
+		return economics.CoinValue(reward, price)
+	}
+	return economics.Revenue(site.Hashrate(), difficulty, reward, price, economics.BlockInterval)
+}
+
+// Usage returns what a site's fleet consumes and deploys over period, which
+// its variable and hosting costs are charged on.
+func Usage(site fleet.Site, period time.Duration) costmodel.Usage {
+	return costmodel.Usage{
+		Energy:   economics.EnergyUsed(site.Power(), period),
+		Hashrate: site.Hashrate(),
+	}
+}
@@ -0,0 +1,251 @@
+package cgminer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultPort is the port CGMiner and BMMiner serve their API on.
+const DefaultPort = 4028
+
+// Status is the status block every API response starts with. STATUS is S
+// (success), I (info), W (warning), E (error) or F (fatal).
+type Status struct {
+	Status      string `json:"STATUS"`
+	When        int64  `json:"When"`
+	Code        int    `json:"Code"`
+	Msg         string `json:"Msg"`
+	Description string `json:"Description"`
+}
+
+// Request is a command sent to a miner.
+type Request struct {
+	Command   string `json:"command"`
+	Parameter string `json:"parameter,omitempty"`
+}
+
+// Summary is the miner-wide mining summary. Power is only reported by
+// firmware that measures it.
+type Summary struct {
+	Elapsed        int64   `json:"Elapsed"`
+	MHSAv          float64 `json:"MHS av"`
+	MHS5s          float64 `json:"MHS 5s"`
+	Accepted       int64   `json:"Accepted"`
+	Rejected       int64   `json:"Rejected"`
+	HardwareErrors int64   `json:"Hardware Errors"`
+	Power          float64 `json:"Power"`
+}
+
+// Pool is one of the miner's configured pools.
+type Pool struct {
+	Pool          int    `json:"POOL"`
+	URL           string `json:"URL"`
+	Status        string `json:"Status"`
+	Priority      int    `json:"Priority"`
+	User          string `json:"User"`
+	StratumActive bool   `json:"Stratum Active"`
+}
+
+// Client talks to one miner's API. Every command opens its own connection,
+// as the miner closes it after replying.
+type Client struct {
+	Address string
+	Timeout time.Duration
+}
+
+// NewClient returns a client for the miner at address, which defaults to
+// DefaultPort when it has no port.
+func NewClient(address string, timeout time.Duration) *Client {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, strconv.Itoa(DefaultPort))
+	}
+	return &Client{Address: address, Timeout: timeout}
+}
+
+// Command sends command with parameter and decodes the reply into reply,
+// which should embed the section the command answers with. It fails when the
+// miner reports an error.
+func (c *Client) Command(command string, parameter string, reply interface{}) error {
+	conn, err := net.DialTimeout("tcp", c.Address, c.Timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(c.Timeout))
+
+	err = json.NewEncoder(conn).Encode(Request{Command: command, Parameter: parameter})
+	if err != nil {
+		return err
+	}
+	body, err := io.ReadAll(conn)
+	if err != nil {
+		return err
+	}
+	// BMMiner terminates its replies with a NUL byte
+	body = bytes.TrimRight(body, "\x00\n")
+
+	var status struct {
+		Status []Status `json:"STATUS"`
+	}
+	err = json.Unmarshal(body, &status)
+	if err != nil {
+		return fmt.Errorf("%s: %s: %w", c.Address, command, err)
+	}
+	if len(status.Status) == 0 {
+		return fmt.Errorf("%s: %s: no status in reply", c.Address, command)
+	}
+	if s := status.Status[0]; s.Status == "E" || s.Status == "F" {
+		return fmt.Errorf("%s: %s: %s", c.Address, command, s.Msg)
+	}
+	if reply == nil {
+		return nil
+	}
+	return json.Unmarshal(body, reply)
+}
+
+// Summary returns the miner's mining summary.
+func (c *Client) Summary() (Summary, error) {
+	var reply struct {
+		Summary []Summary `json:"SUMMARY"`
+	}
+	err := c.Command("summary", "", &reply)
+	if err != nil {
+		return Summary{}, err
+	}
+	if len(reply.Summary) == 0 {
+		return Summary{}, fmt.Errorf("%s: summary: empty reply", c.Address)
+	}
+	return reply.Summary[0], nil
+}
+
+// Stats returns the miner's firmware specific statistics.
+func (c *Client) Stats() ([]map[string]interface{}, error) {
+	var reply struct {
+		Stats []map[string]interface{} `json:"STATS"`
+	}
+	err := c.Command("stats", "", &reply)
+	return reply.Stats, err
+}
+
+// ChipTemperatures picks the chip temperatures out of stats. Firmware reports
+// them as temp2_N numbers or as temp_chipN strings of dash separated readings.
+func ChipTemperatures(stats []map[string]interface{}) []float64 {
+	return statValues(stats, func(key string) bool {
+		return strings.HasPrefix(key, "temp2_") || strings.HasPrefix(key, "temp_chip")
+	})
+}
+
+// FanSpeeds picks the fan speeds, in RPM, out of stats.
+func FanSpeeds(stats []map[string]interface{}) []float64 {
+	return statValues(stats, func(key string) bool {
+		_, err := strconv.Atoi(strings.TrimPrefix(key, "fan"))
+		return strings.HasPrefix(key, "fan") && err == nil
+	})
+}
+
+// statValues returns the readings of every stat whose key matches, in key
+// order. Zero readings are unused sensors and are left out.
+func statValues(stats []map[string]interface{}, match func(string) bool) []float64 {
+	var values []float64
+	for _, stat := range stats {
+		keys := make([]string, 0, len(stat))
+		for key := range stat {
+			if match(key) {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			switch value := stat[key].(type) {
+			case float64:
+				if value != 0 {
+					values = append(values, value)
+				}
+			case string:
+				for _, field := range strings.Split(value, "-") {
+					reading, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+					if err == nil && reading != 0 {
+						values = append(values, reading)
+					}
+				}
+			}
+		}
+	}
+	return values
+}
+
+// Pools returns the miner's configured pools.
+func (c *Client) Pools() ([]Pool, error) {
+	var reply struct {
+		Pools []Pool `json:"POOLS"`
+	}
+	err := c.Command("pools", "", &reply)
+	return reply.Pools, err
+}
+
+// SwitchPool makes pool the miner's highest priority pool. It needs
+// privileged API access.
+func (c *Client) SwitchPool(pool int) error {
+	return c.Command("switchpool", strconv.Itoa(pool), nil)
+}
+
+// EnablePool enables pool. It needs privileged API access.
+func (c *Client) EnablePool(pool int) error {
+	return c.Command("enablepool", strconv.Itoa(pool), nil)
+}
+
+// DisablePool disables pool. It needs privileged API access.
+func (c *Client) DisablePool(pool int) error {
+	return c.Command("disablepool", strconv.Itoa(pool), nil)
+}
+
+// ascCount returns the number of hashing boards the miner reports.
+func (c *Client) ascCount() (int, error) {
+	var reply struct {
+		ASCs []struct {
+			Count int `json:"Count"`
+		} `json:"ASCS"`
+	}
+	err := c.Command("asccount", "", &reply)
+	if err != nil {
+		return 0, err
+	}
+	if len(reply.ASCs) == 0 {
+		return 0, fmt.Errorf("%s: asccount: empty reply", c.Address)
+	}
+	return reply.ASCs[0].Count, nil
+}
+
+// Pause stops every hashing board on the miner. It needs privileged API
+// access.
+func (c *Client) Pause() error {
+	return c.setASCs("ascdisable")
+}
+
+// Resume restarts every hashing board on the miner. It needs privileged API
+// access.
+func (c *Client) Resume() error {
+	return c.setASCs("ascenable")
+}
+
+func (c *Client) setASCs(command string) error {
+	count, err := c.ascCount()
+	if err != nil {
+		return err
+	}
+	for asc := 0; asc < count; asc++ {
+		err = c.Command(command, strconv.Itoa(asc), nil)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package cgminer
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serve starts s on a local port and returns a client for it.
+func serve(t *testing.T, s *Simulator) *Client {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go s.Serve(listener)
+	return NewClient(listener.Addr().String(), 2*time.Second)
+}
+
+func TestNewClientDefaultPort(t *testing.T) {
+	if got := NewClient("10.0.0.5", time.Second).Address; got != "10.0.0.5:4028" {
+		t.Errorf("Address = %s, want 10.0.0.5:4028", got)
+	}
+	if got := NewClient("10.0.0.5:4029", time.Second).Address; got != "10.0.0.5:4029" {
+		t.Errorf("Address = %s, want 10.0.0.5:4029", got)
+	}
+}
+
+func TestPauseResume(t *testing.T) {
+	client := serve(t, NewSimulator("Antminer S19", 95, 3250))
+
+	summary, err := client.Summary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.MHSAv != 95e6 {
+		t.Errorf("MHS av = %v, want 95e6", summary.MHSAv)
+	}
+
+	if err := client.Pause(); err != nil {
+		t.Fatal(err)
+	}
+	summary, err = client.Summary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.MHSAv != 0 || summary.Power != 0 {
+		t.Errorf("paused summary = %+v, want no hashrate or power", summary)
+	}
+
+	if err := client.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	summary, err = client.Summary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.MHSAv != 95e6 || summary.Power <= 0 {
+		t.Errorf("resumed summary = %+v, want full hashrate and power", summary)
+	}
+}
+
+func TestStats(t *testing.T) {
+	client := serve(t, NewSimulator("Antminer S19", 95, 3250))
+	stats, err := client.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(ChipTemperatures(stats)); got != 3 {
+		t.Errorf("%d chip temperatures, want 3", got)
+	}
+	if got := len(FanSpeeds(stats)); got != 4 {
+		t.Errorf("%d fan speeds, want 4", got)
+	}
+}
+
+func TestPools(t *testing.T) {
+	client := serve(t, NewSimulator("Antminer S19", 95, 3250))
+	if err := client.SwitchPool(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.DisablePool(0); err != nil {
+		t.Fatal(err)
+	}
+	pools, err := client.Pools()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pools) != 2 || !pools[1].StratumActive || pools[1].Priority != 0 || pools[0].Status != "Disabled" {
+		t.Errorf("pools = %+v, want the backup active and the first disabled", pools)
+	}
+}
+
+func TestErrorResponse(t *testing.T) {
+	client := serve(t, NewSimulator("Antminer S19", 95, 3250))
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"unknown command", client.Command("reboot", "", nil), "Invalid command"},
+		{"unknown pool", client.SwitchPool(7), "Invalid pool id 7"},
+		{"unknown board", client.Command("ascenable", "9", nil), "Invalid ASC id 9"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.err == nil || !strings.Contains(tt.err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", tt.err, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+	if _, err := NewClient(address, time.Second).Summary(); err == nil {
+		t.Error("Summary() of an unreachable miner succeeded")
+	}
+}
+
+func TestChipTemperatures(t *testing.T) {
+	stats := []map[string]interface{}{
+		{"temp2_1": 70.0, "temp2_2": 0.0, "temp_chip3": "60-61-0-62", "temp1": 40.0},
+	}
+	got := ChipTemperatures(stats)
+	want := []float64{70, 60, 61, 62}
+	if len(got) != len(want) {
+		t.Fatalf("ChipTemperatures() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("ChipTemperatures() = %v, want %v", got, want)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package cgminer
+
+import (
+	"encoding/json"
+	"math/rand"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Simulator answers the miner API like a miner with a number of hashing
+// boards, so the services can be run without hardware. Hashrate and power
+// scale with the boards left enabled.
+type Simulator struct {
+	Model      string
+	Boards     int
+	HashrateTH float64
+	PowerW     float64
+
+	mutex    sync.Mutex
+	started  time.Time
+	disabled map[int]bool
+	pools    []Pool
+	accepted int64
+}
+
+// NewSimulator returns a simulated miner with three hashing boards and two
+// pools.
+func NewSimulator(model string, hashrateTH float64, powerW float64) *Simulator {
+	return &Simulator{
+		Model:      model,
+		Boards:     3,
+		HashrateTH: hashrateTH,
+		PowerW:     powerW,
+		started:    time.Now(),
+		disabled:   make(map[int]bool),
+		pools: []Pool{
+			{Pool: 0, URL: "stratum+tcp://pool.example.com:3333", Status: "Alive", Priority: 0, User: "profitmax", StratumActive: true},
+			{Pool: 1, URL: "stratum+tcp://backup.example.com:3333", Status: "Alive", Priority: 1, User: "profitmax"},
+		},
+	}
+}
+
+// Serve answers API requests on listener until it is closed.
+func (s *Simulator) Serve(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *Simulator) handle(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	var request Request
+	err := json.NewDecoder(conn).Decode(&request)
+	if err != nil {
+		return
+	}
+	reply := s.reply(request)
+	body, err := json.Marshal(reply)
+	if err != nil {
+		return
+	}
+	conn.Write(append(body, 0))
+}
+
+func (s *Simulator) reply(request Request) map[string]interface{} {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	status := func(state string, code int, msg string) []Status {
+		return []Status{{Status: state, When: now.Unix(), Code: code, Msg: msg, Description: "simulator " + s.Model}}
+	}
+	reply := map[string]interface{}{"id": 1}
+
+	switch request.Command {
+	case "summary":
+		share := s.enabledShare()
+		mhs := s.HashrateTH * 1e6 * share
+		s.accepted += int64(share * 10)
+		reply["STATUS"] = status("S", 11, "Summary")
+		reply["SUMMARY"] = []Summary{{
+			Elapsed:  int64(now.Sub(s.started).Seconds()),
+			MHSAv:    mhs,
+			MHS5s:    mhs * (0.98 + 0.04*rand.Float64()),
+			Accepted: s.accepted,
+			Power:    s.PowerW * share * (0.99 + 0.02*rand.Float64()),
+		}}
+	case "stats":
+		reply["STATUS"] = status("S", 70, "CGMiner stats")
+		stats := map[string]interface{}{"Type": s.Model, "Elapsed": int64(now.Sub(s.started).Seconds()), "miner_count": s.Boards}
+		for board := 0; board < s.Boards; board++ {
+			temp := 35.0
+			if !s.disabled[board] {
+				temp = 65
+			}
+			stats["temp2_"+strconv.Itoa(board+1)] = temp + 4*rand.Float64()
+		}
+		for fan := 1; fan <= 4; fan++ {
+			stats["fan"+strconv.Itoa(fan)] = 1800 + 3600*s.enabledShare() + 100*rand.Float64()
+		}
+		reply["STATS"] = []map[string]interface{}{stats}
+	case "pools":
+		reply["STATUS"] = status("S", 7, strconv.Itoa(len(s.pools))+" Pool(s)")
+		reply["POOLS"] = s.pools
+	case "asccount":
+		reply["STATUS"] = status("S", 104, "ASC count")
+		reply["ASCS"] = []map[string]int{{"Count": s.Boards}}
+	case "ascenable", "ascdisable":
+		asc, err := strconv.Atoi(request.Parameter)
+		if err != nil || asc < 0 || asc >= s.Boards {
+			reply["STATUS"] = status("E", 107, "Invalid ASC id "+request.Parameter)
+			break
+		}
+		s.disabled[asc] = request.Command == "ascdisable"
+		reply["STATUS"] = status("S", 109, request.Command+" ASC "+request.Parameter)
+	case "switchpool", "enablepool", "disablepool":
+		pool, err := strconv.Atoi(request.Parameter)
+		if err != nil || pool < 0 || pool >= len(s.pools) {
+			reply["STATUS"] = status("E", 26, "Invalid pool id "+request.Parameter)
+			break
+		}
+		switch request.Command {
+		case "switchpool":
+			for i := range s.pools {
+				s.pools[i].Priority++
+				s.pools[i].StratumActive = false
+			}
+			s.pools[pool].Priority = 0
+			s.pools[pool].StratumActive = true
+		case "enablepool":
+			s.pools[pool].Status = "Alive"
+		case "disablepool":
+			s.pools[pool].Status = "Disabled"
+		}
+		reply["STATUS"] = status("S", 27, request.Command+" "+request.Parameter)
+	default:
+		reply["STATUS"] = status("E", 14, "Invalid command")
+	}
+	return reply
+}
+
+// enabledShare returns the share of hashing boards that are enabled.
+func (s *Simulator) enabledShare() float64 {
+	if s.Boards <= 0 {
+		return 0
+	}
+	enabled := s.Boards
+	for _, disabled := range s.disabled {
+		if disabled {
+			enabled--
+		}
+	}
+	return float64(enabled) / float64(s.Boards)
+}
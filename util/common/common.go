@@ -12,4 +12,5 @@ type Config struct {
 	TimeInterval int      `json:"time_interval"`
 	LocationID   string   `json:"location_id"`
 	Currency     string   `json:"currency"`
+	Efficiency   float64  `json:"efficiency_j_per_th"`
 }
@@ -0,0 +1,266 @@
+package costmodel
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	economics "profitmax/util/economics"
+	fx "profitmax/util/fx"
+)
+
+// ItemType says how a cost item accrues over time.
+type ItemType string
+
+const (
+	// Capex is a capital purchase amortised straight-line over its lifetime.
+	Capex ItemType = "CAPEX"
+	// Fixed is an operating cost charged per month.
+	Fixed ItemType = "FIXED"
+	// Variable is an operating cost charged per MWh consumed.
+	Variable ItemType = "VARIABLE"
+	// Hosting is a fee charged per TH/s per month.
+	Hosting ItemType = "HOSTING"
+)
+
+// averageMonth is the length of the average Gregorian month.
+const averageMonth = time.Duration(365.2425 / 12 * 24 * float64(time.Hour))
+
+const dateLayout = "2006-01-02"
+
+// Item is one line of a site's cost model. Amount is the purchase cost for
+// Capex, the monthly charge for Fixed, the charge per MWh for Variable and
+// the monthly charge per TH/s for Hosting.
+type Item struct {
+	ID             int64     `json:"id"`
+	LocationID     string    `json:"location_id"`
+	CostCode       string    `json:"cost_code"`
+	Type           ItemType  `json:"cost_type"`
+	Currency       string    `json:"currency"`
+	Amount         float64   `json:"amount"`
+	PurchaseDate   time.Time `json:"purchase_date"`
+	LifetimeMonths int       `json:"lifetime_months"`
+	Salvage        float64   `json:"salvage_value"`
+	EffectiveFrom  time.Time `json:"effective_from"`
+	EffectiveTo    time.Time `json:"effective_to"`
+	LastUpdated    time.Time `json:"last_updated"`
+}
+
+// Usage is what a site consumed or deployed during a period.
+type Usage struct {
+	Energy   economics.Energy
+	Hashrate economics.Hashrate
+}
+
+// ActiveAt reports whether the item applies at t. A zero EffectiveTo leaves
+// the item open-ended.
+func (i Item) ActiveAt(t time.Time) bool {
+	if t.Before(i.EffectiveFrom) {
+		return false
+	}
+	return i.EffectiveTo.IsZero() || t.Before(i.EffectiveTo)
+}
+
+// PeriodCost returns the item's cost, in its own currency, for a period of
+// length period starting at t.
+func (i Item) PeriodCost(t time.Time, period time.Duration, usage Usage) economics.Amount {
+	if !i.ActiveAt(t) {
+		return 0
+	}
+	months := float64(period) / float64(averageMonth)
+
+	switch i.Type {
+	case Capex:
+		if i.LifetimeMonths <= 0 || t.Before(i.PurchaseDate) {
+			return 0
+		}
+		if !t.Before(i.PurchaseDate.AddDate(0, i.LifetimeMonths, 0)) {
+			// Fully amortised
+			return 0
+		}
+		perMonth := (i.Amount - i.Salvage) / float64(i.LifetimeMonths)
+		return economics.Amount(perMonth * months)
+	case Fixed:
+		return economics.Amount(i.Amount * months)
+	case Variable:
+		return economics.Amount(i.Amount * usage.Energy.MWh())
+	case Hosting:
+		return economics.Amount(i.Amount * usage.Hashrate.TH() * months)
+	default:
+		return 0
+	}
+}
+
+// Validate reports an item that cannot be costed.
+func (i Item) Validate() error {
+	switch i.Type {
+	case Capex:
+		if i.LifetimeMonths <= 0 || i.PurchaseDate.IsZero() {
+			return fmt.Errorf("cost item %d: capex needs a purchase date and lifetime", i.ID)
+		}
+		if i.Salvage > i.Amount {
+			return fmt.Errorf("cost item %d: salvage value exceeds purchase cost", i.ID)
+		}
+	case Fixed, Variable, Hosting:
+	default:
+		return fmt.Errorf("cost item %d: unknown cost type %q", i.ID, i.Type)
+	}
+	if i.Currency == "" {
+		return fmt.Errorf("cost item %d: no currency", i.ID)
+	}
+	return nil
+}
+
+// PeriodCosts returns the cost of every cost code for a period of length
+// period starting at t, restated in currency.
+func PeriodCosts(items []Item, t time.Time, period time.Duration, usage Usage, currency string, converter *fx.Converter) (map[string]economics.Amount, error) {
+	costs := make(map[string]economics.Amount)
+	for _, item := range items {
+		if !item.ActiveAt(t) {
+			continue
+		}
+		cost, err := fx.Convert(converter, item.PeriodCost(t, period, usage), item.Currency, currency)
+		if err != nil {
+			return nil, err
+		}
+		costs[item.CostCode] += cost
+	}
+	return costs, nil
+}
+
+// LoadItems reads the cost items of a site from tbl_mining_cost_item.
+// Items that fail validation are returned in the error and left out.
+func LoadItems(db *sql.DB, locationID string) ([]Item, error) {
+	rows, err := db.Query("SELECT id, location_id, cost_code, cost_type, currency_code, amount, purchase_date, lifetime_months, salvage_value, effective_from, effective_to, last_updated FROM tbl_mining_cost_item WHERE location_id=?", locationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []Item
+	var invalid error
+	for rows.Next() {
+		var item Item
+		var purchaseDate, effectiveTo sql.NullString
+		var lifetimeMonths sql.NullInt64
+		var salvage sql.NullFloat64
+		var effectiveFrom, lastUpdated string
+
+		err = rows.Scan(&item.ID, &item.LocationID, &item.CostCode, &item.Type, &item.Currency, &item.Amount,
+			&purchaseDate, &lifetimeMonths, &salvage, &effectiveFrom, &effectiveTo, &lastUpdated)
+		if err != nil {
+			return nil, err
+		}
+		item.PurchaseDate = parseDate(purchaseDate.String)
+		item.LifetimeMonths = int(lifetimeMonths.Int64)
+		item.Salvage = salvage.Float64
+		item.EffectiveFrom = parseDate(effectiveFrom)
+		item.EffectiveTo = parseDate(effectiveTo.String)
+		item.LastUpdated, _ = time.ParseInLocation("2006-01-02 15:04:05", lastUpdated, time.Local)
+
+		if err := item.Validate(); err != nil {
+			invalid = err
+			continue
+		}
+		items = append(items, item)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, invalid
+}
+
+func parseDate(value string) time.Time {
+	if len(value) > len(dateLayout) {
+		value = value[:len(dateLayout)]
+	}
+	date, err := time.ParseInLocation(dateLayout, value, time.Local)
+	if err != nil {
+		return time.Time{}
+	}
+	return date
+}
+
+// ItemEvent announces a change to a cost item on the cost items topic.
+type ItemEvent struct {
+	Action    string    `json:"action"`
+	Item      Item      `json:"item"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+const (
+	ActionUpsert = "UPSERT"
+	ActionDelete = "DELETE"
+)
+
+// SaveItem inserts item, or updates it when it has an ID, and stamps its
+// last_updated time.
+func SaveItem(db *sql.DB, item *Item) error {
+	if err := item.Validate(); err != nil {
+		return err
+	}
+	item.LastUpdated = time.Now()
+
+	var purchaseDate, effectiveTo interface{}
+	if !item.PurchaseDate.IsZero() {
+		purchaseDate = item.PurchaseDate.Format(dateLayout)
+	}
+	if !item.EffectiveTo.IsZero() {
+		effectiveTo = item.EffectiveTo.Format(dateLayout)
+	}
+
+	if item.ID == 0 {
+		insertData := "INSERT INTO tbl_mining_cost_item (location_id, cost_code, cost_type, currency_code, amount, purchase_date, lifetime_months, salvage_value, effective_from, effective_to, last_updated) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+		result, err := db.Exec(insertData, item.LocationID, item.CostCode, item.Type, item.Currency, item.Amount,
+			purchaseDate, item.LifetimeMonths, item.Salvage, item.EffectiveFrom.Format(dateLayout), effectiveTo, item.LastUpdated)
+		if err != nil {
+			return err
+		}
+		item.ID, err = result.LastInsertId()
+		return err
+	}
+
+	updateData := "UPDATE tbl_mining_cost_item SET location_id = ?, cost_code = ?, cost_type = ?, currency_code = ?, amount = ?, purchase_date = ?, lifetime_months = ?, salvage_value = ?, effective_from = ?, effective_to = ?, last_updated = ? WHERE id = ?"
+	result, err := db.Exec(updateData, item.LocationID, item.CostCode, item.Type, item.Currency, item.Amount,
+		purchaseDate, item.LifetimeMonths, item.Salvage, item.EffectiveFrom.Format(dateLayout), effectiveTo, item.LastUpdated, item.ID)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("cost item %d not found", item.ID)
+	}
+	return nil
+}
+
+// DeleteItem removes a cost item and returns it as it was.
+func DeleteItem(db *sql.DB, id int64) (Item, error) {
+	var locationID string
+	err := db.QueryRow("SELECT location_id FROM tbl_mining_cost_item WHERE id=?", id).Scan(&locationID)
+	if err != nil {
+		return Item{}, fmt.Errorf("cost item %d: %w", id, err)
+	}
+	items, _ := LoadItems(db, locationID)
+
+	_, err = db.Exec("DELETE FROM tbl_mining_cost_item WHERE id=?", id)
+	if err != nil {
+		return Item{}, err
+	}
+	for _, item := range items {
+		if item.ID == id {
+			return item, nil
+		}
+	}
+	return Item{ID: id, LocationID: locationID}, nil
+}
+
+// Version returns a token that changes whenever a site's cost items are
+// added, edited or removed.
+func Version(db *sql.DB, locationID string) (string, error) {
+	var count int64
+	var lastUpdated sql.NullString
+	err := db.QueryRow("SELECT COUNT(*), MAX(last_updated) FROM tbl_mining_cost_item WHERE location_id=?", locationID).Scan(&count, &lastUpdated)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d/%s", count, lastUpdated.String), nil
+}
@@ -0,0 +1,138 @@
+package costmodel
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	economics "profitmax/util/economics"
+	fx "profitmax/util/fx"
+)
+
+func near(got, want float64) bool {
+	if want == 0 {
+		return math.Abs(got) < 1e-12
+	}
+	return math.Abs(got-want) <= 1e-9*math.Abs(want)
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.Local)
+}
+
+var usage = Usage{Energy: 2 * economics.MegawattHour, Hashrate: 500 * economics.TerahashPerSecond}
+
+func TestActiveAt(t *testing.T) {
+	tests := []struct {
+		name string
+		item Item
+		at   time.Time
+		want bool
+	}{
+		{"before it takes effect", Item{EffectiveFrom: date(2024, 3, 1)}, date(2024, 2, 29), false},
+		{"on the day it takes effect", Item{EffectiveFrom: date(2024, 3, 1)}, date(2024, 3, 1), true},
+		{"open-ended", Item{EffectiveFrom: date(2024, 3, 1)}, date(2034, 3, 1), true},
+		{"before it ends", Item{EffectiveFrom: date(2024, 3, 1), EffectiveTo: date(2024, 6, 1)}, date(2024, 5, 31), true},
+		{"on the day it ends", Item{EffectiveFrom: date(2024, 3, 1), EffectiveTo: date(2024, 6, 1)}, date(2024, 6, 1), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.item.ActiveAt(tt.at); got != tt.want {
+				t.Errorf("ActiveAt() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPeriodCost(t *testing.T) {
+	capex := Item{Type: Capex, Amount: 13000, Salvage: 1000, LifetimeMonths: 12, PurchaseDate: date(2024, 1, 1), EffectiveFrom: date(2024, 1, 1)}
+	retired := capex
+	retired.EffectiveTo = date(2024, 7, 1)
+	noLifetime := capex
+	noLifetime.LifetimeMonths = 0
+
+	tests := []struct {
+		name   string
+		item   Item
+		at     time.Time
+		period time.Duration
+		want   float64
+	}{
+		{"capex over a month", capex, date(2024, 2, 1), averageMonth, 1000},
+		{"capex over a day", capex, date(2024, 2, 1), 24 * time.Hour, 1000 * 24 / (365.2425 / 12 * 24)},
+		{"capex in its last month", capex, date(2024, 12, 31), averageMonth, 1000},
+		{"capex once amortised", capex, date(2025, 1, 1), averageMonth, 0},
+		{"capex before purchase", Item{Type: Capex, Amount: 13000, LifetimeMonths: 12, PurchaseDate: date(2024, 2, 1)}, date(2024, 1, 15), averageMonth, 0},
+		{"capex after it is retired", retired, date(2024, 7, 1), averageMonth, 0},
+		{"capex without a lifetime", noLifetime, date(2024, 2, 1), averageMonth, 0},
+		{"fixed over a month", Item{Type: Fixed, Amount: 5000}, date(2024, 2, 1), averageMonth, 5000},
+		{"fixed over half a month", Item{Type: Fixed, Amount: 5000}, date(2024, 2, 1), averageMonth / 2, 2500},
+		{"fixed before it takes effect", Item{Type: Fixed, Amount: 5000, EffectiveFrom: date(2024, 3, 1)}, date(2024, 2, 1), averageMonth, 0},
+		{"variable per MWh", Item{Type: Variable, Amount: 4}, date(2024, 2, 1), time.Hour, 8},
+		{"variable ignores the period", Item{Type: Variable, Amount: 4}, date(2024, 2, 1), averageMonth, 8},
+		{"hosting per TH/s over a month", Item{Type: Hosting, Amount: 0.5}, date(2024, 2, 1), averageMonth, 250},
+		{"unknown type", Item{Type: "OTHER", Amount: 100}, date(2024, 2, 1), averageMonth, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.item.PeriodCost(tt.at, tt.period, usage)
+			if !near(float64(got), tt.want) {
+				t.Errorf("PeriodCost() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		item    Item
+		wantErr bool
+	}{
+		{"capex", Item{Type: Capex, Currency: "AUD", Amount: 1000, Salvage: 100, LifetimeMonths: 36, PurchaseDate: date(2024, 1, 1)}, false},
+		{"capex without a lifetime", Item{Type: Capex, Currency: "AUD", Amount: 1000, PurchaseDate: date(2024, 1, 1)}, true},
+		{"capex without a purchase date", Item{Type: Capex, Currency: "AUD", Amount: 1000, LifetimeMonths: 36}, true},
+		{"capex salvaged above cost", Item{Type: Capex, Currency: "AUD", Amount: 1000, Salvage: 1001, LifetimeMonths: 36, PurchaseDate: date(2024, 1, 1)}, true},
+		{"fixed", Item{Type: Fixed, Currency: "AUD", Amount: 1000}, false},
+		{"variable", Item{Type: Variable, Currency: "AUD", Amount: 4}, false},
+		{"hosting", Item{Type: Hosting, Currency: "USD", Amount: 0.5}, false},
+		{"no currency", Item{Type: Fixed, Amount: 1000}, true},
+		{"unknown type", Item{Type: "OTHER", Currency: "AUD", Amount: 1000}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.item.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPeriodCosts(t *testing.T) {
+	converter := fx.NewConverter()
+	converter.Set(fx.Rate{Base: "USD", Quote: "AUD", Rate: 1.5})
+
+	items := []Item{
+		{CostCode: "RENT", Type: Fixed, Currency: "AUD", Amount: 3000},
+		{CostCode: "RENT", Type: Fixed, Currency: "AUD", Amount: 1000, EffectiveTo: date(2024, 1, 1)},
+		{CostCode: "HOSTING", Type: Hosting, Currency: "USD", Amount: 0.5},
+		{CostCode: "GRID", Type: Variable, Currency: "AUD", Amount: 4},
+	}
+	costs, err := PeriodCosts(items, date(2024, 2, 1), averageMonth, usage, "AUD", converter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]float64{"RENT": 3000, "HOSTING": 375, "GRID": 8}
+	if len(costs) != len(want) {
+		t.Fatalf("PeriodCosts() = %v, want %v", costs, want)
+	}
+	for code, cost := range want {
+		if !near(float64(costs[code]), cost) {
+			t.Errorf("PeriodCosts()[%s] = %v, want %v", code, costs[code], cost)
+		}
+	}
+
+	if _, err := PeriodCosts(items, date(2024, 2, 1), averageMonth, usage, "EUR", converter); err == nil {
+		t.Error("PeriodCosts() without a rate succeeded")
+	}
+}
@@ -0,0 +1,198 @@
+package decision
+
+import (
+	"math"
+	"time"
+
+	economics "profitmax/util/economics"
+)
+
+// Action is what a site's fleet should be doing.
+type Action string
+
+const (
+	Run     Action = "RUN"
+	Curtail Action = "CURTAIL"
+)
+
+// Reason explains why a decision came out the way it did.
+type Reason string
+
+const (
+	ReasonMarginAboveThreshold Reason = "MARGIN_ABOVE_THRESHOLD"
+	ReasonMarginBelowThreshold Reason = "MARGIN_BELOW_THRESHOLD"
+	ReasonWithinHysteresis     Reason = "WITHIN_HYSTERESIS_BAND"
+	ReasonMinOnTime            Reason = "MIN_ON_TIME"
+	ReasonMinOffTime           Reason = "MIN_OFF_TIME"
+	ReasonRampLimited          Reason = "RAMP_LIMITED"
+	ReasonStaleHold            Reason = "STALE_INPUTS_HOLD"
+	ReasonStaleCurtail         Reason = "STALE_INPUTS_CURTAIL"
+	ReasonLookAheadRun         Reason = "LOOK_AHEAD_RUN"
+	ReasonLookAheadCurtail     Reason = "LOOK_AHEAD_CURTAIL"
+)
+
+// Policy configures when a site runs or curtails. Margins are profit as a
+// fraction of cost: a site runs once its margin rises above
+// MarginThreshold+HysteresisBand and curtails once it falls below
+// MarginThreshold-HysteresisBand. LookAhead, when set, lets a site weigh
+// the intervals ahead instead.
+type Policy struct {
+	MarginThreshold float64 `json:"margin_threshold"`
+	HysteresisBand  float64 `json:"hysteresis_band"`
+	MinOnMinutes    int     `json:"min_on_minutes"`
+	MinOffMinutes   int     `json:"min_off_minutes"`
+	RampPerMinute   float64 `json:"ramp_per_minute"`
+
+	LookAhead *LookAhead `json:"look_ahead,omitempty"`
+}
+
+// State is what the decision maker remembers about a site between decisions.
+// RunFraction is the share of the fleet asked to run.
+type State struct {
+	Action      Action    `json:"action"`
+	RunFraction float64   `json:"run_fraction"`
+	Since       time.Time `json:"since"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Decision is the outcome of one evaluation of a site. Groups, when the
+// site's fleet is known, says how many units of each model should run.
+// Stale names the inputs that were past their TTL and Fallback how the
+// decision was made without them. Plan is the action planned for each
+// look-ahead interval, starting now.
+type Decision struct {
+	LocationID  string        `json:"location_id"`
+	Action      Action        `json:"action"`
+	RunFraction float64       `json:"run_fraction"`
+	Margin      float64       `json:"margin"`
+	Reason      Reason        `json:"reason"`
+	Changed     bool          `json:"changed"`
+	Timestamp   time.Time     `json:"timestamp"`
+	Groups      []GroupTarget `json:"groups,omitempty"`
+	Stale       []string      `json:"stale,omitempty"`
+	Fallback    string        `json:"fallback,omitempty"`
+	Plan        []Action      `json:"plan,omitempty"`
+}
+
+// MaxMargin caps margins, which grow without bound as cost falls to zero.
+const MaxMargin = 1000.0
+
+// Margin returns profit as a fraction of cost, at most MaxMargin. A site that
+// makes a profit at no cost, or is paid to use energy, is as profitable as a
+// site can be.
+func Margin(incentive economics.Amount, cost economics.Amount) float64 {
+	profit := economics.Profit(incentive, cost)
+	if cost <= 0 {
+		if profit > 0 {
+			return MaxMargin
+		}
+		return 0
+	}
+	return math.Min(float64(profit/cost), MaxMargin)
+}
+
+// Decide evaluates a site at margin and returns the decision and the state to
+// carry into the next evaluation. A site with no history is assumed to be
+// running in full.
+func Decide(policy Policy, state State, margin float64, now time.Time) (Decision, State) {
+	if state.Action == "" {
+		state = State{Action: Run, RunFraction: 1}
+	}
+
+	// Pick a side only once the margin leaves the hysteresis band
+	desired := state.Action
+	reason := ReasonWithinHysteresis
+	switch {
+	case margin >= policy.MarginThreshold+policy.HysteresisBand:
+		desired = Run
+		reason = ReasonMarginAboveThreshold
+	case margin <= policy.MarginThreshold-policy.HysteresisBand:
+		desired = Curtail
+		reason = ReasonMarginBelowThreshold
+	}
+
+	return advance(policy, state, desired, reason, margin, now)
+}
+
+// advance moves state toward the desired action, holding the current action
+// until it has lasted its minimum duration and ramping no faster than the
+// policy allows.
+func advance(policy Policy, state State, desired Action, reason Reason, margin float64, now time.Time) (Decision, State) {
+	// Hold the current action until it has lasted its minimum duration
+	held := now.Sub(state.Since)
+	if desired != state.Action && !state.Since.IsZero() {
+		if state.Action == Run && held < time.Duration(policy.MinOnMinutes)*time.Minute {
+			desired = Run
+			reason = ReasonMinOnTime
+		} else if state.Action == Curtail && held < time.Duration(policy.MinOffMinutes)*time.Minute {
+			desired = Curtail
+			reason = ReasonMinOffTime
+		}
+	}
+
+	next := state
+	if desired != state.Action {
+		next.Action = desired
+		next.Since = now
+	}
+
+	// Move the fleet toward its target no faster than the ramp limit
+	target := 0.0
+	if desired == Run {
+		target = 1
+	}
+	next.RunFraction = target
+	if policy.RampPerMinute > 0 && !state.UpdatedAt.IsZero() {
+		step := policy.RampPerMinute * now.Sub(state.UpdatedAt).Minutes()
+		if math.Abs(target-state.RunFraction) > step {
+			next.RunFraction = state.RunFraction + math.Copysign(step, target-state.RunFraction)
+			reason = ReasonRampLimited
+		}
+	}
+	next.UpdatedAt = now
+
+	decision := Decision{
+		Action:      next.Action,
+		RunFraction: next.RunFraction,
+		Margin:      margin,
+		Reason:      reason,
+		Changed:     next.Action != state.Action || next.RunFraction != state.RunFraction,
+		Timestamp:   now,
+	}
+	return decision, next
+}
+
+// Hold repeats the action and run fraction of state, for when a site cannot
+// be evaluated.
+func Hold(state State, margin float64, now time.Time) (Decision, State) {
+	if state.Action == "" {
+		state = State{Action: Run, RunFraction: 1, Since: now}
+	}
+	state.UpdatedAt = now
+	decision := Decision{
+		Action:      state.Action,
+		RunFraction: state.RunFraction,
+		Margin:      margin,
+		Reason:      ReasonStaleHold,
+		Timestamp:   now,
+	}
+	return decision, state
+}
+
+// FailSafe curtails a site at once, regardless of its minimum on-time and
+// ramp limit.
+func FailSafe(state State, margin float64, now time.Time) (Decision, State) {
+	next := State{Action: Curtail, RunFraction: 0, Since: state.Since, UpdatedAt: now}
+	if state.Action != Curtail {
+		next.Since = now
+	}
+	decision := Decision{
+		Action:      Curtail,
+		RunFraction: 0,
+		Margin:      margin,
+		Reason:      ReasonStaleCurtail,
+		Changed:     state.Action != Curtail || state.RunFraction != 0,
+		Timestamp:   now,
+	}
+	return decision, next
+}
@@ -0,0 +1,359 @@
+package decision
+
+import (
+	"testing"
+	"time"
+
+	economics "profitmax/util/economics"
+)
+
+func TestDecide(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	band := Policy{MarginThreshold: 0.1, HysteresisBand: 0.05}
+	dwell := Policy{MarginThreshold: 0.1, MinOnMinutes: 30, MinOffMinutes: 60}
+	ramp := Policy{MarginThreshold: 0.1, RampPerMinute: 0.1}
+	running := State{Action: Run, RunFraction: 1, Since: now.Add(-2 * time.Hour), UpdatedAt: now.Add(-time.Minute)}
+	curtailed := State{Action: Curtail, RunFraction: 0, Since: now.Add(-2 * time.Hour), UpdatedAt: now.Add(-time.Minute)}
+
+	tests := []struct {
+		name         string
+		policy       Policy
+		state        State
+		margin       float64
+		wantAction   Action
+		wantFraction float64
+		wantReason   Reason
+		wantChanged  bool
+		wantSince    time.Time
+	}{
+		{
+			name:         "no history runs",
+			policy:       band,
+			margin:       0.2,
+			wantAction:   Run,
+			wantFraction: 1,
+			wantReason:   ReasonMarginAboveThreshold,
+		},
+		{
+			name:         "no history within the band keeps running",
+			policy:       band,
+			margin:       0.1,
+			wantAction:   Run,
+			wantFraction: 1,
+			wantReason:   ReasonWithinHysteresis,
+		},
+		{
+			name:         "running above the band",
+			policy:       band,
+			state:        running,
+			margin:       0.2,
+			wantAction:   Run,
+			wantFraction: 1,
+			wantReason:   ReasonMarginAboveThreshold,
+			wantSince:    running.Since,
+		},
+		{
+			name:         "running within the band holds",
+			policy:       band,
+			state:        running,
+			margin:       0.06,
+			wantAction:   Run,
+			wantFraction: 1,
+			wantReason:   ReasonWithinHysteresis,
+			wantSince:    running.Since,
+		},
+		{
+			name:         "running below the band curtails",
+			policy:       band,
+			state:        running,
+			margin:       0.04,
+			wantAction:   Curtail,
+			wantFraction: 0,
+			wantReason:   ReasonMarginBelowThreshold,
+			wantChanged:  true,
+			wantSince:    now,
+		},
+		{
+			name:         "curtailed within the band holds",
+			policy:       band,
+			state:        curtailed,
+			margin:       0.14,
+			wantAction:   Curtail,
+			wantFraction: 0,
+			wantReason:   ReasonWithinHysteresis,
+			wantSince:    curtailed.Since,
+		},
+		{
+			name:         "curtailed above the band runs",
+			policy:       band,
+			state:        curtailed,
+			margin:       0.16,
+			wantAction:   Run,
+			wantFraction: 1,
+			wantReason:   ReasonMarginAboveThreshold,
+			wantChanged:  true,
+			wantSince:    now,
+		},
+		{
+			name:         "minimum on-time holds a new run",
+			policy:       dwell,
+			state:        State{Action: Run, RunFraction: 1, Since: now.Add(-29 * time.Minute)},
+			margin:       -1,
+			wantAction:   Run,
+			wantFraction: 1,
+			wantReason:   ReasonMinOnTime,
+			wantSince:    now.Add(-29 * time.Minute),
+		},
+		{
+			name:         "minimum on-time met",
+			policy:       dwell,
+			state:        State{Action: Run, RunFraction: 1, Since: now.Add(-30 * time.Minute)},
+			margin:       -1,
+			wantAction:   Curtail,
+			wantFraction: 0,
+			wantReason:   ReasonMarginBelowThreshold,
+			wantChanged:  true,
+			wantSince:    now,
+		},
+		{
+			name:         "minimum off-time holds a new curtailment",
+			policy:       dwell,
+			state:        State{Action: Curtail, Since: now.Add(-59 * time.Minute)},
+			margin:       1,
+			wantAction:   Curtail,
+			wantFraction: 0,
+			wantReason:   ReasonMinOffTime,
+			wantSince:    now.Add(-59 * time.Minute),
+		},
+		{
+			name:         "minimum off-time met",
+			policy:       dwell,
+			state:        State{Action: Curtail, Since: now.Add(-time.Hour)},
+			margin:       1,
+			wantAction:   Run,
+			wantFraction: 1,
+			wantReason:   ReasonMarginAboveThreshold,
+			wantChanged:  true,
+			wantSince:    now,
+		},
+		{
+			name:         "ramps down",
+			policy:       ramp,
+			state:        State{Action: Run, RunFraction: 1, Since: now.Add(-time.Hour), UpdatedAt: now.Add(-3 * time.Minute)},
+			margin:       -1,
+			wantAction:   Curtail,
+			wantFraction: 0.7,
+			wantReason:   ReasonRampLimited,
+			wantChanged:  true,
+			wantSince:    now,
+		},
+		{
+			name:         "keeps ramping up",
+			policy:       ramp,
+			state:        State{Action: Run, RunFraction: 0.5, Since: now.Add(-5 * time.Minute), UpdatedAt: now.Add(-2 * time.Minute)},
+			margin:       1,
+			wantAction:   Run,
+			wantFraction: 0.7,
+			wantReason:   ReasonRampLimited,
+			wantChanged:  true,
+			wantSince:    now.Add(-5 * time.Minute),
+		},
+		{
+			name:         "ramp reaches its target",
+			policy:       ramp,
+			state:        State{Action: Run, RunFraction: 0.9, Since: now.Add(-5 * time.Minute), UpdatedAt: now.Add(-2 * time.Minute)},
+			margin:       1,
+			wantAction:   Run,
+			wantFraction: 1,
+			wantReason:   ReasonMarginAboveThreshold,
+			wantChanged:  true,
+			wantSince:    now.Add(-5 * time.Minute),
+		},
+		{
+			name:         "curtailed at a negative energy price runs",
+			policy:       band,
+			state:        curtailed,
+			margin:       Margin(100, -5),
+			wantAction:   Run,
+			wantFraction: 1,
+			wantReason:   ReasonMarginAboveThreshold,
+			wantChanged:  true,
+			wantSince:    now,
+		},
+		{
+			name:         "first ramp is not limited",
+			policy:       ramp,
+			state:        State{Action: Run, RunFraction: 1, Since: now.Add(-time.Hour)},
+			margin:       -1,
+			wantAction:   Curtail,
+			wantFraction: 0,
+			wantReason:   ReasonMarginBelowThreshold,
+			wantChanged:  true,
+			wantSince:    now,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, next := Decide(tt.policy, tt.state, tt.margin, now)
+			if d.Action != tt.wantAction || next.Action != tt.wantAction {
+				t.Errorf("action = %s, state %s, want %s", d.Action, next.Action, tt.wantAction)
+			}
+			if !near(d.RunFraction, tt.wantFraction) || !near(next.RunFraction, tt.wantFraction) {
+				t.Errorf("run fraction = %v, state %v, want %v", d.RunFraction, next.RunFraction, tt.wantFraction)
+			}
+			if d.Reason != tt.wantReason {
+				t.Errorf("reason = %s, want %s", d.Reason, tt.wantReason)
+			}
+			if d.Changed != tt.wantChanged {
+				t.Errorf("changed = %v, want %v", d.Changed, tt.wantChanged)
+			}
+			if !next.Since.Equal(tt.wantSince) {
+				t.Errorf("since = %v, want %v", next.Since, tt.wantSince)
+			}
+			if d.Margin != tt.margin || !d.Timestamp.Equal(now) || !next.UpdatedAt.Equal(now) {
+				t.Errorf("decision = %+v, state %+v, want margin %v at %v", d, next, tt.margin, now)
+			}
+		})
+	}
+}
+
+func near(a, b float64) bool {
+	const tolerance = 1e-9
+	return a-b < tolerance && b-a < tolerance
+}
+
+func TestHold(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		state State
+		want  State
+	}{
+		{
+			name:  "no history runs",
+			state: State{},
+			want:  State{Action: Run, RunFraction: 1, Since: now, UpdatedAt: now},
+		},
+		{
+			name:  "repeats a part run",
+			state: State{Action: Run, RunFraction: 0.4, Since: now.Add(-time.Hour), UpdatedAt: now.Add(-time.Minute)},
+			want:  State{Action: Run, RunFraction: 0.4, Since: now.Add(-time.Hour), UpdatedAt: now},
+		},
+		{
+			name:  "repeats a curtailment",
+			state: State{Action: Curtail, Since: now.Add(-time.Hour)},
+			want:  State{Action: Curtail, Since: now.Add(-time.Hour), UpdatedAt: now},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, next := Hold(tt.state, -0.5, now)
+			if next != tt.want {
+				t.Errorf("state = %+v, want %+v", next, tt.want)
+			}
+			if d.Action != tt.want.Action || d.RunFraction != tt.want.RunFraction || d.Reason != ReasonStaleHold || d.Changed {
+				t.Errorf("decision = %+v, want an unchanged %s at %v", d, tt.want.Action, tt.want.RunFraction)
+			}
+		})
+	}
+}
+
+func TestFailSafe(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		state       State
+		wantSince   time.Time
+		wantChanged bool
+	}{
+		{
+			name:        "no history",
+			state:       State{},
+			wantSince:   now,
+			wantChanged: true,
+		},
+		{
+			name:        "curtails at once despite the minimum on-time and ramp",
+			state:       State{Action: Run, RunFraction: 1, Since: now.Add(-time.Minute), UpdatedAt: now.Add(-time.Minute)},
+			wantSince:   now,
+			wantChanged: true,
+		},
+		{
+			name:        "finishes a ramp down",
+			state:       State{Action: Curtail, RunFraction: 0.3, Since: now.Add(-time.Hour)},
+			wantSince:   now.Add(-time.Hour),
+			wantChanged: true,
+		},
+		{
+			name:      "already curtailed",
+			state:     State{Action: Curtail, Since: now.Add(-time.Hour)},
+			wantSince: now.Add(-time.Hour),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, next := FailSafe(tt.state, 0.2, now)
+			want := State{Action: Curtail, Since: tt.wantSince, UpdatedAt: now}
+			if next != want {
+				t.Errorf("state = %+v, want %+v", next, want)
+			}
+			if d.Action != Curtail || d.RunFraction != 0 || d.Reason != ReasonStaleCurtail || d.Changed != tt.wantChanged {
+				t.Errorf("decision = %+v, want a curtailment, changed %v", d, tt.wantChanged)
+			}
+		})
+	}
+}
+
+func TestMargin(t *testing.T) {
+	tests := []struct {
+		incentive, cost economics.Amount
+		want            float64
+	}{
+		{120, 100, 0.2},
+		{80, 100, -0.2},
+		{100, 100, 0},
+		{100, 1e-9, MaxMargin},
+		{100, 0, MaxMargin},
+		{100, -5, MaxMargin},
+		{0, -5, MaxMargin},
+		{0, 0, 0},
+	}
+	for _, tt := range tests {
+		if got := Margin(tt.incentive, tt.cost); !near(got, tt.want) {
+			t.Errorf("Margin(%v, %v) = %v, want %v", tt.incentive, tt.cost, got, tt.want)
+		}
+	}
+}
+
+func TestMeritOrder(t *testing.T) {
+	// At a hashprice of 0.072 per TH/s-day, 20 J/TH breaks even at 150 per
+	// MWh and 30 J/TH at 100
+	classes := []MachineClass{
+		{Model: "old", Units: 10, Hashrate: 100 * economics.TerahashPerSecond, Power: 3000 * economics.Watt},
+		{Model: "new", Units: 10, Hashrate: 150 * economics.TerahashPerSecond, Power: 3000 * economics.Watt},
+	}
+	tests := []struct {
+		name        string
+		energyPrice economics.PricePerMWh
+		runFraction float64
+		want        map[string]int
+	}{
+		{"all run", 50, 1, map[string]int{"old": 10, "new": 10}},
+		{"all run at a negative price", -20, 1, map[string]int{"old": 10, "new": 10}},
+		{"all run at no price", 0, 1, map[string]int{"old": 10, "new": 10}},
+		{"efficient first", 50, 0.5, map[string]int{"old": 0, "new": 10}},
+		{"partial", 50, 0.75, map[string]int{"old": 5, "new": 10}},
+		{"inefficient above break-even", 120, 1, map[string]int{"old": 0, "new": 10}},
+		{"all above break-even", 200, 1, map[string]int{"old": 0, "new": 0}},
+		{"curtailed", 50, 0, map[string]int{"old": 0, "new": 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, target := range MeritOrder(classes, 0.072, tt.energyPrice, tt.runFraction) {
+				if target.RunUnits != tt.want[target.Model] {
+					t.Errorf("%s runs %d, want %d", target.Model, target.RunUnits, tt.want[target.Model])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package decision
+
+import (
+	"time"
+
+	economics "profitmax/util/economics"
+)
+
+// LookAhead configures look-ahead decisions. A site weighs the next
+// Intervals intervals, IntervalMinutes each, of a forward energy price
+// curve, and pays RestartCost, in its own currency, each time it starts back
+// up. Curve names the forecast model, such as PREDISPATCH, the curve comes
+// from; empty means the decision maker's trusted model.
+type LookAhead struct {
+	Intervals       int     `json:"intervals"`
+	IntervalMinutes int     `json:"interval_minutes"`
+	RestartCost     float64 `json:"restart_cost"`
+	Curve           string  `json:"curve"`
+}
+
+// Interval returns the length of one look-ahead interval.
+func (l LookAhead) Interval() time.Duration {
+	return time.Duration(l.IntervalMinutes) * time.Minute
+}
+
+// Interval is what a site earns and spends running through one look-ahead
+// interval.
+type Interval struct {
+	Incentive economics.Amount
+	Cost      economics.Amount
+}
+
+// value is what running through an interval is worth over the margin the
+// policy asks for.
+func (i Interval) value(policy Policy) float64 {
+	return float64(i.Incentive) - float64(i.Cost)*(1+policy.MarginThreshold)
+}
+
+// Plan decides a site by the most valuable sequence of actions over
+// intervals, the first of which starts now. Running earns an interval's
+// value, curtailing earns nothing, every restart costs RestartCost, and no
+// action changes before it has lasted its minimum duration. Riding through
+// a short price spike therefore beats curtailing when the restart costs more
+// than the spike. The first planned action is then ramped to as Decide
+// would.
+func Plan(policy Policy, state State, intervals []Interval, now time.Time) (Decision, State) {
+	if state.Action == "" {
+		state = State{Action: Run, RunFraction: 1}
+	}
+	lookAhead := *policy.LookAhead
+	step := lookAhead.Interval()
+	minSteps := map[Action]int{
+		Run:     ceilSteps(policy.MinOnMinutes, lookAhead.IntervalMinutes),
+		Curtail: ceilSteps(policy.MinOffMinutes, lookAhead.IntervalMinutes),
+	}
+
+	// Dwell counts the intervals an action has lasted, capped where the
+	// minimum durations stop mattering but never below the one interval a
+	// new action has lasted
+	longest := 1
+	for _, steps := range minSteps {
+		if steps > longest {
+			longest = steps
+		}
+	}
+	dwell := longest
+	if !state.Since.IsZero() && step > 0 {
+		held := int(now.Sub(state.Since) / step)
+		if held < dwell {
+			dwell = held
+		}
+	}
+
+	// best[i][a][d] is the most the intervals from i on are worth, entered
+	// in action a after d intervals of it, and choice[i][a][d] the action
+	// taken in interval i to get it
+	actions := []Action{Run, Curtail}
+	n := len(intervals)
+	best := make([][2][]float64, n+1)
+	choice := make([][2][]int, n)
+	for i := range best {
+		for a := range actions {
+			best[i][a] = make([]float64, longest+1)
+			if i < n {
+				choice[i][a] = make([]int, longest+1)
+			}
+		}
+	}
+	for i := n - 1; i >= 0; i-- {
+		for a, from := range actions {
+			for d := 0; d <= longest; d++ {
+				first := true
+				for b, to := range actions {
+					if to != from && d < minSteps[from] {
+						continue
+					}
+					next := 1
+					if to == from {
+						next = d + 1
+						if next > longest {
+							next = longest
+						}
+					}
+					value := best[i+1][b][next]
+					if to == Run {
+						value += intervals[i].value(policy)
+					}
+					if from == Curtail && to == Run {
+						value -= lookAhead.RestartCost
+					}
+					if first || value > best[i][a][d] {
+						best[i][a][d] = value
+						choice[i][a][d] = b
+						first = false
+					}
+				}
+			}
+		}
+	}
+
+	// Walk the plan forward from the current state
+	a := 0
+	if state.Action == Curtail {
+		a = 1
+	}
+	d := dwell
+	plan := make([]Action, n)
+	for i := range plan {
+		b := choice[i][a][d]
+		plan[i] = actions[b]
+		if b == a {
+			d++
+			if d > longest {
+				d = longest
+			}
+		} else {
+			d = 1
+		}
+		a = b
+	}
+
+	desired := state.Action
+	reason := ReasonWithinHysteresis
+	margin := 0.0
+	if n > 0 {
+		desired = plan[0]
+		reason = ReasonLookAheadRun
+		if desired == Curtail {
+			reason = ReasonLookAheadCurtail
+		}
+		margin = Margin(intervals[0].Incentive, intervals[0].Cost)
+	}
+	decision, next := advance(policy, state, desired, reason, margin, now)
+	decision.Plan = plan
+	return decision, next
+}
+
+// ceilSteps returns how many intervals of step minutes cover minutes.
+func ceilSteps(minutes int, step int) int {
+	if minutes <= 0 || step <= 0 {
+		return 0
+	}
+	return (minutes + step - 1) / step
+}
@@ -0,0 +1,69 @@
+package decision
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPlan(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	lookAhead := &LookAhead{Intervals: 4, IntervalMinutes: 30, RestartCost: 10}
+	profitable := Interval{Incentive: 120, Cost: 100}
+	loss := Interval{Incentive: 80, Cost: 100}
+	spike := Interval{Incentive: 95, Cost: 100}
+
+	tests := []struct {
+		name      string
+		policy    Policy
+		state     State
+		intervals []Interval
+		want      []Action
+	}{
+		{
+			name:      "zero dwell",
+			policy:    Policy{LookAhead: lookAhead},
+			state:     State{Action: Run, RunFraction: 1, Since: now.Add(-time.Hour)},
+			intervals: []Interval{loss, loss, profitable, loss},
+			want:      []Action{Curtail, Curtail, Run, Curtail},
+		},
+		{
+			name:      "zero dwell without history",
+			policy:    Policy{LookAhead: lookAhead},
+			intervals: []Interval{profitable, loss, profitable},
+			want:      []Action{Run, Curtail, Run},
+		},
+		{
+			name:      "rides through a spike cheaper than a restart",
+			policy:    Policy{LookAhead: lookAhead},
+			state:     State{Action: Run, RunFraction: 1, Since: now.Add(-time.Hour)},
+			intervals: []Interval{profitable, spike, profitable},
+			want:      []Action{Run, Run, Run},
+		},
+		{
+			name:      "holds the minimum on-time",
+			policy:    Policy{MinOnMinutes: 60, LookAhead: lookAhead},
+			state:     State{Action: Run, RunFraction: 1, Since: now},
+			intervals: []Interval{loss, loss, loss},
+			want:      []Action{Run, Run, Curtail},
+		},
+		{
+			name:      "holds the minimum off-time",
+			policy:    Policy{MinOffMinutes: 90, LookAhead: lookAhead},
+			state:     State{Action: Curtail, Since: now.Add(-30 * time.Minute)},
+			intervals: []Interval{profitable, profitable, profitable},
+			want:      []Action{Curtail, Curtail, Run},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision, _ := Plan(tt.policy, tt.state, tt.intervals, now)
+			if !reflect.DeepEqual(decision.Plan, tt.want) {
+				t.Errorf("Plan() = %v, want %v", decision.Plan, tt.want)
+			}
+			if decision.Action != tt.want[0] {
+				t.Errorf("Action = %v, want %v", decision.Action, tt.want[0])
+			}
+		})
+	}
+}
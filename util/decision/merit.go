@@ -0,0 +1,73 @@
+package decision
+
+import (
+	"math"
+	"sort"
+
+	economics "profitmax/util/economics"
+)
+
+// MachineClass is a group of identical miners at a site, described per unit.
+type MachineClass struct {
+	Model    string
+	Units    int
+	Hashrate economics.Hashrate
+	Power    economics.Power
+}
+
+// Efficiency returns the energy the class spends per terahash.
+func (c MachineClass) Efficiency() economics.Efficiency {
+	if c.Hashrate <= 0 {
+		return 0
+	}
+	return economics.Efficiency(float64(c.Power) / c.Hashrate.TH())
+}
+
+// GroupTarget is how many units of a class should run.
+type GroupTarget struct {
+	Model          string                `json:"model"`
+	Units          int                   `json:"units"`
+	RunUnits       int                   `json:"run_units"`
+	BreakEvenPrice economics.PricePerMWh `json:"break_even_price"`
+}
+
+// MeritOrder allocates a site's run fraction, a share of the fleet's power,
+// to its machine classes in order of break-even energy price. The most
+// efficient classes run first, and a class never runs while energyPrice is
+// above its break-even price, so curtailment sheds the least efficient
+// hashrate first.
+func MeritOrder(classes []MachineClass, hashprice economics.Amount, energyPrice economics.PricePerMWh, runFraction float64) []GroupTarget {
+	targets := make([]GroupTarget, len(classes))
+	var totalPower economics.Power
+	for i, class := range classes {
+		targets[i] = GroupTarget{
+			Model:          class.Model,
+			Units:          class.Units,
+			BreakEvenPrice: economics.BreakEvenEnergyPrice(hashprice, class.Efficiency()),
+		}
+		totalPower += class.Power * economics.Power(class.Units)
+	}
+
+	order := make([]int, len(classes))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return targets[order[a]].BreakEvenPrice > targets[order[b]].BreakEvenPrice
+	})
+
+	budget := totalPower * economics.Power(runFraction)
+	for _, i := range order {
+		class := classes[i]
+		if targets[i].BreakEvenPrice < energyPrice || class.Power <= 0 {
+			continue
+		}
+		units := int(math.Floor(float64(budget/class.Power) + 1e-9))
+		if units > class.Units {
+			units = class.Units
+		}
+		targets[i].RunUnits = units
+		budget -= class.Power * economics.Power(units)
+	}
+	return targets
+}
@@ -0,0 +1,158 @@
+package economics
+
+import (
+	"math"
+	"time"
+)
+
+// BlockInterval is the Bitcoin protocol's target time between blocks.
+const BlockInterval = 10 * time.Minute
+
+// hashesPerDifficulty is the expected number of hashes needed to find a block
+// at difficulty 1.
+var hashesPerDifficulty = math.Pow(2, 32)
+
+// Hashrate is a hashing speed in hashes per second.
+type Hashrate float64
+
+const (
+	HashPerSecond     Hashrate = 1
+	TerahashPerSecond          = 1e12 * HashPerSecond
+	ExahashPerSecond           = 1e18 * HashPerSecond
+)
+
+// TH returns the hashrate in TH/s.
+func (h Hashrate) TH() float64 {
+	return float64(h / TerahashPerSecond)
+}
+
+// Power is a power draw in watts.
+type Power float64
+
+const (
+	Watt     Power = 1
+	Kilowatt       = 1e3 * Watt
+	Megawatt       = 1e6 * Watt
+)
+
+// KW returns the power in kilowatts.
+func (p Power) KW() float64 {
+	return float64(p / Kilowatt)
+}
+
+// Energy is an amount of energy in watt-hours.
+type Energy float64
+
+const (
+	WattHour     Energy = 1
+	KilowattHour        = 1e3 * WattHour
+	MegawattHour        = 1e6 * WattHour
+)
+
+// MWh returns the energy in megawatt-hours.
+func (e Energy) MWh() float64 {
+	return float64(e / MegawattHour)
+}
+
+// Efficiency is the energy a miner spends per unit of work in joules per
+// terahash, which is the same as watts per TH/s.
+type Efficiency float64
+
+// DefaultEfficiency is the Antminer S19-class figure the energy cost
+// calculator assumes when no efficiency is configured.
+const DefaultEfficiency Efficiency = 29.5454545454545
+
+// PricePerMWh is an energy price in currency units per megawatt-hour, the unit
+// the NEM quotes spot prices in.
+type PricePerMWh float64
+
+// PerKWh returns the price in currency units per kilowatt-hour.
+func (p PricePerMWh) PerKWh() float64 {
+	return float64(p) / 1e3
+}
+
+// Amount is a sum of money in the currency of the message that carries it.
+type Amount float64
+
+// Coins is a quantity of cryptocurrency, e.g. a block subsidy in BTC.
+type Coins float64
+
+// EnergyUsed returns the energy drawn by a constant load over d.
+func EnergyUsed(p Power, d time.Duration) Energy {
+	return Energy(float64(p) * d.Hours())
+}
+
+// PowerFor returns the power needed to sustain hashrate h at efficiency e.
+func PowerFor(h Hashrate, e Efficiency) Power {
+	return Power(h.TH() * float64(e))
+}
+
+// EnergyCost returns the cost of running a constant load for d at price.
+func EnergyCost(p Power, d time.Duration, price PricePerMWh) Amount {
+	return Amount(EnergyUsed(p, d).MWh() * float64(price))
+}
+
+// NetworkHashrate returns the total network hashrate implied by difficulty,
+// assuming blocks arrive every BlockInterval.
+func NetworkHashrate(difficulty float64) Hashrate {
+	if difficulty <= 0 {
+		return 0
+	}
+	return Hashrate(difficulty * hashesPerDifficulty / BlockInterval.Seconds())
+}
+
+// BlocksIn returns the number of blocks the network is expected to find in d.
+func BlocksIn(d time.Duration) float64 {
+	return d.Seconds() / BlockInterval.Seconds()
+}
+
+// CoinValue returns the value of coins at price per coin.
+func CoinValue(coins Coins, price Amount) Amount {
+	return Amount(float64(coins) * float64(price))
+}
+
+// ExpectedCoins returns the block reward hashrate h is expected to earn over d
+// at the given difficulty and reward per block.
+func ExpectedCoins(h Hashrate, difficulty float64, reward Coins, d time.Duration) Coins {
+	if difficulty <= 0 {
+		return 0
+	}
+	blocks := float64(h) * d.Seconds() / (difficulty * hashesPerDifficulty)
+	return Coins(blocks * float64(reward))
+}
+
+// Revenue returns the expected revenue of hashrate h over d at the given
+// difficulty, reward per block and coin price.
+func Revenue(h Hashrate, difficulty float64, reward Coins, price Amount, d time.Duration) Amount {
+	return CoinValue(ExpectedCoins(h, difficulty, reward, d), price)
+}
+
+// Hashprice returns the expected revenue of 1 TH/s over one day.
+func Hashprice(difficulty float64, reward Coins, price Amount) Amount {
+	return Revenue(TerahashPerSecond, difficulty, reward, price, 24*time.Hour)
+}
+
+// BreakEvenEnergyPrice returns the energy price at which a miner of efficiency
+// e earning hashprice per TH/s per day exactly covers its energy cost. It
+// returns 0 when e is not positive.
+func BreakEvenEnergyPrice(hashprice Amount, e Efficiency) PricePerMWh {
+	if e <= 0 {
+		return 0
+	}
+	energyPerTHDay := EnergyUsed(PowerFor(TerahashPerSecond, e), 24*time.Hour)
+	return PricePerMWh(float64(hashprice) / energyPerTHDay.MWh())
+}
+
+// Profit returns revenue less cost.
+func Profit(revenue Amount, cost Amount) Amount {
+	return revenue - cost
+}
+
+// Sum returns the total of amounts.
+func Sum(amounts ...Amount) Amount {
+	var total Amount
+	for _, amount := range amounts {
+		total += amount
+	}
+	return total
+}
@@ -0,0 +1,193 @@
+package economics
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func near(got, want float64) bool {
+	if want == 0 {
+		return math.Abs(got) < 1e-12
+	}
+	return math.Abs(got-want) <= 1e-9*math.Abs(want)
+}
+
+func TestUnits(t *testing.T) {
+	tests := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"TH of 1 TH/s", (1 * TerahashPerSecond).TH(), 1},
+		{"TH of 1 EH/s", ExahashPerSecond.TH(), 1e6},
+		{"TH of 95e12 H/s", Hashrate(95e12).TH(), 95},
+		{"TH of nothing", Hashrate(0).TH(), 0},
+		{"TH of negative", Hashrate(-2e12).TH(), -2},
+		{"KW of 1 MW", Megawatt.KW(), 1000},
+		{"KW of 3250 W", (3250 * Watt).KW(), 3.25},
+		{"KW of nothing", Power(0).KW(), 0},
+		{"MWh of 1 MWh", MegawattHour.MWh(), 1},
+		{"MWh of 1 kWh", KilowattHour.MWh(), 0.001},
+		{"MWh of nothing", Energy(0).MWh(), 0},
+		{"MWh of negative", (-2 * MegawattHour).MWh(), -2},
+		{"per kWh of 100 per MWh", PricePerMWh(100).PerKWh(), 0.1},
+		{"per kWh of negative price", PricePerMWh(-40).PerKWh(), -0.04},
+		{"per kWh of nothing", PricePerMWh(0).PerKWh(), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !near(tt.got, tt.want) {
+				t.Errorf("got %v, want %v", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConversions(t *testing.T) {
+	tests := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"energy of 1 MW for an hour", float64(EnergyUsed(Megawatt, time.Hour)), float64(MegawattHour)},
+		{"energy of 3250 W for a block", EnergyUsed(3250*Watt, BlockInterval).MWh(), 3250.0 / 6 / 1e6},
+		{"energy of nothing", float64(EnergyUsed(0, time.Hour)), 0},
+		{"energy over no time", float64(EnergyUsed(Megawatt, 0)), 0},
+		{"power for 100 TH/s at 30 J/TH", float64(PowerFor(100*TerahashPerSecond, 30)), 3000},
+		{"power for no hashrate", float64(PowerFor(0, 30)), 0},
+		{"power at no efficiency", float64(PowerFor(TerahashPerSecond, 0)), 0},
+		{"cost of 1 MW for an hour at 100", float64(EnergyCost(Megawatt, time.Hour, 100)), 100},
+		{"cost of 2 MW for 30 minutes at 80", float64(EnergyCost(2*Megawatt, 30*time.Minute, 80)), 80},
+		{"cost at a negative price", float64(EnergyCost(Megawatt, time.Hour, -50)), -50},
+		{"cost at no price", float64(EnergyCost(Megawatt, time.Hour, 0)), 0},
+		{"network hashrate at difficulty 1", float64(NetworkHashrate(1)), math.Pow(2, 32) / 600},
+		{"network hashrate at no difficulty", float64(NetworkHashrate(0)), 0},
+		{"network hashrate at negative difficulty", float64(NetworkHashrate(-1)), 0},
+		{"blocks in a day", BlocksIn(24 * time.Hour), 144},
+		{"blocks in no time", BlocksIn(0), 0},
+		{"value of 3.125 coins at 60000", float64(CoinValue(3.125, 60000)), 187500},
+		{"value of no coins", float64(CoinValue(0, 60000)), 0},
+		{"value at no price", float64(CoinValue(3.125, 0)), 0},
+		{"network coins over a block", float64(ExpectedCoins(NetworkHashrate(1e12), 1e12, 3.125, BlockInterval)), 3.125},
+		{"network coins over a day", float64(ExpectedCoins(NetworkHashrate(1e12), 1e12, 3.125, 24*time.Hour)), 3.125 * 144},
+		{"coins at no difficulty", float64(ExpectedCoins(TerahashPerSecond, 0, 3.125, time.Hour)), 0},
+		{"coins at negative difficulty", float64(ExpectedCoins(TerahashPerSecond, -1, 3.125, time.Hour)), 0},
+		{"coins of no hashrate", float64(ExpectedCoins(0, 1e12, 3.125, time.Hour)), 0},
+		{"network revenue over a block", float64(Revenue(NetworkHashrate(1e12), 1e12, 3.125, 60000, BlockInterval)), 187500},
+		{"hashprice", float64(Hashprice(1e12, 3.125, 60000)), 1e12 * 24 * 3600 / (1e12 * math.Pow(2, 32)) * 3.125 * 60000},
+		{"hashprice at no difficulty", float64(Hashprice(0, 3.125, 60000)), 0},
+		{"break-even of 0.072 per TH/s-day at 30 J/TH", float64(BreakEvenEnergyPrice(0.072, 30)), 100},
+		{"break-even at no efficiency", float64(BreakEvenEnergyPrice(0.072, 0)), 0},
+		{"break-even at negative efficiency", float64(BreakEvenEnergyPrice(0.072, -30)), 0},
+		{"break-even at no hashprice", float64(BreakEvenEnergyPrice(0, 30)), 0},
+		{"profit", float64(Profit(120, 100)), 20},
+		{"loss", float64(Profit(80, 100)), -20},
+		{"sum", float64(Sum(1, 2, 3.5)), 6.5},
+		{"sum of nothing", float64(Sum()), 0},
+		{"sum with a negative", float64(Sum(10, -4)), 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !near(tt.got, tt.want) {
+				t.Errorf("got %v, want %v", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBreakEvenCoversEnergyCost(t *testing.T) {
+	hashprice := Hashprice(80e12, 3.125, 60000)
+	efficiency := Efficiency(21.5)
+	price := BreakEvenEnergyPrice(hashprice, efficiency)
+	cost := EnergyCost(PowerFor(TerahashPerSecond, efficiency), 24*time.Hour, price)
+	if !near(float64(cost), float64(hashprice)) {
+		t.Errorf("energy cost at break-even = %v, want hashprice %v", cost, hashprice)
+	}
+}
+
+func TestHorizonValid(t *testing.T) {
+	tests := []struct {
+		horizon Horizon
+		want    bool
+	}{
+		{PerBlock, true},
+		{PerHour, true},
+		{PerDay, true},
+		{PerMWh, true},
+		{PerTHDay, true},
+		{"", false},
+		{"WEEK", false},
+		{"day", false},
+	}
+	for _, tt := range tests {
+		if got := tt.horizon.Valid(); got != tt.want {
+			t.Errorf("%q.Valid() = %v, want %v", tt.horizon, got, tt.want)
+		}
+	}
+}
+
+func TestHorizonScale(t *testing.T) {
+	block := Basis{Period: BlockInterval, Energy: MegawattHour / 6, Hashrate: 1000 * TerahashPerSecond}
+	hour := Basis{Period: time.Hour, Energy: 2 * MegawattHour, Hashrate: 500 * TerahashPerSecond}
+
+	tests := []struct {
+		name    string
+		horizon Horizon
+		basis   Basis
+		want    float64
+		wantErr bool
+	}{
+		{"block on block", PerBlock, block, 1, false},
+		{"block on hour", PerHour, block, 6, false},
+		{"block on day", PerDay, block, 144, false},
+		{"block on MWh", PerMWh, block, 6, false},
+		{"block on TH/s-day", PerTHDay, block, 0.144, false},
+		{"hour on block", PerBlock, hour, 1.0 / 6, false},
+		{"hour on hour", PerHour, hour, 1, false},
+		{"hour on day", PerDay, hour, 24, false},
+		{"hour on MWh", PerMWh, hour, 0.5, false},
+		{"hour on TH/s-day", PerTHDay, hour, 0.048, false},
+		{"no period", PerHour, Basis{Energy: MegawattHour, Hashrate: TerahashPerSecond}, 0, true},
+		{"negative period", PerDay, Basis{Period: -time.Hour}, 0, true},
+		{"no energy", PerMWh, Basis{Period: time.Hour}, 0, true},
+		{"negative energy", PerMWh, Basis{Period: time.Hour, Energy: -MegawattHour}, 0, true},
+		{"no hashrate", PerTHDay, Basis{Period: time.Hour}, 0, true},
+		{"unknown horizon", "WEEK", hour, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.horizon.Scale(tt.basis)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Scale() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !near(got, tt.want) {
+				t.Errorf("Scale() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHorizonScaleRestatesAmounts(t *testing.T) {
+	// A block's revenue restated per day is a day's revenue
+	difficulty, reward, price := 8e13, Coins(3.125), Amount(60000)
+	hashrate := 1000 * TerahashPerSecond
+	perBlock := Revenue(hashrate, difficulty, reward, price, BlockInterval)
+	perDay := Revenue(hashrate, difficulty, reward, price, 24*time.Hour)
+	scale, err := PerDay.Scale(Basis{Period: BlockInterval, Hashrate: hashrate})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := float64(perBlock) * scale; !near(got, float64(perDay)) {
+		t.Errorf("block revenue per day = %v, want %v", got, perDay)
+	}
+
+	// Restated per TH/s-day it is the hashprice
+	scale, err = PerTHDay.Scale(Basis{Period: BlockInterval, Hashrate: hashrate})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := float64(perBlock)*scale, float64(Hashprice(difficulty, reward, price)); !near(got, want) {
+		t.Errorf("block revenue per TH/s-day = %v, want hashprice %v", got, want)
+	}
+}
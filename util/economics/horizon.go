@@ -0,0 +1,62 @@
+package economics
+
+import (
+	"fmt"
+	"time"
+)
+
+// Horizon is the basis revenue and costs are reported on.
+type Horizon string
+
+const (
+	PerBlock Horizon = "BLOCK"
+	PerHour  Horizon = "HOUR"
+	PerDay   Horizon = "DAY"
+	PerMWh   Horizon = "MWH"
+	// PerTHDay is per TH/s of hashrate per day, the basis of hashprice.
+	PerTHDay Horizon = "TH_DAY"
+)
+
+// Valid reports whether h is a known horizon.
+func (h Horizon) Valid() bool {
+	switch h {
+	case PerBlock, PerHour, PerDay, PerMWh, PerTHDay:
+		return true
+	}
+	return false
+}
+
+// Basis is what figures accrued over: the length of the period and the
+// energy used and hashrate deployed during it.
+type Basis struct {
+	Period   time.Duration
+	Energy   Energy
+	Hashrate Hashrate
+}
+
+// Scale returns the factor that restates an amount accrued over basis on
+// horizon h. It fails when the basis has nothing to divide by.
+func (h Horizon) Scale(basis Basis) (float64, error) {
+	if basis.Period <= 0 {
+		return 0, fmt.Errorf("horizon %s: no period", h)
+	}
+	switch h {
+	case PerBlock:
+		return BlockInterval.Seconds() / basis.Period.Seconds(), nil
+	case PerHour:
+		return time.Hour.Seconds() / basis.Period.Seconds(), nil
+	case PerDay:
+		return (24 * time.Hour).Seconds() / basis.Period.Seconds(), nil
+	case PerMWh:
+		if basis.Energy <= 0 {
+			return 0, fmt.Errorf("horizon %s: no energy used", h)
+		}
+		return 1 / basis.Energy.MWh(), nil
+	case PerTHDay:
+		if basis.Hashrate <= 0 {
+			return 0, fmt.Errorf("horizon %s: no hashrate", h)
+		}
+		return (24 * time.Hour).Seconds() / basis.Period.Seconds() / basis.Hashrate.TH(), nil
+	}
+	return 0, fmt.Errorf("unknown horizon %q", h)
+}
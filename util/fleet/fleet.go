@@ -0,0 +1,119 @@
+package fleet
+
+import (
+	common "profitmax/util/common"
+	decision "profitmax/util/decision"
+	economics "profitmax/util/economics"
+)
+
+// Config is the part of a service config describing the mining sites it
+// manages.
+type Config struct {
+	common.Config
+	Sites []Site `json:"sites"`
+}
+
+// Site is a mining site in one energy market region.
+type Site struct {
+	LocationID string         `json:"location_id"`
+	Currency   string         `json:"currency"`
+	Fleet      []MachineGroup `json:"fleet"`
+	Devices    []Device       `json:"devices"`
+}
+
+// Device is a single miner reachable over the CGMiner API.
+type Device struct {
+	ID      string `json:"id"`
+	Model   string `json:"model"`
+	Address string `json:"address"`
+}
+
+// MachineGroup is a number of identical miners at a site.
+type MachineGroup struct {
+	Model      string  `json:"model"`
+	Count      int     `json:"count"`
+	HashrateTH float64 `json:"hashrate_th"`
+	PowerW     float64 `json:"power_w"`
+}
+
+// Group returns the site's machine group of model.
+func (s Site) Group(model string) (MachineGroup, bool) {
+	for _, group := range s.Fleet {
+		if group.Model == model {
+			return group, true
+		}
+	}
+	return MachineGroup{}, false
+}
+
+// SiteList returns the configured sites, falling back to the single
+// location_id/currency pair for configs written before multi-site support.
+func (c Config) SiteList() []Site {
+	if len(c.Sites) > 0 {
+		return c.Sites
+	}
+	if c.LocationID == "" {
+		return nil
+	}
+	return []Site{{LocationID: c.LocationID, Currency: c.Currency}}
+}
+
+// Site returns the configured site for locationID.
+func (c Config) Site(locationID string) (Site, bool) {
+	for _, site := range c.SiteList() {
+		if site.LocationID == locationID {
+			return site, true
+		}
+	}
+	return Site{}, false
+}
+
+// Hashrate returns the combined hashrate of the group.
+func (g MachineGroup) Hashrate() economics.Hashrate {
+	return economics.Hashrate(float64(g.Count)*g.HashrateTH) * economics.TerahashPerSecond
+}
+
+// Power returns the combined power draw of the group.
+func (g MachineGroup) Power() economics.Power {
+	return economics.Power(float64(g.Count)*g.PowerW) * economics.Watt
+}
+
+// Efficiency returns the efficiency of one machine in the group.
+func (g MachineGroup) Efficiency() economics.Efficiency {
+	if g.HashrateTH <= 0 {
+		return 0
+	}
+	return economics.Efficiency(g.PowerW / g.HashrateTH)
+}
+
+// Hashrate returns the combined hashrate of the site's fleet.
+func (s Site) Hashrate() economics.Hashrate {
+	var total economics.Hashrate
+	for _, group := range s.Fleet {
+		total += group.Hashrate()
+	}
+	return total
+}
+
+// Power returns the combined power draw of the site's fleet.
+func (s Site) Power() economics.Power {
+	var total economics.Power
+	for _, group := range s.Fleet {
+		total += group.Power()
+	}
+	return total
+}
+
+// Classes describes the site's fleet for merit-order curtailment.
+func (s Site) Classes() []decision.MachineClass {
+	classes := make([]decision.MachineClass, 0, len(s.Fleet))
+	for _, group := range s.Fleet {
+		classes = append(classes, decision.MachineClass{
+			Model:    group.Model,
+			Units:    group.Count,
+			Hashrate: economics.Hashrate(group.HashrateTH) * economics.TerahashPerSecond,
+			Power:    economics.Power(group.PowerW) * economics.Watt,
+		})
+	}
+	return classes
+}
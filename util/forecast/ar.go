@@ -0,0 +1,88 @@
+package forecast
+
+import (
+	"fmt"
+	"math"
+)
+
+// autoregressive is an AR(order) model about the series mean, fitted by
+// Yule-Walker.
+type autoregressive struct {
+	order  int
+	mu     float64
+	phi    []float64
+	recent []float64
+	sd     float64
+}
+
+func (m *autoregressive) Name() string { return AR }
+
+func (m *autoregressive) Fit(values []float64) error {
+	if len(values) <= 2*m.order {
+		return fmt.Errorf("need more than %d values", 2*m.order)
+	}
+
+	m.mu = mean(values)
+	n := len(values)
+	acov := make([]float64, m.order+1)
+	for lag := range acov {
+		for t := lag; t < n; t++ {
+			acov[lag] += (values[t] - m.mu) * (values[t-lag] - m.mu)
+		}
+		acov[lag] /= float64(n)
+	}
+	if acov[0] == 0 {
+		return fmt.Errorf("series is constant")
+	}
+
+	// Levinson-Durbin recursion
+	phi := make([]float64, m.order)
+	variance := acov[0]
+	for k := 0; k < m.order; k++ {
+		reflection := acov[k+1]
+		for j := 0; j < k; j++ {
+			reflection -= phi[j] * acov[k-j]
+		}
+		reflection /= variance
+
+		previous := append([]float64(nil), phi[:k]...)
+		phi[k] = reflection
+		for j := 0; j < k; j++ {
+			phi[j] = previous[j] - reflection*previous[k-1-j]
+		}
+		variance *= 1 - reflection*reflection
+	}
+	m.phi = phi
+	m.sd = math.Sqrt(variance)
+	m.recent = append([]float64(nil), values[n-m.order:]...)
+	return nil
+}
+
+func (m *autoregressive) Predict(steps int) ([]float64, []float64) {
+	history := append([]float64(nil), m.recent...)
+	means := make([]float64, steps)
+	sds := make([]float64, steps)
+
+	// psi holds the weights of past shocks in each step's error
+	psi := make([]float64, steps)
+	var variance float64
+	for h := range means {
+		next := m.mu
+		for i, p := range m.phi {
+			next += p * (history[len(history)-1-i] - m.mu)
+		}
+		history = append(history, next)
+		means[h] = next
+
+		psi[h] = 1
+		if h > 0 {
+			psi[h] = 0
+			for i := 1; i <= h && i <= m.order; i++ {
+				psi[h] += m.phi[i-1] * psi[h-i]
+			}
+		}
+		variance += psi[h] * psi[h]
+		sds[h] = m.sd * math.Sqrt(variance)
+	}
+	return means, sds
+}
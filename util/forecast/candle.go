@@ -0,0 +1,70 @@
+package forecast
+
+import (
+	"database/sql"
+	"time"
+
+	timeseries "profitmax/util/timeseries"
+)
+
+// Tick is one observed price.
+type Tick struct {
+	Time     time.Time
+	Price    float64
+	Currency string
+}
+
+// Candle summarises the ticks of one interval.
+type Candle = timeseries.Bar
+
+// Series returns the prices of ticks as a time series.
+func Series(ticks []Tick) timeseries.Series {
+	series := make(timeseries.Series, len(ticks))
+	for i, tick := range ticks {
+		series[i] = timeseries.Point{Time: tick.Time, Value: tick.Price}
+	}
+	return series
+}
+
+// Candles buckets ticks, oldest first, into candles step long. An interval
+// without ticks repeats the previous close so the candles stay one step
+// apart. It fails when step is not positive.
+func Candles(ticks []Tick, step time.Duration) ([]Candle, error) {
+	return timeseries.OHLC(Series(ticks), step)
+}
+
+// Closes returns the close of every candle.
+func Closes(candles []Candle) []float64 {
+	return timeseries.Closes(candles)
+}
+
+// LoadTicks reads the ticks of a series since from, oldest first: energy
+// prices of a location from tbl_energy_price_tick or crypto prices of a
+// symbol from tbl_crypto_price_tick.
+func LoadTicks(db *sql.DB, series string, key string, from time.Time) ([]Tick, error) {
+	query := "SELECT timestamp, price, currency_code FROM tbl_crypto_price_tick WHERE symbol=? AND timestamp >= ? ORDER BY timestamp, id"
+	if series == EnergyPrice {
+		query = "SELECT timestamp, price, currency_code FROM tbl_energy_price_tick WHERE location_id=? AND timestamp >= ? ORDER BY timestamp, id"
+	}
+	rows, err := db.Query(query, key, from)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ticks []Tick
+	for rows.Next() {
+		var tick Tick
+		var timestamp string
+		err = rows.Scan(&timestamp, &tick.Price, &tick.Currency)
+		if err != nil {
+			return nil, err
+		}
+		tick.Time, err = time.ParseInLocation("2006-01-02 15:04:05", timestamp, time.Local)
+		if err != nil {
+			return nil, err
+		}
+		ticks = append(ticks, tick)
+	}
+	return ticks, rows.Err()
+}
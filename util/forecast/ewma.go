@@ -0,0 +1,40 @@
+package forecast
+
+import (
+	"errors"
+	"math"
+)
+
+// ewma is simple exponential smoothing: a level that moves alpha of the way
+// to each new value, forecast flat.
+type ewma struct {
+	alpha float64
+	level float64
+	sd    float64
+}
+
+func (m *ewma) Name() string { return EWMA }
+
+func (m *ewma) Fit(values []float64) error {
+	if len(values) < 2 {
+		return errors.New("need at least 2 values")
+	}
+	m.level = values[0]
+	var residuals []float64
+	for _, v := range values[1:] {
+		residuals = append(residuals, v-m.level)
+		m.level += m.alpha * (v - m.level)
+	}
+	m.sd = residualSD(residuals)
+	return nil
+}
+
+func (m *ewma) Predict(steps int) ([]float64, []float64) {
+	means := make([]float64, steps)
+	sds := make([]float64, steps)
+	for h := range means {
+		means[h] = m.level
+		sds[h] = m.sd * math.Sqrt(1+float64(h)*m.alpha*m.alpha)
+	}
+	return means, sds
+}
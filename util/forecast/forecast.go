@@ -0,0 +1,56 @@
+package forecast
+
+import "time"
+
+// Series that are forecast.
+const (
+	EnergyPrice = "energy_price"
+	CryptoPrice = "crypto_price"
+)
+
+// Point is a forecast value at a time, with the prediction interval around
+// it.
+type Point struct {
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+	Lower float64   `json:"lower"`
+	Upper float64   `json:"upper"`
+}
+
+// Forecast is one model's forecast of a series. Key is the location of an
+// energy price or the symbol of a crypto price. Level is the coverage of the
+// points' prediction intervals.
+type Forecast struct {
+	Series   string    `json:"series"`
+	Key      string    `json:"key"`
+	Currency string    `json:"currency"`
+	Model    string    `json:"model"`
+	Issued   time.Time `json:"issued"`
+	Level    float64   `json:"level"`
+	Points   []Point   `json:"points"`
+}
+
+// At returns the forecast value at t, interpolated between points. It is
+// false before the forecast was issued or past its last point.
+func (f Forecast) At(t time.Time) (float64, bool) {
+	if len(f.Points) == 0 || t.Before(f.Issued) || t.After(f.Points[len(f.Points)-1].Time) {
+		return 0, false
+	}
+	if !t.After(f.Points[0].Time) {
+		return f.Points[0].Value, true
+	}
+	for i := 1; i < len(f.Points); i++ {
+		next := f.Points[i]
+		if t.After(next.Time) {
+			continue
+		}
+		prev := f.Points[i-1]
+		span := next.Time.Sub(prev.Time).Seconds()
+		if span <= 0 {
+			return next.Value, true
+		}
+		w := t.Sub(prev.Time).Seconds() / span
+		return prev.Value + w*(next.Value-prev.Value), true
+	}
+	return f.Points[len(f.Points)-1].Value, true
+}
@@ -0,0 +1,74 @@
+package forecast
+
+import (
+	"fmt"
+	"math"
+)
+
+// holtWinters is additive Holt-Winters: a level, a trend and a repeating
+// seasonal pattern season steps long, each smoothed.
+type holtWinters struct {
+	alpha    float64
+	beta     float64
+	gamma    float64
+	season   int
+	level    float64
+	trend    float64
+	seasonal []float64
+	n        int
+	sd       float64
+}
+
+func (m *holtWinters) Name() string { return HoltWinters }
+
+func (m *holtWinters) Fit(values []float64) error {
+	if len(values) < 2*m.season {
+		return fmt.Errorf("need at least 2 seasons (%d values)", 2*m.season)
+	}
+
+	// Start from the first two seasons
+	first := mean(values[:m.season])
+	second := mean(values[m.season : 2*m.season])
+	m.level = first
+	m.trend = (second - first) / float64(m.season)
+	m.seasonal = make([]float64, m.season)
+	for i := range m.seasonal {
+		m.seasonal[i] = values[i] - first
+	}
+
+	var residuals []float64
+	for t := m.season; t < len(values); t++ {
+		s := m.seasonal[t%m.season]
+		predicted := m.level + m.trend + s
+		residuals = append(residuals, values[t]-predicted)
+
+		level := m.alpha*(values[t]-s) + (1-m.alpha)*(m.level+m.trend)
+		m.trend = m.beta*(level-m.level) + (1-m.beta)*m.trend
+		m.seasonal[t%m.season] = m.gamma*(values[t]-level) + (1-m.gamma)*s
+		m.level = level
+	}
+	m.n = len(values)
+	m.sd = residualSD(residuals)
+	return nil
+}
+
+func (m *holtWinters) Predict(steps int) ([]float64, []float64) {
+	means := make([]float64, steps)
+	sds := make([]float64, steps)
+	var variance float64
+	for h := range means {
+		means[h] = m.level + float64(h+1)*m.trend + m.seasonal[(m.n+h)%m.season]
+
+		// Error variance grows with the smoothing weights of the steps
+		// already forecast
+		if h > 0 {
+			c := m.alpha * (1 + float64(h)*m.beta)
+			if h%m.season == 0 {
+				c += m.gamma
+			}
+			variance += c * c
+		}
+		sds[h] = m.sd * math.Sqrt(1+variance)
+	}
+	return means, sds
+}
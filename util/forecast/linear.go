@@ -0,0 +1,62 @@
+package forecast
+
+import (
+	"errors"
+	"math"
+)
+
+// linear is a least-squares trend line through the last window values, or
+// all of them when window is 0.
+type linear struct {
+	window    int
+	intercept float64
+	slope     float64
+	n         int
+	meanX     float64
+	sxx       float64
+	sd        float64
+}
+
+func (m *linear) Name() string { return Linear }
+
+func (m *linear) Fit(values []float64) error {
+	if m.window > 0 && len(values) > m.window {
+		values = values[len(values)-m.window:]
+	}
+	if len(values) < 3 {
+		return errors.New("need at least 3 values")
+	}
+
+	m.n = len(values)
+	m.meanX = float64(m.n-1) / 2
+	meanY := mean(values)
+	var sxy float64
+	m.sxx = 0
+	for i, v := range values {
+		dx := float64(i) - m.meanX
+		sxy += dx * (v - meanY)
+		m.sxx += dx * dx
+	}
+	m.slope = sxy / m.sxx
+	m.intercept = meanY - m.slope*m.meanX
+
+	var sum float64
+	for i, v := range values {
+		e := v - (m.intercept + m.slope*float64(i))
+		sum += e * e
+	}
+	m.sd = math.Sqrt(sum / float64(m.n-2))
+	return nil
+}
+
+func (m *linear) Predict(steps int) ([]float64, []float64) {
+	means := make([]float64, steps)
+	sds := make([]float64, steps)
+	for h := range means {
+		x := float64(m.n + h)
+		means[h] = m.intercept + m.slope*x
+		dx := x - m.meanX
+		sds[h] = m.sd * math.Sqrt(1+1/float64(m.n)+dx*dx/m.sxx)
+	}
+	return means, sds
+}
@@ -0,0 +1,143 @@
+package forecast
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Names of the models New builds.
+const (
+	EWMA        = "EWMA"
+	Linear      = "LINEAR"
+	HoltWinters = "HOLT_WINTERS"
+	AR          = "AR"
+)
+
+// Model forecasts a series from its history.
+type Model interface {
+	// Name identifies the model in forecasts.
+	Name() string
+	// Fit trains the model on values one step apart, oldest first.
+	Fit(values []float64) error
+	// Predict returns the next steps values and the standard deviation of
+	// the error of each.
+	Predict(steps int) (means []float64, sds []float64)
+}
+
+// ModelConfig configures one model. Only the fields a model uses are read.
+type ModelConfig struct {
+	Name   string  `json:"name"`
+	Alpha  float64 `json:"alpha"`
+	Beta   float64 `json:"beta"`
+	Gamma  float64 `json:"gamma"`
+	Season int     `json:"season"`
+	Window int     `json:"window"`
+	Order  int     `json:"order"`
+}
+
+// Config configures the forecasting service: candles of CandleSeconds over
+// the last LookbackHours train each model, which forecasts HorizonSteps
+// candles ahead with prediction intervals of coverage Level. The scorer ranks
+// models by Metric over forecasts that came due in the last ScoreWindowHours.
+type Config struct {
+	CandleSeconds int           `json:"candle_seconds"`
+	LookbackHours int           `json:"lookback_hours"`
+	HorizonSteps  int           `json:"horizon_steps"`
+	Level         float64       `json:"level"`
+	Models        []ModelConfig `json:"models"`
+
+	ScoreWindowHours int    `json:"score_window_hours"`
+	Metric           string `json:"metric"`
+}
+
+// Step is the length of one candle.
+func (c Config) Step() time.Duration {
+	return time.Duration(c.CandleSeconds) * time.Second
+}
+
+// Lookback is how far back training data reaches.
+func (c Config) Lookback() time.Duration {
+	return time.Duration(c.LookbackHours) * time.Hour
+}
+
+// ScoreWindow is how far back the scorer reaches.
+func (c Config) ScoreWindow() time.Duration {
+	return time.Duration(c.ScoreWindowHours) * time.Hour
+}
+
+// New builds the model config describes.
+func New(config ModelConfig) (Model, error) {
+	switch config.Name {
+	case EWMA:
+		if config.Alpha <= 0 || config.Alpha > 1 {
+			return nil, fmt.Errorf("%s: alpha must be in (0, 1]", config.Name)
+		}
+		return &ewma{alpha: config.Alpha}, nil
+	case Linear:
+		return &linear{window: config.Window}, nil
+	case HoltWinters:
+		if config.Season < 2 {
+			return nil, fmt.Errorf("%s: season must be at least 2", config.Name)
+		}
+		return &holtWinters{alpha: config.Alpha, beta: config.Beta, gamma: config.Gamma, season: config.Season}, nil
+	case AR:
+		if config.Order < 1 {
+			return nil, fmt.Errorf("%s: order must be at least 1", config.Name)
+		}
+		return &autoregressive{order: config.Order}, nil
+	}
+	return nil, fmt.Errorf("unknown model %q", config.Name)
+}
+
+// ValidLevel reports whether level is a coverage prediction intervals can
+// have: strictly between 0 and 1.
+func ValidLevel(level float64) bool {
+	return level > 0 && level < 1
+}
+
+// Run fits model to values and forecasts the steps after the last of them,
+// which started at last and are step apart. It fails when level is not a
+// valid coverage.
+func Run(model Model, values []float64, last time.Time, step time.Duration, steps int, level float64) (Forecast, error) {
+	if !ValidLevel(level) {
+		return Forecast{}, fmt.Errorf("level %v must be between 0 and 1", level)
+	}
+	err := model.Fit(values)
+	if err != nil {
+		return Forecast{}, fmt.Errorf("%s: %w", model.Name(), err)
+	}
+
+	z := math.Sqrt2 * math.Erfinv(level)
+	means, sds := model.Predict(steps)
+	f := Forecast{Model: model.Name(), Level: level}
+	for i := range means {
+		f.Points = append(f.Points, Point{
+			Time:  last.Add(time.Duration(i+1) * step),
+			Value: means[i],
+			Lower: means[i] - z*sds[i],
+			Upper: means[i] + z*sds[i],
+		})
+	}
+	return f, nil
+}
+
+// residualSD returns the standard deviation of errors.
+func residualSD(errors []float64) float64 {
+	if len(errors) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, e := range errors {
+		sum += e * e
+	}
+	return math.Sqrt(sum / float64(len(errors)))
+}
+
+func mean(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
@@ -0,0 +1,211 @@
+package forecast
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func near(got, want float64) bool {
+	if want == 0 {
+		return math.Abs(got) < 1e-9
+	}
+	return math.Abs(got-want) <= 1e-9*math.Abs(want)
+}
+
+func checkPrediction(t *testing.T, model Model, steps int, wantMeans []float64, wantSDs []float64) {
+	t.Helper()
+	means, sds := model.Predict(steps)
+	if len(means) != steps || len(sds) != steps {
+		t.Fatalf("Predict(%d) returned %d means and %d sds", steps, len(means), len(sds))
+	}
+	for h := range wantMeans {
+		if !near(means[h], wantMeans[h]) {
+			t.Errorf("mean %d = %v, want %v", h, means[h], wantMeans[h])
+		}
+		if !near(sds[h], wantSDs[h]) {
+			t.Errorf("sd %d = %v, want %v", h, sds[h], wantSDs[h])
+		}
+	}
+}
+
+func fit(t *testing.T, config ModelConfig, values []float64) Model {
+	t.Helper()
+	model, err := New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := model.Fit(values); err != nil {
+		t.Fatal(err)
+	}
+	return model
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  ModelConfig
+		wantErr bool
+	}{
+		{"ewma", ModelConfig{Name: EWMA, Alpha: 0.3}, false},
+		{"ewma at alpha 1", ModelConfig{Name: EWMA, Alpha: 1}, false},
+		{"ewma without alpha", ModelConfig{Name: EWMA}, true},
+		{"ewma above alpha 1", ModelConfig{Name: EWMA, Alpha: 1.1}, true},
+		{"linear", ModelConfig{Name: Linear}, false},
+		{"holt-winters", ModelConfig{Name: HoltWinters, Alpha: 0.3, Beta: 0.1, Gamma: 0.1, Season: 24}, false},
+		{"holt-winters without a season", ModelConfig{Name: HoltWinters, Season: 1}, true},
+		{"ar", ModelConfig{Name: AR, Order: 2}, false},
+		{"ar without an order", ModelConfig{Name: AR}, true},
+		{"unknown", ModelConfig{Name: "RANDOM_FOREST"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model, err := New(tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && model.Name() != tt.config.Name {
+				t.Errorf("Name() = %s, want %s", model.Name(), tt.config.Name)
+			}
+		})
+	}
+}
+
+func TestEWMA(t *testing.T) {
+	// The level starts at 10 and moves halfway to 20, missing it by 10
+	model := fit(t, ModelConfig{Name: EWMA, Alpha: 0.5}, []float64{10, 20})
+	checkPrediction(t, model, 3, []float64{15, 15, 15}, []float64{10, 10 * math.Sqrt(1.25), 10 * math.Sqrt(1.5)})
+
+	// A full step tracks the last value exactly
+	model = fit(t, ModelConfig{Name: EWMA, Alpha: 1}, []float64{10, 20, 30, 40})
+	checkPrediction(t, model, 1, []float64{40}, []float64{10})
+
+	if err := (&ewma{alpha: 0.5}).Fit([]float64{10}); err == nil {
+		t.Error("Fit() of one value succeeded")
+	}
+}
+
+func TestLinear(t *testing.T) {
+	model := fit(t, ModelConfig{Name: Linear}, []float64{1, 3, 5, 7})
+	checkPrediction(t, model, 2, []float64{9, 11}, []float64{0, 0})
+
+	// Only the window is fitted, so the outlier before it is ignored
+	model = fit(t, ModelConfig{Name: Linear, Window: 3}, []float64{100, 1, 3, 5})
+	checkPrediction(t, model, 1, []float64{7}, []float64{0})
+
+	// Residuals of +-1 about a flat line: sd is sqrt(4/2) and widens with
+	// distance from the middle of the fit
+	model = fit(t, ModelConfig{Name: Linear}, []float64{1, -1, -1, 1})
+	sd := math.Sqrt(2)
+	checkPrediction(t, model, 2, []float64{0, 0},
+		[]float64{sd * math.Sqrt(1+0.25+2.5*2.5/5), sd * math.Sqrt(1+0.25+3.5*3.5/5)})
+
+	if err := (&linear{}).Fit([]float64{1, 2}); err == nil {
+		t.Error("Fit() of two values succeeded")
+	}
+}
+
+func TestHoltWinters(t *testing.T) {
+	// A pure repeating pattern is forecast exactly at any smoothing
+	values := []float64{1, 3, 1, 3, 1, 3}
+	model := fit(t, ModelConfig{Name: HoltWinters, Alpha: 0.5, Beta: 0.1, Gamma: 0.1, Season: 2}, values)
+	checkPrediction(t, model, 4, []float64{1, 3, 1, 3}, []float64{0, 0, 0, 0})
+
+	// Without smoothing the first season fixes the pattern at +0.5 and -0.5
+	// and the level rises by the initial trend of 1 a step, missing the
+	// third value by 1
+	model = fit(t, ModelConfig{Name: HoltWinters, Season: 2}, []float64{1, 0, 3, 2})
+	sd := math.Sqrt(0.5)
+	checkPrediction(t, model, 2, []float64{4, 4}, []float64{sd, sd})
+
+	if err := (&holtWinters{season: 4}).Fit(values); err == nil {
+		t.Error("Fit() of less than two seasons succeeded")
+	}
+}
+
+// autocovariance returns the autocovariance of values at lag.
+func autocovariance(values []float64, lag int) float64 {
+	mu := mean(values)
+	var sum float64
+	for t := lag; t < len(values); t++ {
+		sum += (values[t] - mu) * (values[t-lag] - mu)
+	}
+	return sum / float64(len(values))
+}
+
+func TestAR(t *testing.T) {
+	values := []float64{3, 1, 4, 1, 5, 9, 2, 6, 5, 3, 5, 8, 9, 7, 9, 3}
+	mu := mean(values)
+	c0, c1, c2 := autocovariance(values, 0), autocovariance(values, 1), autocovariance(values, 2)
+	r1, r2 := c1/c0, c2/c0
+	last, previous := values[len(values)-1], values[len(values)-2]
+
+	t.Run("order 1", func(t *testing.T) {
+		// Yule-Walker: phi is the lag 1 autocorrelation
+		model := fit(t, ModelConfig{Name: AR, Order: 1}, values)
+		sd := math.Sqrt(c0 * (1 - r1*r1))
+		next := mu + r1*(last-mu)
+		checkPrediction(t, model, 2, []float64{next, mu + r1*(next-mu)}, []float64{sd, sd * math.Sqrt(1+r1*r1)})
+	})
+
+	t.Run("order 2", func(t *testing.T) {
+		// Yule-Walker solved directly for two lags
+		phi1 := r1 * (1 - r2) / (1 - r1*r1)
+		phi2 := (r2 - r1*r1) / (1 - r1*r1)
+		model := fit(t, ModelConfig{Name: AR, Order: 2}, values)
+		ar := model.(*autoregressive)
+		if !near(ar.phi[0], phi1) || !near(ar.phi[1], phi2) {
+			t.Errorf("phi = %v, want [%v %v]", ar.phi, phi1, phi2)
+		}
+		sd := math.Sqrt(c0 * (1 - phi1*r1 - phi2*r2))
+		next := mu + phi1*(last-mu) + phi2*(previous-mu)
+		checkPrediction(t, model, 2, []float64{next, mu + phi1*(next-mu) + phi2*(last-mu)}, []float64{sd, sd * math.Sqrt(1+phi1*phi1)})
+	})
+
+	t.Run("alternating", func(t *testing.T) {
+		model := fit(t, ModelConfig{Name: AR, Order: 1}, []float64{1, -1, 1, -1, 1, -1})
+		if phi := model.(*autoregressive).phi[0]; !near(phi, -5.0/6) {
+			t.Errorf("phi = %v, want -5/6", phi)
+		}
+	})
+
+	if err := (&autoregressive{order: 2}).Fit([]float64{1, 2, 3, 4}); err == nil {
+		t.Error("Fit() of 2 x order values succeeded")
+	}
+	if err := (&autoregressive{order: 1}).Fit([]float64{2, 2, 2, 2}); err == nil {
+		t.Error("Fit() of a constant series succeeded")
+	}
+}
+
+func TestRun(t *testing.T) {
+	last := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	model, _ := New(ModelConfig{Name: EWMA, Alpha: 0.5})
+
+	f, err := Run(model, []float64{10, 20}, last, 5*time.Minute, 2, 0.95)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Model != EWMA || f.Level != 0.95 || len(f.Points) != 2 {
+		t.Fatalf("Run() = %+v", f)
+	}
+	// 95% of a normal error lies within 1.96 sds
+	z := 1.959963984540054
+	wantSDs := []float64{10, 10 * math.Sqrt(1.25)}
+	for i, p := range f.Points {
+		if !p.Time.Equal(last.Add(time.Duration(i+1) * 5 * time.Minute)) {
+			t.Errorf("point %d at %v", i, p.Time)
+		}
+		if !near(p.Value, 15) || !near(p.Lower, 15-z*wantSDs[i]) || !near(p.Upper, 15+z*wantSDs[i]) {
+			t.Errorf("point %d = %+v", i, p)
+		}
+	}
+
+	for _, level := range []float64{0, 1, -0.5, 95} {
+		if _, err := Run(model, []float64{10, 20}, last, 5*time.Minute, 2, level); err == nil {
+			t.Errorf("Run() at level %v succeeded", level)
+		}
+	}
+	if _, err := Run(model, []float64{10}, last, 5*time.Minute, 2, 0.95); err == nil {
+		t.Error("Run() of too few values succeeded")
+	}
+}
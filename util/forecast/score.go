@@ -0,0 +1,197 @@
+package forecast
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Metrics models are ranked by. Lower is better for all of them.
+const (
+	MAE     = "MAE"
+	MAPE    = "MAPE"
+	RMSE    = "RMSE"
+	Pinball = "PINBALL"
+)
+
+// Best as the trusted model means whichever model leads the leaderboard.
+const Best = "BEST"
+
+// Outcome is a forecast point beside the price that came true.
+type Outcome struct {
+	Model          string
+	HorizonMinutes int
+	Point          Point
+	Level          float64
+	Actual         float64
+}
+
+// Score is the accuracy of one model at one horizon of a series. Pinball is
+// the pinball loss averaged over the median and the two bounds of the
+// prediction interval.
+type Score struct {
+	Series         string    `json:"series"`
+	Key            string    `json:"key"`
+	Model          string    `json:"model"`
+	HorizonMinutes int       `json:"horizon_minutes"`
+	Samples        int       `json:"samples"`
+	MAE            float64   `json:"mae"`
+	MAPE           float64   `json:"mape"`
+	RMSE           float64   `json:"rmse"`
+	Pinball        float64   `json:"pinball"`
+	LastUpdated    time.Time `json:"last_updated"`
+}
+
+// ValidMetric reports whether models can be ranked by metric.
+func ValidMetric(metric string) bool {
+	switch metric {
+	case MAE, MAPE, RMSE, Pinball:
+		return true
+	}
+	return false
+}
+
+// Metric returns the named metric of s, or NaN for an unknown metric.
+func (s Score) Metric(metric string) float64 {
+	switch metric {
+	case MAE:
+		return s.MAE
+	case MAPE:
+		return s.MAPE
+	case RMSE:
+		return s.RMSE
+	case Pinball:
+		return s.Pinball
+	}
+	return math.NaN()
+}
+
+// PinballLoss is the loss of forecasting the given quantile as forecast
+// when actual came true.
+func PinballLoss(actual float64, forecast float64, quantile float64) float64 {
+	if actual >= forecast {
+		return quantile * (actual - forecast)
+	}
+	return (1 - quantile) * (forecast - actual)
+}
+
+// ScoreOutcomes scores the outcomes of a series per model and horizon,
+// ordered by model then horizon. MAPE leaves out outcomes whose actual is 0.
+func ScoreOutcomes(series string, key string, outcomes []Outcome, now time.Time) []Score {
+	type group struct {
+		model   string
+		horizon int
+	}
+	type sums struct {
+		n, nPercent            int
+		abs, percent, sq, loss float64
+	}
+	totals := make(map[group]*sums)
+	for _, o := range outcomes {
+		g := group{o.Model, o.HorizonMinutes}
+		t, ok := totals[g]
+		if !ok {
+			t = &sums{}
+			totals[g] = t
+		}
+		e := o.Actual - o.Point.Value
+		t.n++
+		t.abs += math.Abs(e)
+		t.sq += e * e
+		if o.Actual != 0 {
+			t.nPercent++
+			t.percent += math.Abs(e / o.Actual)
+		}
+		tail := (1 - o.Level) / 2
+		t.loss += (PinballLoss(o.Actual, o.Point.Lower, tail) +
+			PinballLoss(o.Actual, o.Point.Value, 0.5) +
+			PinballLoss(o.Actual, o.Point.Upper, 1-tail)) / 3
+	}
+
+	var scores []Score
+	for g, t := range totals {
+		score := Score{
+			Series:         series,
+			Key:            key,
+			Model:          g.model,
+			HorizonMinutes: g.horizon,
+			Samples:        t.n,
+			MAE:            t.abs / float64(t.n),
+			RMSE:           math.Sqrt(t.sq / float64(t.n)),
+			Pinball:        t.loss / float64(t.n),
+			LastUpdated:    now,
+		}
+		if t.nPercent > 0 {
+			score.MAPE = t.percent / float64(t.nPercent)
+		}
+		scores = append(scores, score)
+	}
+	sort.Slice(scores, func(i, j int) bool {
+		if scores[i].Model != scores[j].Model {
+			return scores[i].Model < scores[j].Model
+		}
+		return scores[i].HorizonMinutes < scores[j].HorizonMinutes
+	})
+	return scores
+}
+
+// Rank is a model's standing on the leaderboard: its metric averaged over
+// every horizon, weighted by samples.
+type Rank struct {
+	Model   string  `json:"model"`
+	Value   float64 `json:"value"`
+	Samples int     `json:"samples"`
+}
+
+// Leaderboard ranks the models of a series, best first.
+type Leaderboard struct {
+	Series    string    `json:"series"`
+	Key       string    `json:"key"`
+	Metric    string    `json:"metric"`
+	Ranking   []Rank    `json:"ranking"`
+	Scores    []Score   `json:"scores"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// NewLeaderboard ranks the models in scores by metric, which must be valid.
+func NewLeaderboard(series string, key string, metric string, scores []Score, now time.Time) Leaderboard {
+	byModel := make(map[string]*Rank)
+	var ranking []Rank
+	for _, s := range scores {
+		r, ok := byModel[s.Model]
+		if !ok {
+			r = &Rank{Model: s.Model}
+			byModel[s.Model] = r
+		}
+		r.Value += s.Metric(metric) * float64(s.Samples)
+		r.Samples += s.Samples
+	}
+	for _, r := range byModel {
+		if r.Samples > 0 {
+			r.Value /= float64(r.Samples)
+		}
+		ranking = append(ranking, *r)
+	}
+	sort.Slice(ranking, func(i, j int) bool {
+		if ranking[i].Value != ranking[j].Value {
+			return ranking[i].Value < ranking[j].Value
+		}
+		return ranking[i].Model < ranking[j].Model
+	})
+	return Leaderboard{
+		Series:    series,
+		Key:       key,
+		Metric:    metric,
+		Ranking:   ranking,
+		Scores:    scores,
+		Timestamp: now,
+	}
+}
+
+// Leader is the best model on the leaderboard, or "" when none is scored.
+func (l Leaderboard) Leader() string {
+	if len(l.Ranking) == 0 {
+		return ""
+	}
+	return l.Ranking[0].Model
+}
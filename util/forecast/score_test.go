@@ -0,0 +1,132 @@
+package forecast
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestPinballLoss(t *testing.T) {
+	tests := []struct {
+		name     string
+		actual   float64
+		forecast float64
+		quantile float64
+		want     float64
+	}{
+		{"median under", 100, 90, 0.5, 5},
+		{"median over", 90, 100, 0.5, 5},
+		{"exact", 100, 100, 0.9, 0},
+		{"upper bound under", 100, 90, 0.9, 9},
+		{"upper bound over", 90, 100, 0.9, 1},
+		{"lower bound under", 100, 90, 0.1, 1},
+		{"lower bound over", 90, 100, 0.1, 9},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PinballLoss(tt.actual, tt.forecast, tt.quantile); !near(got, tt.want) {
+				t.Errorf("PinballLoss() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScoreOutcomes(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	outcomes := []Outcome{
+		{Model: EWMA, HorizonMinutes: 5, Point: Point{Value: 90, Lower: 80, Upper: 110}, Level: 0.8, Actual: 100},
+		{Model: EWMA, HorizonMinutes: 5, Point: Point{Value: 4, Lower: 2, Upper: 6}, Level: 0.8, Actual: 0},
+		{Model: EWMA, HorizonMinutes: 60, Point: Point{Value: 110, Lower: 100, Upper: 120}, Level: 0.8, Actual: 100},
+		{Model: AR, HorizonMinutes: 5, Point: Point{Value: 100, Lower: 90, Upper: 110}, Level: 0.8, Actual: 100},
+	}
+	scores := ScoreOutcomes(EnergyPrice, "QLD1", outcomes, now)
+
+	want := []Score{
+		{Model: AR, HorizonMinutes: 5, Samples: 1, MAE: 0, MAPE: 0, RMSE: 0, Pinball: (1 + 0 + 1) / 3.0},
+		// Errors of 10 and -4: MAPE leaves out the actual of 0, and the
+		// pinball losses of the two outcomes are (2+5+1)/3 and (1.8+2+0.6)/3
+		{Model: EWMA, HorizonMinutes: 5, Samples: 2, MAE: 7, MAPE: 0.1, RMSE: math.Sqrt(58), Pinball: (8.0/3 + 4.4/3) / 2},
+		{Model: EWMA, HorizonMinutes: 60, Samples: 1, MAE: 10, MAPE: 0.1, RMSE: 10, Pinball: (0 + 5 + 2) / 3.0},
+	}
+	if len(scores) != len(want) {
+		t.Fatalf("ScoreOutcomes() = %+v, want %d scores", scores, len(want))
+	}
+	for i, s := range scores {
+		w := want[i]
+		if s.Series != EnergyPrice || s.Key != "QLD1" || !s.LastUpdated.Equal(now) {
+			t.Errorf("score %d = %+v", i, s)
+		}
+		if s.Model != w.Model || s.HorizonMinutes != w.HorizonMinutes || s.Samples != w.Samples {
+			t.Fatalf("score %d is %s at %d minutes over %d samples, want %s at %d over %d",
+				i, s.Model, s.HorizonMinutes, s.Samples, w.Model, w.HorizonMinutes, w.Samples)
+		}
+		if !near(s.MAE, w.MAE) || !near(s.MAPE, w.MAPE) || !near(s.RMSE, w.RMSE) || !near(s.Pinball, w.Pinball) {
+			t.Errorf("score %d = %+v, want %+v", i, s, w)
+		}
+	}
+}
+
+func TestMetric(t *testing.T) {
+	s := Score{MAE: 1, MAPE: 2, RMSE: 3, Pinball: 4}
+	for metric, want := range map[string]float64{MAE: 1, MAPE: 2, RMSE: 3, Pinball: 4} {
+		if !ValidMetric(metric) {
+			t.Errorf("ValidMetric(%s) = false", metric)
+		}
+		if got := s.Metric(metric); got != want {
+			t.Errorf("Metric(%s) = %v, want %v", metric, got, want)
+		}
+	}
+	for _, metric := range []string{"", "mae", "R2"} {
+		if ValidMetric(metric) {
+			t.Errorf("ValidMetric(%q) = true", metric)
+		}
+		if got := s.Metric(metric); !math.IsNaN(got) {
+			t.Errorf("Metric(%q) = %v, want NaN", metric, got)
+		}
+	}
+}
+
+func TestLeaderboard(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	scores := []Score{
+		{Model: EWMA, HorizonMinutes: 5, Samples: 1, MAE: 2, RMSE: 2},
+		{Model: EWMA, HorizonMinutes: 60, Samples: 3, MAE: 5, RMSE: 9},
+		{Model: Linear, HorizonMinutes: 5, Samples: 4, MAE: 4, RMSE: 4},
+		{Model: AR, HorizonMinutes: 5, Samples: 2, MAE: 4, RMSE: 6},
+		{Model: AR, HorizonMinutes: 60, Samples: 0, MAE: 100, RMSE: 100},
+	}
+
+	tests := []struct {
+		metric string
+		want   []Rank
+	}{
+		// EWMA weighs its horizons by samples: (2 + 3*5) / 4. AR and LINEAR
+		// tie and are ordered by name
+		{MAE, []Rank{{AR, 4, 2}, {Linear, 4, 4}, {EWMA, 4.25, 4}}},
+		{RMSE, []Rank{{Linear, 4, 4}, {AR, 6, 2}, {EWMA, 7.25, 4}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.metric, func(t *testing.T) {
+			board := NewLeaderboard(EnergyPrice, "QLD1", tt.metric, scores, now)
+			if board.Metric != tt.metric || len(board.Scores) != len(scores) || !board.Timestamp.Equal(now) {
+				t.Errorf("NewLeaderboard() = %+v", board)
+			}
+			if len(board.Ranking) != len(tt.want) {
+				t.Fatalf("ranking = %+v, want %+v", board.Ranking, tt.want)
+			}
+			for i, r := range board.Ranking {
+				if r.Model != tt.want[i].Model || !near(r.Value, tt.want[i].Value) || r.Samples != tt.want[i].Samples {
+					t.Errorf("ranking = %+v, want %+v", board.Ranking, tt.want)
+					break
+				}
+			}
+			if board.Leader() != tt.want[0].Model {
+				t.Errorf("Leader() = %s, want %s", board.Leader(), tt.want[0].Model)
+			}
+		})
+	}
+
+	if leader := NewLeaderboard(EnergyPrice, "QLD1", MAE, nil, now).Leader(); leader != "" {
+		t.Errorf("Leader() of no scores = %q", leader)
+	}
+}
@@ -0,0 +1,117 @@
+package forecast
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Save stores every point of f in tbl_price_forecast.
+func Save(db *sql.DB, f Forecast) error {
+	insertData := `INSERT INTO tbl_price_forecast (series, series_key, model, issued, target_time, currency_code, value, lower_bound, upper_bound, level)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE currency_code = VALUES(currency_code), value = VALUES(value), lower_bound = VALUES(lower_bound), upper_bound = VALUES(upper_bound), level = VALUES(level)`
+	for _, point := range f.Points {
+		_, err := db.Exec(insertData, f.Series, f.Key, f.Model, f.Issued, point.Time, f.Currency, point.Value, point.Lower, point.Upper, f.Level)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadOutcomes pairs every forecast point of a series whose target fell
+// between from and to with the close of the candle step long starting at
+// the target. A point's horizon is how far past its issue the target was,
+// rounded up to whole steps.
+func LoadOutcomes(db *sql.DB, series string, key string, from time.Time, to time.Time, step time.Duration) ([]Outcome, error) {
+	ticks, err := LoadTicks(db, series, key, from.Add(-step))
+	if err != nil {
+		return nil, err
+	}
+	candles, err := Candles(ticks, step)
+	if err != nil {
+		return nil, err
+	}
+	closes := make(map[time.Time]float64)
+	for _, candle := range candles {
+		if candle.Count > 0 {
+			closes[candle.Start] = candle.Close
+		}
+	}
+
+	query := `SELECT model, issued, target_time, value, lower_bound, upper_bound, level FROM tbl_price_forecast
+		WHERE series=? AND series_key=? AND target_time >= ? AND target_time <= ?`
+	rows, err := db.Query(query, series, key, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var outcomes []Outcome
+	for rows.Next() {
+		var outcome Outcome
+		var issued, target string
+		err = rows.Scan(&outcome.Model, &issued, &target, &outcome.Point.Value, &outcome.Point.Lower, &outcome.Point.Upper, &outcome.Level)
+		if err != nil {
+			return nil, err
+		}
+		issuedTime, err := time.ParseInLocation("2006-01-02 15:04:05", issued, time.Local)
+		if err != nil {
+			return nil, err
+		}
+		outcome.Point.Time, err = time.ParseInLocation("2006-01-02 15:04:05", target, time.Local)
+		if err != nil {
+			return nil, err
+		}
+		actual, ok := closes[outcome.Point.Time.Truncate(step)]
+		if !ok {
+			continue
+		}
+		outcome.Actual = actual
+		steps := (outcome.Point.Time.Sub(issuedTime) + step - 1) / step
+		outcome.HorizonMinutes = int((steps * step).Minutes())
+		outcomes = append(outcomes, outcome)
+	}
+	return outcomes, rows.Err()
+}
+
+// SaveScores stores scores in tbl_forecast_score.
+func SaveScores(db *sql.DB, scores []Score) error {
+	insertData := `INSERT INTO tbl_forecast_score (series, series_key, model, horizon_minutes, samples, mae, mape, rmse, pinball, last_updated)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE samples = VALUES(samples), mae = VALUES(mae), mape = VALUES(mape), rmse = VALUES(rmse), pinball = VALUES(pinball), last_updated = VALUES(last_updated)`
+	for _, s := range scores {
+		_, err := db.Exec(insertData, s.Series, s.Key, s.Model, s.HorizonMinutes, s.Samples, s.MAE, s.MAPE, s.RMSE, s.Pinball, s.LastUpdated)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadScores reads the stored scores of a series.
+func LoadScores(db *sql.DB, series string, key string) ([]Score, error) {
+	query := `SELECT model, horizon_minutes, samples, mae, mape, rmse, pinball, last_updated FROM tbl_forecast_score
+		WHERE series=? AND series_key=? ORDER BY model, horizon_minutes`
+	rows, err := db.Query(query, series, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var scores []Score
+	for rows.Next() {
+		s := Score{Series: series, Key: key}
+		var updated string
+		err = rows.Scan(&s.Model, &s.HorizonMinutes, &s.Samples, &s.MAE, &s.MAPE, &s.RMSE, &s.Pinball, &updated)
+		if err != nil {
+			return nil, err
+		}
+		s.LastUpdated, err = time.ParseInLocation("2006-01-02 15:04:05", updated, time.Local)
+		if err != nil {
+			return nil, err
+		}
+		scores = append(scores, s)
+	}
+	return scores, rows.Err()
+}
@@ -0,0 +1,135 @@
+package fx
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrNoRate is returned when no rate is known between two currencies.
+var ErrNoRate = errors.New("no fx rate")
+
+// Rate is the number of units of Quote one unit of Base buys.
+type Rate struct {
+	Base      string    `json:"base"`
+	Quote     string    `json:"quote"`
+	Rate      float64   `json:"rate"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Converter holds the latest rate for each currency pair.
+type Converter struct {
+	mu    sync.RWMutex
+	rates map[string]Rate
+}
+
+func NewConverter() *Converter {
+	return &Converter{rates: make(map[string]Rate)}
+}
+
+func pairKey(base string, quote string) string {
+	return base + "/" + quote
+}
+
+// Set records r if it is newer than the rate already held for its pair.
+func (c *Converter) Set(r Rate) {
+	if r.Rate <= 0 || r.Base == "" || r.Quote == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := pairKey(r.Base, r.Quote)
+	if current, ok := c.rates[key]; ok && current.Timestamp.After(r.Timestamp) {
+		return
+	}
+	c.rates[key] = r
+}
+
+// Latest returns the time of the newest rate held, or the zero time when
+// none is.
+func (c *Converter) Latest() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var latest time.Time
+	for _, r := range c.rates {
+		if r.Timestamp.After(latest) {
+			latest = r.Timestamp
+		}
+	}
+	return latest
+}
+
+// Rate returns the multiplier that turns an amount in from into an amount in
+// to. Pairs are looked up directly, inverted, or crossed through one common
+// currency.
+func (c *Converter) Rate(from string, to string) (float64, error) {
+	if from == to {
+		return 1, nil
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if rate, ok := c.direct(from, to); ok {
+		return rate, nil
+	}
+	for _, r := range c.rates {
+		var via string
+		switch {
+		case r.Base == from:
+			via = r.Quote
+		case r.Quote == from:
+			via = r.Base
+		default:
+			continue
+		}
+		first, _ := c.direct(from, via)
+		if second, ok := c.direct(via, to); ok {
+			return first * second, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %s to %s", ErrNoRate, from, to)
+}
+
+func (c *Converter) direct(from string, to string) (float64, bool) {
+	if r, ok := c.rates[pairKey(from, to)]; ok {
+		return r.Rate, true
+	}
+	if r, ok := c.rates[pairKey(to, from)]; ok {
+		return 1 / r.Rate, true
+	}
+	return 0, false
+}
+
+// Convert restates value, held in currency from, in currency to.
+func Convert[T ~float64](c *Converter, value T, from string, to string) (T, error) {
+	rate, err := c.Rate(from, to)
+	if err != nil {
+		return 0, err
+	}
+	return T(float64(value) * rate), nil
+}
+
+// LoadRates reads the latest rate of every pair from tbl_fx_rate into c.
+func LoadRates(db *sql.DB, c *Converter) error {
+	rows, err := db.Query("SELECT r.base_currency, r.quote_currency, r.rate, r.timestamp FROM tbl_fx_rate r " +
+		"JOIN (SELECT base_currency, quote_currency, MAX(timestamp) AS timestamp FROM tbl_fx_rate GROUP BY base_currency, quote_currency) l " +
+		"ON r.base_currency = l.base_currency AND r.quote_currency = l.quote_currency AND r.timestamp = l.timestamp")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r Rate
+		var timestamp string
+		err = rows.Scan(&r.Base, &r.Quote, &r.Rate, &timestamp)
+		if err != nil {
+			return err
+		}
+		r.Timestamp, _ = time.ParseInLocation("2006-01-02 15:04:05", timestamp, time.Local)
+		c.Set(r)
+	}
+	return rows.Err()
+}
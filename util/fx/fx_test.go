@@ -0,0 +1,79 @@
+package fx
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestRate(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewConverter()
+	c.Set(Rate{Base: "USD", Quote: "AUD", Rate: 1.5, Timestamp: now})
+	c.Set(Rate{Base: "USD", Quote: "EUR", Rate: 0.9, Timestamp: now})
+	c.Set(Rate{Base: "JPY", Quote: "CHF", Rate: 0.006, Timestamp: now})
+
+	tests := []struct {
+		name     string
+		from, to string
+		want     float64
+		wantErr  bool
+	}{
+		{"same currency", "GBP", "GBP", 1, false},
+		{"direct", "USD", "AUD", 1.5, false},
+		{"inverse", "AUD", "USD", 1 / 1.5, false},
+		{"cross through the base", "AUD", "EUR", 0.9 / 1.5, false},
+		{"cross back", "EUR", "AUD", 1.5 / 0.9, false},
+		{"unknown currency", "USD", "GBP", 0, true},
+		{"unconnected pairs", "AUD", "CHF", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.Rate(tt.from, tt.to)
+			if tt.wantErr {
+				if !errors.Is(err, ErrNoRate) {
+					t.Errorf("Rate() error = %v, want ErrNoRate", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if math.Abs(got-tt.want) > 1e-12 {
+				t.Errorf("Rate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSet(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewConverter()
+	c.Set(Rate{Base: "USD", Quote: "AUD", Rate: 1.5, Timestamp: now})
+	c.Set(Rate{Base: "USD", Quote: "AUD", Rate: 1.4, Timestamp: now.Add(-time.Hour)})
+	c.Set(Rate{Base: "USD", Quote: "AUD", Rate: 0, Timestamp: now.Add(time.Hour)})
+	c.Set(Rate{Base: "USD", Quote: "EUR", Rate: -1, Timestamp: now.Add(time.Hour)})
+
+	if rate, _ := c.Rate("USD", "AUD"); rate != 1.5 {
+		t.Errorf("Rate() = %v after an older and a zero rate, want 1.5", rate)
+	}
+	if _, err := c.Rate("USD", "EUR"); err == nil {
+		t.Error("Rate() of a negative rate succeeded")
+	}
+	if latest := c.Latest(); !latest.Equal(now) {
+		t.Errorf("Latest() = %v, want %v", latest, now)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	c := NewConverter()
+	c.Set(Rate{Base: "USD", Quote: "AUD", Rate: 1.5})
+
+	if got, err := Convert(c, 200.0, "USD", "AUD"); err != nil || got != 300 {
+		t.Errorf("Convert() = %v, %v, want 300", got, err)
+	}
+	if _, err := Convert(c, 200.0, "USD", "GBP"); !errors.Is(err, ErrNoRate) {
+		t.Errorf("Convert() error = %v, want ErrNoRate", err)
+	}
+}
@@ -0,0 +1,409 @@
+package inventory
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	fleet "profitmax/util/fleet"
+)
+
+// Status is where a miner is in its life.
+type Status string
+
+const (
+	// Active miners are installed and expected to hash.
+	Active Status = "ACTIVE"
+	// Spare miners are owned and on site but not installed.
+	Spare Status = "SPARE"
+	// Maintenance miners are out for repair.
+	Maintenance Status = "MAINTENANCE"
+	// Retired miners are no longer part of the fleet.
+	Retired Status = "RETIRED"
+)
+
+const dateLayout = "2006-01-02"
+
+// Device is one miner we own. HashrateTH and PowerW are its nameplate
+// figures.
+type Device struct {
+	ID               string    `json:"id"`
+	Model            string    `json:"model"`
+	Serial           string    `json:"serial"`
+	IP               string    `json:"ip"`
+	LocationID       string    `json:"location_id"`
+	Rack             string    `json:"rack"`
+	Status           Status    `json:"status"`
+	HashrateTH       float64   `json:"hashrate_th"`
+	PowerW           float64   `json:"power_w"`
+	PurchaseCost     float64   `json:"purchase_cost"`
+	PurchaseCurrency string    `json:"purchase_currency"`
+	PurchaseDate     time.Time `json:"purchase_date"`
+	Firmware         string    `json:"firmware"`
+	LastUpdated      time.Time `json:"last_updated"`
+}
+
+// Validate reports a device that cannot be stored.
+func (d Device) Validate() error {
+	if d.ID == "" || d.Model == "" || d.LocationID == "" {
+		return fmt.Errorf("device %q: id, model and location_id are required", d.ID)
+	}
+	switch d.Status {
+	case Active, Spare, Maintenance, Retired:
+	default:
+		return fmt.Errorf("device %s: unknown status %q", d.ID, d.Status)
+	}
+	if d.PurchaseCost > 0 && d.PurchaseCurrency == "" {
+		return fmt.Errorf("device %s: purchase cost has no currency", d.ID)
+	}
+	return nil
+}
+
+// Event announces a change to a device on the inventory topic.
+// PreviousLocationID is the site an upsert moved the device from.
+type Event struct {
+	Action             string    `json:"action"`
+	Device             Device    `json:"device"`
+	PreviousLocationID string    `json:"previous_location_id,omitempty"`
+	Timestamp          time.Time `json:"timestamp"`
+}
+
+// Locations returns the sites the event changed: the device's site, and the
+// site it left when it moved.
+func (e Event) Locations() []string {
+	locations := []string{e.Device.LocationID}
+	if e.PreviousLocationID != "" && e.PreviousLocationID != e.Device.LocationID {
+		locations = append(locations, e.PreviousLocationID)
+	}
+	return locations
+}
+
+const (
+	ActionUpsert = "UPSERT"
+	ActionDelete = "DELETE"
+)
+
+const selectDevices = "SELECT device_id, model, serial, ip, location_id, rack, status, hashrate_th, power_w, purchase_cost, purchase_currency, purchase_date, firmware, last_updated FROM tbl_inventory_device"
+
+// Load reads the devices of a site, or of every site when locationID is
+// empty, from tbl_inventory_device.
+func Load(db *sql.DB, locationID string) ([]Device, error) {
+	query := selectDevices + " ORDER BY location_id, device_id"
+	var args []interface{}
+	if locationID != "" {
+		query = selectDevices + " WHERE location_id=? ORDER BY device_id"
+		args = append(args, locationID)
+	}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var devices []Device
+	for rows.Next() {
+		device, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		devices = append(devices, device)
+	}
+	return devices, rows.Err()
+}
+
+// Get reads one device.
+func Get(db *sql.DB, id string) (Device, error) {
+	device, err := scan(db.QueryRow(selectDevices+" WHERE device_id=?", id))
+	if err != nil {
+		return Device{}, fmt.Errorf("device %s: %w", id, err)
+	}
+	return device, nil
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scan(row scanner) (Device, error) {
+	var device Device
+	var serial, ip, rack, purchaseCurrency, purchaseDate, firmware sql.NullString
+	var hashrate, power, purchaseCost sql.NullFloat64
+	var lastUpdated string
+
+	err := row.Scan(&device.ID, &device.Model, &serial, &ip, &device.LocationID, &rack, &device.Status,
+		&hashrate, &power, &purchaseCost, &purchaseCurrency, &purchaseDate, &firmware, &lastUpdated)
+	if err != nil {
+		return Device{}, err
+	}
+	device.Serial = serial.String
+	device.IP = ip.String
+	device.Rack = rack.String
+	device.HashrateTH = hashrate.Float64
+	device.PowerW = power.Float64
+	device.PurchaseCost = purchaseCost.Float64
+	device.PurchaseCurrency = purchaseCurrency.String
+	device.PurchaseDate = parseDate(purchaseDate.String)
+	device.Firmware = firmware.String
+	device.LastUpdated, _ = time.ParseInLocation("2006-01-02 15:04:05", lastUpdated, time.Local)
+	return device, nil
+}
+
+func parseDate(value string) time.Time {
+	if len(value) > len(dateLayout) {
+		value = value[:len(dateLayout)]
+	}
+	date, err := time.ParseInLocation(dateLayout, value, time.Local)
+	if err != nil {
+		return time.Time{}
+	}
+	return date
+}
+
+// Save inserts or updates device and stamps its last_updated time.
+func Save(db *sql.DB, device *Device) error {
+	if err := device.Validate(); err != nil {
+		return err
+	}
+	device.LastUpdated = time.Now()
+
+	var purchaseDate interface{}
+	if !device.PurchaseDate.IsZero() {
+		purchaseDate = device.PurchaseDate.Format(dateLayout)
+	}
+
+	insertData := `INSERT INTO tbl_inventory_device (device_id, model, serial, ip, location_id, rack, status, hashrate_th, power_w, purchase_cost, purchase_currency, purchase_date, firmware, last_updated)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE model = VALUES(model), serial = VALUES(serial), ip = VALUES(ip), location_id = VALUES(location_id), rack = VALUES(rack),
+		status = VALUES(status), hashrate_th = VALUES(hashrate_th), power_w = VALUES(power_w), purchase_cost = VALUES(purchase_cost),
+		purchase_currency = VALUES(purchase_currency), purchase_date = VALUES(purchase_date), firmware = VALUES(firmware), last_updated = VALUES(last_updated)`
+	_, err := db.Exec(insertData, device.ID, device.Model, device.Serial, device.IP, device.LocationID, device.Rack, device.Status,
+		device.HashrateTH, device.PowerW, device.PurchaseCost, device.PurchaseCurrency, purchaseDate, device.Firmware, device.LastUpdated)
+	return err
+}
+
+// Delete removes a device and returns it as it was.
+func Delete(db *sql.DB, id string) (Device, error) {
+	device, err := Get(db, id)
+	if err != nil {
+		return Device{}, err
+	}
+	_, err = db.Exec("DELETE FROM tbl_inventory_device WHERE device_id=?", id)
+	if err != nil {
+		return Device{}, err
+	}
+	return device, nil
+}
+
+// csvColumns are the columns ReadCSV understands, in the order WriteCSV
+// writes them.
+var csvColumns = []string{"id", "model", "serial", "ip", "location_id", "rack", "status", "hashrate_th", "power_w", "purchase_cost", "purchase_currency", "purchase_date", "firmware"}
+
+// ReadCSV reads devices from CSV with a header row naming its columns. Only
+// id, model and location_id are required; status defaults to ACTIVE.
+func ReadCSV(r io.Reader) ([]Device, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"id", "model", "location_id"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("csv: no %s column", required)
+		}
+	}
+
+	var devices []Device
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		field := func(name string) string {
+			i, ok := columns[name]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+		number := func(name string) (float64, error) {
+			if field(name) == "" {
+				return 0, nil
+			}
+			value, err := strconv.ParseFloat(field(name), 64)
+			if err != nil {
+				return 0, fmt.Errorf("csv line %d: %s: %w", line, name, err)
+			}
+			return value, nil
+		}
+
+		device := Device{
+			ID:               field("id"),
+			Model:            field("model"),
+			Serial:           field("serial"),
+			IP:               field("ip"),
+			LocationID:       field("location_id"),
+			Rack:             field("rack"),
+			Status:           Status(strings.ToUpper(field("status"))),
+			PurchaseCurrency: field("purchase_currency"),
+			Firmware:         field("firmware"),
+		}
+		if device.Status == "" {
+			device.Status = Active
+		}
+		if device.HashrateTH, err = number("hashrate_th"); err != nil {
+			return nil, err
+		}
+		if device.PowerW, err = number("power_w"); err != nil {
+			return nil, err
+		}
+		if device.PurchaseCost, err = number("purchase_cost"); err != nil {
+			return nil, err
+		}
+		if field("purchase_date") != "" {
+			device.PurchaseDate, err = time.ParseInLocation(dateLayout, field("purchase_date"), time.Local)
+			if err != nil {
+				return nil, fmt.Errorf("csv line %d: purchase_date: %w", line, err)
+			}
+		}
+		if err := device.Validate(); err != nil {
+			return nil, fmt.Errorf("csv line %d: %w", line, err)
+		}
+		devices = append(devices, device)
+	}
+	return devices, nil
+}
+
+// WriteCSV writes devices in the format ReadCSV reads.
+func WriteCSV(w io.Writer, devices []Device) error {
+	writer := csv.NewWriter(w)
+	writer.Write(csvColumns)
+	for _, d := range devices {
+		var purchaseDate string
+		if !d.PurchaseDate.IsZero() {
+			purchaseDate = d.PurchaseDate.Format(dateLayout)
+		}
+		writer.Write([]string{d.ID, d.Model, d.Serial, d.IP, d.LocationID, d.Rack, string(d.Status),
+			strconv.FormatFloat(d.HashrateTH, 'f', -1, 64), strconv.FormatFloat(d.PowerW, 'f', -1, 64),
+			strconv.FormatFloat(d.PurchaseCost, 'f', -1, 64), d.PurchaseCurrency, purchaseDate, d.Firmware})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// Sites returns sites with their fleet and devices taken from the active
+// devices in the inventory, replacing whatever was configured. A site with
+// no active devices has an empty fleet. A group's hashrate and power are the
+// averages of its devices' nameplate figures.
+func Sites(sites []fleet.Site, devices []Device) []fleet.Site {
+	result := make([]fleet.Site, len(sites))
+	for i, site := range sites {
+		var siteFleet []fleet.MachineGroup
+		var siteDevices []fleet.Device
+		groups := make(map[string]int)
+		for _, device := range devices {
+			if device.LocationID != site.LocationID || device.Status != Active {
+				continue
+			}
+			siteDevices = append(siteDevices, fleet.Device{ID: device.ID, Model: device.Model, Address: device.IP})
+
+			g, ok := groups[device.Model]
+			if !ok {
+				g = len(siteFleet)
+				groups[device.Model] = g
+				siteFleet = append(siteFleet, fleet.MachineGroup{Model: device.Model})
+			}
+			siteFleet[g].Count++
+			siteFleet[g].HashrateTH += device.HashrateTH
+			siteFleet[g].PowerW += device.PowerW
+		}
+		for g := range siteFleet {
+			siteFleet[g].HashrateTH /= float64(siteFleet[g].Count)
+			siteFleet[g].PowerW /= float64(siteFleet[g].Count)
+		}
+
+		result[i] = site
+		result[i].Fleet = siteFleet
+		result[i].Devices = siteDevices
+	}
+	return result
+}
+
+// LoadSites is Sites over every device in tbl_inventory_device.
+func LoadSites(db *sql.DB, sites []fleet.Site) ([]fleet.Site, error) {
+	devices, err := Load(db, "")
+	if err != nil {
+		return nil, err
+	}
+	return Sites(sites, devices), nil
+}
+
+// Fleet holds a service's sites as the inventory last described them, so
+// that inventory events can take effect without a restart. It is safe for
+// concurrent use.
+type Fleet struct {
+	mutex      sync.RWMutex
+	configured []fleet.Site
+	sites      []fleet.Site
+}
+
+// NewFleet returns a Fleet of the configured sites. They keep their
+// configured fleet and devices until the first Reload replaces them with the
+// inventory's.
+func NewFleet(configured []fleet.Site) *Fleet {
+	return &Fleet{configured: configured, sites: configured}
+}
+
+// Reload takes the sites' fleet and devices from tbl_inventory_device
+// afresh. On error the sites are left as they were.
+func (f *Fleet) Reload(db *sql.DB) error {
+	sites, err := LoadSites(db, f.configured)
+	if err != nil {
+		return err
+	}
+	f.mutex.Lock()
+	f.sites = sites
+	f.mutex.Unlock()
+	return nil
+}
+
+// List returns the sites. The result must not be modified.
+func (f *Fleet) List() []fleet.Site {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	return f.sites
+}
+
+// Site returns the site with the given location ID.
+func (f *Fleet) Site(locationID string) (fleet.Site, bool) {
+	for _, site := range f.List() {
+		if site.LocationID == locationID {
+			return site, true
+		}
+	}
+	return fleet.Site{}, false
+}
+
+// Affected returns the sites among those an event changed that f holds.
+func (f *Fleet) Affected(e Event) []string {
+	var affected []string
+	for _, locationID := range e.Locations() {
+		if _, ok := f.Site(locationID); ok {
+			affected = append(affected, locationID)
+		}
+	}
+	return affected
+}
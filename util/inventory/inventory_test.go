@@ -0,0 +1,141 @@
+package inventory
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	fleet "profitmax/util/fleet"
+)
+
+func TestSites(t *testing.T) {
+	configured := []fleet.Site{
+		{LocationID: "QLD1", Currency: "AUD", Fleet: []fleet.MachineGroup{{Model: "S9", Count: 500, HashrateTH: 14, PowerW: 1400}}},
+		{LocationID: "NSW1", Currency: "AUD", Fleet: []fleet.MachineGroup{{Model: "S19", Count: 10, HashrateTH: 95, PowerW: 3250}}},
+		{LocationID: "VIC1", Currency: "AUD", Fleet: []fleet.MachineGroup{{Model: "S19", Count: 10, HashrateTH: 95, PowerW: 3250}}},
+	}
+	devices := []Device{
+		{ID: "a", Model: "S19", LocationID: "QLD1", IP: "10.0.0.1", Status: Active, HashrateTH: 90, PowerW: 3200},
+		{ID: "b", Model: "S19", LocationID: "QLD1", IP: "10.0.0.2", Status: Active, HashrateTH: 100, PowerW: 3300},
+		{ID: "c", Model: "S21", LocationID: "QLD1", IP: "10.0.0.3", Status: Active, HashrateTH: 200, PowerW: 3500},
+		{ID: "d", Model: "S19", LocationID: "QLD1", IP: "10.0.0.4", Status: Spare, HashrateTH: 95, PowerW: 3250},
+		{ID: "e", Model: "S19", LocationID: "NSW1", IP: "10.0.1.1", Status: Maintenance, HashrateTH: 95, PowerW: 3250},
+		{ID: "f", Model: "S19", LocationID: "NSW1", IP: "10.0.1.2", Status: Retired, HashrateTH: 95, PowerW: 3250},
+		{ID: "g", Model: "S19", LocationID: "SA1", IP: "10.0.2.1", Status: Active, HashrateTH: 95, PowerW: 3250},
+	}
+	sites := Sites(configured, devices)
+
+	want := []fleet.Site{
+		{
+			LocationID: "QLD1",
+			Currency:   "AUD",
+			Fleet: []fleet.MachineGroup{
+				{Model: "S19", Count: 2, HashrateTH: 95, PowerW: 3250},
+				{Model: "S21", Count: 1, HashrateTH: 200, PowerW: 3500},
+			},
+			Devices: []fleet.Device{
+				{ID: "a", Model: "S19", Address: "10.0.0.1"},
+				{ID: "b", Model: "S19", Address: "10.0.0.2"},
+				{ID: "c", Model: "S21", Address: "10.0.0.3"},
+			},
+		},
+		// Neither devices out of service nor the configured fleet count
+		{LocationID: "NSW1", Currency: "AUD"},
+		{LocationID: "VIC1", Currency: "AUD"},
+	}
+	if !reflect.DeepEqual(sites, want) {
+		t.Errorf("Sites() = %+v, want %+v", sites, want)
+	}
+
+	// The configured sites are left as they were
+	if len(configured[1].Fleet) != 1 {
+		t.Errorf("Sites() changed the configured fleet: %+v", configured[1].Fleet)
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+	input := `ID, Model, location_id, status, hashrate_th, power_w, purchase_cost, purchase_currency, purchase_date
+a, S19, QLD1, active, 95, 3250, 2500, AUD, 2024-03-01
+b, S21, QLD1, , 200, 3500, , ,
+`
+	devices, err := ReadCSV(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Device{
+		{ID: "a", Model: "S19", LocationID: "QLD1", Status: Active, HashrateTH: 95, PowerW: 3250, PurchaseCost: 2500, PurchaseCurrency: "AUD",
+			PurchaseDate: time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local)},
+		{ID: "b", Model: "S21", LocationID: "QLD1", Status: Active, HashrateTH: 200, PowerW: 3500},
+	}
+	if !reflect.DeepEqual(devices, want) {
+		t.Errorf("ReadCSV() = %+v, want %+v", devices, want)
+	}
+}
+
+func TestReadCSVErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"empty", ""},
+		{"no location column", "id,model\na,S19\n"},
+		{"missing id", "id,model,location_id\n,S19,QLD1\n"},
+		{"unknown status", "id,model,location_id,status\na,S19,QLD1,LOST\n"},
+		{"bad number", "id,model,location_id,hashrate_th\na,S19,QLD1,fast\n"},
+		{"bad date", "id,model,location_id,purchase_date\na,S19,QLD1,01/03/2024\n"},
+		{"cost without currency", "id,model,location_id,purchase_cost\na,S19,QLD1,2500\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ReadCSV(strings.NewReader(tt.input)); err == nil {
+				t.Error("ReadCSV() succeeded")
+			}
+		})
+	}
+}
+
+func TestCSVRoundTrip(t *testing.T) {
+	devices := []Device{
+		{ID: "a", Model: "S19", Serial: "SN1", IP: "10.0.0.1", LocationID: "QLD1", Rack: "R1", Status: Spare, HashrateTH: 95.5, PowerW: 3250,
+			PurchaseCost: 2500, PurchaseCurrency: "AUD", PurchaseDate: time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local), Firmware: "2024.1"},
+		{ID: "b", Model: "S21", LocationID: "NSW1", Status: Active},
+	}
+	var buffer bytes.Buffer
+	if err := WriteCSV(&buffer, devices); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadCSV(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, devices) {
+		t.Errorf("ReadCSV(WriteCSV()) = %+v, want %+v", read, devices)
+	}
+}
+
+func TestEventLocations(t *testing.T) {
+	tests := []struct {
+		name  string
+		event Event
+		want  []string
+	}{
+		{"new device", Event{Device: Device{LocationID: "QLD1"}}, []string{"QLD1"}},
+		{"same site", Event{Device: Device{LocationID: "QLD1"}, PreviousLocationID: "QLD1"}, []string{"QLD1"}},
+		{"moved", Event{Device: Device{LocationID: "QLD1"}, PreviousLocationID: "NSW1"}, []string{"QLD1", "NSW1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.event.Locations(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Locations() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	sites := NewFleet([]fleet.Site{{LocationID: "QLD1"}, {LocationID: "NSW1"}})
+	moved := Event{Device: Device{LocationID: "SA1"}, PreviousLocationID: "NSW1"}
+	if got := sites.Affected(moved); !reflect.DeepEqual(got, []string{"NSW1"}) {
+		t.Errorf("Affected() = %v, want [NSW1]", got)
+	}
+}
@@ -0,0 +1,103 @@
+package quality
+
+import (
+	"sort"
+	"time"
+)
+
+// Statuses of a service's inputs.
+const (
+	// Fresh inputs are all within their TTL.
+	Fresh = "FRESH"
+	// Stale inputs include at least one older than its TTL.
+	Stale = "STALE"
+)
+
+// Fallback policies for decisions made while inputs are stale.
+const (
+	// Hold repeats the last decision.
+	Hold = "HOLD"
+	// Curtail fails safe and curtails the site outright.
+	Curtail = "CURTAIL"
+	// Forecast stands in the latest forecast for stale prices, and curtails
+	// when there is none.
+	Forecast = "FORECAST"
+)
+
+// Times holds the event time of each input a value was computed from,
+// keyed by input name.
+type Times map[string]time.Time
+
+// Observe notes eventTime for input, keeping the latest. It reports whether
+// the input moved forward.
+func (t Times) Observe(input string, eventTime time.Time) bool {
+	if eventTime.IsZero() || !eventTime.After(t[input]) {
+		return false
+	}
+	t[input] = eventTime
+	return true
+}
+
+// Merge observes every event time in other.
+func (t Times) Merge(other Times) bool {
+	moved := false
+	for input, eventTime := range other {
+		if t.Observe(input, eventTime) {
+			moved = true
+		}
+	}
+	return moved
+}
+
+// EventTime returns the event time a message carried, or when it was
+// produced for messages that carry none.
+func EventTime(carried time.Time, produced time.Time) time.Time {
+	if carried.IsZero() {
+		return produced
+	}
+	return carried
+}
+
+// Input is the freshness of one input.
+type Input struct {
+	Name       string    `json:"name"`
+	EventTime  time.Time `json:"event_time"`
+	AgeSeconds float64   `json:"age_seconds"`
+	TTLSeconds int       `json:"ttl_seconds,omitempty"`
+	Stale      bool      `json:"stale"`
+}
+
+// Report is the data-quality status of a service's output.
+type Report struct {
+	LocationID string    `json:"location_id,omitempty"`
+	Status     string    `json:"status"`
+	Inputs     []Input   `json:"inputs"`
+	Stale      []string  `json:"stale,omitempty"`
+	Fallback   string    `json:"fallback,omitempty"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// Check reports the freshness of every input in times against ttls, in
+// seconds. An input without a TTL never goes stale.
+func Check(times Times, ttls map[string]int, now time.Time) Report {
+	report := Report{Status: Fresh, Timestamp: now}
+	for name, eventTime := range times {
+		input := Input{
+			Name:       name,
+			EventTime:  eventTime,
+			AgeSeconds: now.Sub(eventTime).Seconds(),
+			TTLSeconds: ttls[name],
+		}
+		if input.TTLSeconds > 0 && input.AgeSeconds > float64(input.TTLSeconds) {
+			input.Stale = true
+			report.Stale = append(report.Stale, name)
+		}
+		report.Inputs = append(report.Inputs, input)
+	}
+	sort.Slice(report.Inputs, func(i, j int) bool { return report.Inputs[i].Name < report.Inputs[j].Name })
+	sort.Strings(report.Stale)
+	if len(report.Stale) > 0 {
+		report.Status = Stale
+	}
+	return report
+}
@@ -0,0 +1,91 @@
+package quality
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func TestObserve(t *testing.T) {
+	times := Times{}
+	tests := []struct {
+		name string
+		at   time.Time
+		want bool
+	}{
+		{"first", now, true},
+		{"later", now.Add(time.Minute), true},
+		{"same", now.Add(time.Minute), false},
+		{"earlier", now, false},
+		{"zero", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		if got := times.Observe("energy_price", tt.at); got != tt.want {
+			t.Errorf("Observe() of %s time = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if !times["energy_price"].Equal(now.Add(time.Minute)) {
+		t.Errorf("event time = %v, want the latest", times["energy_price"])
+	}
+}
+
+func TestMerge(t *testing.T) {
+	times := Times{"energy_price": now, "crypto_price": now}
+	if !times.Merge(Times{"energy_price": now.Add(-time.Minute), "difficulty": now}) {
+		t.Error("Merge() of a new input reported no move")
+	}
+	if times.Merge(Times{"energy_price": now.Add(-time.Minute), "crypto_price": now}) {
+		t.Error("Merge() of older times reported a move")
+	}
+	want := Times{"energy_price": now, "crypto_price": now, "difficulty": now}
+	if !reflect.DeepEqual(times, want) {
+		t.Errorf("Merge() = %v, want %v", times, want)
+	}
+}
+
+func TestEventTime(t *testing.T) {
+	produced := now.Add(time.Second)
+	if got := EventTime(now, produced); !got.Equal(now) {
+		t.Errorf("EventTime() = %v, want the carried time", got)
+	}
+	if got := EventTime(time.Time{}, produced); !got.Equal(produced) {
+		t.Errorf("EventTime() = %v, want the produced time", got)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	times := Times{
+		"energy_price": now.Add(-10 * time.Minute),
+		"crypto_price": now.Add(-time.Minute),
+		"difficulty":   now.Add(-48 * time.Hour),
+		"mining_cost":  now.Add(-5 * time.Minute),
+	}
+	ttls := map[string]int{"energy_price": 300, "crypto_price": 300, "mining_cost": 300}
+	report := Check(times, ttls, now)
+
+	// An input exactly at its TTL is still fresh, and one without a TTL
+	// never goes stale
+	want := Report{
+		Status: Stale,
+		Inputs: []Input{
+			{Name: "crypto_price", EventTime: times["crypto_price"], AgeSeconds: 60, TTLSeconds: 300},
+			{Name: "difficulty", EventTime: times["difficulty"], AgeSeconds: 48 * 3600},
+			{Name: "energy_price", EventTime: times["energy_price"], AgeSeconds: 600, TTLSeconds: 300, Stale: true},
+			{Name: "mining_cost", EventTime: times["mining_cost"], AgeSeconds: 300, TTLSeconds: 300},
+		},
+		Stale:     []string{"energy_price"},
+		Timestamp: now,
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("Check() = %+v, want %+v", report, want)
+	}
+
+	if report := Check(times, nil, now); report.Status != Fresh || len(report.Stale) != 0 {
+		t.Errorf("Check() without TTLs = %+v", report)
+	}
+	if report := Check(Times{}, ttls, now); report.Status != Fresh || len(report.Inputs) != 0 {
+		t.Errorf("Check() of no inputs = %+v", report)
+	}
+}
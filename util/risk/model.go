@@ -0,0 +1,220 @@
+package risk
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+
+	timeseries "profitmax/util/timeseries"
+)
+
+// CryptoModel is a jump diffusion of the crypto price: geometric Brownian
+// motion with annual Drift and Volatility, plus JumpsPerYear jumps on
+// average whose log sizes are normal with mean JumpMean and deviation
+// JumpSD. Drift is the expected annual return, jumps included.
+type CryptoModel struct {
+	Drift        float64 `json:"drift"`
+	Volatility   float64 `json:"volatility"`
+	JumpsPerYear float64 `json:"jumps_per_year"`
+	JumpMean     float64 `json:"jump_mean"`
+	JumpSD       float64 `json:"jump_sd"`
+}
+
+// EnergyModel is a mean-reverting energy price with spikes: starting at
+// Start, or Mean when it is zero, the price is pulled toward Mean at
+// Reversion per day, diffuses with Volatility per square-root day, and jumps
+// up by an exponentially distributed SpikeMean with SpikesPerDay on average.
+// Spikes then revert like any other move. The price never falls below Floor.
+type EnergyModel struct {
+	Start        float64 `json:"start"`
+	Mean         float64 `json:"mean"`
+	Reversion    float64 `json:"reversion"`
+	Volatility   float64 `json:"volatility"`
+	SpikesPerDay float64 `json:"spikes_per_day"`
+	SpikeMean    float64 `json:"spike_mean"`
+	Floor        float64 `json:"floor"`
+}
+
+// DifficultyModel moves difficulty around the scenario's difficulty path
+// with a monthly log Volatility.
+type DifficultyModel struct {
+	Volatility float64 `json:"volatility"`
+}
+
+// Correlations are the correlations between the shocks of each pair of
+// processes.
+type Correlations struct {
+	CryptoEnergy     float64 `json:"crypto_energy"`
+	CryptoDifficulty float64 `json:"crypto_difficulty"`
+	EnergyDifficulty float64 `json:"energy_difficulty"`
+}
+
+// cholesky returns the lower triangular factor of the correlation matrix, so
+// independent normals times it are correlated. It is false when the
+// correlations are not a valid correlation matrix.
+func (c Correlations) cholesky() ([3][3]float64, bool) {
+	m := [3][3]float64{
+		{1, c.CryptoEnergy, c.CryptoDifficulty},
+		{c.CryptoEnergy, 1, c.EnergyDifficulty},
+		{c.CryptoDifficulty, c.EnergyDifficulty, 1},
+	}
+	var l [3][3]float64
+	for i := 0; i < 3; i++ {
+		for j := 0; j <= i; j++ {
+			sum := m[i][j]
+			for k := 0; k < j; k++ {
+				sum -= l[i][k] * l[j][k]
+			}
+			if i == j {
+				if sum <= 0 {
+					return l, false
+				}
+				l[i][i] = math.Sqrt(sum)
+			} else {
+				l[i][j] = sum / l[j][j]
+			}
+		}
+	}
+	return l, true
+}
+
+// poisson draws from a Poisson distribution with mean lambda.
+func poisson(r *rand.Rand, lambda float64) int {
+	if lambda <= 0 {
+		return 0
+	}
+	limit := math.Exp(-lambda)
+	n := 0
+	p := r.Float64()
+	for p > limit {
+		n++
+		p *= r.Float64()
+	}
+	return n
+}
+
+// CalibrateEnergy fits an EnergyModel to prices step apart, oldest first.
+// Moves more than five median absolute deviations above the median move
+// are taken as spikes; the rest are regressed on the price to find the mean
+// and the speed of reversion.
+func CalibrateEnergy(prices []float64, step time.Duration) EnergyModel {
+	if len(prices) < 3 {
+		return EnergyModel{}
+	}
+	dt := step.Hours() / 24
+	moves := make([]float64, len(prices)-1)
+	for i := range moves {
+		moves[i] = prices[i+1] - prices[i]
+	}
+	center := median(moves)
+	deviations := make([]float64, len(moves))
+	for i, move := range moves {
+		deviations[i] = math.Abs(move - center)
+	}
+	threshold := center + 5*median(deviations)
+
+	var spikes, spikeTotal float64
+	var xs, ys []float64
+	for i, move := range moves {
+		if move > threshold && threshold > center {
+			spikes++
+			spikeTotal += move
+			continue
+		}
+		xs = append(xs, prices[i])
+		ys = append(ys, move)
+	}
+
+	// Ordinary least squares of move on price: move = a + b*price
+	model := EnergyModel{Mean: mean(prices)}
+	if spikes > 0 {
+		model.SpikesPerDay = spikes / (float64(len(moves)) * dt)
+		model.SpikeMean = spikeTotal / spikes
+	}
+	mx, my := mean(xs), mean(ys)
+	var sxy, sxx float64
+	for i := range xs {
+		sxy += (xs[i] - mx) * (ys[i] - my)
+		sxx += (xs[i] - mx) * (xs[i] - mx)
+	}
+	if sxx > 0 {
+		b := sxy / sxx
+		a := my - b*mx
+		if b < 0 {
+			model.Reversion = -b / dt
+			model.Mean = a / -b
+		}
+	}
+	var residuals float64
+	for i := range xs {
+		e := ys[i] - (my + (xs[i]-mx)*(-model.Reversion*dt))
+		residuals += e * e
+	}
+	if len(xs) > 2 {
+		model.Volatility = math.Sqrt(residuals/float64(len(xs)-2)) / math.Sqrt(dt)
+	}
+	model.Start = prices[len(prices)-1]
+	model.Floor = minimum(prices)
+	return model
+}
+
+// CalibrateCrypto fits the drift and volatility of a CryptoModel, without
+// jumps, to prices step apart, oldest first.
+func CalibrateCrypto(prices []float64, step time.Duration) CryptoModel {
+	var returns []float64
+	for i := 1; i < len(prices); i++ {
+		if prices[i-1] > 0 && prices[i] > 0 {
+			returns = append(returns, math.Log(prices[i]/prices[i-1]))
+		}
+	}
+	if len(returns) < 2 {
+		return CryptoModel{}
+	}
+	dt := step.Hours() / hoursPerYear
+	m := mean(returns)
+	var squares float64
+	for _, r := range returns {
+		squares += (r - m) * (r - m)
+	}
+	variance := squares / float64(len(returns)-1) / dt
+	return CryptoModel{
+		Drift:      math.Exp(m/dt+variance/2) - 1,
+		Volatility: math.Sqrt(variance),
+	}
+}
+
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+func median(values []float64) float64 {
+	return quantile(values, 0.5)
+}
+
+func minimum(values []float64) float64 {
+	low := values[0]
+	for _, v := range values {
+		if v < low {
+			low = v
+		}
+	}
+	return low
+}
+
+// quantile returns the q-quantile of values, interpolating between ranks.
+func quantile(values []float64, q float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	return timeseries.Quantile(sorted, q)
+}
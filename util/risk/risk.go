@@ -0,0 +1,278 @@
+package risk
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+
+	economics "profitmax/util/economics"
+	scenario "profitmax/util/scenario"
+)
+
+const hoursPerYear = 365 * 24
+
+// Config configures the risk engine. Paths paths are simulated an hour at a
+// time from Seed, so the same seed draws the same paths. Each month's hourly
+// energy prices are grouped into Bands price bands for the projection. VaR
+// and CVaR are reported at Level, such as 0.95.
+type Config struct {
+	Paths        int             `json:"paths"`
+	Seed         int64           `json:"seed"`
+	Bands        int             `json:"bands"`
+	Level        float64         `json:"level"`
+	Crypto       CryptoModel     `json:"crypto"`
+	Energy       EnergyModel     `json:"energy"`
+	Difficulty   DifficultyModel `json:"difficulty"`
+	Correlations Correlations    `json:"correlations"`
+}
+
+// Validate reports a configuration the engine cannot run.
+func (c Config) Validate() error {
+	if c.Paths <= 0 || c.Bands <= 0 {
+		return fmt.Errorf("risk: paths and bands must be positive")
+	}
+	if c.Level <= 0 || c.Level >= 1 {
+		return fmt.Errorf("risk: level %g is not between 0 and 1", c.Level)
+	}
+	if c.Crypto.Volatility < 0 || c.Energy.Volatility < 0 || c.Difficulty.Volatility < 0 {
+		return fmt.Errorf("risk: volatilities cannot be negative")
+	}
+	_, ok := c.Correlations.cholesky()
+	if !ok {
+		return fmt.Errorf("risk: correlations are not a valid correlation matrix")
+	}
+	return nil
+}
+
+// Distribution summarises simulated profits: their mean and 5th, 50th and
+// 95th percentiles, and the probability of a loss.
+type Distribution struct {
+	Mean            economics.Amount `json:"mean"`
+	P5              economics.Amount `json:"p5"`
+	P50             economics.Amount `json:"p50"`
+	P95             economics.Amount `json:"p95"`
+	LossProbability float64          `json:"loss_probability"`
+}
+
+// MonthRisk is the distribution of one month's profit across the paths.
+type MonthRisk struct {
+	Month string `json:"month"`
+	Distribution
+}
+
+// Report is the profit distribution of a scenario across the simulated
+// paths. VaR is the loss over the whole scenario exceeded with probability
+// 1-Level, and CVaR the mean loss in that tail; both are negative when even
+// the tail makes a profit. MonthLossProbability is the share of all months
+// on all paths that made a loss.
+type Report struct {
+	Scenario             string           `json:"scenario"`
+	Currency             string           `json:"currency"`
+	Paths                int              `json:"paths"`
+	Level                float64          `json:"level"`
+	Profit               Distribution     `json:"profit"`
+	VaR                  economics.Amount `json:"var"`
+	CVaR                 economics.Amount `json:"cvar"`
+	MonthLossProbability float64          `json:"month_loss_probability"`
+	Months               []MonthRisk      `json:"months"`
+}
+
+// path is one simulated path: each month's mean crypto price, difficulty
+// and hourly energy prices.
+type path struct {
+	cryptoPrices []float64
+	difficulties []float64
+	energyPrices [][]float64
+}
+
+// Simulate projects s along config.Paths simulated paths and reports the
+// distribution of its profit. The crypto price starts at the scenario's and
+// follows the jump diffusion; the difficulty follows the scenario's path
+// with lognormal noise around it; the energy price follows the
+// mean-reverting model, replacing the scenario's price distribution. Each
+// path is then projected as the scenario would be.
+func Simulate(s scenario.Scenario, config Config) (Report, error) {
+	err := config.Validate()
+	if err != nil {
+		return Report{}, err
+	}
+	err = s.Validate()
+	if err != nil {
+		return Report{}, err
+	}
+
+	random := rand.New(rand.NewSource(config.Seed))
+	totals := make([]float64, config.Paths)
+	months := make([][]float64, s.Months)
+	for p := 0; p < config.Paths; p++ {
+		simulated := simulate(s, config, random)
+		projection, err := scenario.Project(pathScenario(s, simulated, config.Bands))
+		if err != nil {
+			return Report{}, err
+		}
+		totals[p] = float64(projection.Profit)
+		for m, month := range projection.Months {
+			months[m] = append(months[m], float64(month.Profit))
+		}
+	}
+
+	report := Report{
+		Scenario: s.Name,
+		Currency: s.Currency,
+		Paths:    config.Paths,
+		Level:    config.Level,
+		Profit:   distribution(totals),
+	}
+	tail := quantile(totals, 1-config.Level)
+	var tailTotal float64
+	var tailCount int
+	for _, total := range totals {
+		if total <= tail {
+			tailTotal += total
+			tailCount++
+		}
+	}
+	report.VaR = economics.Amount(-tail)
+	if tailCount > 0 {
+		report.CVaR = economics.Amount(-tailTotal / float64(tailCount))
+	}
+
+	var losses, count int
+	for m, profits := range months {
+		report.Months = append(report.Months, MonthRisk{
+			Month:        s.Start.AddDate(0, m, 0).Format("2006-01"),
+			Distribution: distribution(profits),
+		})
+		for _, profit := range profits {
+			if profit < 0 {
+				losses++
+			}
+			count++
+		}
+	}
+	if count > 0 {
+		report.MonthLossProbability = float64(losses) / float64(count)
+	}
+	return report, nil
+}
+
+func distribution(values []float64) Distribution {
+	d := Distribution{
+		Mean: economics.Amount(mean(values)),
+		P5:   economics.Amount(quantile(values, 0.05)),
+		P50:  economics.Amount(quantile(values, 0.5)),
+		P95:  economics.Amount(quantile(values, 0.95)),
+	}
+	var losses int
+	for _, v := range values {
+		if v < 0 {
+			losses++
+		}
+	}
+	if len(values) > 0 {
+		d.LossProbability = float64(losses) / float64(len(values))
+	}
+	return d
+}
+
+// simulate draws one path hour by hour. The difficulty shock of a month is
+// the sum of its hourly shocks, so it is correlated with the prices over
+// the same month.
+func simulate(s scenario.Scenario, config Config, random *rand.Rand) path {
+	l, _ := config.Correlations.cholesky()
+	crypto, energy := config.Crypto, config.Energy
+
+	hour := 1.0 / hoursPerYear
+	drift := math.Log(1 + crypto.Drift)
+	jumpMean := math.Exp(crypto.JumpMean+crypto.JumpSD*crypto.JumpSD/2) - 1
+	cryptoStep := (drift - crypto.Volatility*crypto.Volatility/2 - crypto.JumpsPerYear*jumpMean) * hour
+	day := 1.0 / 24
+
+	logPrice := math.Log(s.CryptoPrice.At(0))
+	energyPrice := energy.Start
+	if energyPrice == 0 {
+		energyPrice = energy.Mean
+	}
+	var difficultyShock float64
+
+	var simulated path
+	for m := 0; m < s.Months; m++ {
+		from := s.Start.AddDate(0, m, 0)
+		hours := int(s.Start.AddDate(0, m+1, 0).Sub(from) / time.Hour)
+
+		// Difficulty is fixed through the month at its value on the first
+		volatility := config.Difficulty.Volatility
+		simulated.difficulties = append(simulated.difficulties, s.Difficulty.At(m)*math.Exp(volatility*difficultyShock-volatility*volatility*float64(m)/2))
+
+		var cryptoTotal, monthShock float64
+		prices := make([]float64, hours)
+		for h := 0; h < hours; h++ {
+			z := [3]float64{random.NormFloat64(), random.NormFloat64(), random.NormFloat64()}
+			var shock [3]float64
+			for i := 0; i < 3; i++ {
+				for j := 0; j <= i; j++ {
+					shock[i] += l[i][j] * z[j]
+				}
+			}
+
+			logPrice += cryptoStep + crypto.Volatility*math.Sqrt(hour)*shock[0]
+			for n := poisson(random, crypto.JumpsPerYear*hour); n > 0; n-- {
+				logPrice += crypto.JumpMean + crypto.JumpSD*random.NormFloat64()
+			}
+			cryptoTotal += math.Exp(logPrice)
+
+			energyPrice += energy.Reversion*(energy.Mean-energyPrice)*day + energy.Volatility*math.Sqrt(day)*shock[1]
+			for n := poisson(random, energy.SpikesPerDay*day); n > 0; n-- {
+				energyPrice += energy.SpikeMean * random.ExpFloat64()
+			}
+			if energyPrice < energy.Floor {
+				energyPrice = energy.Floor
+			}
+			prices[h] = energyPrice
+
+			monthShock += shock[2]
+		}
+		if hours > 0 {
+			cryptoTotal /= float64(hours)
+			difficultyShock += monthShock / math.Sqrt(float64(hours))
+		}
+		simulated.cryptoPrices = append(simulated.cryptoPrices, cryptoTotal)
+		simulated.energyPrices = append(simulated.energyPrices, prices)
+	}
+	return simulated
+}
+
+// pathScenario returns s along a simulated path: each month at its mean
+// crypto price and its difficulty, and its hourly energy prices grouped
+// into bands of equal share.
+func pathScenario(s scenario.Scenario, simulated path, bands int) scenario.Scenario {
+	s.CryptoPrice = scenario.Path{Values: simulated.cryptoPrices}
+	s.Difficulty = scenario.Path{Values: simulated.difficulties}
+	s.MonthlyPrices = make([][]scenario.PriceBand, len(simulated.energyPrices))
+	for m, prices := range simulated.energyPrices {
+		s.MonthlyPrices[m] = priceBands(prices, bands)
+	}
+	return s
+}
+
+// priceBands groups prices into at most count bands of equal share, each at
+// the mean price of the hours in it.
+func priceBands(prices []float64, count int) []scenario.PriceBand {
+	sorted := append([]float64(nil), prices...)
+	sort.Float64s(sorted)
+	if count > len(sorted) {
+		count = len(sorted)
+	}
+	var bands []scenario.PriceBand
+	for b := 0; b < count; b++ {
+		from := b * len(sorted) / count
+		to := (b + 1) * len(sorted) / count
+		bands = append(bands, scenario.PriceBand{
+			Price: economics.PricePerMWh(mean(sorted[from:to])),
+			Share: float64(to-from) / float64(len(sorted)),
+		})
+	}
+	return bands
+}
@@ -0,0 +1,287 @@
+package risk
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+
+	fleet "profitmax/util/fleet"
+	scenario "profitmax/util/scenario"
+)
+
+func near(got, want, tolerance float64) bool {
+	return math.Abs(got-want) <= tolerance*math.Max(1, math.Abs(want))
+}
+
+func TestCholesky(t *testing.T) {
+	tests := []struct {
+		name string
+		c    Correlations
+		want bool
+	}{
+		{"independent", Correlations{}, true},
+		{"all positive", Correlations{0.5, 0.5, 0.5}, true},
+		{"mixed", Correlations{0.3, -0.2, 0.4}, true},
+		{"above 1", Correlations{CryptoEnergy: 1.2}, false},
+		{"below -1", Correlations{EnergyDifficulty: -1.5}, false},
+		{"perfectly correlated", Correlations{CryptoEnergy: 1}, false},
+		{"inconsistent", Correlations{0.9, 0.9, -0.9}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, ok := tt.c.cholesky()
+			if ok != tt.want {
+				t.Fatalf("cholesky() ok = %v, want %v", ok, tt.want)
+			}
+			if !ok {
+				return
+			}
+			m := [3][3]float64{
+				{1, tt.c.CryptoEnergy, tt.c.CryptoDifficulty},
+				{tt.c.CryptoEnergy, 1, tt.c.EnergyDifficulty},
+				{tt.c.CryptoDifficulty, tt.c.EnergyDifficulty, 1},
+			}
+			for i := 0; i < 3; i++ {
+				for j := 0; j < 3; j++ {
+					var product float64
+					for k := 0; k < 3; k++ {
+						product += l[i][k] * l[j][k]
+					}
+					if !near(product, m[i][j], 1e-12) {
+						t.Errorf("L*Lt[%d][%d] = %v, want %v", i, j, product, m[i][j])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := Config{Paths: 100, Bands: 10, Level: 0.95}
+	tests := []struct {
+		name    string
+		change  func(*Config)
+		wantErr bool
+	}{
+		{"valid", func(c *Config) {}, false},
+		{"no paths", func(c *Config) { c.Paths = 0 }, true},
+		{"no bands", func(c *Config) { c.Bands = 0 }, true},
+		{"level 0", func(c *Config) { c.Level = 0 }, true},
+		{"level 1", func(c *Config) { c.Level = 1 }, true},
+		{"negative volatility", func(c *Config) { c.Energy.Volatility = -1 }, true},
+		{"invalid correlations", func(c *Config) { c.Correlations = Correlations{0.9, 0.9, -0.9} }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := valid
+			tt.change(&config)
+			if err := config.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCalibrateCrypto(t *testing.T) {
+	year := hoursPerYear * time.Hour
+
+	// Doubling every year without deviation
+	model := CalibrateCrypto([]float64{1, 2, 4, 8}, year)
+	if !near(model.Drift, 1, 1e-12) || model.Volatility != 0 {
+		t.Errorf("CalibrateCrypto() of doubling prices = %+v", model)
+	}
+
+	// Log returns of +a and -a have a sample variance of 4a^2/3, and no
+	// mean, so the only drift is the lognormal correction
+	a := 0.1
+	variance := 4 * a * a / 3
+	model = CalibrateCrypto([]float64{1, math.Exp(a), 1, math.Exp(a), 1}, year)
+	if !near(model.Volatility, math.Sqrt(variance), 1e-12) || !near(model.Drift, math.Exp(variance/2)-1, 1e-12) {
+		t.Errorf("CalibrateCrypto() of alternating prices = %+v", model)
+	}
+
+	// Prices that are not positive are skipped, leaving too few returns
+	if model := CalibrateCrypto([]float64{1, 0, 2, -1}, year); model != (CryptoModel{}) {
+		t.Errorf("CalibrateCrypto() of too few returns = %+v", model)
+	}
+}
+
+func TestCalibrateEnergy(t *testing.T) {
+	// Prices close 2% of the gap to 50 every hour, without noise
+	prices := []float64{10}
+	for i := 0; i < 30; i++ {
+		last := prices[len(prices)-1]
+		prices = append(prices, last+0.02*(50-last))
+	}
+	model := CalibrateEnergy(prices, time.Hour)
+	if !near(model.Mean, 50, 1e-9) || !near(model.Reversion, 0.02*24, 1e-9) {
+		t.Errorf("CalibrateEnergy() mean %v and reversion %v, want 50 and 0.48", model.Mean, model.Reversion)
+	}
+	if model.Volatility > 1e-9 || model.SpikesPerDay != 0 {
+		t.Errorf("CalibrateEnergy() of a smooth series = %+v", model)
+	}
+	if model.Start != prices[len(prices)-1] || model.Floor != 10 {
+		t.Errorf("CalibrateEnergy() start %v and floor %v", model.Start, model.Floor)
+	}
+
+	if model := CalibrateEnergy([]float64{50, 60}, time.Hour); model != (EnergyModel{}) {
+		t.Errorf("CalibrateEnergy() of two prices = %+v", model)
+	}
+}
+
+// energyPrices simulates an hourly energy price following model.
+func energyPrices(random *rand.Rand, model EnergyModel, hours int) []float64 {
+	day := 1.0 / 24
+	price := model.Mean
+	prices := make([]float64, hours)
+	for h := range prices {
+		price += model.Reversion*(model.Mean-price)*day + model.Volatility*math.Sqrt(day)*random.NormFloat64()
+		if random.Float64() < model.SpikesPerDay*day {
+			price += model.SpikeMean * random.ExpFloat64()
+		}
+		prices[h] = price
+	}
+	return prices
+}
+
+func TestCalibrateEnergyRoundTrip(t *testing.T) {
+	want := EnergyModel{Mean: 80, Reversion: 2, Volatility: 20, SpikesPerDay: 0.2, SpikeMean: 300}
+	prices := energyPrices(rand.New(rand.NewSource(1)), want, 2*hoursPerYear)
+	got := CalibrateEnergy(prices, time.Hour)
+
+	tests := []struct {
+		name      string
+		got, want float64
+		tolerance float64
+	}{
+		{"mean", got.Mean, want.Mean, 0.02},
+		{"reversion", got.Reversion, want.Reversion, 0.1},
+		{"volatility", got.Volatility, want.Volatility, 0.03},
+		// Spikes too small to stand out from the noise are missed, so fewer
+		// and larger spikes are found
+		{"spikes per day", got.SpikesPerDay, want.SpikesPerDay, 0.1},
+		{"spike mean", got.SpikeMean, want.SpikeMean, 0.1},
+	}
+	for _, tt := range tests {
+		if !near(tt.got, tt.want, tt.tolerance) {
+			t.Errorf("calibrated %s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestCalibrateCryptoRoundTrip(t *testing.T) {
+	want := CryptoModel{Drift: 0.2, Volatility: 0.5}
+	random := rand.New(rand.NewSource(1))
+	hour := 1.0 / hoursPerYear
+	logDrift := math.Log(1+want.Drift) - want.Volatility*want.Volatility/2
+	prices := []float64{60000}
+	for h := 0; h < 10*hoursPerYear; h++ {
+		next := prices[h] * math.Exp(logDrift*hour+want.Volatility*math.Sqrt(hour)*random.NormFloat64())
+		prices = append(prices, next)
+	}
+	got := CalibrateCrypto(prices, time.Hour)
+
+	// The volatility is pinned down by the hourly returns, the drift only
+	// by the ten years they add up to
+	if !near(got.Volatility, want.Volatility, 0.02) {
+		t.Errorf("calibrated volatility = %v, want %v", got.Volatility, want.Volatility)
+	}
+	if math.Abs(got.Drift-want.Drift) > want.Volatility/math.Sqrt(10) {
+		t.Errorf("calibrated drift = %v, want %v", got.Drift, want.Drift)
+	}
+}
+
+func testScenario() scenario.Scenario {
+	return scenario.Scenario{
+		Name:         "base",
+		Start:        time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Months:       3,
+		Currency:     "USD",
+		CryptoPrice:  scenario.Path{Start: 60000},
+		Difficulty:   scenario.Path{Start: 8e13},
+		Subsidy:      3.125,
+		EnergyPrices: []scenario.PriceBand{{Price: 50, Share: 1}},
+		Sites: []fleet.Site{{
+			LocationID: "QLD1",
+			Currency:   "USD",
+			Fleet:      []fleet.MachineGroup{{Model: "S19", Count: 100, HashrateTH: 110, PowerW: 3250}},
+		}},
+	}
+}
+
+func TestSimulateWithoutRisk(t *testing.T) {
+	// Without volatility every path is the scenario at a flat energy price
+	s := testScenario()
+	config := Config{Paths: 5, Seed: 1, Bands: 4, Level: 0.95, Energy: EnergyModel{Mean: 50, Reversion: 1}}
+	report, err := Simulate(s, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	projection, err := scenario.Project(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	profit := float64(projection.Profit)
+	if !near(float64(report.Profit.Mean), profit, 1e-6) || !near(float64(report.Profit.P5), profit, 1e-6) || !near(float64(report.Profit.P95), profit, 1e-6) {
+		t.Errorf("profit = %+v, want %v on every path", report.Profit, profit)
+	}
+	if !near(float64(report.VaR), -profit, 1e-6) || !near(float64(report.CVaR), -profit, 1e-6) {
+		t.Errorf("VaR %v and CVaR %v, want %v", report.VaR, report.CVaR, -profit)
+	}
+	if report.Profit.LossProbability != 0 || report.MonthLossProbability != 0 || len(report.Months) != s.Months {
+		t.Errorf("report = %+v", report)
+	}
+}
+
+func TestSimulateSeeded(t *testing.T) {
+	s := testScenario()
+	config := Config{
+		Paths:        200,
+		Seed:         7,
+		Bands:        10,
+		Level:        0.95,
+		Crypto:       CryptoModel{Volatility: 0.6, JumpsPerYear: 2, JumpMean: -0.05, JumpSD: 0.1},
+		Energy:       EnergyModel{Mean: 50, Reversion: 1, Volatility: 60, SpikesPerDay: 0.1, SpikeMean: 200, Floor: -100},
+		Difficulty:   DifficultyModel{Volatility: 0.05},
+		Correlations: Correlations{CryptoEnergy: 0.2},
+	}
+	report, err := Simulate(s, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := Simulate(s, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(report, again) {
+		t.Error("Simulate() with the same seed drew different paths")
+	}
+	config.Seed = 8
+	other, err := Simulate(s, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.VaR == report.VaR {
+		t.Error("Simulate() with another seed drew the same paths")
+	}
+
+	// VaR at 95% is the loss at the 5th percentile, and CVaR the mean loss
+	// beyond it
+	if !near(float64(report.VaR), float64(-report.Profit.P5), 1e-12) {
+		t.Errorf("VaR = %v, want %v", report.VaR, -report.Profit.P5)
+	}
+	if report.CVaR < report.VaR {
+		t.Errorf("CVaR %v below VaR %v", report.CVaR, report.VaR)
+	}
+	if report.Profit.P5 > report.Profit.P50 || report.Profit.P50 > report.Profit.P95 {
+		t.Errorf("profit percentiles out of order: %+v", report.Profit)
+	}
+	if report.Profit.P5 == report.Profit.P95 {
+		t.Error("simulated profits do not vary")
+	}
+	if report.MonthLossProbability < 0 || report.MonthLossProbability > 1 || len(report.Months) != s.Months {
+		t.Errorf("report = %+v", report)
+	}
+}
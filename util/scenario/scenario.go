@@ -0,0 +1,300 @@
+package scenario
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	calculator "profitmax/util/calculator"
+	costmodel "profitmax/util/costmodel"
+	decision "profitmax/util/decision"
+	economics "profitmax/util/economics"
+	fleet "profitmax/util/fleet"
+	fx "profitmax/util/fx"
+)
+
+// halvingInterval is the time between halvings: 210,000 blocks.
+const halvingInterval = 210000 * economics.BlockInterval
+
+// Path is a value month by month: Values for the months they cover, then
+// growing by MonthlyGrowth a month from the last of them, or from Start when
+// there are none.
+type Path struct {
+	Start         float64   `json:"start"`
+	MonthlyGrowth float64   `json:"monthly_growth"`
+	Values        []float64 `json:"values"`
+}
+
+// At returns the value in month m, counting from 0.
+func (p Path) At(m int) float64 {
+	if m < len(p.Values) {
+		return p.Values[m]
+	}
+	base, from := p.Start, 0
+	if len(p.Values) > 0 {
+		base, from = p.Values[len(p.Values)-1], len(p.Values)-1
+	}
+	return base * math.Pow(1+p.MonthlyGrowth, float64(m-from))
+}
+
+// PriceBand is one part of the energy price distribution: the site pays
+// Price for Share of the hours.
+type PriceBand struct {
+	Price economics.PricePerMWh `json:"price"`
+	Share float64               `json:"share"`
+}
+
+// Scenario is a set of assumptions to project a fleet's profitability
+// under, in Currency. Subsidy halves on HalvingDate and every 210,000 blocks
+// after it; Fees are earned on every block. With Curtail set, the fleet
+// stops in price bands where its margin falls below MarginThreshold, as the
+// decision maker would. MonthlyEnergyPrices, where given, replaces the
+// energy price distribution month by month. Rates convert cost items in
+// other currencies.
+type Scenario struct {
+	Name            string           `json:"name"`
+	Start           time.Time        `json:"start"`
+	Months          int              `json:"months"`
+	Currency        string           `json:"currency"`
+	CryptoPrice     Path             `json:"crypto_price"`
+	Difficulty      Path             `json:"difficulty"`
+	Subsidy         economics.Coins  `json:"subsidy"`
+	Fees            economics.Coins  `json:"fees"`
+	HalvingDate     time.Time        `json:"halving_date"`
+	EnergyPrices    []PriceBand      `json:"energy_prices"`
+	MonthlyPrices   [][]PriceBand    `json:"monthly_energy_prices"`
+	Curtail         bool             `json:"curtail"`
+	MarginThreshold float64          `json:"margin_threshold"`
+	Sites           []fleet.Site     `json:"sites"`
+	CostItems       []costmodel.Item `json:"cost_items"`
+	Rates           []fx.Rate        `json:"rates"`
+}
+
+// Reward returns the block reward at t.
+func (s Scenario) Reward(t time.Time) economics.Coins {
+	subsidy := s.Subsidy
+	if !s.HalvingDate.IsZero() && !t.Before(s.HalvingDate) {
+		halvings := 1 + int(t.Sub(s.HalvingDate)/halvingInterval)
+		subsidy /= economics.Coins(math.Pow(2, float64(halvings)))
+	}
+	return subsidy + s.Fees
+}
+
+// Bands returns the energy price distribution of month m.
+func (s Scenario) Bands(m int) []PriceBand {
+	if m < len(s.MonthlyPrices) {
+		return s.MonthlyPrices[m]
+	}
+	return s.EnergyPrices
+}
+
+// Validate reports a scenario that cannot be projected.
+func (s Scenario) Validate() error {
+	if s.Months <= 0 || s.Start.IsZero() || s.Currency == "" {
+		return fmt.Errorf("scenario %s: start, months and currency are required", s.Name)
+	}
+	for m := 0; m < s.Months; m++ {
+		var share float64
+		for _, band := range s.Bands(m) {
+			share += band.Share
+		}
+		if math.Abs(share-1) > 1e-6 {
+			return fmt.Errorf("scenario %s: month %d energy price shares add up to %g, not 1", s.Name, m, share)
+		}
+	}
+	for _, site := range s.Sites {
+		if len(site.Fleet) == 0 {
+			return fmt.Errorf("scenario %s: site %s has no fleet", s.Name, site.LocationID)
+		}
+	}
+	for _, item := range s.CostItems {
+		err := item.Validate()
+		if err != nil {
+			return fmt.Errorf("scenario %s: %v", s.Name, err)
+		}
+	}
+	return nil
+}
+
+// Month is the fleet's projection for one calendar month. EnergyPrice is
+// the mean of the price distribution and RunShare the share of hours the
+// fleet ran. OtherCost includes capex depreciation; CashFlow instead charges
+// capex when it is bought, and Cumulative sums CashFlow to date.
+type Month struct {
+	Month       string                `json:"month"`
+	CryptoPrice economics.Amount      `json:"crypto_price"`
+	Difficulty  float64               `json:"difficulty"`
+	Reward      economics.Coins       `json:"reward"`
+	EnergyPrice economics.PricePerMWh `json:"energy_price"`
+	RunShare    float64               `json:"run_share"`
+	EnergyMWh   float64               `json:"energy_mwh"`
+	Coins       economics.Coins       `json:"coins"`
+	Revenue     economics.Amount      `json:"revenue"`
+	EnergyCost  economics.Amount      `json:"energy_cost"`
+	OtherCost   economics.Amount      `json:"other_cost"`
+	Profit      economics.Amount      `json:"profit"`
+	CashFlow    economics.Amount      `json:"cash_flow"`
+	Cumulative  economics.Amount      `json:"cumulative"`
+}
+
+// Projection is a scenario's monthly projection. PaybackMonth is the first
+// month cumulative cash flow stops being negative, or empty when it does not
+// within the scenario.
+type Projection struct {
+	Scenario     string           `json:"scenario"`
+	Currency     string           `json:"currency"`
+	Months       []Month          `json:"months"`
+	Revenue      economics.Amount `json:"revenue"`
+	Cost         economics.Amount `json:"cost"`
+	Profit       economics.Amount `json:"profit"`
+	Investment   economics.Amount `json:"investment"`
+	PaybackMonth string           `json:"payback_month,omitempty"`
+}
+
+// Project projects a scenario month by month. Each day is priced per block
+// with the live calculators in every energy price band, at the month's
+// crypto price and difficulty and the day's reward.
+func Project(s Scenario) (Projection, error) {
+	err := s.Validate()
+	if err != nil {
+		return Projection{}, err
+	}
+	converter := fx.NewConverter()
+	for _, rate := range s.Rates {
+		converter.Set(rate)
+	}
+	items := make(map[string][]costmodel.Item)
+	for _, item := range s.CostItems {
+		items[item.LocationID] = append(items[item.LocationID], item)
+	}
+
+	projection := Projection{Scenario: s.Name, Currency: s.Currency}
+	var cumulative economics.Amount
+	for m := 0; m < s.Months; m++ {
+		from := s.Start.AddDate(0, m, 0)
+		to := s.Start.AddDate(0, m+1, 0)
+		month := Month{
+			Month:       from.Format("2006-01"),
+			CryptoPrice: economics.Amount(s.CryptoPrice.At(m)),
+			Difficulty:  s.Difficulty.At(m),
+			Reward:      s.Reward(from),
+		}
+		bands := s.Bands(m)
+		for _, band := range bands {
+			month.EnergyPrice += band.Price * economics.PricePerMWh(band.Share)
+		}
+
+		var hours, runHours float64
+		for _, site := range s.Sites {
+			siteItems := items[site.LocationID]
+			var energy economics.Energy
+			for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+				length := day.AddDate(0, 0, 1).Sub(day)
+				reward := s.Reward(day)
+				other, err := blockCost(site, siteItems, day, s.Currency, converter)
+				if err != nil {
+					return Projection{}, err
+				}
+				for _, band := range bands {
+					period := time.Duration(float64(length) * band.Share)
+					hours += period.Hours()
+					if !runs(s, site, month, reward, band.Price, other) {
+						continue
+					}
+					runHours += period.Hours()
+					coins := economics.ExpectedCoins(site.Hashrate(), month.Difficulty, reward, period)
+					month.Coins += coins
+					month.Revenue += economics.CoinValue(coins, month.CryptoPrice)
+					month.EnergyCost += economics.EnergyCost(site.Power(), period, band.Price)
+					energy += economics.EnergyUsed(site.Power(), period)
+				}
+			}
+			month.EnergyMWh += energy.MWh()
+
+			// Hosting is charged on the whole fleet, variable costs on the
+			// energy actually used
+			usage := costmodel.Usage{Energy: energy, Hashrate: site.Hashrate()}
+			costs, err := costmodel.PeriodCosts(siteItems, from, to.Sub(from), usage, s.Currency, converter)
+			if err != nil {
+				return Projection{}, err
+			}
+			for _, cost := range costs {
+				month.OtherCost += cost
+			}
+			spent, depreciation, err := capex(siteItems, from, to, m == 0, usage, s.Currency, converter)
+			if err != nil {
+				return Projection{}, err
+			}
+			month.CashFlow -= spent - depreciation
+			projection.Investment += spent
+		}
+		if hours > 0 {
+			month.RunShare = runHours / hours
+		}
+		month.Profit = economics.Profit(month.Revenue, economics.Sum(month.EnergyCost, month.OtherCost))
+		month.CashFlow += month.Profit
+		cumulative += month.CashFlow
+		month.Cumulative = cumulative
+		if projection.PaybackMonth == "" && projection.Investment > 0 && cumulative >= 0 {
+			projection.PaybackMonth = month.Month
+		}
+
+		projection.Months = append(projection.Months, month)
+		projection.Revenue += month.Revenue
+		projection.Cost += month.EnergyCost + month.OtherCost
+		projection.Profit += month.Profit
+	}
+	return projection, nil
+}
+
+// blockCost returns a site's non-energy cost of one block interval at t, as
+// the mining cost calculator costs it.
+func blockCost(site fleet.Site, items []costmodel.Item, t time.Time, currency string, converter *fx.Converter) (economics.Amount, error) {
+	costs, err := costmodel.PeriodCosts(items, t, economics.BlockInterval, calculator.Usage(site, economics.BlockInterval), currency, converter)
+	if err != nil {
+		return 0, err
+	}
+	var total economics.Amount
+	for _, cost := range costs {
+		total += cost
+	}
+	return total, nil
+}
+
+// runs reports whether a site runs through a price band: always, unless
+// curtailing and its margin over one block, with other the block's
+// non-energy cost, falls below the threshold.
+func runs(s Scenario, site fleet.Site, month Month, reward economics.Coins, price economics.PricePerMWh, other economics.Amount) bool {
+	if !s.Curtail {
+		return true
+	}
+	cost := economics.Sum(calculator.EnergyCost(site, month.Difficulty, economics.DefaultEfficiency, price), other)
+	incentive := calculator.Incentive(site, month.Difficulty, reward, month.CryptoPrice)
+	return decision.Margin(incentive, cost) >= s.MarginThreshold
+}
+
+// capex returns what a site's capex items cost in cash between from and to,
+// counting items bought before the scenario in its first month, and the
+// depreciation OtherCost charged on them.
+func capex(items []costmodel.Item, from time.Time, to time.Time, first bool, usage costmodel.Usage, currency string, converter *fx.Converter) (economics.Amount, economics.Amount, error) {
+	var spent, depreciation economics.Amount
+	for _, item := range items {
+		if item.Type != costmodel.Capex {
+			continue
+		}
+		cost, err := fx.Convert(converter, item.PeriodCost(from, to.Sub(from), usage), item.Currency, currency)
+		if err != nil {
+			return 0, 0, err
+		}
+		depreciation += cost
+		bought := !item.PurchaseDate.Before(from) || first
+		if bought && item.PurchaseDate.Before(to) {
+			amount, err := fx.Convert(converter, economics.Amount(item.Amount), item.Currency, currency)
+			if err != nil {
+				return 0, 0, err
+			}
+			spent += amount
+		}
+	}
+	return spent, depreciation, nil
+}
@@ -0,0 +1,227 @@
+package scenario
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	costmodel "profitmax/util/costmodel"
+	economics "profitmax/util/economics"
+	fleet "profitmax/util/fleet"
+	fx "profitmax/util/fx"
+)
+
+func near(got, want float64) bool {
+	if want == 0 {
+		return math.Abs(got) < 1e-9
+	}
+	return math.Abs(got-want) <= 1e-9*math.Abs(want)
+}
+
+var start = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+var site = fleet.Site{
+	LocationID: "QLD1",
+	Currency:   "USD",
+	Fleet:      []fleet.MachineGroup{{Model: "S19", Count: 100, HashrateTH: 110, PowerW: 3250}},
+}
+
+func base() Scenario {
+	return Scenario{
+		Name:         "base",
+		Start:        start,
+		Months:       2,
+		Currency:     "USD",
+		CryptoPrice:  Path{Start: 60000},
+		Difficulty:   Path{Start: 8e13},
+		Subsidy:      3.125,
+		EnergyPrices: []PriceBand{{Price: 50, Share: 1}},
+		Sites:        []fleet.Site{site},
+	}
+}
+
+func TestPathAt(t *testing.T) {
+	tests := []struct {
+		name string
+		path Path
+		m    int
+		want float64
+	}{
+		{"flat", Path{Start: 100}, 5, 100},
+		{"growing from start", Path{Start: 100, MonthlyGrowth: 0.1}, 2, 121},
+		{"first month", Path{Start: 100, MonthlyGrowth: 0.1}, 0, 100},
+		{"given value", Path{Start: 100, Values: []float64{50, 60}}, 1, 60},
+		{"growing from the last value", Path{Start: 100, MonthlyGrowth: 0.5, Values: []float64{50, 60}}, 3, 135},
+		{"falling", Path{Start: 100, MonthlyGrowth: -0.5}, 2, 25},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.path.At(tt.m); !near(got, tt.want) {
+				t.Errorf("At(%d) = %v, want %v", tt.m, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReward(t *testing.T) {
+	s := Scenario{Subsidy: 3.125, Fees: 0.5, HalvingDate: start}
+	tests := []struct {
+		name string
+		at   time.Time
+		want economics.Coins
+	}{
+		{"before the halving", start.Add(-time.Hour), 3.125 + 0.5},
+		{"at the halving", start, 1.5625 + 0.5},
+		{"before the next halving", start.Add(halvingInterval - time.Hour), 1.5625 + 0.5},
+		{"at the next halving", start.Add(halvingInterval), 0.78125 + 0.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.Reward(tt.at); got != tt.want {
+				t.Errorf("Reward() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if got := (Scenario{Subsidy: 3.125}).Reward(start); got != 3.125 {
+		t.Errorf("Reward() without a halving date = %v, want 3.125", got)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(*Scenario)
+		wantErr bool
+	}{
+		{"valid", func(s *Scenario) {}, false},
+		{"no months", func(s *Scenario) { s.Months = 0 }, true},
+		{"no start", func(s *Scenario) { s.Start = time.Time{} }, true},
+		{"no currency", func(s *Scenario) { s.Currency = "" }, true},
+		{"shares short of 1", func(s *Scenario) { s.EnergyPrices = []PriceBand{{Price: 50, Share: 0.9}} }, true},
+		{"monthly shares short of 1", func(s *Scenario) { s.MonthlyPrices = [][]PriceBand{{{Price: 50, Share: 0.5}}} }, true},
+		{"site without a fleet", func(s *Scenario) { s.Sites = []fleet.Site{{LocationID: "QLD1"}} }, true},
+		{"invalid cost item", func(s *Scenario) { s.CostItems = []costmodel.Item{{Type: costmodel.Fixed}} }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := base()
+			tt.change(&s)
+			if err := s.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProject(t *testing.T) {
+	s := base()
+	s.CryptoPrice = Path{Start: 60000, MonthlyGrowth: 0.1}
+	s.CostItems = []costmodel.Item{{LocationID: "QLD1", CostCode: "RENT", Type: costmodel.Fixed, Currency: "AUD", Amount: 1500}}
+	s.Rates = []fx.Rate{{Base: "USD", Quote: "AUD", Rate: 1.5}}
+
+	projection, err := Project(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(projection.Months) != 2 {
+		t.Fatalf("Project() returned %d months, want 2", len(projection.Months))
+	}
+
+	// The same formulas over the whole month give the same answer as day by
+	// day
+	var profit economics.Amount
+	for m, month := range projection.Months {
+		from := start.AddDate(0, m, 0)
+		length := from.AddDate(0, 1, 0).Sub(from)
+		price := economics.Amount(60000 * math.Pow(1.1, float64(m)))
+		coins := economics.ExpectedCoins(site.Hashrate(), 8e13, 3.125, length)
+		revenue := economics.CoinValue(coins, price)
+		energyCost := economics.EnergyCost(site.Power(), length, 50)
+		otherCost := economics.Amount(1000 * float64(length) / float64(365.2425/12*24*float64(time.Hour)))
+		want := revenue - energyCost - otherCost
+		profit += want
+
+		if month.Month != from.Format("2006-01") || month.RunShare != 1 || float64(month.CryptoPrice) != float64(price) {
+			t.Errorf("month %d = %+v", m, month)
+		}
+		if !near(float64(month.Coins), float64(coins)) || !near(float64(month.Revenue), float64(revenue)) {
+			t.Errorf("month %d coins %v and revenue %v, want %v and %v", m, month.Coins, month.Revenue, coins, revenue)
+		}
+		if !near(float64(month.EnergyCost), float64(energyCost)) || !near(month.EnergyMWh, economics.EnergyUsed(site.Power(), length).MWh()) {
+			t.Errorf("month %d energy cost %v over %v MWh, want %v", m, month.EnergyCost, month.EnergyMWh, energyCost)
+		}
+		if !near(float64(month.OtherCost), float64(otherCost)) || !near(float64(month.Profit), float64(want)) {
+			t.Errorf("month %d other cost %v and profit %v, want %v and %v", m, month.OtherCost, month.Profit, otherCost, want)
+		}
+	}
+	if !near(float64(projection.Profit), float64(profit)) || projection.PaybackMonth != "" || projection.Investment != 0 {
+		t.Errorf("projection = %+v", projection)
+	}
+}
+
+func TestProjectCurtails(t *testing.T) {
+	s := base()
+	s.Months = 1
+	s.EnergyPrices = []PriceBand{{Price: 20, Share: 0.75}, {Price: 500, Share: 0.25}}
+
+	running, err := Project(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Curtail = true
+	curtailed, err := Project(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the expensive hours are lost, and with them their loss
+	r, c := running.Months[0], curtailed.Months[0]
+	if r.RunShare != 1 || !near(c.RunShare, 0.75) {
+		t.Errorf("run shares %v and %v, want 1 and 0.75", r.RunShare, c.RunShare)
+	}
+	if !near(c.EnergyMWh, 0.75*r.EnergyMWh) || !near(float64(c.Revenue), 0.75*float64(r.Revenue)) {
+		t.Errorf("curtailed month = %+v, running month = %+v", c, r)
+	}
+	if !near(float64(r.EnergyPrice), 20*0.75+500*0.25) || c.Profit <= r.Profit {
+		t.Errorf("curtailed profit %v, running profit %v", c.Profit, r.Profit)
+	}
+}
+
+func TestProjectPayback(t *testing.T) {
+	s := base()
+	s.Months = 12
+	item := costmodel.Item{LocationID: "QLD1", CostCode: "MINERS", Type: costmodel.Capex, Currency: "USD", Amount: 10000,
+		LifetimeMonths: 24, PurchaseDate: start.AddDate(0, 1, 0)}
+	s.CostItems = []costmodel.Item{item}
+
+	projection, err := Project(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if projection.Investment != 10000 {
+		t.Errorf("investment = %v, want 10000", projection.Investment)
+	}
+
+	// Cash flow is the profit before depreciation, less the purchase in the
+	// month it is bought
+	var cumulative economics.Amount
+	payback := ""
+	for m, month := range projection.Months {
+		from := start.AddDate(0, m, 0)
+		cashFlow := month.Profit + item.PeriodCost(from, from.AddDate(0, 1, 0).Sub(from), costmodel.Usage{})
+		if m == 1 {
+			cashFlow -= 10000
+		}
+		cumulative += cashFlow
+		if !near(float64(month.CashFlow), float64(cashFlow)) || !near(float64(month.Cumulative), float64(cumulative)) {
+			t.Errorf("month %d cash flow %v to %v, want %v to %v", m, month.CashFlow, month.Cumulative, cashFlow, cumulative)
+		}
+		if payback == "" && m >= 1 && cumulative >= 0 {
+			payback = month.Month
+		}
+	}
+	if payback == "" || projection.PaybackMonth != payback {
+		t.Errorf("payback month = %q, want %q", projection.PaybackMonth, payback)
+	}
+}
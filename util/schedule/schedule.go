@@ -0,0 +1,357 @@
+package schedule
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	decision "profitmax/util/decision"
+	economics "profitmax/util/economics"
+)
+
+// Config configures the run scheduler: a schedule of Intervals intervals,
+// IntervalMinutes each, priced on the forward energy price curve of the
+// forecast model Curve, or of whichever model forecast last when Curve is
+// empty. Levels is how finely the run fraction is stepped.
+type Config struct {
+	IntervalMinutes int    `json:"interval_minutes"`
+	Intervals       int    `json:"intervals"`
+	Curve           string `json:"curve"`
+	Levels          int    `json:"levels"`
+
+	Constraints Constraints `json:"constraints"`
+}
+
+// Interval returns the length of one schedule interval.
+func (c Config) Interval() time.Duration {
+	return time.Duration(c.IntervalMinutes) * time.Minute
+}
+
+// Constraints limit how a site's fleet may run. A site is up while any of
+// its fleet runs, and starts each time it comes up. MaxStartsPerDay of 0 and RampPerInterval, the largest
+// change in run fraction between intervals, of 0 are unlimited.
+// MinConsumptionMW is the contracted minimum draw in every interval.
+type Constraints struct {
+	MinUpMinutes     int     `json:"min_up_minutes"`
+	MinDownMinutes   int     `json:"min_down_minutes"`
+	MaxStartsPerDay  int     `json:"max_starts_per_day"`
+	RampPerInterval  float64 `json:"ramp_per_interval"`
+	MinConsumptionMW float64 `json:"min_consumption_mw"`
+}
+
+// Slot is what the schedule runs in one interval. Groups says how many units
+// of each model run; the most efficient models are filled first.
+type Slot struct {
+	Start       time.Time             `json:"start"`
+	EnergyPrice economics.PricePerMWh `json:"energy_price"`
+	RunFraction float64               `json:"run_fraction"`
+	PowerMW     float64               `json:"power_mw"`
+	HashrateTH  float64               `json:"hashrate_th"`
+	Revenue     economics.Amount      `json:"revenue"`
+	EnergyCost  economics.Amount      `json:"energy_cost"`
+	Profit      economics.Amount      `json:"profit"`
+	Groups      []GroupRun            `json:"groups"`
+}
+
+// GroupRun is how many units of a machine model run in a slot.
+type GroupRun struct {
+	Model    string `json:"model"`
+	Units    int    `json:"units"`
+	RunUnits int    `json:"run_units"`
+}
+
+// Schedule is the profit-maximising run schedule of a site, in the site's
+// currency.
+type Schedule struct {
+	LocationID      string           `json:"location_id"`
+	Currency        string           `json:"currency"`
+	Hashprice       economics.Amount `json:"hashprice"`
+	IntervalMinutes int              `json:"interval_minutes"`
+	Profit          economics.Amount `json:"profit"`
+	Starts          int              `json:"starts"`
+	Slots           []Slot           `json:"slots"`
+	Issued          time.Time        `json:"issued"`
+}
+
+// level is the fleet running at one step of run fraction.
+type level struct {
+	fraction float64
+	power    economics.Power
+	hashrate economics.Hashrate
+	groups   []GroupRun
+}
+
+// levels fills each step of run fraction, a share of the fleet's power,
+// with the most efficient machines first.
+func levels(classes []decision.MachineClass, steps int) []level {
+	order := make([]int, len(classes))
+	var totalPower economics.Power
+	for i, class := range classes {
+		order[i] = i
+		totalPower += class.Power * economics.Power(class.Units)
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return classes[order[a]].Efficiency() < classes[order[b]].Efficiency()
+	})
+
+	result := make([]level, steps+1)
+	for k := range result {
+		l := level{fraction: float64(k) / float64(steps), groups: make([]GroupRun, len(classes))}
+		budget := totalPower * economics.Power(l.fraction)
+		for i, class := range classes {
+			l.groups[i] = GroupRun{Model: class.Model, Units: class.Units}
+		}
+		for _, i := range order {
+			class := classes[i]
+			if class.Power <= 0 {
+				continue
+			}
+			units := int(math.Floor(float64(budget/class.Power) + 1e-9))
+			if units > class.Units {
+				units = class.Units
+			}
+			l.groups[i].RunUnits = units
+			l.power += class.Power * economics.Power(units)
+			l.hashrate += class.Hashrate * economics.Hashrate(units)
+			budget -= class.Power * economics.Power(units)
+		}
+		result[k] = l
+	}
+	return result
+}
+
+// Current is the state a site is in when its schedule starts. RunFraction is
+// the share of its fleet running, Since when it last came up or went down,
+// zero when unknown, and Starts the times it has come up recently.
+type Current struct {
+	RunFraction float64     `json:"run_fraction"`
+	Since       time.Time   `json:"since"`
+	Starts      []time.Time `json:"starts,omitempty"`
+}
+
+// Solve finds the run schedule over prices, one per interval from start,
+// that earns the most at hashprice, per TH/s per day, within constraints.
+// It is solved by dynamic programming over the run fraction, stepped into
+// steps levels, how long the site has been up or down and how many times it
+// has started that calendar day. The schedule moves away from current within
+// the constraints; a nil current leaves the site free to start at any level.
+func Solve(classes []decision.MachineClass, hashprice economics.Amount, prices []economics.PricePerMWh, start time.Time, interval time.Duration, steps int, constraints Constraints, current *Current) (Schedule, error) {
+	if len(prices) == 0 {
+		return Schedule{}, fmt.Errorf("no prices to schedule against")
+	}
+	if steps < 1 || interval <= 0 {
+		return Schedule{}, fmt.Errorf("levels and interval must be positive")
+	}
+	fleet := levels(classes, steps)
+	minutes := int(interval / time.Minute)
+	minUp := ceilSteps(constraints.MinUpMinutes, minutes)
+	minDown := ceilSteps(constraints.MinDownMinutes, minutes)
+
+	// Dwell is capped where the minimum durations stop mattering, but never
+	// below the one interval a new level has lasted
+	longest := 1
+	if minUp > longest {
+		longest = minUp
+	}
+	if minDown > longest {
+		longest = minDown
+	}
+
+	// Starts are only counted when they are limited, and from nought again
+	// in the first interval of each calendar day
+	maxStarts := 0
+	if constraints.MaxStartsPerDay > 0 {
+		maxStarts = constraints.MaxStartsPerDay
+	}
+	newDay := make([]bool, len(prices))
+	for i := 1; i < len(prices); i++ {
+		newDay[i] = !sameDay(start.Add(time.Duration(i-1)*interval), start.Add(time.Duration(i)*interval))
+	}
+
+	// Profit of each level in each interval, or -Inf where it draws less
+	// than the contracted minimum
+	minPower := economics.Power(constraints.MinConsumptionMW) * economics.Megawatt
+	dayShare := economics.Amount(interval.Hours() / 24)
+	profit := make([][]float64, len(prices))
+	for i, price := range prices {
+		profit[i] = make([]float64, len(fleet))
+		for k, l := range fleet {
+			if l.power < minPower-1e-6 {
+				profit[i][k] = math.Inf(-1)
+				continue
+			}
+			revenue := hashprice * economics.Amount(l.hashrate.TH()) * dayShare
+			cost := economics.EnergyCost(l.power, interval, price)
+			profit[i][k] = float64(revenue - cost)
+		}
+	}
+
+	// best[i] holds, for each level, dwell and start count, the most the
+	// intervals up to i can earn ending there; parent the state before it
+	dwells := longest + 1
+	starts := maxStarts + 1
+	size := len(fleet) * dwells * starts
+	index := func(k, d, s int) int { return (k*dwells+d)*starts + s }
+	best := make([][]float64, len(prices))
+	parent := make([][]int32, len(prices))
+	for i := range best {
+		best[i] = make([]float64, size)
+		parent[i] = make([]int32, size)
+		for j := range best[i] {
+			best[i][j] = math.Inf(-1)
+			parent[i][j] = -1
+		}
+	}
+
+	// move returns the state of interval i at level next, reached from a
+	// run fraction, up or down for d intervals after s starts that day, and
+	// false when the constraints rule the move out
+	move := func(i int, fraction float64, up bool, d, s, next int) (int, bool) {
+		if math.IsInf(profit[i][next], -1) {
+			return 0, false
+		}
+		if constraints.RampPerInterval > 0 && math.Abs(fleet[next].fraction-fraction) > constraints.RampPerInterval+1e-9 {
+			return 0, false
+		}
+		nextUp := fleet[next].power > 0
+		nextDwell, nextStarts := d+1, s
+		if nextDwell > longest {
+			nextDwell = longest
+		}
+		if newDay[i] {
+			nextStarts = 0
+		}
+		if nextUp != up {
+			if (up && d < minUp) || (!up && d < minDown) {
+				return 0, false
+			}
+			nextDwell = 1
+			if nextUp && maxStarts > 0 {
+				nextStarts++
+				if nextStarts > maxStarts {
+					return 0, false
+				}
+			}
+		}
+		return index(next, nextDwell, nextStarts), true
+	}
+
+	if current == nil {
+		for k := range fleet {
+			best[0][index(k, longest, 0)] = profit[0][k]
+		}
+	} else {
+		dwell := longest
+		if !current.Since.IsZero() {
+			held := int(start.Sub(current.Since) / interval)
+			if held < 0 {
+				held = 0
+			}
+			if held < dwell {
+				dwell = held
+			}
+		}
+		started := 0
+		for _, at := range current.Starts {
+			if sameDay(at, start) && started < maxStarts {
+				started++
+			}
+		}
+		for k := range fleet {
+			if j, ok := move(0, current.RunFraction, current.RunFraction > 0, dwell, started, k); ok {
+				best[0][j] = profit[0][k]
+			}
+		}
+	}
+
+	for i := 1; i < len(prices); i++ {
+		for k := range fleet {
+			for d := 0; d < dwells; d++ {
+				for s := 0; s < starts; s++ {
+					from := best[i-1][index(k, d, s)]
+					if math.IsInf(from, -1) {
+						continue
+					}
+					for next := range fleet {
+						j, ok := move(i, fleet[k].fraction, fleet[k].power > 0, d, s, next)
+						if !ok {
+							continue
+						}
+						value := from + profit[i][next]
+						if value > best[i][j] {
+							best[i][j] = value
+							parent[i][j] = int32(index(k, d, s))
+						}
+					}
+				}
+			}
+		}
+	}
+
+	last := len(prices) - 1
+	end := -1
+	for j, value := range best[last] {
+		if !math.IsInf(value, -1) && (end < 0 || value > best[last][end]) {
+			end = j
+		}
+	}
+	if end < 0 {
+		return Schedule{}, fmt.Errorf("no schedule meets the constraints")
+	}
+
+	// Walk the parents back to the first interval
+	path := make([]int, len(prices))
+	for i, j := last, end; i >= 0; i-- {
+		path[i] = j
+		j = int(parent[i][j])
+	}
+
+	result := Schedule{
+		Hashprice:       hashprice,
+		IntervalMinutes: minutes,
+	}
+	for i, j := range path {
+		l := fleet[j/(dwells*starts)]
+		wasUp := true
+		if i > 0 {
+			wasUp = result.Slots[i-1].PowerMW > 0
+		} else if current != nil {
+			wasUp = current.RunFraction > 0
+		}
+		if l.power > 0 && !wasUp {
+			result.Starts++
+		}
+		revenue := hashprice * economics.Amount(l.hashrate.TH()) * dayShare
+		cost := economics.EnergyCost(l.power, interval, prices[i])
+		result.Slots = append(result.Slots, Slot{
+			Start:       start.Add(time.Duration(i) * interval),
+			EnergyPrice: prices[i],
+			RunFraction: l.fraction,
+			PowerMW:     float64(l.power / economics.Megawatt),
+			HashrateTH:  l.hashrate.TH(),
+			Revenue:     revenue,
+			EnergyCost:  cost,
+			Profit:      economics.Profit(revenue, cost),
+			Groups:      l.groups,
+		})
+		result.Profit += economics.Profit(revenue, cost)
+	}
+	return result, nil
+}
+
+// ceilSteps returns how many intervals of step minutes cover minutes.
+func ceilSteps(minutes int, step int) int {
+	if minutes <= 0 || step <= 0 {
+		return 0
+	}
+	return (minutes + step - 1) / step
+}
+
+// sameDay reports whether a and b fall on the same calendar day in a's
+// location.
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.In(a.Location()).Date()
+	return ay == by && am == bm && ad == bd
+}
@@ -0,0 +1,179 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	decision "profitmax/util/decision"
+	economics "profitmax/util/economics"
+)
+
+// One class of 10 machines drawing 100 kW each, 1 MW in all, at 1000 TH/s
+// and a hashprice of 24 per TH/s per day: 500 per 30-minute interval, or an
+// energy price of 1000 per MWh to break even.
+var classes = []decision.MachineClass{{
+	Model:    "M",
+	Units:    10,
+	Hashrate: 100 * economics.TerahashPerSecond,
+	Power:    100 * economics.Kilowatt,
+}}
+
+const (
+	cheap  = economics.PricePerMWh(100)
+	costly = economics.PricePerMWh(5000)
+)
+
+func fractions(s Schedule) []float64 {
+	result := make([]float64, len(s.Slots))
+	for i, slot := range s.Slots {
+		result[i] = slot.RunFraction
+	}
+	return result
+}
+
+func equal(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSolve(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	late := time.Date(2024, 1, 1, 22, 0, 0, 0, time.UTC)
+	interval := 30 * time.Minute
+
+	tests := []struct {
+		name        string
+		start       time.Time
+		prices      []economics.PricePerMWh
+		steps       int
+		constraints Constraints
+		current     *Current
+		want        []float64
+		wantStarts  int
+	}{
+		{
+			name:   "zero dwell",
+			start:  start,
+			prices: []economics.PricePerMWh{cheap, costly, cheap, costly},
+			steps:  1,
+			want:   []float64{1, 0, 1, 0},
+			// Without a current state the first interval is not a start
+			wantStarts: 1,
+		},
+		{
+			name:        "minimum up time",
+			start:       start,
+			prices:      []economics.PricePerMWh{cheap, 1500, costly, costly},
+			steps:       1,
+			constraints: Constraints{MinUpMinutes: 60},
+			current:     &Current{},
+			want:        []float64{1, 1, 0, 0},
+			wantStarts:  1,
+		},
+		{
+			name:        "starts limited over the horizon's one day",
+			start:       start,
+			prices:      []economics.PricePerMWh{costly, cheap, costly, cheap, costly, cheap},
+			steps:       1,
+			constraints: Constraints{MaxStartsPerDay: 2},
+			want:        []float64{0, 1, 0, 1, 0, 0},
+			wantStarts:  2,
+		},
+		{
+			name:        "starts limit resets at midnight",
+			start:       late,
+			prices:      []economics.PricePerMWh{costly, cheap, costly, cheap, costly, cheap, costly, cheap, costly, cheap},
+			steps:       1,
+			constraints: Constraints{MaxStartsPerDay: 1},
+			// 22:00 to 23:30 allows one start, from midnight one more
+			want:       []float64{0, 1, 0, 0, 0, 1, 0, 0, 0, 0},
+			wantStarts: 2,
+		},
+		{
+			name:        "starts already made today count",
+			start:       start.Add(6 * time.Hour),
+			prices:      []economics.PricePerMWh{cheap, cheap},
+			steps:       1,
+			constraints: Constraints{MaxStartsPerDay: 1},
+			current:     &Current{Starts: []time.Time{start.Add(time.Hour)}},
+			want:        []float64{0, 0},
+		},
+		{
+			name:        "starts from yesterday do not count",
+			start:       start.Add(6 * time.Hour),
+			prices:      []economics.PricePerMWh{cheap, cheap},
+			steps:       1,
+			constraints: Constraints{MaxStartsPerDay: 1},
+			current:     &Current{Starts: []time.Time{start.Add(-time.Hour)}},
+			want:        []float64{1, 1},
+			wantStarts:  1,
+		},
+		{
+			name:        "ramps up from the current run state",
+			start:       start,
+			prices:      []economics.PricePerMWh{cheap, cheap, cheap},
+			steps:       4,
+			constraints: Constraints{RampPerInterval: 0.25},
+			current:     &Current{RunFraction: 0.25},
+			want:        []float64{0.5, 0.75, 1},
+		},
+		{
+			name:        "ramps down from the current run state",
+			start:       start,
+			prices:      []economics.PricePerMWh{costly, costly},
+			steps:       2,
+			constraints: Constraints{RampPerInterval: 0.5},
+			current:     &Current{RunFraction: 1},
+			want:        []float64{0.5, 0},
+		},
+		{
+			name:        "holds the current minimum down time",
+			start:       start,
+			prices:      []economics.PricePerMWh{cheap, cheap, cheap},
+			steps:       1,
+			constraints: Constraints{MinDownMinutes: 60},
+			current:     &Current{Since: start.Add(-30 * time.Minute)},
+			want:        []float64{0, 1, 1},
+			wantStarts:  1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Solve(classes, 24, tt.prices, tt.start, interval, tt.steps, tt.constraints, tt.current)
+			if err != nil {
+				t.Fatalf("Solve() error = %v", err)
+			}
+			if got := fractions(result); !equal(got, tt.want) {
+				t.Errorf("run fractions = %v, want %v", got, tt.want)
+			}
+			if result.Starts != tt.wantStarts {
+				t.Errorf("Starts = %d, want %d", result.Starts, tt.wantStarts)
+			}
+		})
+	}
+}
+
+func TestSolveErrors(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	prices := []economics.PricePerMWh{cheap}
+	if _, err := Solve(classes, 24, nil, start, time.Hour, 1, Constraints{}, nil); err == nil {
+		t.Error("Solve() without prices succeeded")
+	}
+	if _, err := Solve(classes, 24, prices, start, 0, 1, Constraints{}, nil); err == nil {
+		t.Error("Solve() with a zero interval succeeded")
+	}
+	if _, err := Solve(classes, 24, prices, start, time.Hour, 0, Constraints{}, nil); err == nil {
+		t.Error("Solve() with no levels succeeded")
+	}
+	impossible := Constraints{MinConsumptionMW: 2}
+	if _, err := Solve(classes, 24, prices, start, time.Hour, 1, impossible, nil); err == nil {
+		t.Error("Solve() above the fleet's power succeeded")
+	}
+}
@@ -0,0 +1,17 @@
+package schedule
+
+import "database/sql"
+
+// Save stores every slot of s in tbl_run_schedule.
+func Save(db *sql.DB, s Schedule) error {
+	insertData := `INSERT INTO tbl_run_schedule (location_id, issued, start_time, currency_code, energy_price, run_fraction, power_mw, hashrate_th, revenue, energy_cost, profit)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE currency_code = VALUES(currency_code), energy_price = VALUES(energy_price), run_fraction = VALUES(run_fraction), power_mw = VALUES(power_mw), hashrate_th = VALUES(hashrate_th), revenue = VALUES(revenue), energy_cost = VALUES(energy_cost), profit = VALUES(profit)`
+	for _, slot := range s.Slots {
+		_, err := db.Exec(insertData, s.LocationID, s.Issued, slot.Start, s.Currency, slot.EnergyPrice, slot.RunFraction, slot.PowerMW, slot.HashrateTH, slot.Revenue, slot.EnergyCost, slot.Profit)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package sensitivity
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	costmodel "profitmax/util/costmodel"
+	decision "profitmax/util/decision"
+	economics "profitmax/util/economics"
+	fleet "profitmax/util/fleet"
+	fx "profitmax/util/fx"
+)
+
+// Drivers of a site's profit.
+const (
+	CryptoPrice = "CRYPTO_PRICE"
+	Difficulty  = "DIFFICULTY"
+	EnergyPrice = "ENERGY_PRICE"
+	Fees        = "FEES"
+	Efficiency  = "EFFICIENCY"
+)
+
+// Drivers lists every driver in the order they are reported.
+var Drivers = []string{CryptoPrice, Difficulty, EnergyPrice, Fees, Efficiency}
+
+// Point is the operating point a site's profit is taken at, with prices in
+// the site's currency. Efficiency is the fleet's, in J/TH.
+type Point struct {
+	LocationID  string                `json:"location_id"`
+	Currency    string                `json:"currency"`
+	Time        time.Time             `json:"time"`
+	CryptoPrice economics.Amount      `json:"crypto_price"`
+	Difficulty  float64               `json:"difficulty"`
+	Subsidy     economics.Coins       `json:"subsidy"`
+	Fees        economics.Coins       `json:"fees"`
+	EnergyPrice economics.PricePerMWh `json:"energy_price"`
+	Efficiency  economics.Efficiency  `json:"efficiency"`
+}
+
+// Shift returns the point with driver changed by change, a fraction such as
+// -0.2 for 20% lower.
+func (p Point) Shift(driver string, change float64) (Point, error) {
+	switch driver {
+	case CryptoPrice:
+		p.CryptoPrice *= economics.Amount(1 + change)
+	case Difficulty:
+		p.Difficulty *= 1 + change
+	case EnergyPrice:
+		p.EnergyPrice *= economics.PricePerMWh(1 + change)
+	case Fees:
+		p.Fees *= economics.Coins(1 + change)
+	case Efficiency:
+		p.Efficiency *= economics.Efficiency(1 + change)
+	default:
+		return p, fmt.Errorf("unknown driver %s", driver)
+	}
+	return p, nil
+}
+
+// Model prices a site over Period. With Curtail set, the site stops when its
+// margin falls below MarginThreshold, as the decision maker would, and then
+// pays only the costs charged whether it runs or not.
+type Model struct {
+	Site            fleet.Site
+	Items           []costmodel.Item
+	Converter       *fx.Converter
+	Period          time.Duration
+	Curtail         bool
+	MarginThreshold float64
+}
+
+// Profit is what a site makes over the model's period at a point.
+type Profit struct {
+	Running    bool             `json:"running"`
+	Margin     float64          `json:"margin"`
+	Revenue    economics.Amount `json:"revenue"`
+	EnergyCost economics.Amount `json:"energy_cost"`
+	OtherCost  economics.Amount `json:"other_cost"`
+	Profit     economics.Amount `json:"profit"`
+}
+
+// At prices the site at a point.
+func (m Model) At(p Point) (Profit, error) {
+	hashrate := m.Site.Hashrate()
+	power := economics.PowerFor(hashrate, p.Efficiency)
+	revenue := economics.Revenue(hashrate, p.Difficulty, p.Subsidy+p.Fees, p.CryptoPrice, m.Period)
+	energyCost := economics.EnergyCost(power, m.Period, p.EnergyPrice)
+	usage := costmodel.Usage{Energy: economics.EnergyUsed(power, m.Period), Hashrate: hashrate}
+	other, err := m.otherCost(p, usage)
+	if err != nil {
+		return Profit{}, err
+	}
+
+	result := Profit{
+		Running:    true,
+		Margin:     decision.Margin(revenue, economics.Sum(energyCost, other)),
+		Revenue:    revenue,
+		EnergyCost: energyCost,
+		OtherCost:  other,
+	}
+	if m.Curtail && result.Margin < m.MarginThreshold {
+		idle, err := m.otherCost(p, costmodel.Usage{})
+		if err != nil {
+			return Profit{}, err
+		}
+		result = Profit{Margin: result.Margin, OtherCost: idle}
+	}
+	result.Profit = economics.Profit(result.Revenue, economics.Sum(result.EnergyCost, result.OtherCost))
+	return result, nil
+}
+
+func (m Model) otherCost(p Point, usage costmodel.Usage) (economics.Amount, error) {
+	costs, err := costmodel.PeriodCosts(m.Items, p.Time, m.Period, usage, p.Currency, m.Converter)
+	if err != nil {
+		return 0, err
+	}
+	var total economics.Amount
+	for _, cost := range costs {
+		total += cost
+	}
+	return total, nil
+}
+
+// Sensitivity is how a site's profit responds to one driver. Delta is the
+// change in profit for a 1% rise in the driver, and Elasticity the
+// percentage change in profit for it, or zero when the profit is zero. Low
+// and High are the profits at the bottom and top of the range, and Swing
+// the distance between them.
+type Sensitivity struct {
+	Driver     string           `json:"driver"`
+	Delta      economics.Amount `json:"delta"`
+	Elasticity float64          `json:"elasticity"`
+	Low        economics.Amount `json:"low"`
+	High       economics.Amount `json:"high"`
+	Swing      economics.Amount `json:"swing"`
+}
+
+// Report is a site's profit at a point and its sensitivity to every driver
+// over a range either side of the point, largest swing first as a tornado
+// chart draws them.
+type Report struct {
+	Point         Point         `json:"point"`
+	Period        string        `json:"period"`
+	Range         float64       `json:"range"`
+	Base          Profit        `json:"base"`
+	Sensitivities []Sensitivity `json:"sensitivities"`
+}
+
+// Analyse reports a site's sensitivity at a point, moving each driver on its
+// own by rangeFraction either way, such as 0.2 for 20%. Deltas are central
+// differences a percent either side of the point, so they stay the same
+// however the range is set.
+func Analyse(m Model, p Point, rangeFraction float64) (Report, error) {
+	if m.Period <= 0 {
+		return Report{}, fmt.Errorf("period must be positive")
+	}
+	if rangeFraction <= 0 || rangeFraction >= 1 {
+		return Report{}, fmt.Errorf("range %g is not between 0 and 1", rangeFraction)
+	}
+	base, err := m.At(p)
+	if err != nil {
+		return Report{}, err
+	}
+	report := Report{Point: p, Period: m.Period.String(), Range: rangeFraction, Base: base}
+	for _, driver := range Drivers {
+		var profits [4]economics.Amount
+		for i, change := range []float64{-0.01, 0.01, -rangeFraction, rangeFraction} {
+			shifted, err := p.Shift(driver, change)
+			if err != nil {
+				return Report{}, err
+			}
+			profit, err := m.At(shifted)
+			if err != nil {
+				return Report{}, err
+			}
+			profits[i] = profit.Profit
+		}
+		s := Sensitivity{
+			Driver: driver,
+			Delta:  (profits[1] - profits[0]) / 2,
+			Low:    profits[2],
+			High:   profits[3],
+			Swing:  economics.Amount(math.Abs(float64(profits[3] - profits[2]))),
+		}
+		if base.Profit != 0 {
+			s.Elasticity = float64(s.Delta) / math.Abs(float64(base.Profit)) * 100
+		}
+		report.Sensitivities = append(report.Sensitivities, s)
+	}
+	sort.SliceStable(report.Sensitivities, func(i, j int) bool {
+		return report.Sensitivities[i].Swing > report.Sensitivities[j].Swing
+	})
+	return report, nil
+}
@@ -0,0 +1,169 @@
+package sensitivity
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	costmodel "profitmax/util/costmodel"
+	economics "profitmax/util/economics"
+	fleet "profitmax/util/fleet"
+	fx "profitmax/util/fx"
+)
+
+func near(got, want float64) bool {
+	if want == 0 {
+		return math.Abs(got) < 1e-9
+	}
+	return math.Abs(got-want) <= 1e-9*math.Abs(want)
+}
+
+var point = Point{
+	LocationID:  "QLD1",
+	Currency:    "USD",
+	Time:        time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	CryptoPrice: 60000,
+	Difficulty:  8e13,
+	Subsidy:     3.125,
+	Fees:        0.25,
+	EnergyPrice: 50,
+	Efficiency:  30,
+}
+
+func model() Model {
+	return Model{
+		Site: fleet.Site{
+			LocationID: "QLD1",
+			Currency:   "USD",
+			Fleet:      []fleet.MachineGroup{{Model: "S19", Count: 100, HashrateTH: 110, PowerW: 3300}},
+		},
+		Converter: fx.NewConverter(),
+		Period:    24 * time.Hour,
+	}
+}
+
+func TestShift(t *testing.T) {
+	tests := []struct {
+		driver string
+		got    func(Point) float64
+		want   float64
+	}{
+		{CryptoPrice, func(p Point) float64 { return float64(p.CryptoPrice) }, 48000},
+		{Difficulty, func(p Point) float64 { return p.Difficulty }, 6.4e13},
+		{EnergyPrice, func(p Point) float64 { return float64(p.EnergyPrice) }, 40},
+		{Fees, func(p Point) float64 { return float64(p.Fees) }, 0.2},
+		{Efficiency, func(p Point) float64 { return float64(p.Efficiency) }, 24},
+	}
+	for _, tt := range tests {
+		t.Run(tt.driver, func(t *testing.T) {
+			shifted, err := point.Shift(tt.driver, -0.2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !near(tt.got(shifted), tt.want) {
+				t.Errorf("Shift() = %v, want %v", tt.got(shifted), tt.want)
+			}
+			if shifted.Subsidy != point.Subsidy {
+				t.Errorf("Shift() changed the subsidy to %v", shifted.Subsidy)
+			}
+		})
+	}
+	if _, err := point.Shift("WEATHER", 0.1); err == nil {
+		t.Error("Shift() of an unknown driver succeeded")
+	}
+}
+
+func TestAt(t *testing.T) {
+	m := model()
+	m.Items = []costmodel.Item{
+		{CostCode: "RENT", Type: costmodel.Fixed, Currency: "USD", Amount: 3000},
+		{CostCode: "GRID", Type: costmodel.Variable, Currency: "USD", Amount: 4},
+	}
+	hashrate := m.Site.Hashrate()
+	power := economics.PowerFor(hashrate, point.Efficiency)
+	revenue := economics.Revenue(hashrate, point.Difficulty, point.Subsidy+point.Fees, point.CryptoPrice, m.Period)
+	energyCost := economics.EnergyCost(power, m.Period, point.EnergyPrice)
+	rent := 3000 * float64(m.Period) / (365.2425 / 12 * 24 * float64(time.Hour))
+	other := economics.Amount(rent + 4*economics.EnergyUsed(power, m.Period).MWh())
+
+	profit, err := m.At(point)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !profit.Running || !near(float64(profit.Revenue), float64(revenue)) || !near(float64(profit.EnergyCost), float64(energyCost)) || !near(float64(profit.OtherCost), float64(other)) {
+		t.Errorf("At() = %+v, want revenue %v, energy cost %v and other cost %v", profit, revenue, energyCost, other)
+	}
+	if !near(float64(profit.Profit), float64(revenue-energyCost-other)) {
+		t.Errorf("At() profit = %v, want %v", profit.Profit, revenue-energyCost-other)
+	}
+
+	// Curtailed at a price it cannot cover, the site pays only the rent
+	m.Curtail = true
+	expensive := point
+	expensive.EnergyPrice = 5000
+	profit, err = m.At(expensive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profit.Running || profit.Revenue != 0 || profit.EnergyCost != 0 || !near(float64(profit.Profit), -rent) || profit.Margin >= 0 {
+		t.Errorf("curtailed At() = %+v, want a loss of the rent %v", profit, rent)
+	}
+
+	// A cost item in a currency without a rate cannot be priced
+	m.Items = append(m.Items, costmodel.Item{CostCode: "HOSTING", Type: costmodel.Hosting, Currency: "EUR", Amount: 1})
+	if _, err := m.At(point); err == nil {
+		t.Error("At() without an fx rate succeeded")
+	}
+}
+
+func TestAnalyse(t *testing.T) {
+	m := model()
+	report, err := Analyse(m, point, 0.2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without cost items or curtailment profit is revenue, which is
+	// proportional to the price and reward and inversely to the difficulty,
+	// less energy cost, which is proportional to the price and efficiency
+	revenue, energyCost := float64(report.Base.Revenue), float64(report.Base.EnergyCost)
+	base := revenue - energyCost
+	fees := revenue * float64(point.Fees/(point.Subsidy+point.Fees))
+	want := map[string]Sensitivity{
+		CryptoPrice: {Delta: economics.Amount(0.01 * revenue), Low: economics.Amount(base - 0.2*revenue), High: economics.Amount(base + 0.2*revenue)},
+		Difficulty: {Delta: economics.Amount((revenue/1.01 - revenue/0.99) / 2),
+			Low: economics.Amount(revenue/0.8 - energyCost), High: economics.Amount(revenue/1.2 - energyCost)},
+		EnergyPrice: {Delta: economics.Amount(-0.01 * energyCost), Low: economics.Amount(base + 0.2*energyCost), High: economics.Amount(base - 0.2*energyCost)},
+		Fees:        {Delta: economics.Amount(0.01 * fees), Low: economics.Amount(base - 0.2*fees), High: economics.Amount(base + 0.2*fees)},
+		Efficiency:  {Delta: economics.Amount(-0.01 * energyCost), Low: economics.Amount(base + 0.2*energyCost), High: economics.Amount(base - 0.2*energyCost)},
+	}
+
+	if len(report.Sensitivities) != len(Drivers) || report.Range != 0.2 || report.Period != "24h0m0s" || !near(float64(report.Base.Profit), base) {
+		t.Fatalf("Analyse() = %+v", report)
+	}
+	for i, s := range report.Sensitivities {
+		w := want[s.Driver]
+		if !near(float64(s.Delta), float64(w.Delta)) || !near(float64(s.Low), float64(w.Low)) || !near(float64(s.High), float64(w.High)) {
+			t.Errorf("%s = %+v, want %+v", s.Driver, s, w)
+		}
+		if !near(float64(s.Swing), math.Abs(float64(w.High-w.Low))) || !near(s.Elasticity, float64(w.Delta)/math.Abs(base)*100) {
+			t.Errorf("%s swing %v and elasticity %v", s.Driver, s.Swing, s.Elasticity)
+		}
+		if i > 0 && s.Swing > report.Sensitivities[i-1].Swing {
+			t.Errorf("%s swings more than %s before it", s.Driver, report.Sensitivities[i-1].Driver)
+		}
+	}
+}
+
+func TestAnalyseInvalid(t *testing.T) {
+	m := model()
+	for _, rangeFraction := range []float64{0, 1, -0.2} {
+		if _, err := Analyse(m, point, rangeFraction); err == nil {
+			t.Errorf("Analyse() over a range of %v succeeded", rangeFraction)
+		}
+	}
+	m.Period = 0
+	if _, err := Analyse(m, point, 0.2); err == nil {
+		t.Error("Analyse() over no period succeeded")
+	}
+}
@@ -0,0 +1,67 @@
+package sensitivity
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	economics "profitmax/util/economics"
+	fleet "profitmax/util/fleet"
+	fx "profitmax/util/fx"
+)
+
+// feeBlocks is how many recent blocks fees are averaged over, about a day.
+const feeBlocks = 144
+
+// LoadPoint reads a site's current operating point: the latest price of
+// symbol, its chain's subsidy and difficulty, the fees of recent blocks over
+// the subsidy, and the site's current energy price, converted into the
+// site's currency. The site must have a fleet.
+func LoadPoint(db *sql.DB, symbol string, site fleet.Site, converter *fx.Converter, now time.Time) (Point, error) {
+	if site.Hashrate() <= 0 {
+		return Point{}, fmt.Errorf("site %s has no fleet", site.LocationID)
+	}
+	point := Point{
+		LocationID: site.LocationID,
+		Currency:   site.Currency,
+		Time:       now,
+		Efficiency: economics.Efficiency(float64(site.Power()) / site.Hashrate().TH()),
+	}
+
+	var price float64
+	var currency string
+	err := db.QueryRow("SELECT price, currency_code FROM tbl_crypto_price_tick WHERE symbol=? ORDER BY timestamp DESC, id DESC LIMIT 1", symbol).Scan(&price, &currency)
+	if err != nil {
+		return Point{}, fmt.Errorf("crypto price of %s: %v", symbol, err)
+	}
+	point.CryptoPrice, err = fx.Convert(converter, economics.Amount(price), currency, site.Currency)
+	if err != nil {
+		return Point{}, err
+	}
+
+	var subsidy float64
+	err = db.QueryRow("SELECT subsidy, difficulty FROM tbl_blockchain_info WHERE blockchain=?", symbol).Scan(&subsidy, &point.Difficulty)
+	if err != nil {
+		return Point{}, fmt.Errorf("blockchain info of %s: %v", symbol, err)
+	}
+	point.Subsidy = economics.Coins(subsidy)
+
+	var reward sql.NullFloat64
+	err = db.QueryRow("SELECT AVG(reward) FROM (SELECT reward FROM tbl_block_info WHERE blockchain=? ORDER BY block_height DESC LIMIT ?) recent", symbol, feeBlocks).Scan(&reward)
+	if err != nil {
+		return Point{}, fmt.Errorf("block rewards of %s: %v", symbol, err)
+	}
+	if reward.Valid && economics.Coins(reward.Float64) > point.Subsidy {
+		point.Fees = economics.Coins(reward.Float64) - point.Subsidy
+	}
+
+	err = db.QueryRow("SELECT price, currency_code FROM tbl_energy_price_current WHERE location_id=? ORDER BY last_updated DESC LIMIT 1", site.LocationID).Scan(&price, &currency)
+	if err != nil {
+		return Point{}, fmt.Errorf("energy price at %s: %v", site.LocationID, err)
+	}
+	point.EnergyPrice, err = fx.Convert(converter, economics.PricePerMWh(price), currency, site.Currency)
+	if err != nil {
+		return Point{}, err
+	}
+	return point, nil
+}
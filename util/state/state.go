@@ -0,0 +1,69 @@
+package state
+
+import (
+	"database/sql"
+	"encoding/json"
+)
+
+// Statuses of a service's output.
+const (
+	// WarmingUp output is missing at least one input and must not be acted on.
+	WarmingUp = "WARMING_UP"
+	// Ready output was computed from every input.
+	Ready = "READY"
+)
+
+// Of returns WarmingUp while any input is missing and Ready after.
+func Of(missing []string) string {
+	if len(missing) > 0 {
+		return WarmingUp
+	}
+	return Ready
+}
+
+// Save snapshots value, as JSON, as the state of service under key in
+// tbl_service_state.
+func Save(db *sql.DB, service string, key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	insertData := "INSERT INTO tbl_service_state (service, state_key, state, last_updated) VALUES (?, ?, ?, now()) ON DUPLICATE KEY UPDATE state = ?, last_updated = now()"
+	_, err = db.Exec(insertData, service, key, string(data), string(data))
+	return err
+}
+
+// Load restores the state of service under key into value. It reports
+// whether there was any.
+func Load(db *sql.DB, service string, key string, value interface{}) (bool, error) {
+	var data string
+	err := db.QueryRow("SELECT state FROM tbl_service_state WHERE service=? AND state_key=?", service, key).Scan(&data)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, json.Unmarshal([]byte(data), value)
+}
+
+// Lacks reports whether input is among missing.
+func Lacks(missing []string, input string) bool {
+	for _, name := range missing {
+		if name == input {
+			return true
+		}
+	}
+	return false
+}
+
+// Known returns missing without input, once input has been received.
+func Known(missing []string, input string) []string {
+	var still []string
+	for _, name := range missing {
+		if name != input {
+			still = append(still, name)
+		}
+	}
+	return still
+}
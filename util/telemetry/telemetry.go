@@ -0,0 +1,148 @@
+package telemetry
+
+import (
+	"time"
+
+	cgminer "profitmax/util/cgminer"
+	fleet "profitmax/util/fleet"
+)
+
+// Sample is one poll of one miner. A miner that did not answer is reported
+// offline with the error and no readings.
+type Sample struct {
+	LocationID     string    `json:"location_id"`
+	DeviceID       string    `json:"device_id"`
+	Model          string    `json:"model"`
+	Online         bool      `json:"online"`
+	Error          string    `json:"error,omitempty"`
+	HashrateTH     float64   `json:"hashrate_th"`
+	Accepted       int64     `json:"accepted"`
+	Rejected       int64     `json:"rejected"`
+	HardwareErrors int64     `json:"hardware_errors"`
+	PowerW         float64   `json:"power_w"`
+	ChipTemps      []float64 `json:"chip_temps"`
+	ChipTempMax    float64   `json:"chip_temp_max"`
+	FanSpeeds      []float64 `json:"fan_speeds"`
+	Elapsed        int64     `json:"elapsed"`
+	Timestamp      time.Time `json:"timestamp"`
+}
+
+// ModelSummary is what the hashing miners of one model measured on average,
+// per unit. PowerW is 0 when none of them report power.
+type ModelSummary struct {
+	Model      string  `json:"model"`
+	Devices    int     `json:"devices"`
+	Hashing    int     `json:"hashing"`
+	HashrateTH float64 `json:"hashrate_th"`
+	PowerW     float64 `json:"power_w"`
+}
+
+// SiteSummary totals one poll of a site's miners.
+type SiteSummary struct {
+	LocationID string         `json:"location_id"`
+	Devices    int            `json:"devices"`
+	Online     int            `json:"online"`
+	Hashing    int            `json:"hashing"`
+	HashrateTH float64        `json:"hashrate_th"`
+	PowerW     float64        `json:"power_w"`
+	Models     []ModelSummary `json:"models"`
+	Timestamp  time.Time      `json:"timestamp"`
+}
+
+// NewSample builds a sample from a miner's summary and stats.
+func NewSample(site fleet.Site, device fleet.Device, summary cgminer.Summary, stats []map[string]interface{}, now time.Time) Sample {
+	sample := Sample{
+		LocationID:     site.LocationID,
+		DeviceID:       device.ID,
+		Model:          device.Model,
+		Online:         true,
+		HashrateTH:     summary.MHS5s / 1e6,
+		Accepted:       summary.Accepted,
+		Rejected:       summary.Rejected,
+		HardwareErrors: summary.HardwareErrors,
+		PowerW:         summary.Power,
+		ChipTemps:      cgminer.ChipTemperatures(stats),
+		FanSpeeds:      cgminer.FanSpeeds(stats),
+		Elapsed:        summary.Elapsed,
+		Timestamp:      now,
+	}
+	for _, temp := range sample.ChipTemps {
+		if temp > sample.ChipTempMax {
+			sample.ChipTempMax = temp
+		}
+	}
+	return sample
+}
+
+// Summarise totals the samples of one poll of a site.
+func Summarise(locationID string, samples []Sample, now time.Time) SiteSummary {
+	summary := SiteSummary{LocationID: locationID, Timestamp: now}
+
+	models := make(map[string]*ModelSummary)
+	var order []string
+	powered := make(map[string]int)
+	for _, sample := range samples {
+		model, ok := models[sample.Model]
+		if !ok {
+			model = &ModelSummary{Model: sample.Model}
+			models[sample.Model] = model
+			order = append(order, sample.Model)
+		}
+		summary.Devices++
+		model.Devices++
+		if !sample.Online {
+			continue
+		}
+		summary.Online++
+		summary.HashrateTH += sample.HashrateTH
+		summary.PowerW += sample.PowerW
+		if sample.HashrateTH <= 0 {
+			continue
+		}
+		summary.Hashing++
+		model.Hashing++
+		model.HashrateTH += sample.HashrateTH
+		if sample.PowerW > 0 {
+			model.PowerW += sample.PowerW
+			powered[sample.Model]++
+		}
+	}
+
+	for _, name := range order {
+		model := models[name]
+		if model.Hashing > 0 {
+			model.HashrateTH /= float64(model.Hashing)
+		}
+		if powered[name] > 0 {
+			model.PowerW /= float64(powered[name])
+		}
+		summary.Models = append(summary.Models, *model)
+	}
+	return summary
+}
+
+// Apply returns site with the nameplate hashrate and power of each machine
+// group replaced by what its hashing miners measured, as long as summary is
+// no older than maxAge. Measurements are per hashing unit, so a curtailed
+// fleet keeps the economics it would have when running.
+func Apply(site fleet.Site, summary SiteSummary, maxAge time.Duration, now time.Time) fleet.Site {
+	if summary.LocationID != site.LocationID || now.Sub(summary.Timestamp) > maxAge {
+		return site
+	}
+
+	fleet := make([]fleet.MachineGroup, len(site.Fleet))
+	copy(fleet, site.Fleet)
+	for i, group := range fleet {
+		for _, model := range summary.Models {
+			if model.Model != group.Model || model.Hashing == 0 {
+				continue
+			}
+			fleet[i].HashrateTH = model.HashrateTH
+			if model.PowerW > 0 {
+				fleet[i].PowerW = model.PowerW
+			}
+		}
+	}
+	site.Fleet = fleet
+	return site
+}
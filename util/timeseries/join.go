@@ -0,0 +1,60 @@
+package timeseries
+
+import "time"
+
+// AsOf samples s at times, oldest first: each sample is the value of the
+// last point at or before its time, or missing before the first point or
+// when that point is more than maxStale old. Zero maxStale takes points of
+// any age.
+func AsOf(s Series, times []time.Time, maxStale time.Duration) Series {
+	samples := make(Series, len(times))
+	next := 0
+	for i, t := range times {
+		for next < len(s) && !s[next].Time.After(t) {
+			next++
+		}
+		samples[i] = Point{Time: t, Value: Missing}
+		if next == 0 {
+			continue
+		}
+		last := s[next-1]
+		if maxStale > 0 && t.Sub(last.Time) > maxStale {
+			continue
+		}
+		samples[i].Value = last.Value
+	}
+	return samples
+}
+
+// Pair is the values of two series at one time.
+type Pair struct {
+	Time  time.Time `json:"time"`
+	Left  float64   `json:"left"`
+	Right float64   `json:"right"`
+}
+
+// AsOfJoin pairs every point of left with the value of right as of its
+// time, no more than maxStale old. Points of left without a value on both
+// sides are dropped. Joining on exact times instead would drop nearly every
+// point of two series sampled independently.
+func AsOfJoin(left Series, right Series, maxStale time.Duration) []Pair {
+	matched := AsOf(right, left.Times(), maxStale)
+	var pairs []Pair
+	for i, p := range left {
+		if IsMissing(p.Value) || IsMissing(matched[i].Value) {
+			continue
+		}
+		pairs = append(pairs, Pair{Time: p.Time, Left: p.Value, Right: matched[i].Value})
+	}
+	return pairs
+}
+
+// Split returns the left and right values of pairs.
+func Split(pairs []Pair) ([]float64, []float64) {
+	left := make([]float64, len(pairs))
+	right := make([]float64, len(pairs))
+	for i, pair := range pairs {
+		left[i], right[i] = pair.Left, pair.Right
+	}
+	return left, right
+}
@@ -0,0 +1,159 @@
+package timeseries
+
+import (
+	"fmt"
+	"time"
+)
+
+// Ways Resample reduces the points of an interval to one value.
+const (
+	Last         = "LAST"
+	Mean         = "MEAN"
+	TimeWeighted = "TIME_WEIGHTED"
+)
+
+// Resample reduces s to one point every step from from up to to, each
+// stamped at the start of its interval and taken from the points in it:
+// the last of them, their mean, or the mean over time of the value in force,
+// which counts a value carried in from before the interval and weighs every
+// value by how long it held. An interval with nothing to take is missing.
+func Resample(s Series, from time.Time, to time.Time, step time.Duration, method string) (Series, error) {
+	if step <= 0 {
+		return nil, fmt.Errorf("step must be positive")
+	}
+	switch method {
+	case Last, Mean, TimeWeighted:
+	default:
+		return nil, fmt.Errorf("unknown resampling method %s", method)
+	}
+
+	var resampled Series
+	next := s.search(from)
+	current, known := Missing, false
+	if next > 0 {
+		current, known = s[next-1].Value, !IsMissing(s[next-1].Value)
+	}
+	for start := from; start.Before(to); start = start.Add(step) {
+		end := start.Add(step)
+		value := Missing
+		var total, weight float64
+		var count int
+		held := start
+		for next < len(s) && s[next].Time.Before(end) {
+			p := s[next]
+			next++
+			if IsMissing(p.Value) {
+				continue
+			}
+			switch method {
+			case Last:
+				value = p.Value
+			case Mean:
+				total += p.Value
+				count++
+			case TimeWeighted:
+				if known {
+					seconds := p.Time.Sub(held).Seconds()
+					total += current * seconds
+					weight += seconds
+				}
+				held = p.Time
+			}
+			current, known = p.Value, true
+		}
+		switch method {
+		case Mean:
+			if count > 0 {
+				value = total / float64(count)
+			}
+		case TimeWeighted:
+			if known {
+				seconds := end.Sub(held).Seconds()
+				total += current * seconds
+				weight += seconds
+			}
+			if weight > 0 {
+				value = total / weight
+			}
+		}
+		resampled = append(resampled, Point{Time: start, Value: value})
+	}
+	return resampled, nil
+}
+
+// Bar summarises the points of one interval starting at Start. Count is how
+// many points fell in it.
+type Bar struct {
+	Start time.Time `json:"start"`
+	Open  float64   `json:"open"`
+	High  float64   `json:"high"`
+	Low   float64   `json:"low"`
+	Close float64   `json:"close"`
+	Count int       `json:"count"`
+}
+
+// OHLC buckets the points of s into bars step long, aligned to step, from
+// the first point to the last. An interval without points repeats the
+// previous close so the bars stay one step apart. Missing points are
+// skipped.
+func OHLC(s Series, step time.Duration) ([]Bar, error) {
+	if step <= 0 {
+		return nil, fmt.Errorf("step must be positive")
+	}
+	var bars []Bar
+	for _, p := range s {
+		if IsMissing(p.Value) {
+			continue
+		}
+		start := p.Time.Truncate(step)
+		if len(bars) > 0 {
+			last := &bars[len(bars)-1]
+			if start.Equal(last.Start) {
+				if p.Value > last.High {
+					last.High = p.Value
+				}
+				if p.Value < last.Low {
+					last.Low = p.Value
+				}
+				last.Close = p.Value
+				last.Count++
+				continue
+			}
+			for gap := last.Start.Add(step); gap.Before(start); gap = gap.Add(step) {
+				price := bars[len(bars)-1].Close
+				bars = append(bars, Bar{Start: gap, Open: price, High: price, Low: price, Close: price})
+			}
+		}
+		bars = append(bars, Bar{Start: start, Open: p.Value, High: p.Value, Low: p.Value, Close: p.Value, Count: 1})
+	}
+	return bars, nil
+}
+
+// Closes returns the close of every bar.
+func Closes(bars []Bar) []float64 {
+	closes := make([]float64, len(bars))
+	for i, bar := range bars {
+		closes[i] = bar.Close
+	}
+	return closes
+}
+
+// FillForward returns s with every missing value replaced by the last value
+// before it, unless that value is more than maxStale older; zero maxStale
+// carries values forward indefinitely.
+func FillForward(s Series, maxStale time.Duration) Series {
+	filled := make(Series, len(s))
+	var last Point
+	known := false
+	for i, p := range s {
+		filled[i] = p
+		if !IsMissing(p.Value) {
+			last, known = p, true
+			continue
+		}
+		if known && (maxStale <= 0 || p.Time.Sub(last.Time) <= maxStale) {
+			filled[i].Value = last.Value
+		}
+	}
+	return filled
+}
@@ -0,0 +1,191 @@
+package timeseries
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// window tracks the present points of s within a trailing window: after
+// advance(i), points from..i with values are those after s[i].Time-length.
+type window struct {
+	s      Series
+	length time.Duration
+	from   int
+}
+
+// advance moves the window to end at point i, calling add for point i and
+// remove for every point that falls out, each only when present.
+func (w *window) advance(i int, add func(float64), remove func(float64)) {
+	if !IsMissing(w.s[i].Value) {
+		add(w.s[i].Value)
+	}
+	start := w.s[i].Time.Add(-w.length)
+	for w.from <= i && !w.s[w.from].Time.After(start) {
+		if !IsMissing(w.s[w.from].Value) {
+			remove(w.s[w.from].Value)
+		}
+		w.from++
+	}
+}
+
+// moments returns the mean of the values in the window ending at point i,
+// and the sum of their squared deviations from it, computed afresh.
+func (w *window) moments(i int) (float64, float64) {
+	var sum float64
+	var count int
+	for _, p := range w.s[w.from : i+1] {
+		if !IsMissing(p.Value) {
+			sum += p.Value
+			count++
+		}
+	}
+	if count == 0 {
+		return 0, 0
+	}
+	mean := sum / float64(count)
+	var squares float64
+	for _, p := range w.s[w.from : i+1] {
+		if !IsMissing(p.Value) {
+			squares += (p.Value - mean) * (p.Value - mean)
+		}
+	}
+	return mean, squares
+}
+
+// RollingMean returns, at every point, the mean of the values in the window
+// length long ending at it, including it. A window without values is
+// missing.
+func RollingMean(s Series, length time.Duration) Series {
+	w := window{s: s, length: length}
+	var sum float64
+	var count int
+	add := func(v float64) { sum += v; count++ }
+	remove := func(v float64) { sum -= v; count-- }
+
+	rolled := make(Series, len(s))
+	for i := range s {
+		w.advance(i, add, remove)
+		rolled[i] = Point{Time: s[i].Time, Value: Missing}
+		if count > 0 {
+			rolled[i].Value = sum / float64(count)
+		}
+	}
+	return rolled
+}
+
+// RollingStd returns, at every point, the sample standard deviation of the
+// values in the window length long ending at it. A window with fewer than
+// two values is missing. The mean and the sum of squared deviations from it
+// are updated as values enter and leave, as in Welford's method, which keeps
+// their precision on large values. When values that far outweighed the rest
+// leave, the sum has cancelled down past its precision and is recomputed
+// from the window.
+func RollingStd(s Series, length time.Duration) Series {
+	w := window{s: s, length: length}
+	var mean, squares, peak float64
+	var count int
+	add := func(v float64) {
+		count++
+		d := v - mean
+		mean += d / float64(count)
+		squares += d * (v - mean)
+	}
+	remove := func(v float64) {
+		count--
+		if count == 0 {
+			mean, squares = 0, 0
+			return
+		}
+		d := v - mean
+		mean -= d / float64(count)
+		squares -= d * (v - mean)
+	}
+
+	rolled := make(Series, len(s))
+	for i := range s {
+		w.advance(i, add, remove)
+		if squares < peak*1e-6 {
+			mean, squares = w.moments(i)
+			peak = squares
+		}
+		if squares > peak {
+			peak = squares
+		}
+		rolled[i] = Point{Time: s[i].Time, Value: Missing}
+		if count > 1 {
+			variance := squares / float64(count-1)
+			rolled[i].Value = math.Sqrt(math.Max(variance, 0))
+		}
+	}
+	return rolled
+}
+
+// RollingQuantile returns, at every point, the q-quantile of the values in
+// the window length long ending at it, interpolating between ranks. A
+// window without values is missing.
+func RollingQuantile(s Series, length time.Duration, q float64) Series {
+	w := window{s: s, length: length}
+	var sorted []float64
+	add := func(v float64) {
+		i := sort.SearchFloat64s(sorted, v)
+		sorted = append(sorted, 0)
+		copy(sorted[i+1:], sorted[i:])
+		sorted[i] = v
+	}
+	remove := func(v float64) {
+		i := sort.SearchFloat64s(sorted, v)
+		sorted = append(sorted[:i], sorted[i+1:]...)
+	}
+
+	rolled := make(Series, len(s))
+	for i := range s {
+		w.advance(i, add, remove)
+		rolled[i] = Point{Time: s[i].Time, Value: Quantile(sorted, q)}
+	}
+	return rolled
+}
+
+// Quantile returns the q-quantile of sorted values, interpolating between
+// ranks, or missing when there are none.
+func Quantile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return Missing
+	}
+	position := q * float64(len(sorted)-1)
+	low := int(math.Floor(position))
+	if low < 0 {
+		return sorted[0]
+	}
+	if low+1 >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+	weight := position - float64(low)
+	return sorted[low]*(1-weight) + sorted[low+1]*weight
+}
+
+// EWMA returns the exponentially weighted moving average of s, where a
+// value's weight halves every halfLife after it. Points further apart
+// therefore move the average more. Missing values leave it unchanged.
+func EWMA(s Series, halfLife time.Duration) (Series, error) {
+	if halfLife <= 0 {
+		return nil, fmt.Errorf("half-life must be positive")
+	}
+	rolled := make(Series, len(s))
+	average := Missing
+	var last time.Time
+	for i, p := range s {
+		if !IsMissing(p.Value) {
+			if IsMissing(average) {
+				average = p.Value
+			} else {
+				alpha := 1 - math.Exp2(-p.Time.Sub(last).Seconds()/halfLife.Seconds())
+				average += alpha * (p.Value - average)
+			}
+			last = p.Time
+		}
+		rolled[i] = Point{Time: p.Time, Value: average}
+	}
+	return rolled, nil
+}
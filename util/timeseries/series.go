@@ -0,0 +1,104 @@
+package timeseries
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Point is one value of a series at a time. A NaN value is missing.
+type Point struct {
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+}
+
+// Series is a time series, oldest first. Points may share a time; the later
+// one is then the more recent.
+type Series []Point
+
+// Missing is the value of a point with no value.
+var Missing = math.NaN()
+
+// IsMissing reports whether v is a missing value.
+func IsMissing(v float64) bool {
+	return math.IsNaN(v)
+}
+
+// Values returns the value of every point.
+func (s Series) Values() []float64 {
+	values := make([]float64, len(s))
+	for i, p := range s {
+		values[i] = p.Value
+	}
+	return values
+}
+
+// Times returns the time of every point.
+func (s Series) Times() []time.Time {
+	times := make([]time.Time, len(s))
+	for i, p := range s {
+		times[i] = p.Time
+	}
+	return times
+}
+
+// Sort orders the points oldest first, keeping the order of points that
+// share a time.
+func (s Series) Sort() {
+	sort.SliceStable(s, func(i, j int) bool {
+		return s[i].Time.Before(s[j].Time)
+	})
+}
+
+// Between returns the points from from up to but not including to. It
+// shares the series' storage.
+func (s Series) Between(from time.Time, to time.Time) Series {
+	return s[s.search(from):s.search(to)]
+}
+
+// At returns the value as of t: the value of the last point at or before t.
+// It is false before the first point.
+func (s Series) At(t time.Time) (float64, bool) {
+	i := s.after(t)
+	if i == 0 {
+		return Missing, false
+	}
+	return s[i-1].Value, true
+}
+
+// Present returns the points that are not missing.
+func (s Series) Present() Series {
+	var present Series
+	for _, p := range s {
+		if !IsMissing(p.Value) {
+			present = append(present, p)
+		}
+	}
+	return present
+}
+
+// search returns the index of the first point at or after t.
+func (s Series) search(t time.Time) int {
+	return sort.Search(len(s), func(i int) bool {
+		return !s[i].Time.Before(t)
+	})
+}
+
+// after returns the index of the first point after t.
+func (s Series) after(t time.Time) int {
+	return sort.Search(len(s), func(i int) bool {
+		return s[i].Time.After(t)
+	})
+}
+
+// Grid returns the times every step after from up to and including to.
+func Grid(from time.Time, to time.Time, step time.Duration) []time.Time {
+	if step <= 0 {
+		return nil
+	}
+	var times []time.Time
+	for t := from.Add(step); !t.After(to); t = t.Add(step) {
+		times = append(times, t)
+	}
+	return times
+}
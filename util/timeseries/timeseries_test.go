@@ -0,0 +1,386 @@
+package timeseries
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func at(minutes float64) time.Time {
+	return epoch.Add(time.Duration(minutes * float64(time.Minute)))
+}
+
+func near(got, want float64) bool {
+	if IsMissing(want) {
+		return IsMissing(got)
+	}
+	return math.Abs(got-want) <= 1e-9*math.Max(1, math.Abs(want))
+}
+
+func equalValues(got Series, want []float64) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range want {
+		if !near(got[i].Value, want[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestResample(t *testing.T) {
+	// 10 is carried in from before the first hour
+	s := Series{
+		{at(-10), 10},
+		{at(15), 20},
+		{at(30), Missing},
+		{at(45), 40},
+	}
+	tests := []struct {
+		method string
+		want   []float64
+	}{
+		{Last, []float64{40, Missing}},
+		{Mean, []float64{30, Missing}},
+		// (10*15 + 20*30 + 40*15) / 60, then 40 held all hour
+		{TimeWeighted, []float64{22.5, 40}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			got, err := Resample(s, epoch, at(120), time.Hour, tt.method)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !equalValues(got, tt.want) {
+				t.Errorf("Resample() = %v, want %v", got.Values(), tt.want)
+			}
+			if !got[1].Time.Equal(at(60)) {
+				t.Errorf("second interval starts at %v, want %v", got[1].Time, at(60))
+			}
+		})
+	}
+}
+
+func TestResampleTimeWeightedWithoutHistory(t *testing.T) {
+	// Nothing is known before 30 minutes in, so only the last half counts
+	s := Series{{at(30), 8}, {at(45), 4}}
+	got, err := Resample(s, epoch, at(60), time.Hour, TimeWeighted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalValues(got, []float64{6}) {
+		t.Errorf("Resample() = %v, want [6]", got.Values())
+	}
+}
+
+func TestResampleRejects(t *testing.T) {
+	if _, err := Resample(nil, epoch, at(60), 0, Last); err == nil {
+		t.Error("Resample() with zero step succeeded")
+	}
+	if _, err := Resample(nil, epoch, at(60), -time.Minute, Last); err == nil {
+		t.Error("Resample() with negative step succeeded")
+	}
+	if _, err := Resample(nil, epoch, at(60), time.Minute, "MEDIAN"); err == nil {
+		t.Error("Resample() with unknown method succeeded")
+	}
+}
+
+func TestOHLC(t *testing.T) {
+	s := Series{
+		{at(5), 10},
+		{at(20), 12},
+		{at(25), Missing},
+		{at(50), 9},
+		{at(130), 11},
+		{at(135), 7},
+	}
+	got, err := OHLC(s, 30*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Bar{
+		{Start: at(0), Open: 10, High: 12, Low: 10, Close: 12, Count: 2},
+		{Start: at(30), Open: 9, High: 9, Low: 9, Close: 9, Count: 1},
+		// Gaps repeat the previous close
+		{Start: at(60), Open: 9, High: 9, Low: 9, Close: 9},
+		{Start: at(90), Open: 9, High: 9, Low: 9, Close: 9},
+		{Start: at(120), Open: 11, High: 11, Low: 7, Close: 7, Count: 2},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("OHLC() = %d bars, want %d: %v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("bar %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestOHLCRejectsNonPositiveStep(t *testing.T) {
+	s := Series{{at(0), 1}, {at(1), 2}}
+	for _, step := range []time.Duration{0, -time.Minute} {
+		if _, err := OHLC(s, step); err == nil {
+			t.Errorf("OHLC() with step %v succeeded", step)
+		}
+	}
+}
+
+func TestFillForward(t *testing.T) {
+	s := Series{
+		{at(0), Missing},
+		{at(1), 5},
+		{at(2), Missing},
+		{at(4), Missing},
+		{at(5), 6},
+	}
+	tests := []struct {
+		name     string
+		maxStale time.Duration
+		want     []float64
+	}{
+		{"any age", 0, []float64{Missing, 5, 5, 5, 6}},
+		{"up to two minutes", 2 * time.Minute, []float64{Missing, 5, 5, Missing, 6}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FillForward(s, tt.maxStale); !equalValues(got, tt.want) {
+				t.Errorf("FillForward() = %v, want %v", got.Values(), tt.want)
+			}
+		})
+	}
+}
+
+func TestAsOf(t *testing.T) {
+	s := Series{{at(10), 1}, {at(20), 2}, {at(20), 3}}
+	times := []time.Time{at(5), at(10), at(15), at(20), at(25), at(30), at(31)}
+	tests := []struct {
+		name     string
+		maxStale time.Duration
+		want     []float64
+	}{
+		{"any age", 0, []float64{Missing, 1, 1, 3, 3, 3, 3}},
+		// A point exactly maxStale old is still fresh
+		{"ten minutes", 10 * time.Minute, []float64{Missing, 1, 1, 3, 3, 3, Missing}},
+		{"exact times only", time.Nanosecond, []float64{Missing, 1, Missing, 3, Missing, Missing, Missing}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := AsOf(s, times, tt.maxStale)
+			if !equalValues(got, tt.want) {
+				t.Errorf("AsOf() = %v, want %v", got.Values(), tt.want)
+			}
+			for i := range times {
+				if !got[i].Time.Equal(times[i]) {
+					t.Errorf("sample %d at %v, want %v", i, got[i].Time, times[i])
+				}
+			}
+		})
+	}
+}
+
+func TestAsOfJoin(t *testing.T) {
+	left := Series{{at(0), 1}, {at(10), 2}, {at(20), Missing}, {at(30), 4}, {at(50), 5}}
+	right := Series{{at(5), 100}, {at(25), 200}}
+	got := AsOfJoin(left, right, 10*time.Minute)
+	want := []Pair{
+		{Time: at(10), Left: 2, Right: 100},
+		{Time: at(30), Left: 4, Right: 200},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("AsOfJoin() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if !got[i].Time.Equal(want[i].Time) || got[i].Left != want[i].Left || got[i].Right != want[i].Right {
+			t.Errorf("pair %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestRollingMean(t *testing.T) {
+	s := Series{{at(0), 1}, {at(1), 2}, {at(2), Missing}, {at(3), 6}, {at(10), Missing}}
+	got := RollingMean(s, 2*time.Minute)
+	want := []float64{1, 1.5, 2, 6, Missing}
+	if !equalValues(got, want) {
+		t.Errorf("RollingMean() = %v, want %v", got.Values(), want)
+	}
+}
+
+// stdOf is the two-pass sample standard deviation of values.
+func stdOf(values []float64) float64 {
+	var mean float64
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	var squares float64
+	for _, v := range values {
+		squares += (v - mean) * (v - mean)
+	}
+	return math.Sqrt(squares / float64(len(values)-1))
+}
+
+func TestRollingStd(t *testing.T) {
+	s := Series{{at(0), 2}, {at(1), 4}, {at(2), 4}, {at(3), 4}, {at(4), 5}, {at(5), 5}, {at(6), 7}, {at(7), 9}}
+	got := RollingStd(s, 8*time.Minute)
+	if !near(got[0].Value, Missing) {
+		t.Errorf("first point = %v, want missing", got[0].Value)
+	}
+	if want := stdOf(s.Values()); !near(got[7].Value, want) {
+		t.Errorf("last point = %v, want %v", got[7].Value, want)
+	}
+}
+
+func TestRollingStdPrecisionAfterValuesLeave(t *testing.T) {
+	// The series starts near nought and jumps to a level where the spread
+	// is tiny next to the values, long after the first values left the
+	// window
+	var s Series
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		level := 0.0
+		if i >= 500 {
+			level = 1e9
+		}
+		s = append(s, Point{Time: at(float64(i)), Value: level + r.Float64()})
+	}
+	length := 50 * time.Minute
+	got := RollingStd(s, length)
+	for i := 600; i < len(s); i += 97 {
+		window := s.Between(s[i].Time.Add(-length+time.Nanosecond), s[i].Time.Add(time.Nanosecond))
+		want := stdOf(window.Values())
+		if math.Abs(got[i].Value-want) > 1e-6 {
+			t.Fatalf("point %d = %v, want %v", i, got[i].Value, want)
+		}
+	}
+}
+
+func TestRollingQuantile(t *testing.T) {
+	s := Series{{at(0), 5}, {at(1), 1}, {at(2), 3}, {at(3), Missing}, {at(4), 9}}
+	got := RollingQuantile(s, 3*time.Minute, 0.5)
+	want := []float64{5, 3, 3, 2, 6}
+	if !equalValues(got, want) {
+		t.Errorf("RollingQuantile() = %v, want %v", got.Values(), want)
+	}
+}
+
+func TestEWMA(t *testing.T) {
+	s := Series{{at(0), 0}, {at(10), 1}, {at(10), 1}, {at(15), Missing}, {at(20), 0}}
+	got, err := EWMA(s, 10*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A point half a life on moves the average half way; a repeated time
+	// does not move it at all
+	want := []float64{0, 0.5, 0.5, 0.5, 0.25}
+	if !equalValues(got, want) {
+		t.Errorf("EWMA() = %v, want %v", got.Values(), want)
+	}
+}
+
+func TestEWMARejectsNonPositiveHalfLife(t *testing.T) {
+	s := Series{{at(0), 1}, {at(0), 2}}
+	for _, halfLife := range []time.Duration{0, -time.Minute} {
+		if _, err := EWMA(s, halfLife); err == nil {
+			t.Errorf("EWMA() with half-life %v succeeded", halfLife)
+		}
+	}
+}
+
+// million is a random walk of a million points a second apart, one in a
+// hundred missing.
+func million() Series {
+	r := rand.New(rand.NewSource(1))
+	s := make(Series, 1000000)
+	value := 100.0
+	for i := range s {
+		value += r.NormFloat64()
+		s[i] = Point{Time: epoch.Add(time.Duration(i) * time.Second), Value: value}
+		if i%100 == 99 {
+			s[i].Value = Missing
+		}
+	}
+	return s
+}
+
+func benchmarkResample(b *testing.B, method string) {
+	s := million()
+	to := s[len(s)-1].Time
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Resample(s, epoch, to, 5*time.Minute, method); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkResampleLast(b *testing.B)         { benchmarkResample(b, Last) }
+func BenchmarkResampleMean(b *testing.B)         { benchmarkResample(b, Mean) }
+func BenchmarkResampleTimeWeighted(b *testing.B) { benchmarkResample(b, TimeWeighted) }
+
+func BenchmarkOHLC(b *testing.B) {
+	s := million()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := OHLC(s, 5*time.Minute); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFillForward(b *testing.B) {
+	s := million()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		FillForward(s, time.Minute)
+	}
+}
+
+func BenchmarkAsOfJoin(b *testing.B) {
+	left := million()
+	right := make(Series, len(left))
+	for i, p := range left {
+		right[i] = Point{Time: p.Time.Add(700 * time.Millisecond), Value: p.Value}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		AsOfJoin(left, right, 5*time.Second)
+	}
+}
+
+func BenchmarkRollingMean(b *testing.B) {
+	s := million()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		RollingMean(s, time.Hour)
+	}
+}
+
+func BenchmarkRollingStd(b *testing.B) {
+	s := million()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		RollingStd(s, time.Hour)
+	}
+}
+
+func BenchmarkRollingQuantile(b *testing.B) {
+	s := million()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		RollingQuantile(s, 5*time.Minute, 0.95)
+	}
+}
+
+func BenchmarkEWMA(b *testing.B) {
+	s := million()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := EWMA(s, time.Hour); err != nil {
+			b.Fatal(err)
+		}
+	}
+}